@@ -1,23 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	// "github.com/99designs/gqlgen/graphql/playground"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+	"github.com/gorilla/websocket"
 	"github.com/joel2607/FileVault/database"
 	"github.com/joel2607/FileVault/graphQL"
 	"github.com/joel2607/FileVault/handlers"
 	"github.com/joel2607/FileVault/middleware"
 	"github.com/joel2607/FileVault/services"
+	"github.com/joel2607/FileVault/services/mailer"
 	"github.com/joel2607/FileVault/services/storage"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
-	"github.com/gorilla/websocket"
+	"github.com/joel2607/FileVault/services/validation"
 	"github.com/spf13/viper"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 const defaultPort = "8080"
@@ -42,6 +48,15 @@ func init() {
 	viper.BindEnv("download_token_secret", "DOWNLOAD_TOKEN_SECRET")
 	viper.BindEnv("app.base_url", "APP_BASE_URL")
 	viper.BindEnv("ratelimit.limit", "RATELIMIT_LIMIT")
+	viper.BindEnv("server.client_ip_header", "CLIENT_IP_HEADER")
+	viper.BindEnv("password_policy.breached_list_path", "BREACHED_PASSWORDS_PATH")
+	viper.BindEnv("invite_token_secret", "INVITE_TOKEN_SECRET")
+	viper.BindEnv("mail.provider", "MAIL_PROVIDER")
+	viper.BindEnv("mail.from", "MAIL_FROM")
+	viper.BindEnv("mail.smtp.host", "SMTP_HOST")
+	viper.BindEnv("mail.smtp.port", "SMTP_PORT")
+	viper.BindEnv("mail.smtp.username", "SMTP_USERNAME")
+	viper.BindEnv("mail.smtp.password", "SMTP_PASSWORD")
 }
 
 func main() {
@@ -54,10 +69,35 @@ func main() {
 	rdb := database.InitRedis()
 
 	// Service Initialization
-	authService := services.NewAuthService(db)
+	loginThrottle := services.NewLoginThrottleService(rdb, services.LoginThrottlePolicyFromConfig())
+	auditService := services.NewAuditService(db)
+	authService := services.NewAuthService(db, loginThrottle, validation.PasswordPolicyFromConfig(), auditService)
 	storageProvider := storage.NewLocalStorageProvider(viper.GetString("app.base_url"))
-	fileService := services.NewFileService(db, rdb, storageProvider)
-	shareService := services.NewShareService(db)
+	notificationService := services.NewNotificationService(db, rdb)
+	webhookService := services.NewWebhookService(db)
+	activityService := services.NewActivityService(db)
+	fileService := services.NewFileService(db, rdb, storageProvider, notificationService, webhookService, activityService, auditService)
+	shareService := services.NewShareService(db, notificationService, webhookService, activityService, auditService)
+	userService := services.NewUserService(db, authService, fileService)
+	adminService := services.NewAdminService(db, authService, auditService)
+	organizationService := services.NewOrganizationService(db, auditService)
+	groupService := services.NewGroupService(db)
+	tagService := services.NewTagService(db)
+	folderStatsCache := services.NewFolderStatsCache(rdb)
+	if err := folderStatsCache.RegisterCallbacks(db); err != nil {
+		log.Fatalf("Failed to register folder stats cache callbacks: %v", err)
+	}
+	var mail mailer.Mailer = mailer.NewLogMailer()
+	if viper.GetString("mail.provider") == "smtp" {
+		mail = mailer.NewSMTPMailer(viper.GetString("mail.smtp.host"), viper.GetString("mail.smtp.port"),
+			viper.GetString("mail.smtp.username"), viper.GetString("mail.smtp.password"), viper.GetString("mail.from"))
+	}
+	inviteService := services.NewInviteService(db, mail, viper.GetString("app.base_url"))
+	shareExpiryService := services.NewShareExpiryService(db, mail, notificationService)
+	contentIndexService := services.NewContentIndexService(db)
+	accessRequestService := services.NewAccessRequestService(db, rdb, shareService, notificationService)
+	ownershipTransferService := services.NewOwnershipTransferService(db, fileService)
+	commentService := services.NewCommentService(db, rdb, notificationService)
 
 	// Setup Chi Router
 	router := chi.NewRouter()
@@ -69,19 +109,40 @@ func main() {
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Authorization", "Content-Type"},
 	}).Handler)
+	trustedProxies, err := middleware.ParseTrustedProxies(viper.GetStringSlice("server.trusted_proxies"))
+	if err != nil {
+		log.Fatalf("Invalid server.trusted_proxies: %v", err)
+	}
+	router.Use(middleware.ClientIPMiddleware(viper.GetString("server.client_ip_header"), trustedProxies))
 	router.Use(middleware.AuthMiddleware(authService))
-	router.Use(middleware.RateLimitMiddleware(rdb, viper.GetInt("ratelimit.limit"), 1*time.Second))
+	router.Use(middleware.DataloaderMiddleware(db, folderStatsCache))
 
 	// Setup GraphQL Server
 	resolver := &graphQL.Resolver{
-		DB:           db,
-		RDB:          rdb,
-		AuthService:  authService,
-		FileService:  fileService,
-		ShareService: shareService,
+		DB:                       db,
+		RDB:                      rdb,
+		AuthService:              authService,
+		FileService:              fileService,
+		ShareService:             shareService,
+		UserService:              userService,
+		AdminService:             adminService,
+		OrganizationService:      organizationService,
+		GroupService:             groupService,
+		InviteService:            inviteService,
+		AccessRequestService:     accessRequestService,
+		OwnershipTransferService: ownershipTransferService,
+		CommentService:           commentService,
+		NotificationService:      notificationService,
+		WebhookService:           webhookService,
+		ActivityService:          activityService,
+		TagService:               tagService,
+		FolderStatsCache:         folderStatsCache,
 	}
-	srv := handler.NewDefaultServer(graphQL.NewExecutableSchema(graphQL.Config{Resolvers: resolver}))
+	rateLimiter := middleware.NewRateLimiter(rdb, viper.GetInt("ratelimit.limit"), 1*time.Second)
+	queryLimits := middleware.QueryLimitsFromConfig()
+	viper.SetDefault("ratelimit.complexity_per_token", 100)
 
+	srv := handler.New(graphQL.NewExecutableSchema(graphQL.Config{Resolvers: resolver, Complexity: graphQL.Complexity()}))
 	srv.AddTransport(&transport.Websocket{
 		Upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
@@ -90,15 +151,43 @@ func main() {
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
-		InitFunc: middleware.WebSocketInitFunc(authService),
+		InitFunc:              middleware.WebSocketInitFunc(authService),
+		KeepAlivePingInterval: 10 * time.Second,
 	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: graphQL.NewAPQCache(rdb)})
+	// Limits are checked before the operation is charged to the rate limit, which needs its complexity
+	srv.Use(queryLimits.ComplexityLimit())
+	srv.Use(middleware.DepthLimit{Limits: queryLimits})
+	srv.Use(middleware.QueryCost{Limiter: rateLimiter, ComplexityPerToken: viper.GetInt("ratelimit.complexity_per_token")})
+
+	srv.SetErrorPresenter(graphQL.ErrorPresenter)
+	srv.AroundOperations(middleware.ReadOnlyImpersonation)
 
 	// Define Routes
 	// router.Handle("/", playground.Handler("GraphQL playground", "/graphql"))
 	router.Handle("/graphql", srv)
-	router.Get("/downloads/*", handlers.DownloadHandler)
+	router.With(rateLimiter.Middleware).Get("/downloads/*", handlers.DownloadHandler)
+
+	// Remove expired shares in the background
+	go shareExpiryService.Run(context.Background())
+
+	// Finish account deletions that failed part way
+	go userService.Run(context.Background())
+
+	// Deliver and retry webhook events in the background
+	go webhookService.Run(context.Background())
+
+	// Extract the text of new uploads for full-text search in the background
+	go contentIndexService.Run(context.Background())
 
 	// Start Server
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, router))
-}
\ No newline at end of file
+}
@@ -35,7 +35,32 @@ func Init() *gorm.DB {
 	}
 
 	// AutoMigrate the schema
-	err = DB.AutoMigrate(&models.User{}, &models.DeduplicatedContent{}, &models.Folder{}, &models.File{}, &models.FileSharing{}, &models.FolderSharing{})
+	err = DB.AutoMigrate(&models.User{}, &models.Organization{}, &models.Membership{}, &models.DeduplicatedContent{}, &models.ContentText{}, &models.Folder{}, &models.File{}, &models.FileSharing{}, &models.FolderSharing{}, &models.Group{}, &models.GroupMember{}, &models.FileGroupSharing{}, &models.FolderGroupSharing{}, &models.ShareInvite{}, &models.AccessRequest{}, &models.OwnershipTransfer{}, &models.Comment{}, &models.CommentMention{}, &models.Notification{}, &models.NotificationPreference{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.Activity{}, &models.AuditLog{})
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	// Extracted text used to be kept on deduplicated_contents; move it to content_texts. Its
+	// search vector is generated from it, so it goes with it.
+	if DB.Migrator().HasColumn(&models.DeduplicatedContent{}, "extracted_text") {
+		err = DB.Exec(`INSERT INTO content_texts (deduplicated_content_id, extracted_text)
+			SELECT id, extracted_text FROM deduplicated_contents WHERE text_extracted_at IS NOT NULL
+			ON CONFLICT DO NOTHING`).Error
+		if err == nil {
+			err = DB.Exec("ALTER TABLE deduplicated_contents DROP COLUMN extracted_text CASCADE").Error
+		}
+		if err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	}
+
+	// The full-text index of file contents. The search vector is generated from the extracted
+	// text, so it can never be out of step with it.
+	err = DB.Exec(`ALTER TABLE content_texts ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('english', extracted_text)) STORED`).Error
+	if err == nil {
+		err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_content_texts_search_vector ON content_texts USING GIN (search_vector)").Error
+	}
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
@@ -0,0 +1,48 @@
+// Package testdb opens throwaway SQLite databases for tests of code that queries through GORM.
+// Postgres-only features, such as full-text search and JSON operators, aren't available.
+package testdb
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresOnly rewrites the Postgres-only statements the application runs for SQLite.
+var postgresOnly = strings.NewReplacer(" USING gin", "", "pg_advisory_xact_lock(?)", "?")
+
+// Open creates an empty database in the test's temporary directory and migrates the given
+// models into it. The database is closed when the test ends.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SQLite has no GIN indexes, which are created as plain ones, and no advisory locks, which
+	// it doesn't need as it only lets one transaction write at a time.
+	err = db.Callback().Raw().Before("gorm:raw").Register("testdb:postgres_only", func(db *gorm.DB) {
+		if sql := db.Statement.SQL.String(); postgresOnly.Replace(sql) != sql {
+			db.Statement.SQL.Reset()
+			db.Statement.SQL.WriteString(postgresOnly.Replace(sql))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
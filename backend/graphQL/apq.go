@@ -0,0 +1,40 @@
+package graphQL
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+// apqKeyPrefix prefixes the Redis keys of persisted queries.
+const apqKeyPrefix = "apq:"
+
+// APQCache stores automatic persisted queries in Redis, so that they are shared between
+// server instances and survive restarts. Clients send the SHA-256 hash of a query instead
+// of its text once the server has seen it.
+type APQCache struct {
+	RDB *redis.Client
+	TTL time.Duration
+}
+
+// NewAPQCache creates an APQCache keeping queries for the graphql.apq_ttl_hours setting.
+func NewAPQCache(rdb *redis.Client) *APQCache {
+	viper.SetDefault("graphql.apq_ttl_hours", 24)
+	return &APQCache{RDB: rdb, TTL: time.Duration(viper.GetInt("graphql.apq_ttl_hours")) * time.Hour}
+}
+
+// Get returns the query with the given hash.
+func (c *APQCache) Get(ctx context.Context, hash string) (string, bool) {
+	query, err := c.RDB.Get(ctx, apqKeyPrefix+hash).Result()
+	if err != nil {
+		return "", false
+	}
+	return query, true
+}
+
+// Add stores a query under its hash.
+func (c *APQCache) Add(ctx context.Context, hash string, query string) {
+	c.RDB.Set(ctx, apqKeyPrefix+hash, query, c.TTL)
+}
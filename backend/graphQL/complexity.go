@@ -0,0 +1,137 @@
+package graphQL
+
+import (
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services"
+)
+
+// unboundedListSize is how many items a list without a page size is assumed to hold when
+// scoring the complexity of an operation.
+const unboundedListSize = 50
+
+// listCost is the complexity of a field returning a list without a page size.
+func listCost(childComplexity int) int {
+	return 1 + childComplexity*unboundedListSize
+}
+
+// pageCost is the complexity of a field returning a page of at most size items, or
+// defaultSize items if size isn't given, and never more than maxSize.
+func pageCost(childComplexity int, size *int32, defaultSize int, maxSize int) int {
+	items := defaultSize
+	if size != nil && *size >= 0 {
+		items = int(*size)
+	}
+	if items > maxSize {
+		items = maxSize
+	}
+	return 1 + childComplexity*items
+}
+
+// connectionCost is the complexity of a connection field.
+func connectionCost(childComplexity int, first *int32) int {
+	return pageCost(childComplexity, first, services.DefaultConnectionPageSize, services.MaxConnectionPageSize)
+}
+
+// activityCost is the complexity of an activity page field.
+func activityCost(childComplexity int, first *int32) int {
+	return pageCost(childComplexity, first, services.DefaultActivityPageSize, services.MaxActivityPageSize)
+}
+
+// Complexity returns the functions that score the complexity of operations. Every field
+// costs 1 plus the complexity of its selections; fields returning lists multiply the
+// complexity of their selections by the number of items they can return, so that nested
+// lists such as folder { folders { folders { files } } } grow quickly.
+func Complexity() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Root.Files = listCost
+	c.Root.Folders = listCost
+	c.Root.FilesConnection = func(childComplexity int, first *int32, _ *string, _ *models.FileSort) int {
+		return connectionCost(childComplexity, first)
+	}
+	c.Root.FoldersConnection = func(childComplexity int, first *int32, _ *string, _ *models.FolderSort) int {
+		return connectionCost(childComplexity, first)
+	}
+
+	c.Folder.Files = listCost
+	c.Folder.Folders = listCost
+	c.Folder.FilesConnection = func(childComplexity int, first *int32, _ *string, _ *models.FileSort) int {
+		return connectionCost(childComplexity, first)
+	}
+	c.Folder.FoldersConnection = func(childComplexity int, first *int32, _ *string, _ *models.FolderSort) int {
+		return connectionCost(childComplexity, first)
+	}
+	c.Folder.Activity = func(childComplexity int, first *int32, _ *string) int {
+		return activityCost(childComplexity, first)
+	}
+	c.File.Activity = func(childComplexity int, first *int32, _ *string) int {
+		return activityCost(childComplexity, first)
+	}
+
+	c.Organization.Members = listCost
+	c.Organization.Folders = listCost
+	c.Group.Members = listCost
+	c.IncomingShares.Files = listCost
+	c.IncomingShares.Folders = listCost
+	c.Comment.Replies = listCost
+	c.Comment.Mentions = listCost
+
+	c.Query.SearchFiles = func(childComplexity int, _ *string, _ *models.FileFilterInput) int {
+		return listCost(childComplexity)
+	}
+	c.Query.SearchFilesConnection = func(childComplexity int, _ *string, _ *models.FileFilterInput, first *int32, _ *string, _ *models.FileSort) int {
+		return connectionCost(childComplexity, first)
+	}
+	c.Query.SearchFileContents = func(childComplexity int, _ string, _ *models.FileFilterInput, limit *int32) int {
+		return pageCost(childComplexity, limit, services.DefaultSearchResultLimit, services.MaxSearchResultLimit)
+	}
+	c.Query.SearchUsers = func(childComplexity int, _ string) int {
+		return listCost(childComplexity)
+	}
+	c.Query.SearchUsersConnection = func(childComplexity int, _ string, first *int32, _ *string, _ *models.UserSort) int {
+		return connectionCost(childComplexity, first)
+	}
+	c.Query.GetUsersWithAccess = func(childComplexity int, _ string) int {
+		return listCost(childComplexity)
+	}
+	c.Query.GetUsersWithAccessConnection = func(childComplexity int, _ string, first *int32, _ *string, _ *models.UserSort) int {
+		return connectionCost(childComplexity, first)
+	}
+	c.Query.GetUsersWithFolderAccess = func(childComplexity int, _ string) int {
+		return listCost(childComplexity)
+	}
+	c.Query.MyActivity = func(childComplexity int, first *int32, _ *string) int {
+		return activityCost(childComplexity, first)
+	}
+	c.Query.SharedWithMe = listCost
+	c.Query.OutgoingShares = listCost
+	c.Query.MyShareInvites = listCost
+	c.Query.MyOrganizations = listCost
+	c.Query.MyGroups = listCost
+	c.Query.MyAccessRequests = listCost
+	c.Query.OutgoingOwnershipTransfers = listCost
+	c.Query.MyWebhooks = listCost
+	c.Query.IncomingAccessRequests = func(childComplexity int, _ *models.AccessRequestStatus) int {
+		return listCost(childComplexity)
+	}
+	c.Query.IncomingOwnershipTransfers = func(childComplexity int, _ *models.OwnershipTransferStatus) int {
+		return listCost(childComplexity)
+	}
+	c.Query.FileComments = func(childComplexity int, _ string, _ *bool) int {
+		return listCost(childComplexity)
+	}
+	c.Query.Notifications = func(childComplexity int, _ *bool, limit *int32) int {
+		return pageCost(childComplexity, limit, unboundedListSize, unboundedListSize)
+	}
+	c.Query.WebhookDeliveries = func(childComplexity int, _ string, limit *int32) int {
+		return pageCost(childComplexity, limit, unboundedListSize, unboundedListSize)
+	}
+	c.Query.AdminUsers = func(childComplexity int, _ *string, limit *int32, _ *int32) int {
+		return pageCost(childComplexity, limit, services.DefaultAdminPageSize, services.MaxAdminPageSize)
+	}
+	c.Query.AdminAuditLog = func(childComplexity int, _ *models.AuditLogFilter, limit *int32, _ *int32) int {
+		return pageCost(childComplexity, limit, services.DefaultAdminPageSize, services.MaxAdminPageSize)
+	}
+
+	return c
+}
@@ -0,0 +1,277 @@
+package graphQL
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/middleware"
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services"
+	"gorm.io/gorm"
+)
+
+// listingQueries are representative listing documents. Each item selects the fields that
+// used to cost a query per item: the owner, folder and content of files, the user they are
+// shared with, and the authors, replies and mentions of comments.
+var listingQueries = map[string]string{
+	"folder files": `query($id: ID!) {
+		folder(id: $id) {
+			files {
+				fileName
+				user { username }
+				folder { folderName }
+				deduplicatedContent { sha256Hash }
+			}
+		}
+	}`,
+	"folder files connection": `query($id: ID!) {
+		folder(id: $id) {
+			filesConnection(first: 200) {
+				totalCount
+				edges {
+					node {
+						fileName
+						user { username }
+						folder { folderName }
+						deduplicatedContent { sha256Hash }
+					}
+				}
+			}
+		}
+	}`,
+	"search": `{
+		searchFiles(filter: { mimeTypes: ["application/pdf"] }) {
+			fileName
+			user { username }
+			folder { folderName }
+			deduplicatedContent { sha256Hash }
+		}
+	}`,
+	"search connection": `{
+		searchFilesConnection(filter: { mimeTypes: ["application/pdf"] }, first: 200) {
+			edges {
+				node {
+					fileName
+					user { username }
+					folder { folderName }
+					deduplicatedContent { sha256Hash }
+				}
+			}
+		}
+	}`,
+	"outgoing shares": `{
+		outgoingShares {
+			permissionLevel
+			sharedWithUser { username }
+			file {
+				fileName
+				user { username }
+				folder { folderName }
+				deduplicatedContent { sha256Hash }
+			}
+		}
+	}`,
+	"file comments": `query($fileID: ID!) {
+		fileComments(fileID: $fileID) {
+			body
+			author { username }
+			mentions { username }
+			replies { body }
+		}
+	}`,
+}
+
+// sharedWithMeQuery lists the files shared with the fixture's reader.
+const sharedWithMeQuery = `{
+	sharedWithMe {
+		sharer { username }
+		files {
+			fileName
+			user { username }
+			folder { folderName }
+			deduplicatedContent { sha256Hash }
+		}
+	}
+}`
+
+// listingFixture is a folder of files, each shared with a different user and with a reader.
+// The first file has a comment from each of those users, mentioning them, with a reply.
+type listingFixture struct {
+	db      *gorm.DB
+	owner   *models.User
+	reader  *models.User
+	folder  *models.Folder
+	file    *models.File
+	queries *atomic.Int64
+}
+
+// newListingFixture creates a folder holding fileCount files, with as many comments on the
+// first, and counts the queries run against the database from then on.
+func newListingFixture(t testing.TB, fileCount int) *listingFixture {
+	t.Helper()
+	db := testdb.Open(t, &models.User{}, &models.Organization{}, &models.Membership{}, &models.DeduplicatedContent{},
+		&models.ContentText{}, &models.Folder{}, &models.File{}, &models.FileSharing{}, &models.FolderSharing{},
+		&models.Group{}, &models.GroupMember{}, &models.FileGroupSharing{}, &models.FolderGroupSharing{},
+		&models.Comment{}, &models.CommentMention{})
+
+	owner := &models.User{Username: "owner", Email: "owner@example.com", PasswordHash: "x"}
+	if err := db.Create(owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	reader := &models.User{Username: "reader", Email: "reader@example.com", PasswordHash: "x"}
+	if err := db.Create(reader).Error; err != nil {
+		t.Fatal(err)
+	}
+	folder := &models.Folder{UserID: owner.ID, FolderName: "Reports"}
+	if err := db.Create(folder).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	recipients := make([]*models.User, fileCount)
+	contents := make([]*models.DeduplicatedContent, fileCount)
+	for i := range recipients {
+		name := "user" + strconv.Itoa(i)
+		recipients[i] = &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		contents[i] = &models.DeduplicatedContent{SHA256Hash: fmt.Sprintf("%064d", i), ReferenceCount: 1}
+	}
+	if err := db.CreateInBatches(recipients, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateInBatches(contents, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	files := make([]*models.File, fileCount)
+	for i := range files {
+		files[i] = &models.File{UserID: owner.ID, FileName: fmt.Sprintf("report-%d.pdf", i), MIMEType: "application/pdf",
+			Size: 1024, DeduplicationID: contents[i].ID, FolderID: &folder.ID, Tags: "[]"}
+	}
+	if err := db.CreateInBatches(files, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	shares := make([]*models.FileSharing, 0, 2*fileCount)
+	for i := range files {
+		shares = append(shares,
+			&models.FileSharing{FileID: files[i].ID, SharedWithUserID: recipients[i].ID, PermissionLevel: "read"},
+			&models.FileSharing{FileID: files[i].ID, SharedWithUserID: reader.ID, PermissionLevel: "read"})
+	}
+	if err := db.CreateInBatches(shares, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	comments := make([]*models.Comment, fileCount)
+	for i := range comments {
+		comments[i] = &models.Comment{FileID: files[0].ID, AuthorID: recipients[i].ID, Body: "@" + recipients[i].Username + " please check"}
+	}
+	if err := db.CreateInBatches(comments, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	replies := make([]*models.Comment, fileCount)
+	mentions := make([]*models.CommentMention, fileCount)
+	for i := range replies {
+		replies[i] = &models.Comment{FileID: files[0].ID, AuthorID: owner.ID, ParentCommentID: &comments[i].ID, Body: "done"}
+		mentions[i] = &models.CommentMention{CommentID: comments[i].ID, UserID: recipients[i].ID}
+	}
+	if err := db.CreateInBatches(replies, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateInBatches(mentions, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	fixture := &listingFixture{db: db, owner: owner, reader: reader, folder: folder, file: files[0], queries: &atomic.Int64{}}
+	count := func(*gorm.DB) { fixture.queries.Add(1) }
+	db.Callback().Query().Before("gorm:query").Register("test:count_queries", count)
+	db.Callback().Raw().Before("gorm:raw").Register("test:count_queries", count)
+	db.Callback().Row().Before("gorm:row").Register("test:count_queries", count)
+	return fixture
+}
+
+// run sends a document through the GraphQL handler, with the dataloader middleware, as the
+// given user and returns the number of queries it took.
+func (f *listingFixture) run(t testing.TB, user *models.User, query string) int64 {
+	t.Helper()
+	resolver := &Resolver{
+		DB:             f.db,
+		ShareService:   services.NewShareService(f.db, nil, nil, nil, nil),
+		CommentService: services.NewCommentService(f.db, nil, nil),
+	}
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: resolver}))
+	srv.AddTransport(transport.POST{})
+	withUser := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), middleware.UserCtxKey, user)
+		middleware.DataloaderMiddleware(f.db, nil)(srv).ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": query,
+		"variables": map[string]interface{}{
+			"id":     strconv.FormatUint(uint64(f.folder.ID), 10),
+			"fileID": strconv.FormatUint(uint64(f.file.ID), 10),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	before := f.queries.Load()
+	withUser.ServeHTTP(rec, req)
+	queries := f.queries.Load() - before
+
+	var resp struct {
+		Errors []map[string]interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("query failed: %v", resp.Errors)
+	}
+	return queries
+}
+
+// TestListingQueryCount checks that listing 500 items takes as many queries as listing 10:
+// the fields of every item are loaded in batches rather than item by item.
+func TestListingQueryCount(t *testing.T) {
+	small := newListingFixture(t, 10)
+	large := newListingFixture(t, 500)
+	check := func(t *testing.T, smallQueries, largeQueries int64) {
+		if largeQueries != smallQueries {
+			t.Errorf("listing 500 items took %d queries, listing 10 took %d; want the same", largeQueries, smallQueries)
+		}
+		t.Logf("%d queries", largeQueries)
+	}
+	for name, query := range listingQueries {
+		t.Run(name, func(t *testing.T) {
+			check(t, small.run(t, small.owner, query), large.run(t, large.owner, query))
+		})
+	}
+	t.Run("shared with me", func(t *testing.T) {
+		check(t, small.run(t, small.reader, sharedWithMeQuery), large.run(t, large.reader, sharedWithMeQuery))
+	})
+}
+
+func BenchmarkListing(b *testing.B) {
+	fixture := newListingFixture(b, 500)
+	for name, query := range listingQueries {
+		b.Run(name, func(b *testing.B) {
+			var queries int64
+			for i := 0; i < b.N; i++ {
+				queries = fixture.run(b, fixture.owner, query)
+			}
+			b.ReportMetric(float64(queries), "queries/op")
+		})
+	}
+}
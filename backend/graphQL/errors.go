@@ -0,0 +1,64 @@
+package graphQL
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/joel2607/FileVault/services"
+	"github.com/joel2607/FileVault/services/searchquery"
+	"github.com/joel2607/FileVault/services/validation"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrorPresenter converts service errors into GraphQL errors, attaching a
+// machine-readable code in the extensions for errors the client may act on.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		fields := make([]map[string]interface{}, len(validationErrs))
+		for i, fieldErr := range validationErrs {
+			fields[i] = map[string]interface{}{
+				"field":   fieldErr.Field,
+				"code":    fieldErr.Code,
+				"message": fieldErr.Message,
+			}
+		}
+		gqlErr.Message = "validation failed"
+		gqlErr.Extensions = map[string]interface{}{
+			"code":   "VALIDATION_FAILED",
+			"fields": fields,
+		}
+	}
+
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		gqlErr.Extensions = map[string]interface{}{
+			"code":              "LOGIN_THROTTLED",
+			"locked":            throttled.Locked,
+			"retryAfterSeconds": int(math.Ceil(throttled.RetryAfter.Seconds())),
+		}
+	}
+
+	var queryErrs searchquery.Errors
+	if errors.As(err, &queryErrs) {
+		terms := make([]map[string]interface{}, len(queryErrs))
+		for i, queryErr := range queryErrs {
+			terms[i] = map[string]interface{}{
+				"offset":  queryErr.Offset,
+				"term":    queryErr.Term,
+				"message": queryErr.Message,
+			}
+		}
+		gqlErr.Message = "invalid search query"
+		gqlErr.Extensions = map[string]interface{}{
+			"code":   "INVALID_SEARCH_QUERY",
+			"errors": terms,
+		}
+	}
+
+	return gqlErr
+}
@@ -0,0 +1,99 @@
+package graphQL
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/joel2607/FileVault/services"
+	"github.com/joel2607/FileVault/services/searchquery"
+	"github.com/joel2607/FileVault/services/validation"
+)
+
+func TestErrorPresenter(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		message    string
+		extensions map[string]interface{}
+	}{
+		{
+			name: "validation errors",
+			err: validation.Errors{
+				{Field: "input.email", Code: validation.CodeInvalidFormat, Message: "email is not a valid address"},
+				{Field: "input.password", Code: validation.CodeBreachedPassword, Message: "breached"},
+			},
+			message: "validation failed",
+			extensions: map[string]interface{}{
+				"code": "VALIDATION_FAILED",
+				"fields": []map[string]interface{}{
+					{"field": "input.email", "code": validation.CodeInvalidFormat, "message": "email is not a valid address"},
+					{"field": "input.password", "code": validation.CodeBreachedPassword, "message": "breached"},
+				},
+			},
+		},
+		{
+			name:    "wrapped validation errors",
+			err:     fmt.Errorf("register: %w", validation.Errors{{Field: "input.username", Code: validation.CodeAlreadyTaken, Message: "taken"}}),
+			message: "validation failed",
+			extensions: map[string]interface{}{
+				"code": "VALIDATION_FAILED",
+				"fields": []map[string]interface{}{
+					{"field": "input.username", "code": validation.CodeAlreadyTaken, "message": "taken"},
+				},
+			},
+		},
+		{
+			name:    "login backoff",
+			err:     &services.LoginThrottledError{RetryAfter: 1500 * time.Millisecond},
+			message: (&services.LoginThrottledError{RetryAfter: 1500 * time.Millisecond}).Error(),
+			extensions: map[string]interface{}{
+				"code":              "LOGIN_THROTTLED",
+				"locked":            false,
+				"retryAfterSeconds": 2,
+			},
+		},
+		{
+			name:    "login lockout",
+			err:     &services.LoginThrottledError{RetryAfter: 15 * time.Minute, Locked: true},
+			message: (&services.LoginThrottledError{RetryAfter: 15 * time.Minute, Locked: true}).Error(),
+			extensions: map[string]interface{}{
+				"code":              "LOGIN_THROTTLED",
+				"locked":            true,
+				"retryAfterSeconds": 900,
+			},
+		},
+		{
+			name:    "invalid search query",
+			err:     searchquery.Errors{{Offset: 7, Term: "size:>ten", Message: "invalid size"}},
+			message: "invalid search query",
+			extensions: map[string]interface{}{
+				"code": "INVALID_SEARCH_QUERY",
+				"errors": []map[string]interface{}{
+					{"offset": 7, "term": "size:>ten", "message": "invalid size"},
+				},
+			},
+		},
+		{
+			name:    "other errors",
+			err:     errors.New("file not found or access denied"),
+			message: "file not found or access denied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := graphql.WithResponseContext(context.Background(), ErrorPresenter, graphql.DefaultRecover)
+			gqlErr := ErrorPresenter(ctx, tt.err)
+			if gqlErr.Message != tt.message {
+				t.Errorf("got message %q, want %q", gqlErr.Message, tt.message)
+			}
+			if !reflect.DeepEqual(gqlErr.Extensions, tt.extensions) {
+				t.Errorf("got extensions %v, want %v", gqlErr.Extensions, tt.extensions)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package graphQL
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/joel2607/FileVault/middleware"
+	"github.com/joel2607/FileVault/models"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// selectedFields returns the names of the fields the query selects on what the current field
+// returns, or on the objects below it when path names fields nested in it. Fragments apply if
+// their type condition is one of satisfies.
+func selectedFields(ctx context.Context, satisfies []string, path ...string) map[string]bool {
+	opCtx := graphql.GetOperationContext(ctx)
+	selections := graphql.GetFieldContext(ctx).Field.Selections
+	for _, name := range path {
+		var nested ast.SelectionSet
+		for _, field := range graphql.CollectFields(opCtx, selections, satisfies) {
+			if field.Name == name {
+				nested = append(nested, field.Selections...)
+			}
+		}
+		selections = nested
+	}
+	selected := make(map[string]bool)
+	for _, field := range graphql.CollectFields(opCtx, selections, satisfies) {
+		selected[field.Name] = true
+	}
+	return selected
+}
+
+// prefetchFiles starts loading the owners, folders and contents a query selects on a list of
+// files, in one batch each, before the fields of the files are resolved. The file resolvers
+// then find them in the request's loaders, so a list costs the same number of queries however
+// long it is, even when resolving it takes longer than the loaders wait to gather keys.
+// path leads from the current field to the files, as for selectedFields.
+func (r *Resolver) prefetchFiles(ctx context.Context, files []*models.File, path ...string) {
+	loaders := middleware.LoadersFromContext(ctx)
+	if loaders == nil || len(files) == 0 {
+		// Without request-scoped loaders nothing would be kept for the file resolvers.
+		return
+	}
+	selected := selectedFields(ctx, []string{"File", "OutgoingShare", "IncomingShares", "FileConnection", "FileEdge"}, path...)
+
+	var userIDs, folderIDs, contentIDs []uint
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		userIDs = append(userIDs, file.UserID)
+		contentIDs = append(contentIDs, file.DeduplicationID)
+		if file.FolderID != nil {
+			folderIDs = append(folderIDs, *file.FolderID)
+		}
+	}
+	if selected["user"] {
+		loadAll(ctx, loaders.UserByID, userIDs)
+	}
+	if selected["folder"] {
+		loadAll(ctx, loaders.FolderByID, folderIDs)
+	}
+	if selected["deduplicatedContent"] {
+		loadAll(ctx, loaders.DeduplicatedContentByID, contentIDs)
+	}
+}
+
+// prefetchConnectionFiles is prefetchFiles for the files on a page of a connection.
+func (r *Resolver) prefetchConnectionFiles(ctx context.Context, connection *models.FileConnection) {
+	files := make([]*models.File, 0, len(connection.Edges))
+	for _, edge := range connection.Edges {
+		files = append(files, edge.Node)
+	}
+	r.prefetchFiles(ctx, files, "edges", "node")
+}
+
+// prefetchComments starts loading the authors, replies and mentions a query selects on a list
+// of comments, as prefetchFiles does for files.
+func (r *Resolver) prefetchComments(ctx context.Context, comments []*models.Comment) {
+	loaders := middleware.LoadersFromContext(ctx)
+	if loaders == nil || len(comments) == 0 {
+		return
+	}
+	selected := selectedFields(ctx, []string{"Comment"})
+
+	var commentIDs, authorIDs []uint
+	for _, comment := range comments {
+		commentIDs = append(commentIDs, comment.ID)
+		authorIDs = append(authorIDs, comment.AuthorID)
+	}
+	if selected["author"] {
+		loadAll(ctx, loaders.UserByID, authorIDs)
+	}
+	if selected["replies"] {
+		loadAll(ctx, loaders.RepliesByCommentID, commentIDs)
+	}
+	if selected["mentions"] {
+		loadAll(ctx, loaders.MentionsByCommentID, commentIDs)
+	}
+}
+
+// loadAll adds keys to a loader's current batch before returning. LoadMany adds them from
+// goroutines of its own, so they could still miss the batch.
+func loadAll[V any](ctx context.Context, loader *dataloader.Loader[uint, V], keys []uint) {
+	for _, key := range keys {
+		loader.Load(ctx, key)
+	}
+}
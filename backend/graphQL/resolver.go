@@ -3,8 +3,11 @@ package graphQL
 //go:generate go run github.com/99designs/gqlgen generate
 
 import (
-	"github.com/joel2607/FileVault/services"
+	"context"
+
 	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/middleware"
+	"github.com/joel2607/FileVault/services"
 	"gorm.io/gorm"
 )
 
@@ -13,9 +16,31 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	DB           *gorm.DB
-	RDB          *redis.Client
-	AuthService  *services.AuthService
-	FileService  *services.FileService
-	ShareService *services.ShareService
-}
\ No newline at end of file
+	DB                       *gorm.DB
+	RDB                      *redis.Client
+	AuthService              *services.AuthService
+	FileService              *services.FileService
+	ShareService             *services.ShareService
+	UserService              *services.UserService
+	AdminService             *services.AdminService
+	OrganizationService      *services.OrganizationService
+	GroupService             *services.GroupService
+	InviteService            *services.InviteService
+	AccessRequestService     *services.AccessRequestService
+	OwnershipTransferService *services.OwnershipTransferService
+	CommentService           *services.CommentService
+	NotificationService      *services.NotificationService
+	WebhookService           *services.WebhookService
+	ActivityService          *services.ActivityService
+	TagService               *services.TagService
+	FolderStatsCache         *services.FolderStatsCache
+}
+
+// loaders returns the request's dataloaders, or new ones where the dataloader middleware
+// doesn't run, such as for subscriptions over WebSocket.
+func (r *Resolver) loaders(ctx context.Context) *middleware.Loaders {
+	if loaders := middleware.LoadersFromContext(ctx); loaders != nil {
+		return loaders
+	}
+	return middleware.NewLoaders(r.DB, r.FolderStatsCache)
+}
@@ -7,12 +7,298 @@ package graphQL
 import (
 	"context"
 	"strconv"
+	"strings"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/joel2607/FileVault/middleware"
 	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services"
 )
 
+// ID resolves the id field for the AccessRequest type.
+func (r *accessRequestResolver) ID(ctx context.Context, obj *models.AccessRequest) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the AccessRequest type.
+func (r *accessRequestResolver) CreatedAt(ctx context.Context, obj *models.AccessRequest) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// Requester resolves the requester field for the AccessRequest type.
+func (r *accessRequestResolver) Requester(ctx context.Context, obj *models.AccessRequest) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.RequesterID)()
+}
+
+// Owner resolves the owner field for the AccessRequest type.
+func (r *accessRequestResolver) Owner(ctx context.Context, obj *models.AccessRequest) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.OwnerID)()
+}
+
+// File resolves the file field for the AccessRequest type.
+// It returns null for folder requests, and to a requester who can't read the file yet.
+func (r *accessRequestResolver) File(ctx context.Context, obj *models.AccessRequest) (*models.File, error) {
+	if obj.FileID == nil {
+		return nil, nil
+	}
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, err := r.loaders(ctx).FileByID.Load(ctx, *obj.FileID)()
+	if err != nil {
+		return nil, err
+	}
+	if !r.AccessRequestService.CanSeeFile(obj, file, user) {
+		return nil, nil
+	}
+	return file, nil
+}
+
+// Folder resolves the folder field for the AccessRequest type.
+// It returns null for file requests, and to a requester who can't read the folder yet.
+func (r *accessRequestResolver) Folder(ctx context.Context, obj *models.AccessRequest) (*models.Folder, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folder, err := r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+	if err != nil {
+		return nil, err
+	}
+	if !r.AccessRequestService.CanSeeFolder(obj, folder, user) {
+		return nil, nil
+	}
+	return folder, nil
+}
+
+// Status resolves the status field for the AccessRequest type.
+func (r *accessRequestResolver) Status(ctx context.Context, obj *models.AccessRequest) (models.AccessRequestStatus, error) {
+	return models.AccessRequestStatus(obj.Status), nil
+}
+
+// DecidedAt resolves the decidedAt field for the AccessRequest type.
+func (r *accessRequestResolver) DecidedAt(ctx context.Context, obj *models.AccessRequest) (*string, error) {
+	if obj.DecidedAt == nil {
+		return nil, nil
+	}
+	decidedAt := obj.DecidedAt.String()
+	return &decidedAt, nil
+}
+
+// ID resolves the id field for the Activity type.
+func (r *activityResolver) ID(ctx context.Context, obj *models.Activity) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Activity type.
+func (r *activityResolver) CreatedAt(ctx context.Context, obj *models.Activity) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// Action resolves the action field for the Activity type.
+func (r *activityResolver) Action(ctx context.Context, obj *models.Activity) (models.ActivityAction, error) {
+	return models.ActivityAction(obj.Action), nil
+}
+
+// Actor resolves the actor field for the Activity type.
+// It returns null when the actor has deleted their account.
+func (r *activityResolver) Actor(ctx context.Context, obj *models.Activity) (*models.User, error) {
+	if obj.ActorID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).UserByID.Load(ctx, *obj.ActorID)()
+}
+
+// FileID resolves the fileId field for the Activity type.
+func (r *activityResolver) FileID(ctx context.Context, obj *models.Activity) (*string, error) {
+	if obj.FileID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.FileID), 10)
+	return &id, nil
+}
+
+// File resolves the file field for the Activity type.
+// It returns null when the activity isn't about a file, or the file was deleted.
+func (r *activityResolver) File(ctx context.Context, obj *models.Activity) (*models.File, error) {
+	if obj.FileID == nil {
+		return nil, nil
+	}
+	file, err := r.loaders(ctx).FileByID.Load(ctx, *obj.FileID)()
+	if err != nil {
+		return nil, nil
+	}
+	return file, nil
+}
+
+// FolderID resolves the folderId field for the Activity type.
+func (r *activityResolver) FolderID(ctx context.Context, obj *models.Activity) (*string, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.FolderID), 10)
+	return &id, nil
+}
+
+// Folder resolves the folder field for the Activity type.
+// It returns null when the activity isn't about a folder, or the folder was deleted.
+func (r *activityResolver) Folder(ctx context.Context, obj *models.Activity) (*models.Folder, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	folder, err := r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+	if err != nil {
+		return nil, nil
+	}
+	return folder, nil
+}
+
+// TargetUser resolves the targetUser field for the Activity type.
+func (r *activityResolver) TargetUser(ctx context.Context, obj *models.Activity) (*models.User, error) {
+	if obj.TargetUserID == nil {
+		return nil, nil
+	}
+	user, err := r.loaders(ctx).UserByID.Load(ctx, *obj.TargetUserID)()
+	if err != nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// TargetGroup resolves the targetGroup field for the Activity type.
+// It returns null when the group has since been deleted.
+func (r *activityResolver) TargetGroup(ctx context.Context, obj *models.Activity) (*models.Group, error) {
+	if obj.TargetGroupID == nil {
+		return nil, nil
+	}
+	group, err := r.loaders(ctx).GroupByID.Load(ctx, *obj.TargetGroupID)()
+	if err != nil {
+		return nil, nil
+	}
+	return group, nil
+}
+
+// ID resolves the id field for the AuditLogEntry type.
+// It converts the numeric ID of the audit log entry into a string.
+func (r *auditLogEntryResolver) ID(ctx context.Context, obj *models.AuditLog) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the AuditLogEntry type.
+// It returns the time the action was recorded as a string.
+func (r *auditLogEntryResolver) CreatedAt(ctx context.Context, obj *models.AuditLog) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// ActorID resolves the actorId field for the AuditLogEntry type.
+// It returns the ID of the user who performed the action, or null for system actions.
+func (r *auditLogEntryResolver) ActorID(ctx context.Context, obj *models.AuditLog) (*string, error) {
+	if obj.ActorID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.ActorID), 10)
+	return &id, nil
+}
+
+// Actor resolves the actor field for the AuditLogEntry type.
+// It returns null for system actions, anonymous login attempts, and actors whose account was deleted.
+func (r *auditLogEntryResolver) Actor(ctx context.Context, obj *models.AuditLog) (*models.User, error) {
+	if obj.ActorID == nil {
+		return nil, nil
+	}
+	user, err := r.loaders(ctx).UserByID.Load(ctx, *obj.ActorID)()
+	if err != nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// TargetID resolves the targetId field for the AuditLogEntry type.
+// It returns the ID of the object acted upon, or null if there is none.
+func (r *auditLogEntryResolver) TargetID(ctx context.Context, obj *models.AuditLog) (*string, error) {
+	if obj.TargetID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.TargetID), 10)
+	return &id, nil
+}
+
+// ID resolves the id field for the Comment type.
+func (r *commentResolver) ID(ctx context.Context, obj *models.Comment) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Comment type.
+func (r *commentResolver) CreatedAt(ctx context.Context, obj *models.Comment) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// EditedAt resolves the editedAt field for the Comment type.
+// It returns null for comments that were never edited.
+func (r *commentResolver) EditedAt(ctx context.Context, obj *models.Comment) (*string, error) {
+	if obj.EditedAt == nil {
+		return nil, nil
+	}
+	editedAt := obj.EditedAt.String()
+	return &editedAt, nil
+}
+
+// File resolves the file field for the Comment type.
+func (r *commentResolver) File(ctx context.Context, obj *models.Comment) (*models.File, error) {
+	return r.loaders(ctx).FileByID.Load(ctx, obj.FileID)()
+}
+
+// Author resolves the author field for the Comment type.
+func (r *commentResolver) Author(ctx context.Context, obj *models.Comment) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.AuthorID)()
+}
+
+// ParentID resolves the parentID field for the Comment type.
+// It returns null for top-level comments.
+func (r *commentResolver) ParentID(ctx context.Context, obj *models.Comment) (*string, error) {
+	if obj.ParentCommentID == nil {
+		return nil, nil
+	}
+	parentID := strconv.FormatUint(uint64(*obj.ParentCommentID), 10)
+	return &parentID, nil
+}
+
+// Replies resolves the replies field for the Comment type.
+func (r *commentResolver) Replies(ctx context.Context, obj *models.Comment) ([]*models.Comment, error) {
+	return r.loaders(ctx).RepliesByCommentID.Load(ctx, obj.ID)()
+}
+
+// Mentions resolves the mentions field for the Comment type.
+func (r *commentResolver) Mentions(ctx context.Context, obj *models.Comment) ([]*models.User, error) {
+	return r.loaders(ctx).MentionsByCommentID.Load(ctx, obj.ID)()
+}
+
+// Resolved resolves the resolved field for the Comment type.
+func (r *commentResolver) Resolved(ctx context.Context, obj *models.Comment) (bool, error) {
+	return obj.ResolvedAt != nil, nil
+}
+
+// ResolvedAt resolves the resolvedAt field for the Comment type.
+func (r *commentResolver) ResolvedAt(ctx context.Context, obj *models.Comment) (*string, error) {
+	if obj.ResolvedAt == nil {
+		return nil, nil
+	}
+	resolvedAt := obj.ResolvedAt.String()
+	return &resolvedAt, nil
+}
+
+// ResolvedBy resolves the resolvedBy field for the Comment type.
+func (r *commentResolver) ResolvedBy(ctx context.Context, obj *models.Comment) (*models.User, error) {
+	if obj.ResolvedByID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).UserByID.Load(ctx, *obj.ResolvedByID)()
+}
+
 // ID resolves the id field for the DeduplicatedContent type.
 // It converts the numeric ID of the content object into a string.
 func (r *deduplicatedContentResolver) ID(ctx context.Context, obj *models.DeduplicatedContent) (string, error) {
@@ -64,9 +350,7 @@ func (r *fileResolver) UserID(ctx context.Context, obj *models.File) (string, er
 // User resolves the user field for the File type.
 // It retrieves and returns the user who owns the file.
 func (r *fileResolver) User(ctx context.Context, obj *models.File) (*models.User, error) {
-	var user models.User
-	err := r.DB.First(&user, obj.UserID).Error
-	return &user, err
+	return r.loaders(ctx).UserByID.Load(ctx, obj.UserID)()
 }
 
 // Size resolves the size field for the File type.
@@ -83,9 +367,17 @@ func (r *fileResolver) DeduplicationID(ctx context.Context, obj *models.File) (s
 
 // DeduplicatedContent resolves the deduplicatedContent field for the File type.
 func (r *fileResolver) DeduplicatedContent(ctx context.Context, obj *models.File) (*models.DeduplicatedContent, error) {
-	var content models.DeduplicatedContent
-	err := r.DB.First(&content, obj.DeduplicationID).Error
-	return &content, err
+	return r.loaders(ctx).DeduplicatedContentByID.Load(ctx, obj.DeduplicationID)()
+}
+
+// PublicExpiresAt resolves the publicExpiresAt field for the File type.
+// It returns null if public visibility has no expiry.
+func (r *fileResolver) PublicExpiresAt(ctx context.Context, obj *models.File) (*string, error) {
+	if obj.PublicExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.PublicExpiresAt.String()
+	return &expiresAt, nil
 }
 
 // DownloadCount resolves the downloadCount field for the File type.
@@ -94,6 +386,20 @@ func (r *fileResolver) DownloadCount(ctx context.Context, obj *models.File) (int
 	return int32(obj.DownloadCount), nil
 }
 
+// Activity resolves the activity field for the File type.
+func (r *fileResolver) Activity(ctx context.Context, obj *models.File, first *int32, after *string) (*models.ActivityPage, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ActivityService.FileActivity(ctx, obj, first, after, user)
+}
+
+// TagList resolves the tagList field for the File type.
+func (r *fileResolver) TagList(ctx context.Context, obj *models.File) ([]string, error) {
+	return r.TagService.FileTags(obj), nil
+}
+
 // ParentFolderID resolves the parentFolderId field for the File type.
 // It returns the ID of the folder containing this file, or null if it's in the root.
 func (r *fileResolver) ParentFolderID(ctx context.Context, obj *models.File) (*string, error) {
@@ -109,9 +415,83 @@ func (r *fileResolver) Folder(ctx context.Context, obj *models.File) (*models.Fo
 	if obj.FolderID == nil {
 		return nil, nil
 	}
-	var folder models.Folder
-	err := r.DB.First(&folder, *obj.FolderID).Error
-	return &folder, err
+	return r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+}
+
+// Path resolves the path field for the File type.
+// It returns the folders containing the file that the current user can see, top-level first.
+func (r *fileResolver) Path(ctx context.Context, obj *models.File) ([]*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if obj.FolderID == nil {
+		return []*models.Folder{}, nil
+	}
+	path, err := r.loaders(ctx).FolderPathByID.Load(ctx, *obj.FolderID)()
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.VisiblePath(ctx, path, user), nil
+}
+
+// PathString resolves the pathString field for the File type.
+func (r *fileResolver) PathString(ctx context.Context, obj *models.File) (string, error) {
+	path, err := r.Path(ctx, obj)
+	if err != nil {
+		return "", err
+	}
+	return services.PathString(path, obj.FileName), nil
+}
+
+// OrganizationID resolves the organizationId field for the File type.
+// It returns the ID of the organization the file is charged to, or null for personal files.
+func (r *fileResolver) OrganizationID(ctx context.Context, obj *models.File) (*string, error) {
+	if obj.OrganizationID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.OrganizationID), 10)
+	return &id, nil
+}
+
+// ID resolves the id field for the FileGroupSharing type.
+func (r *fileGroupSharingResolver) ID(ctx context.Context, obj *models.FileGroupSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the FileGroupSharing type.
+func (r *fileGroupSharingResolver) CreatedAt(ctx context.Context, obj *models.FileGroupSharing) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// FileID resolves the fileId field for the FileGroupSharing type.
+func (r *fileGroupSharingResolver) FileID(ctx context.Context, obj *models.FileGroupSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.FileID), 10), nil
+}
+
+// File resolves the file field for the FileGroupSharing type.
+func (r *fileGroupSharingResolver) File(ctx context.Context, obj *models.FileGroupSharing) (*models.File, error) {
+	return r.loaders(ctx).FileByID.Load(ctx, obj.FileID)()
+}
+
+// GroupID resolves the groupId field for the FileGroupSharing type.
+func (r *fileGroupSharingResolver) GroupID(ctx context.Context, obj *models.FileGroupSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.GroupID), 10), nil
+}
+
+// Group resolves the group field for the FileGroupSharing type.
+func (r *fileGroupSharingResolver) Group(ctx context.Context, obj *models.FileGroupSharing) (*models.Group, error) {
+	return r.loaders(ctx).GroupByID.Load(ctx, obj.GroupID)()
+}
+
+// ExpiresAt resolves the expiresAt field for the FileGroupSharing type.
+// It returns null for shares without an expiry.
+func (r *fileGroupSharingResolver) ExpiresAt(ctx context.Context, obj *models.FileGroupSharing) (*string, error) {
+	if obj.ExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.ExpiresAt.String()
+	return &expiresAt, nil
 }
 
 // ID resolves the id field for the FileSharing type.
@@ -140,9 +520,7 @@ func (r *fileSharingResolver) FileID(ctx context.Context, obj *models.FileSharin
 
 // File resolves the file field for the FileSharing type.
 func (r *fileSharingResolver) File(ctx context.Context, obj *models.FileSharing) (*models.File, error) {
-	var file models.File
-	err := r.DB.First(&file, obj.FileID).Error
-	return &file, err
+	return r.loaders(ctx).FileByID.Load(ctx, obj.FileID)()
 }
 
 // SharedWithUserID resolves the sharedWithUserId field for the FileSharing type.
@@ -153,9 +531,17 @@ func (r *fileSharingResolver) SharedWithUserID(ctx context.Context, obj *models.
 
 // SharedWithUser resolves the sharedWithUser field for the FileSharing type.
 func (r *fileSharingResolver) SharedWithUser(ctx context.Context, obj *models.FileSharing) (*models.User, error) {
-	var user models.User
-	err := r.DB.First(&user, obj.SharedWithUserID).Error
-	return &user, err
+	return r.loaders(ctx).UserByID.Load(ctx, obj.SharedWithUserID)()
+}
+
+// ExpiresAt resolves the expiresAt field for the FileSharing type.
+// It returns null for shares without an expiry.
+func (r *fileSharingResolver) ExpiresAt(ctx context.Context, obj *models.FileSharing) (*string, error) {
+	if obj.ExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.ExpiresAt.String()
+	return &expiresAt, nil
 }
 
 // ID resolves the id field for the Folder type.
@@ -184,9 +570,7 @@ func (r *folderResolver) UserID(ctx context.Context, obj *models.Folder) (string
 
 // User resolves the user field for the Folder type.
 func (r *folderResolver) User(ctx context.Context, obj *models.Folder) (*models.User, error) {
-	var user models.User
-	err := r.DB.First(&user, obj.UserID).Error
-	return &user, err
+	return r.loaders(ctx).UserByID.Load(ctx, obj.UserID)()
 }
 
 // ParentFolderID resolves the parentFolderId field for the Folder type.
@@ -199,64 +583,294 @@ func (r *folderResolver) ParentFolderID(ctx context.Context, obj *models.Folder)
 	return &id, nil
 }
 
-// Files resolves the files field for the Folder type.
-// It retrieves and returns a list of all files located directly within the folder.
-func (r *folderResolver) Files(ctx context.Context, obj *models.Folder) ([]*models.File, error) {
-	var files []*models.File
-	err := r.DB.Where("folder_id = ?", obj.ID).Find(&files).Error
-	return files, err
+// PublicExpiresAt resolves the publicExpiresAt field for the Folder type.
+// It returns null if public visibility has no expiry.
+func (r *folderResolver) PublicExpiresAt(ctx context.Context, obj *models.Folder) (*string, error) {
+	if obj.PublicExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.PublicExpiresAt.String()
+	return &expiresAt, nil
 }
 
-// Folders resolves the folders field for the Folder type.
-// It retrieves and returns a list of all subfolders located directly within the folder.
-func (r *folderResolver) Folders(ctx context.Context, obj *models.Folder) ([]*models.Folder, error) {
-	var folders []*models.Folder
-	err := r.DB.Where("parent_folder_id = ?", obj.ID).Find(&folders).Error
-	return folders, err
+// Path resolves the path field for the Folder type.
+// It returns the folder and the folders above it that the current user can see, top-level first.
+func (r *folderResolver) Path(ctx context.Context, obj *models.Folder) ([]*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path, err := r.loaders(ctx).FolderPathByID.Load(ctx, obj.ID)()
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.VisiblePath(ctx, path, user), nil
 }
 
-// ID resolves the id field for the FolderSharing type.
-// It converts the numeric ID of the sharing record into a string.
-func (r *folderSharingResolver) ID(ctx context.Context, obj *models.FolderSharing) (string, error) {
-	return strconv.FormatUint(uint64(obj.ID), 10), nil
+// PathString resolves the pathString field for the Folder type.
+func (r *folderResolver) PathString(ctx context.Context, obj *models.Folder) (string, error) {
+	path, err := r.Path(ctx, obj)
+	if err != nil {
+		return "", err
+	}
+	return services.PathString(path, ""), nil
 }
 
-// CreatedAt resolves the createdAt field for the FolderSharing type.
-// It returns the creation timestamp as a string.
-func (r *folderSharingResolver) CreatedAt(ctx context.Context, obj *models.FolderSharing) (string, error) {
-	return obj.CreatedAt.String(), nil
+// TotalSize resolves the totalSize field for the Folder type.
+// It returns the size in bytes of all the files in the folder's subtree.
+func (r *folderResolver) TotalSize(ctx context.Context, obj *models.Folder) (float64, error) {
+	stats, err := r.loaders(ctx).FolderStatsByID.Load(ctx, obj.ID)()
+	if err != nil {
+		return 0, err
+	}
+	return float64(stats.TotalSize), nil
 }
 
-// UpdatedAt resolves the updatedAt field for the FolderSharing type.
-// It returns the last update timestamp as a string.
-func (r *folderSharingResolver) UpdatedAt(ctx context.Context, obj *models.FolderSharing) (string, error) {
-	return obj.UpdatedAt.String(), nil
+// FileCount resolves the fileCount field for the Folder type.
+func (r *folderResolver) FileCount(ctx context.Context, obj *models.Folder) (int32, error) {
+	stats, err := r.loaders(ctx).FolderStatsByID.Load(ctx, obj.ID)()
+	if err != nil {
+		return 0, err
+	}
+	return int32(stats.FileCount), nil
 }
 
-// FileID resolves the fileId field for the FolderSharing type.
-// It returns the ID of the file being shared as a string.
-func (r *folderSharingResolver) FolderID(ctx context.Context, obj *models.FolderSharing) (string, error) {
-	return strconv.FormatUint(uint64(obj.FolderID), 10), nil
+// FolderCount resolves the folderCount field for the Folder type.
+func (r *folderResolver) FolderCount(ctx context.Context, obj *models.Folder) (int32, error) {
+	stats, err := r.loaders(ctx).FolderStatsByID.Load(ctx, obj.ID)()
+	if err != nil {
+		return 0, err
+	}
+	return int32(stats.FolderCount), nil
 }
 
-// Folder resolves the folder field for the FolderSharing type.
-func (r *folderSharingResolver) Folder(ctx context.Context, obj *models.FolderSharing) (*models.Folder, error) {
-	var folder models.Folder
-	err := r.DB.First(&folder, obj.FolderID).Error
-	return &folder, err
+// DedupSavedBytes resolves the dedupSavedBytes field for the Folder type.
+func (r *folderResolver) DedupSavedBytes(ctx context.Context, obj *models.Folder) (float64, error) {
+	stats, err := r.loaders(ctx).FolderStatsByID.Load(ctx, obj.ID)()
+	if err != nil {
+		return 0, err
+	}
+	return float64(stats.DedupSavedBytes), nil
 }
 
-// SharedWithUserID resolves the sharedWithUserId field for the FolderSharing type.
-// It returns the ID of the user with whom the folder is shared as a string.
+// LastModified resolves the lastModified field for the Folder type.
+// It returns when the folder or anything in it was last created or changed, as a string.
+func (r *folderResolver) LastModified(ctx context.Context, obj *models.Folder) (string, error) {
+	stats, err := r.loaders(ctx).FolderStatsByID.Load(ctx, obj.ID)()
+	if err != nil {
+		return "", err
+	}
+	return stats.LastModified.String(), nil
+}
+
+// Files resolves the files field for the Folder type.
+// It retrieves and returns a list of all files located directly within the folder.
+func (r *folderResolver) Files(ctx context.Context, obj *models.Folder) ([]*models.File, error) {
+	files, err := r.loaders(ctx).FilesByFolderID.Load(ctx, obj.ID)()
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchFiles(ctx, files)
+	return files, nil
+}
+
+// Folders resolves the folders field for the Folder type.
+// It retrieves and returns a list of all subfolders located directly within the folder.
+func (r *folderResolver) Folders(ctx context.Context, obj *models.Folder) ([]*models.Folder, error) {
+	return r.loaders(ctx).FoldersByParentID.Load(ctx, obj.ID)()
+}
+
+// FilesConnection resolves the filesConnection field for the Folder type.
+// It returns a page of the files located directly within the folder.
+func (r *folderResolver) FilesConnection(ctx context.Context, obj *models.Folder, first *int32, after *string, sortBy *models.FileSort) (*models.FileConnection, error) {
+	connection, err := r.ShareService.FolderFilesConnection(ctx, obj, first, after, sortBy)
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchConnectionFiles(ctx, connection)
+	return connection, nil
+}
+
+// FoldersConnection resolves the foldersConnection field for the Folder type.
+// It returns a page of the subfolders located directly within the folder.
+func (r *folderResolver) FoldersConnection(ctx context.Context, obj *models.Folder, first *int32, after *string, sortBy *models.FolderSort) (*models.FolderConnection, error) {
+	return r.ShareService.FolderFoldersConnection(ctx, obj, first, after, sortBy)
+}
+
+// Activity resolves the activity field for the Folder type.
+func (r *folderResolver) Activity(ctx context.Context, obj *models.Folder, first *int32, after *string) (*models.ActivityPage, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ActivityService.FolderActivity(ctx, obj, first, after, user)
+}
+
+// OrganizationID resolves the organizationId field for the Folder type.
+// It returns the ID of the organization for team folders, or null for personal folders.
+func (r *folderResolver) OrganizationID(ctx context.Context, obj *models.Folder) (*string, error) {
+	if obj.OrganizationID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.OrganizationID), 10)
+	return &id, nil
+}
+
+// ID resolves the id field for the FolderGroupSharing type.
+func (r *folderGroupSharingResolver) ID(ctx context.Context, obj *models.FolderGroupSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the FolderGroupSharing type.
+func (r *folderGroupSharingResolver) CreatedAt(ctx context.Context, obj *models.FolderGroupSharing) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// FolderID resolves the folderId field for the FolderGroupSharing type.
+func (r *folderGroupSharingResolver) FolderID(ctx context.Context, obj *models.FolderGroupSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.FolderID), 10), nil
+}
+
+// Folder resolves the folder field for the FolderGroupSharing type.
+func (r *folderGroupSharingResolver) Folder(ctx context.Context, obj *models.FolderGroupSharing) (*models.Folder, error) {
+	return r.loaders(ctx).FolderByID.Load(ctx, obj.FolderID)()
+}
+
+// GroupID resolves the groupId field for the FolderGroupSharing type.
+func (r *folderGroupSharingResolver) GroupID(ctx context.Context, obj *models.FolderGroupSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.GroupID), 10), nil
+}
+
+// Group resolves the group field for the FolderGroupSharing type.
+func (r *folderGroupSharingResolver) Group(ctx context.Context, obj *models.FolderGroupSharing) (*models.Group, error) {
+	return r.loaders(ctx).GroupByID.Load(ctx, obj.GroupID)()
+}
+
+// ExpiresAt resolves the expiresAt field for the FolderGroupSharing type.
+// It returns null for shares without an expiry.
+func (r *folderGroupSharingResolver) ExpiresAt(ctx context.Context, obj *models.FolderGroupSharing) (*string, error) {
+	if obj.ExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.ExpiresAt.String()
+	return &expiresAt, nil
+}
+
+// ID resolves the id field for the FolderSharing type.
+// It converts the numeric ID of the sharing record into a string.
+func (r *folderSharingResolver) ID(ctx context.Context, obj *models.FolderSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the FolderSharing type.
+// It returns the creation timestamp as a string.
+func (r *folderSharingResolver) CreatedAt(ctx context.Context, obj *models.FolderSharing) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// UpdatedAt resolves the updatedAt field for the FolderSharing type.
+// It returns the last update timestamp as a string.
+func (r *folderSharingResolver) UpdatedAt(ctx context.Context, obj *models.FolderSharing) (string, error) {
+	return obj.UpdatedAt.String(), nil
+}
+
+// FileID resolves the fileId field for the FolderSharing type.
+// It returns the ID of the file being shared as a string.
+func (r *folderSharingResolver) FolderID(ctx context.Context, obj *models.FolderSharing) (string, error) {
+	return strconv.FormatUint(uint64(obj.FolderID), 10), nil
+}
+
+// Folder resolves the folder field for the FolderSharing type.
+func (r *folderSharingResolver) Folder(ctx context.Context, obj *models.FolderSharing) (*models.Folder, error) {
+	return r.loaders(ctx).FolderByID.Load(ctx, obj.FolderID)()
+}
+
+// SharedWithUserID resolves the sharedWithUserId field for the FolderSharing type.
+// It returns the ID of the user with whom the folder is shared as a string.
 func (r *folderSharingResolver) SharedWithUserID(ctx context.Context, obj *models.FolderSharing) (string, error) {
 	return strconv.FormatUint(uint64(obj.SharedWithUserID), 10), nil
 }
 
 // SharedWithUser resolves the sharedWithUser field for the FolderSharing type.
 func (r *folderSharingResolver) SharedWithUser(ctx context.Context, obj *models.FolderSharing) (*models.User, error) {
-	var user models.User
-	err := r.DB.First(&user, obj.SharedWithUserID).Error
-	return &user, err
+	return r.loaders(ctx).UserByID.Load(ctx, obj.SharedWithUserID)()
+}
+
+// ExpiresAt resolves the expiresAt field for the FolderSharing type.
+// It returns null for shares without an expiry.
+func (r *folderSharingResolver) ExpiresAt(ctx context.Context, obj *models.FolderSharing) (*string, error) {
+	if obj.ExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.ExpiresAt.String()
+	return &expiresAt, nil
+}
+
+// ID resolves the id field for the Group type.
+func (r *groupResolver) ID(ctx context.Context, obj *models.Group) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Group type.
+func (r *groupResolver) CreatedAt(ctx context.Context, obj *models.Group) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// OwnerUserID resolves the ownerUserId field for the Group type.
+// It is null for groups owned by an organization.
+func (r *groupResolver) OwnerUserID(ctx context.Context, obj *models.Group) (*string, error) {
+	if obj.OwnerUserID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.OwnerUserID), 10)
+	return &id, nil
+}
+
+// OrganizationID resolves the organizationId field for the Group type.
+// It is null for groups owned by a user.
+func (r *groupResolver) OrganizationID(ctx context.Context, obj *models.Group) (*string, error) {
+	if obj.OrganizationID == nil {
+		return nil, nil
+	}
+	id := strconv.FormatUint(uint64(*obj.OrganizationID), 10)
+	return &id, nil
+}
+
+// Members resolves the members field for the Group type.
+func (r *groupResolver) Members(ctx context.Context, obj *models.Group) ([]*models.User, error) {
+	return r.GroupService.ListMembers(ctx, obj.ID)
+}
+
+// ID resolves the id field for the Membership type.
+func (r *membershipResolver) ID(ctx context.Context, obj *models.Membership) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Membership type.
+func (r *membershipResolver) CreatedAt(ctx context.Context, obj *models.Membership) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// OrganizationID resolves the organizationId field for the Membership type.
+func (r *membershipResolver) OrganizationID(ctx context.Context, obj *models.Membership) (string, error) {
+	return strconv.FormatUint(uint64(obj.OrganizationID), 10), nil
+}
+
+// Organization resolves the organization field for the Membership type.
+func (r *membershipResolver) Organization(ctx context.Context, obj *models.Membership) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.DB.First(&organization, obj.OrganizationID).Error
+	return &organization, err
+}
+
+// UserID resolves the userId field for the Membership type.
+func (r *membershipResolver) UserID(ctx context.Context, obj *models.Membership) (string, error) {
+	return strconv.FormatUint(uint64(obj.UserID), 10), nil
+}
+
+// User resolves the user field for the Membership type.
+func (r *membershipResolver) User(ctx context.Context, obj *models.Membership) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.UserID)()
 }
 
 // Register is the resolver for the register mutation.
@@ -266,256 +880,1414 @@ func (r *mutationResolver) Register(ctx context.Context, input models.RegisterIn
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+	return user, nil
+}
+
+// Login is the resolver for the login mutation.
+// It authenticates a user and returns a token by calling the AuthService.
+func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*models.AuthResponse, error) {
+	token, user, err := r.AuthService.Login(ctx, email, password, middleware.GetClientIP(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthResponse{Token: token, User: user}, nil
+}
+
+// UploadFiles is the resolver for the uploadFiles field.
+func (r *mutationResolver) UploadFiles(ctx context.Context, files []*graphql.Upload, parentFolderID *string, tags []string) ([]*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploadedFiles []*models.File
+	for _, file := range files {
+		uploadedFile, err := r.FileService.UploadFile(ctx, *file, user, parentFolderID, tags)
+		if err != nil {
+			// For now, we'll just return the first error we encounter.
+			return nil, err
+		}
+		uploadedFiles = append(uploadedFiles, uploadedFile)
+	}
+
+	return uploadedFiles, nil
+}
+
+// CreateFolder is the resolver for the createFolder mutation.
+// It creates a new folder by calling the FileService.
+func (r *mutationResolver) CreateFolder(ctx context.Context, input models.NewFolder) (*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileService.CreateFolder(ctx, input, user)
+}
+
+// UpdateFolder is the resolver for the updateFolder mutation.
+// It updates an existing folder's details by calling the FileService.
+func (r *mutationResolver) UpdateFolder(ctx context.Context, input models.UpdateFolder) (*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileService.UpdateFolder(ctx, input, user)
+}
+
+// DeleteFolder is the resolver for the deleteFolder mutation.
+// It deletes a folder by calling the FileService.
+func (r *mutationResolver) DeleteFolder(ctx context.Context, id string) (*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileService.DeleteFolder(ctx, id, user)
+}
+
+// UpdateFile is the resolver for the updateFile mutation.
+// It updates a file's metadata by calling the FileService.
+func (r *mutationResolver) UpdateFile(ctx context.Context, input models.UpdateFile) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileService.UpdateFile(ctx, input, user)
+}
+
+// DeleteFile is the resolver for the deleteFile mutation.
+// It deletes a file by calling the FileService.
+func (r *mutationResolver) DeleteFile(ctx context.Context, id string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.FileService.DeleteFile(ctx, id, user)
+}
+
+// AddTags is the resolver for the addTags mutation.
+func (r *mutationResolver) AddTags(ctx context.Context, fileID string, tags []string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.TagService.AddTags(ctx, fileID, tags, user)
+}
+
+// RemoveTags is the resolver for the removeTags mutation.
+func (r *mutationResolver) RemoveTags(ctx context.Context, fileID string, tags []string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.TagService.RemoveTags(ctx, fileID, tags, user)
+}
+
+// SetTags is the resolver for the setTags mutation.
+func (r *mutationResolver) SetTags(ctx context.Context, fileID string, tags []string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.TagService.SetTags(ctx, fileID, tags, user)
+}
+
+// RenameTag is the resolver for the renameTag mutation.
+func (r *mutationResolver) RenameTag(ctx context.Context, from string, to string) (int32, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.TagService.RenameTag(ctx, from, to, user)
+}
+
+// GenerateDownloadURL is the resolver for the generateDownloadUrl field.
+func (r *mutationResolver) GenerateDownloadURL(ctx context.Context, fileID string) (string, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	return r.FileService.GenerateDownloadURL(ctx, fileID, user)
+}
+
+// SetFilePublic is the resolver for the setFilePublic mutation.
+// It makes a file public and can only be performed by the file owner.
+func (r *mutationResolver) SetFilePublic(ctx context.Context, fileID string, expiresAt *string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.SetFilePublic(ctx, fileID, expiresAt, user)
+}
+
+// SetFilePrivate is the resolver for the setFilePrivate mutation.
+// It makes a file private and can only be performed by the file owner.
+func (r *mutationResolver) SetFilePrivate(ctx context.Context, fileID string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.SetFilePrivate(ctx, fileID, user)
+}
+
+// ShareFileWithUser is the resolver for the shareFileWithUser mutation.
+// It grants another user access to a private file.
+// This action can only be performed by the file owner and fails if the file is public.
+func (r *mutationResolver) ShareFileWithUser(ctx context.Context, fileID string, userID string, expiresAt *string) (*models.FileSharing, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ShareFileWithUser(ctx, fileID, userID, expiresAt, user)
+}
+
+// RemoveFileAccess is the resolver for the removeFileAccess mutation.
+// It removes a user's access to a shared file.
+// This action can only be performed by the file owner.
+func (r *mutationResolver) RemoveFileAccess(ctx context.Context, fileID string, userID string) (bool, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.ShareService.RemoveFileAccess(ctx, fileID, userID, user)
+}
+
+// SetFolderPublic is the resolver for the setFolderPublic mutation.
+// It makes a folder public and can only be performed by the folder owner.
+func (r *mutationResolver) SetFolderPublic(ctx context.Context, folderID string, expiresAt *string) (*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.SetFolderPublic(ctx, folderID, expiresAt, user)
+}
+
+// SetFolderPrivate is the resolver for the setFolderPrivate mutation.
+// It makes a folder private and can only be performed by the folder owner.
+func (r *mutationResolver) SetFolderPrivate(ctx context.Context, folderID string) (*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.SetFolderPrivate(ctx, folderID, user)
+}
+
+// ShareFolderWithUser is the resolver for the shareFolderWithUser mutation.
+// It grants another user access to a private folder.
+// This action can only be performed by the folder owner and fails if the folder is public.
+func (r *mutationResolver) ShareFolderWithUser(ctx context.Context, folderID string, userID string, expiresAt *string) (*models.FolderSharing, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ShareFolderWithUser(ctx, folderID, userID, expiresAt, user)
+}
+
+// RemoveFolderAccess is the resolver for the removeFolderAccess mutation.
+// It removes a user's access to a shared folder.
+// This action can only be performed by the folder owner.
+func (r *mutationResolver) RemoveFolderAccess(ctx context.Context, folderID string, userID string) (bool, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.ShareService.RemoveFolderAccess(ctx, folderID, userID, user)
+}
+
+// UnlockLogin is the resolver for the unlockLogin mutation.
+// It clears login lockouts for an email and/or client IP. Admins only.
+func (r *mutationResolver) UnlockLogin(ctx context.Context, email *string, ip *string) (bool, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return false, err
+	}
+	var emailValue, ipValue string
+	if email != nil {
+		emailValue = *email
+	}
+	if ip != nil {
+		ipValue = *ip
+	}
+	if err := r.AdminService.UnlockLogin(ctx, admin, emailValue, ipValue); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateProfile is the resolver for the updateProfile mutation.
+// It changes the current user's username, email and/or password by calling the UserService.
+func (r *mutationResolver) UpdateProfile(ctx context.Context, input models.UpdateProfileInput) (*models.User, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UserService.UpdateProfile(ctx, input, user)
+}
+
+// DeleteAccount is the resolver for the deleteAccount mutation.
+// It confirms the user's password, optionally exports their data, and then
+// permanently deletes the account by calling the UserService.
+func (r *mutationResolver) DeleteAccount(ctx context.Context, password string, exportData *bool) (*models.AccountDeletionResult, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.UserService.VerifyPassword(user, password); err != nil {
+		return nil, err
+	}
+
+	export, err := r.UserService.DeleteAccount(ctx, user, exportData != nil && *exportData)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.AccountDeletionResult{Success: true}
+	if exportData != nil && *exportData {
+		result.Export = &export
+	}
+	return result, nil
+}
+
+// AdminSetUserQuota is the resolver for the adminSetUserQuota mutation.
+// It changes a user's storage quota. Admins only.
+func (r *mutationResolver) AdminSetUserQuota(ctx context.Context, userID string, storageQuotaKb float64) (*models.User, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AdminService.SetUserQuota(ctx, admin, userID, storageQuotaKb)
+}
+
+// AdminSetUserRateLimit is the resolver for the adminSetUserRateLimit mutation.
+// It changes a user's API rate limit. Admins only.
+func (r *mutationResolver) AdminSetUserRateLimit(ctx context.Context, userID string, apiRateLimit int32) (*models.User, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AdminService.SetUserRateLimit(ctx, admin, userID, int(apiRateLimit))
+}
+
+// AdminSetUserRole is the resolver for the adminSetUserRole mutation.
+// It promotes or demotes a user. Admins only.
+func (r *mutationResolver) AdminSetUserRole(ctx context.Context, userID string, role models.UserRole) (*models.User, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AdminService.SetUserRole(ctx, admin, userID, role)
+}
+
+// AdminSuspendUser is the resolver for the adminSuspendUser mutation.
+// It suspends a user's account and revokes their tokens. Admins only.
+func (r *mutationResolver) AdminSuspendUser(ctx context.Context, userID string, reason *string) (*models.User, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reasonValue := ""
+	if reason != nil {
+		reasonValue = *reason
+	}
+	return r.AdminService.SuspendUser(ctx, admin, userID, reasonValue)
+}
+
+// AdminReactivateUser is the resolver for the adminReactivateUser mutation.
+// It lifts a user's suspension. Admins only.
+func (r *mutationResolver) AdminReactivateUser(ctx context.Context, userID string) (*models.User, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AdminService.ReactivateUser(ctx, admin, userID)
+}
+
+// AdminForceLogout is the resolver for the adminForceLogout mutation.
+// It revokes every token issued to a user. Admins only.
+func (r *mutationResolver) AdminForceLogout(ctx context.Context, userID string) (bool, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := r.AdminService.ForceLogout(ctx, admin, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AdminImpersonateUser is the resolver for the adminImpersonateUser mutation.
+// It returns a read-only token for viewing the application as another user. Admins only.
+func (r *mutationResolver) AdminImpersonateUser(ctx context.Context, userID string) (*models.AuthResponse, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, user, err := r.AdminService.ImpersonateUser(ctx, admin, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthResponse{Token: token, User: user}, nil
+}
+
+// AdminSetOrganizationQuota is the resolver for the adminSetOrganizationQuota mutation.
+// It changes the storage quota of an organization's shared pool. Admins only.
+func (r *mutationResolver) AdminSetOrganizationQuota(ctx context.Context, organizationID string, storageQuotaKb float64) (*models.Organization, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AdminService.SetOrganizationQuota(ctx, admin, organizationID, storageQuotaKb)
+}
+
+// CreateOrganization is the resolver for the createOrganization mutation.
+// It creates an organization owned by the current user.
+func (r *mutationResolver) CreateOrganization(ctx context.Context, name string) (*models.Organization, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.OrganizationService.CreateOrganization(ctx, name, currentUser)
+}
+
+// DeleteOrganization is the resolver for the deleteOrganization mutation.
+// It deletes an organization that has no team folders left. Owner only.
+func (r *mutationResolver) DeleteOrganization(ctx context.Context, id string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.OrganizationService.DeleteOrganization(ctx, id, currentUser)
+}
+
+// AddOrganizationMember is the resolver for the addOrganizationMember mutation.
+// It adds a user to an organization, as a member unless another role is given.
+func (r *mutationResolver) AddOrganizationMember(ctx context.Context, organizationID string, userID string, role *models.OrganizationRole) (*models.Membership, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memberRole := models.OrgRoleMember
+	if role != nil {
+		memberRole = *role
+	}
+	return r.OrganizationService.AddMember(ctx, organizationID, userID, memberRole, currentUser)
+}
+
+// UpdateOrganizationMemberRole is the resolver for the updateOrganizationMemberRole mutation.
+// It changes a member's role, transferring ownership if the new role is OWNER. Owner only.
+func (r *mutationResolver) UpdateOrganizationMemberRole(ctx context.Context, organizationID string, userID string, role models.OrganizationRole) (*models.Membership, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.OrganizationService.UpdateMemberRole(ctx, organizationID, userID, role, currentUser)
+}
+
+// RemoveOrganizationMember is the resolver for the removeOrganizationMember mutation.
+// It removes a user from an organization, or lets the current user leave it.
+func (r *mutationResolver) RemoveOrganizationMember(ctx context.Context, organizationID string, userID string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.OrganizationService.RemoveMember(ctx, organizationID, userID, currentUser)
+}
+
+// ShareFileWithOrganization is the resolver for the shareFileWithOrganization mutation.
+// It shares a file with every other member of an organization.
+func (r *mutationResolver) ShareFileWithOrganization(ctx context.Context, fileID string, organizationID string) ([]*models.FileSharing, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ShareFileWithOrganization(ctx, fileID, organizationID, currentUser)
+}
+
+// ShareFolderWithOrganization is the resolver for the shareFolderWithOrganization mutation.
+// It shares a folder with every other member of an organization.
+func (r *mutationResolver) ShareFolderWithOrganization(ctx context.Context, folderID string, organizationID string) ([]*models.FolderSharing, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ShareFolderWithOrganization(ctx, folderID, organizationID, currentUser)
+}
+
+// CreateGroup is the resolver for the createGroup mutation.
+// It creates a group owned by the current user or by one of their organizations.
+func (r *mutationResolver) CreateGroup(ctx context.Context, name string, organizationID *string) (*models.Group, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.GroupService.CreateGroup(ctx, name, organizationID, currentUser)
+}
+
+// DeleteGroup is the resolver for the deleteGroup mutation.
+// It deletes a group and everything shared with it.
+func (r *mutationResolver) DeleteGroup(ctx context.Context, id string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.GroupService.DeleteGroup(ctx, id, currentUser)
+}
+
+// AddGroupMember is the resolver for the addGroupMember mutation.
+// It adds a user to a group, giving them access to everything shared with it.
+func (r *mutationResolver) AddGroupMember(ctx context.Context, groupID string, userID string) (*models.Group, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.GroupService.AddMember(ctx, groupID, userID, currentUser)
+}
+
+// RemoveGroupMember is the resolver for the removeGroupMember mutation.
+// It removes a user from a group, or lets the current user leave it.
+func (r *mutationResolver) RemoveGroupMember(ctx context.Context, groupID string, userID string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.GroupService.RemoveMember(ctx, groupID, userID, currentUser)
+}
+
+// ShareFileWithGroup is the resolver for the shareFileWithGroup mutation.
+// It shares a file with every member of a group.
+func (r *mutationResolver) ShareFileWithGroup(ctx context.Context, fileID string, groupID string, expiresAt *string) (*models.FileGroupSharing, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ShareFileWithGroup(ctx, fileID, groupID, expiresAt, currentUser)
+}
+
+// RemoveFileGroupAccess is the resolver for the removeFileGroupAccess mutation.
+// It removes a group's access to a file.
+func (r *mutationResolver) RemoveFileGroupAccess(ctx context.Context, fileID string, groupID string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.ShareService.RemoveFileGroupAccess(ctx, fileID, groupID, currentUser)
+}
+
+// ShareFolderWithGroup is the resolver for the shareFolderWithGroup mutation.
+// It shares a folder with every member of a group.
+func (r *mutationResolver) ShareFolderWithGroup(ctx context.Context, folderID string, groupID string, expiresAt *string) (*models.FolderGroupSharing, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ShareFolderWithGroup(ctx, folderID, groupID, expiresAt, currentUser)
+}
+
+// RemoveFolderGroupAccess is the resolver for the removeFolderGroupAccess mutation.
+// It removes a group's access to a folder.
+func (r *mutationResolver) RemoveFolderGroupAccess(ctx context.Context, folderID string, groupID string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.ShareService.RemoveFolderGroupAccess(ctx, folderID, groupID, currentUser)
+}
+
+// InviteToFile is the resolver for the inviteToFile mutation.
+// It invites an email address to one of the current user's files.
+func (r *mutationResolver) InviteToFile(ctx context.Context, fileID string, email string, expiresInHours *int32) (*models.ShareInvite, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.InviteService.InviteToFile(ctx, fileID, email, expiresInHours, currentUser)
+}
+
+// InviteToFolder is the resolver for the inviteToFolder mutation.
+// It invites an email address to one of the current user's folders.
+func (r *mutationResolver) InviteToFolder(ctx context.Context, folderID string, email string, expiresInHours *int32) (*models.ShareInvite, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.InviteService.InviteToFolder(ctx, folderID, email, expiresInHours, currentUser)
+}
+
+// RevokeShareInvite is the resolver for the revokeShareInvite mutation.
+// It cancels a pending invite sent by the current user.
+func (r *mutationResolver) RevokeShareInvite(ctx context.Context, id string) (*models.ShareInvite, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.InviteService.RevokeInvite(ctx, id, currentUser)
+}
+
+// AcceptShareInvite is the resolver for the acceptShareInvite mutation.
+// It turns the invite behind an invitation token into a share for the current user.
+func (r *mutationResolver) AcceptShareInvite(ctx context.Context, token string) (*models.ShareInvite, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.InviteService.AcceptInvite(ctx, token, currentUser)
+}
+
+// RequestAccess is the resolver for the requestAccess mutation.
+// It asks the owner of a file or folder the current user cannot access for access.
+func (r *mutationResolver) RequestAccess(ctx context.Context, fileID *string, folderID *string, message *string, permission *string) (*models.AccessRequest, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AccessRequestService.RequestAccess(ctx, fileID, folderID, message, permission, currentUser)
+}
+
+// ApproveAccessRequest is the resolver for the approveAccessRequest mutation.
+// It shares the requested item with the requester.
+func (r *mutationResolver) ApproveAccessRequest(ctx context.Context, id string, expiresAt *string) (*models.AccessRequest, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AccessRequestService.ApproveAccessRequest(ctx, id, expiresAt, currentUser)
+}
+
+// DenyAccessRequest is the resolver for the denyAccessRequest mutation.
+// It rejects a pending access request for one of the current user's items.
+func (r *mutationResolver) DenyAccessRequest(ctx context.Context, id string) (*models.AccessRequest, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AccessRequestService.DenyAccessRequest(ctx, id, currentUser)
+}
+
+// TransferOwnership is the resolver for the transferOwnership mutation.
+// It offers a file or folder the current user owns to another user.
+func (r *mutationResolver) TransferOwnership(ctx context.Context, fileID *string, folderID *string, newOwnerID string) (*models.OwnershipTransfer, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.OwnershipTransferService.TransferOwnership(ctx, fileID, folderID, newOwnerID, currentUser)
+}
+
+// AcceptOwnershipTransfer is the resolver for the acceptOwnershipTransfer mutation.
+// It makes the current user the owner of the transferred items.
+func (r *mutationResolver) AcceptOwnershipTransfer(ctx context.Context, id string) (*models.OwnershipTransfer, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.OwnershipTransferService.AcceptOwnershipTransfer(ctx, id, currentUser)
+}
+
+// DeclineOwnershipTransfer is the resolver for the declineOwnershipTransfer mutation.
+// It turns down a transfer offered to the current user.
+func (r *mutationResolver) DeclineOwnershipTransfer(ctx context.Context, id string) (*models.OwnershipTransfer, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.OwnershipTransferService.DeclineOwnershipTransfer(ctx, id, currentUser)
+}
+
+// CancelOwnershipTransfer is the resolver for the cancelOwnershipTransfer mutation.
+// It withdraws a transfer the current user offered.
+func (r *mutationResolver) CancelOwnershipTransfer(ctx context.Context, id string) (*models.OwnershipTransfer, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.OwnershipTransferService.CancelOwnershipTransfer(ctx, id, currentUser)
+}
+
+// AddComment is the resolver for the addComment mutation.
+// It comments on a file, or replies to a thread, as the current user.
+func (r *mutationResolver) AddComment(ctx context.Context, fileID string, body string, parentCommentID *string) (*models.Comment, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommentService.AddComment(ctx, fileID, body, parentCommentID, currentUser)
+}
+
+// UpdateComment is the resolver for the updateComment mutation.
+// It edits a comment written by the current user.
+func (r *mutationResolver) UpdateComment(ctx context.Context, id string, body string) (*models.Comment, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommentService.UpdateComment(ctx, id, body, currentUser)
+}
+
+// DeleteComment is the resolver for the deleteComment mutation.
+// It deletes a comment and its replies.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.CommentService.DeleteComment(ctx, id, currentUser)
+}
+
+// ResolveComment is the resolver for the resolveComment mutation.
+// It marks a thread as resolved.
+func (r *mutationResolver) ResolveComment(ctx context.Context, id string) (*models.Comment, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommentService.SetCommentResolved(ctx, id, true, currentUser)
+}
+
+// UnresolveComment is the resolver for the unresolveComment mutation.
+// It reopens a resolved thread.
+func (r *mutationResolver) UnresolveComment(ctx context.Context, id string) (*models.Comment, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommentService.SetCommentResolved(ctx, id, false, currentUser)
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead mutation.
+// It marks the given notifications of the current user as read.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []string) (int32, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.NotificationService.MarkRead(ctx, ids, currentUser)
+}
+
+// MarkAllNotificationsRead is the resolver for the markAllNotificationsRead mutation.
+// It marks all of the current user's notifications as read.
+func (r *mutationResolver) MarkAllNotificationsRead(ctx context.Context) (int32, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.NotificationService.MarkAllRead(ctx, currentUser)
+}
+
+// SetNotificationPreference is the resolver for the setNotificationPreference mutation.
+// It turns a type of notification on or off for the current user.
+func (r *mutationResolver) SetNotificationPreference(ctx context.Context, typeArg models.NotificationType, enabled bool) (*models.NotificationPreference, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.NotificationService.SetPreference(ctx, typeArg, enabled, currentUser)
+}
+
+// CreateWebhook is the resolver for the createWebhook mutation.
+// It registers a webhook for the current user.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, url string, eventTypes []string, folderID *string, global *bool) (*models.Webhook, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.WebhookService.CreateWebhook(ctx, url, eventTypes, folderID, global != nil && *global, currentUser)
+}
+
+// UpdateWebhook is the resolver for the updateWebhook mutation.
+// It changes one of the current user's webhooks.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id string, url *string, eventTypes []string, active *bool) (*models.Webhook, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.WebhookService.UpdateWebhook(ctx, id, url, eventTypes, active, currentUser)
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook mutation.
+// It deletes one of the current user's webhooks and its delivery log.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.WebhookService.DeleteWebhook(ctx, id, currentUser)
+}
+
+// RedeliverWebhookDelivery is the resolver for the redeliverWebhookDelivery mutation.
+// It queues the payload of an earlier delivery to be sent again.
+func (r *mutationResolver) RedeliverWebhookDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.WebhookService.Redeliver(ctx, id, currentUser)
+}
+
+// ID resolves the id field for the Notification type.
+func (r *notificationResolver) ID(ctx context.Context, obj *models.Notification) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Notification type.
+func (r *notificationResolver) CreatedAt(ctx context.Context, obj *models.Notification) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// Type resolves the type field for the Notification type.
+func (r *notificationResolver) Type(ctx context.Context, obj *models.Notification) (models.NotificationType, error) {
+	return models.NotificationType(obj.Type), nil
+}
+
+// Actor resolves the actor field for the Notification type.
+// It returns null when the notification wasn't caused by another user.
+func (r *notificationResolver) Actor(ctx context.Context, obj *models.Notification) (*models.User, error) {
+	if obj.ActorID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).UserByID.Load(ctx, *obj.ActorID)()
+}
+
+// File resolves the file field for the Notification type.
+// It returns null when the notification isn't about a file, or the file was deleted.
+func (r *notificationResolver) File(ctx context.Context, obj *models.Notification) (*models.File, error) {
+	if obj.FileID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).FileByID.Load(ctx, *obj.FileID)()
+}
+
+// Folder resolves the folder field for the Notification type.
+// It returns null when the notification isn't about a folder, or the folder was deleted.
+func (r *notificationResolver) Folder(ctx context.Context, obj *models.Notification) (*models.Folder, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+}
+
+// Read resolves the read field for the Notification type.
+func (r *notificationResolver) Read(ctx context.Context, obj *models.Notification) (bool, error) {
+	return obj.ReadAt != nil, nil
+}
+
+// Type resolves the type field for the NotificationPreference type.
+func (r *notificationPreferenceResolver) Type(ctx context.Context, obj *models.NotificationPreference) (models.NotificationType, error) {
+	return models.NotificationType(obj.Type), nil
+}
+
+// ID resolves the id field for the Organization type.
+func (r *organizationResolver) ID(ctx context.Context, obj *models.Organization) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Organization type.
+func (r *organizationResolver) CreatedAt(ctx context.Context, obj *models.Organization) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// UpdatedAt resolves the updatedAt field for the Organization type.
+func (r *organizationResolver) UpdatedAt(ctx context.Context, obj *models.Organization) (string, error) {
+	return obj.UpdatedAt.String(), nil
+}
+
+// Members resolves the members field for the Organization type.
+func (r *organizationResolver) Members(ctx context.Context, obj *models.Organization) ([]*models.Membership, error) {
+	return r.OrganizationService.ListMembers(ctx, obj.ID)
+}
+
+// Folders resolves the folders field for the Organization type.
+// It returns the organization's top-level team folders.
+func (r *organizationResolver) Folders(ctx context.Context, obj *models.Organization) ([]*models.Folder, error) {
+	return r.OrganizationService.ListTeamFolders(ctx, obj.ID)
+}
+
+// StorageStatistics resolves the storageStatistics field for the Organization type.
+// It reports the usage and deduplication savings of the organization's storage pool.
+func (r *organizationResolver) StorageStatistics(ctx context.Context, obj *models.Organization) (*models.StorageStatistics, error) {
+	return r.FileService.GetOrganizationStorageStatistics(obj.ID)
+}
+
+// ID resolves the id field for the OwnershipTransfer type.
+func (r *ownershipTransferResolver) ID(ctx context.Context, obj *models.OwnershipTransfer) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the OwnershipTransfer type.
+func (r *ownershipTransferResolver) CreatedAt(ctx context.Context, obj *models.OwnershipTransfer) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// FromUser resolves the fromUser field for the OwnershipTransfer type.
+func (r *ownershipTransferResolver) FromUser(ctx context.Context, obj *models.OwnershipTransfer) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.FromUserID)()
+}
+
+// ToUser resolves the toUser field for the OwnershipTransfer type.
+func (r *ownershipTransferResolver) ToUser(ctx context.Context, obj *models.OwnershipTransfer) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.ToUserID)()
+}
+
+// File resolves the file field for the OwnershipTransfer type.
+// It returns null for folder transfers.
+func (r *ownershipTransferResolver) File(ctx context.Context, obj *models.OwnershipTransfer) (*models.File, error) {
+	if obj.FileID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).FileByID.Load(ctx, *obj.FileID)()
+}
+
+// Folder resolves the folder field for the OwnershipTransfer type.
+// It returns null for file transfers.
+func (r *ownershipTransferResolver) Folder(ctx context.Context, obj *models.OwnershipTransfer) (*models.Folder, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+}
+
+// Status resolves the status field for the OwnershipTransfer type.
+func (r *ownershipTransferResolver) Status(ctx context.Context, obj *models.OwnershipTransfer) (models.OwnershipTransferStatus, error) {
+	return models.OwnershipTransferStatus(obj.Status), nil
+}
+
+// DecidedAt resolves the decidedAt field for the OwnershipTransfer type.
+func (r *ownershipTransferResolver) DecidedAt(ctx context.Context, obj *models.OwnershipTransfer) (*string, error) {
+	if obj.DecidedAt == nil {
+		return nil, nil
+	}
+	decidedAt := obj.DecidedAt.String()
+	return &decidedAt, nil
+}
+
+// Me is the resolver for the me query.
+// It retrieves the currently authenticated user's information from the context.
+func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
+	return middleware.GetCurrentUser(ctx)
+}
+
+// Folder is the resolver for the folder query.
+// It retrieves a specific folder by its ID for the current user.
+func (r *queryResolver) Folder(ctx context.Context, id string) (*models.Folder, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.GetFolder(ctx, id, user)
+}
+
+// Root is the resolver for the root query.
+// It retrieves the top-level files and folders for the currently authenticated user.
+func (r *queryResolver) Root(ctx context.Context) (*models.Root, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.GetRoot(ctx, user)
+}
+
+// File is the resolver for the file query.
+// It retrieves a specific file by its ID for the current user.
+func (r *queryResolver) File(ctx context.Context, id string) (*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.GetFile(ctx, id, user)
+}
+
+// ResolvePath is the resolver for the resolvePath query.
+// It finds the folder or file at a path in the current user's own files.
+func (r *queryResolver) ResolvePath(ctx context.Context, path string) (*models.ResolvedPath, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.ResolvePath(ctx, path, user)
+}
+
+// GetUsersWithAccess is the resolver for the getUsersWithAccess query.
+// It returns a list of users who have access to a file.
+// This includes the file owner and any users the file has been shared with.
+// Only the file owner can perform this action.
+func (r *queryResolver) GetUsersWithAccess(ctx context.Context, fileID string) ([]*models.User, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.GetUsersWithAccess(ctx, fileID, user)
+}
+
+// GetUsersWithAccessConnection is the resolver for the getUsersWithAccessConnection query.
+// It returns a page of the users the file has been shared with.
+// Only the file owner can perform this action.
+func (r *queryResolver) GetUsersWithAccessConnection(ctx context.Context, fileID string, first *int32, after *string, sortBy *models.UserSort) (*models.UserConnection, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.GetUsersWithAccessConnection(ctx, fileID, first, after, sortBy, user)
+}
+
+// GetUsersWithFolderAccess is the resolver for the getUsersWithFolderAccess query.
+// It returns a list of users the folder has been shared with.
+// Only the folder owner can perform this action.
+func (r *queryResolver) GetUsersWithFolderAccess(ctx context.Context, folderID string) ([]*models.User, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.GetUsersWithFolderAccess(ctx, folderID, user)
+}
+
+// SharedWithMe is the resolver for the sharedWithMe query.
+// It returns the files and folders shared with the current user, grouped by sharer.
+func (r *queryResolver) SharedWithMe(ctx context.Context) ([]*models.IncomingShares, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := r.ShareService.SharedWithMe(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	var files []*models.File
+	for _, share := range shares {
+		files = append(files, share.Files...)
+	}
+	r.prefetchFiles(ctx, files, "files")
+	return shares, nil
+}
+
+// OutgoingShares is the resolver for the outgoingShares query.
+// It lists everything the current user has shared with other users and groups.
+func (r *queryResolver) OutgoingShares(ctx context.Context) ([]*models.OutgoingShare, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := r.ShareService.OutgoingShares(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*models.File, 0, len(shares))
+	for _, share := range shares {
+		if share.File != nil {
+			files = append(files, share.File)
+		}
+	}
+	r.prefetchFiles(ctx, files, "file")
+	return shares, nil
+}
+
+// MyShareInvites is the resolver for the myShareInvites query.
+// It lists the invites the current user has sent.
+func (r *queryResolver) MyShareInvites(ctx context.Context) ([]*models.ShareInvite, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.InviteService.ListSentInvites(ctx, currentUser)
+}
+
+// SearchFiles is the resolver for the searchFiles query.
+// It searches for files matching a query, which may contain key:value filters, and a set of filters.
+func (r *queryResolver) SearchFiles(ctx context.Context, query *string, filter *models.FileFilterInput) ([]*models.File, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryString := ""
+	if query != nil {
+		queryString = *query
+	}
+	files, err := r.ShareService.SearchFiles(ctx, queryString, filter, user)
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchFiles(ctx, files)
+	return files, nil
+}
+
+// SearchFilesConnection is the resolver for the searchFilesConnection query.
+// It returns a page of the files matching a search.
+func (r *queryResolver) SearchFilesConnection(ctx context.Context, query *string, filter *models.FileFilterInput, first *int32, after *string, sortBy *models.FileSort) (*models.FileConnection, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryString := ""
+	if query != nil {
+		queryString = *query
+	}
+	connection, err := r.ShareService.SearchFilesConnection(ctx, queryString, filter, first, after, sortBy, user)
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchConnectionFiles(ctx, connection)
+	return connection, nil
+}
+
+// SearchFileContents is the resolver for the searchFileContents query.
+// It returns ranked search results with excerpts of the matching text.
+func (r *queryResolver) SearchFileContents(ctx context.Context, query string, filter *models.FileFilterInput, limit *int32) ([]*models.FileSearchResult, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ShareService.SearchFileContents(ctx, query, filter, limit, user)
+}
+
+// SearchUsers is the resolver for the searchUsers field.
+func (r *queryResolver) SearchUsers(ctx context.Context, query string) ([]*models.User, error) {
+	return r.AuthService.SearchUsers(ctx, query)
+}
+
+// SearchUsersConnection is the resolver for the searchUsersConnection query.
+// It returns a page of the users whose username or email contains the query.
+// Only signed-in users can search.
+func (r *queryResolver) SearchUsersConnection(ctx context.Context, query string, first *int32, after *string, sortBy *models.UserSort) (*models.UserConnection, error) {
+	if _, err := middleware.GetCurrentUser(ctx); err != nil {
+		return nil, err
+	}
+	return r.AuthService.SearchUsersConnection(ctx, query, first, after, sortBy)
+}
+
+// ExportMyData is the resolver for the exportMyData query.
+// It returns a JSON export of the current user's account data.
+func (r *queryResolver) ExportMyData(ctx context.Context) (string, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	return r.UserService.ExportData(ctx, user)
+}
+
+// AdminUsers is the resolver for the adminUsers query.
+// It returns a page of users, optionally filtered by username or email. Admins only.
+func (r *queryResolver) AdminUsers(ctx context.Context, query *string, limit *int32, offset *int32) (*models.UserList, error) {
+	if _, err := middleware.GetCurrentAdmin(ctx); err != nil {
+		return nil, err
+	}
+	queryString := ""
+	if query != nil {
+		queryString = *query
+	}
+	return r.AdminService.ListUsers(ctx, queryString, limit, offset)
+}
+
+// AdminAuditLog is the resolver for the adminAuditLog query.
+// It returns a page of the audit trail, optionally filtered. Admins only.
+func (r *queryResolver) AdminAuditLog(ctx context.Context, filter *models.AuditLogFilter, limit *int32, offset *int32) (*models.AuditLogList, error) {
+	if _, err := middleware.GetCurrentAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.AdminService.ListAuditLog(ctx, filter, limit, offset)
 }
 
-// Login is the resolver for the login mutation.
-// It authenticates a user and returns a token by calling the AuthService.
-func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*models.AuthResponse, error) {
-	token, user, err := r.AuthService.Login(email, password)
+// AdminExportAuditLog is the resolver for the adminExportAuditLog query.
+// It returns the matching audit trail entries as JSON Lines. Admins only.
+func (r *queryResolver) AdminExportAuditLog(ctx context.Context, filter *models.AuditLogFilter) (string, error) {
+	admin, err := middleware.GetCurrentAdmin(ctx)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &models.AuthResponse{Token: token, User: user}, nil
+	return r.AdminService.ExportAuditLog(ctx, admin, filter)
 }
 
-// UploadFiles is the resolver for the uploadFiles field.
-func (r *mutationResolver) UploadFiles(ctx context.Context, files []*graphql.Upload, parentFolderID *string) ([]*models.File, error) {
-	user, err := middleware.GetCurrentUser(ctx)
-	if err != nil {
+// AdminVerifyAuditLog is the resolver for the adminVerifyAuditLog query.
+// It checks the audit trail's hash chain. Admins only.
+func (r *queryResolver) AdminVerifyAuditLog(ctx context.Context) (*models.AuditLogVerification, error) {
+	if _, err := middleware.GetCurrentAdmin(ctx); err != nil {
 		return nil, err
 	}
+	return r.AdminService.VerifyAuditLog(ctx)
+}
 
-	var uploadedFiles []*models.File
-	for _, file := range files {
-		uploadedFile, err := r.FileService.UploadFile(ctx, *file, user, parentFolderID)
-		if err != nil {
-			// For now, we'll just return the first error we encounter.
-			return nil, err
-		}
-		uploadedFiles = append(uploadedFiles, uploadedFile)
+// MyOrganizations is the resolver for the myOrganizations query.
+// It returns the organizations the current user is a member of.
+func (r *queryResolver) MyOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	return uploadedFiles, nil
+	return r.OrganizationService.ListMyOrganizations(ctx, currentUser)
 }
 
-// CreateFolder is the resolver for the createFolder mutation.
-// It creates a new folder by calling the FileService.
-func (r *mutationResolver) CreateFolder(ctx context.Context, input models.NewFolder) (*models.Folder, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// Organization is the resolver for the organization query.
+// It returns an organization the current user is a member of.
+func (r *queryResolver) Organization(ctx context.Context, id string) (*models.Organization, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.FileService.CreateFolder(ctx, input, user)
+	return r.OrganizationService.GetOrganization(ctx, id, currentUser)
 }
 
-// UpdateFolder is the resolver for the updateFolder mutation.
-// It updates an existing folder's details by calling the FileService.
-func (r *mutationResolver) UpdateFolder(ctx context.Context, input models.UpdateFolder) (*models.Folder, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// MyGroups is the resolver for the myGroups query.
+// It returns the groups the current user can share with.
+func (r *queryResolver) MyGroups(ctx context.Context) ([]*models.Group, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.FileService.UpdateFolder(ctx, input, user)
+	return r.GroupService.ListMyGroups(ctx, currentUser)
 }
 
-// DeleteFolder is the resolver for the deleteFolder mutation.
-// It deletes a folder by calling the FileService.
-func (r *mutationResolver) DeleteFolder(ctx context.Context, id string) (*models.Folder, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// Group is the resolver for the group query.
+// It returns a group the current user can see.
+func (r *queryResolver) Group(ctx context.Context, id string) (*models.Group, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.FileService.DeleteFolder(ctx, id, user)
+	return r.GroupService.GetGroup(ctx, id, currentUser)
 }
 
-// UpdateFile is the resolver for the updateFile mutation.
-// It updates a file's metadata by calling the FileService.
-func (r *mutationResolver) UpdateFile(ctx context.Context, input models.UpdateFile) (*models.File, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// IncomingAccessRequests is the resolver for the incomingAccessRequests query.
+// It lists the access requests for the current user's items, optionally filtered by status.
+func (r *queryResolver) IncomingAccessRequests(ctx context.Context, status *models.AccessRequestStatus) ([]*models.AccessRequest, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.FileService.UpdateFile(ctx, input, user)
+	return r.AccessRequestService.ListIncoming(ctx, status, currentUser)
 }
 
-// DeleteFile is the resolver for the deleteFile mutation.
-// It deletes a file by calling the FileService.
-func (r *mutationResolver) DeleteFile(ctx context.Context, id string) (*models.File, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// MyAccessRequests is the resolver for the myAccessRequests query.
+// It lists the access requests the current user has made.
+func (r *queryResolver) MyAccessRequests(ctx context.Context) ([]*models.AccessRequest, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.FileService.DeleteFile(ctx, id, user)
+	return r.AccessRequestService.ListOutgoing(ctx, currentUser)
 }
 
-// GenerateDownloadURL is the resolver for the generateDownloadUrl field.
-func (r *mutationResolver) GenerateDownloadURL(ctx context.Context, fileID string) (string, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// IncomingOwnershipTransfers is the resolver for the incomingOwnershipTransfers query.
+// It lists the transfers offered to the current user, optionally filtered by status.
+func (r *queryResolver) IncomingOwnershipTransfers(ctx context.Context, status *models.OwnershipTransferStatus) ([]*models.OwnershipTransfer, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return r.FileService.GenerateDownloadURL(ctx, fileID, user)
+	return r.OwnershipTransferService.ListIncoming(ctx, status, currentUser)
 }
 
-// SetFilePublic is the resolver for the setFilePublic mutation.
-// It makes a file public and can only be performed by the file owner.
-func (r *mutationResolver) SetFilePublic(ctx context.Context, fileID string) (*models.File, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// OutgoingOwnershipTransfers is the resolver for the outgoingOwnershipTransfers query.
+// It lists the transfers the current user has offered.
+func (r *queryResolver) OutgoingOwnershipTransfers(ctx context.Context) ([]*models.OwnershipTransfer, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.SetFilePublic(ctx, fileID, user)
+	return r.OwnershipTransferService.ListOutgoing(ctx, currentUser)
 }
 
-// SetFilePrivate is the resolver for the setFilePrivate mutation.
-// It makes a file private and can only be performed by the file owner.
-func (r *mutationResolver) SetFilePrivate(ctx context.Context, fileID string) (*models.File, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// FileComments is the resolver for the fileComments query.
+// It lists the comment threads on a file the current user can read.
+func (r *queryResolver) FileComments(ctx context.Context, fileID string, includeResolved *bool) ([]*models.Comment, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.SetFilePrivate(ctx, fileID, user)
+	comments, err := r.CommentService.ListFileComments(ctx, fileID, includeResolved == nil || *includeResolved, currentUser)
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchComments(ctx, comments)
+	return comments, nil
 }
 
-// ShareFileWithUser is the resolver for the shareFileWithUser mutation.
-// It grants another user access to a private file.
-// This action can only be performed by the file owner and fails if the file is public.
-func (r *mutationResolver) ShareFileWithUser(ctx context.Context, fileID string, userID string) (*models.FileSharing, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// Notifications is the resolver for the notifications query.
+// It lists the current user's notifications.
+func (r *queryResolver) Notifications(ctx context.Context, unreadOnly *bool, limit *int32) ([]*models.Notification, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.ShareFileWithUser(ctx, fileID, userID, user)
+	return r.NotificationService.ListNotifications(ctx, unreadOnly == nil || *unreadOnly, limit, currentUser)
 }
 
-// RemoveFileAccess is the resolver for the removeFileAccess mutation.
-// It removes a user's access to a shared file.
-// This action can only be performed by the file owner.
-func (r *mutationResolver) RemoveFileAccess(ctx context.Context, fileID string, userID string) (bool, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// UnreadNotificationCount is the resolver for the unreadNotificationCount query.
+func (r *queryResolver) UnreadNotificationCount(ctx context.Context) (int32, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	return r.ShareService.RemoveFileAccess(ctx, fileID, userID, user)
+	return r.NotificationService.UnreadCount(ctx, currentUser)
 }
 
-// SetFolderPublic is the resolver for the setFolderPublic mutation.
-// It makes a folder public and can only be performed by the folder owner.
-func (r *mutationResolver) SetFolderPublic(ctx context.Context, folderID string) (*models.Folder, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// NotificationPreferences is the resolver for the notificationPreferences query.
+// It returns whether each type of notification is on for the current user.
+func (r *queryResolver) NotificationPreferences(ctx context.Context) ([]*models.NotificationPreference, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.SetFolderPublic(ctx, folderID, user)
+	return r.NotificationService.GetPreferences(ctx, currentUser)
 }
 
-// SetFolderPrivate is the resolver for the setFolderPrivate mutation.
-// It makes a folder private and can only be performed by the folder owner.
-func (r *mutationResolver) SetFolderPrivate(ctx context.Context, folderID string) (*models.Folder, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// MyWebhooks is the resolver for the myWebhooks query.
+// It lists the webhooks the current user has registered.
+func (r *queryResolver) MyWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.SetFolderPrivate(ctx, folderID, user)
+	return r.WebhookService.ListWebhooks(ctx, currentUser)
 }
 
-// ShareFolderWithUser is the resolver for the shareFolderWithUser mutation.
-// It grants another user access to a private folder.
-// This action can only be performed by the folder owner and fails if the folder is public.
-func (r *mutationResolver) ShareFolderWithUser(ctx context.Context, folderID string, userID string) (*models.FolderSharing, error) {
-	user, err := middleware.GetCurrentUser(ctx)
+// WebhookDeliveries is the resolver for the webhookDeliveries query.
+// It lists the delivery log of one of the current user's webhooks.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID string, limit *int32) ([]*models.WebhookDelivery, error) {
+	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.ShareFolderWithUser(ctx, folderID, userID, user)
+	return r.WebhookService.ListDeliveries(ctx, webhookID, limit, currentUser)
 }
 
-// RemoveFolderAccess is the resolver for the removeFolderAccess mutation.
-// It removes a user's access to a shared folder.
-// This action can only be performed by the folder owner.
-func (r *mutationResolver) RemoveFolderAccess(ctx context.Context, folderID string, userID string) (bool, error) {
+// WebhookEventTypes is the resolver for the webhookEventTypes query.
+func (r *queryResolver) WebhookEventTypes(ctx context.Context) ([]string, error) {
+	return services.WebhookEventTypes(), nil
+}
+
+// MyActivity is the resolver for the myActivity query.
+// It returns a page of the current user's activity feed.
+func (r *queryResolver) MyActivity(ctx context.Context, first *int32, after *string) (*models.ActivityPage, error) {
 	user, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return r.ShareService.RemoveFolderAccess(ctx, folderID, userID, user)
-}
-
-// Me is the resolver for the me query.
-// It retrieves the currently authenticated user's information from the context.
-func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
-	return middleware.GetCurrentUser(ctx)
+	return r.ActivityService.MyActivity(ctx, first, after, user)
 }
 
-// Folder is the resolver for the folder query.
-// It retrieves a specific folder by its ID for the current user.
-func (r *queryResolver) Folder(ctx context.Context, id string) (*models.Folder, error) {
+// MyTags is the resolver for the myTags query.
+// It returns the tags on the current user's files with their usage counts.
+func (r *queryResolver) MyTags(ctx context.Context) ([]*models.TagCount, error) {
 	user, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.GetFolder(ctx, id, user)
+	return r.TagService.ListTags(ctx, user)
 }
 
-// Root is the resolver for the root query.
-// It retrieves the top-level files and folders for the currently authenticated user.
-func (r *queryResolver) Root(ctx context.Context) (*models.Root, error) {
+// Files resolves the files field for the Root type.
+// It returns all of the current user's top-level files.
+func (r *rootResolver) Files(ctx context.Context, obj *models.Root) ([]*models.File, error) {
 	user, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.GetRoot(ctx, user)
+	files, err := r.ShareService.RootFiles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchFiles(ctx, files)
+	return files, nil
 }
 
-// File is the resolver for the file query.
-// It retrieves a specific file by its ID for the current user.
-func (r *queryResolver) File(ctx context.Context, id string) (*models.File, error) {
+// Folders resolves the folders field for the Root type.
+// It returns all of the current user's top-level folders.
+func (r *rootResolver) Folders(ctx context.Context, obj *models.Root) ([]*models.Folder, error) {
 	user, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.GetFile(ctx, id, user)
+	return r.ShareService.RootFolders(ctx, user)
 }
 
-// GetUsersWithAccess is the resolver for the getUsersWithAccess query.
-// It returns a list of users who have access to a file.
-// This includes the file owner and any users the file has been shared with.
-// Only the file owner can perform this action.
-func (r *queryResolver) GetUsersWithAccess(ctx context.Context, fileID string) ([]*models.User, error) {
+// FilesConnection resolves the filesConnection field for the Root type.
+// It returns a page of the current user's top-level files.
+func (r *rootResolver) FilesConnection(ctx context.Context, obj *models.Root, first *int32, after *string, sortBy *models.FileSort) (*models.FileConnection, error) {
 	user, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.ShareService.GetUsersWithAccess(ctx, fileID, user)
+	connection, err := r.ShareService.RootFilesConnection(ctx, first, after, sortBy, user)
+	if err != nil {
+		return nil, err
+	}
+	r.prefetchConnectionFiles(ctx, connection)
+	return connection, nil
 }
 
-// SearchFiles is the resolver for the searchFiles query.
-// It searches for files based on a set of filters.
-func (r *queryResolver) SearchFiles(ctx context.Context, query *string, filter *models.FileFilterInput) ([]*models.File, error) {
+// FoldersConnection resolves the foldersConnection field for the Root type.
+// It returns a page of the current user's top-level folders.
+func (r *rootResolver) FoldersConnection(ctx context.Context, obj *models.Root, first *int32, after *string, sortBy *models.FolderSort) (*models.FolderConnection, error) {
 	user, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	queryString := ""
-	if query != nil {
-		queryString = *query
+	return r.ShareService.RootFoldersConnection(ctx, first, after, sortBy, user)
+}
+
+// ID resolves the id field for the ShareInvite type.
+func (r *shareInviteResolver) ID(ctx context.Context, obj *models.ShareInvite) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the ShareInvite type.
+func (r *shareInviteResolver) CreatedAt(ctx context.Context, obj *models.ShareInvite) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// InvitedBy resolves the invitedBy field for the ShareInvite type.
+func (r *shareInviteResolver) InvitedBy(ctx context.Context, obj *models.ShareInvite) (*models.User, error) {
+	return r.loaders(ctx).UserByID.Load(ctx, obj.InvitedByID)()
+}
+
+// File resolves the file field for the ShareInvite type.
+// It returns null for folder invites.
+func (r *shareInviteResolver) File(ctx context.Context, obj *models.ShareInvite) (*models.File, error) {
+	if obj.FileID == nil {
+		return nil, nil
 	}
-	return r.ShareService.SearchFiles(ctx, queryString, filter, user)
+	return r.loaders(ctx).FileByID.Load(ctx, *obj.FileID)()
 }
 
-// SearchUsers is the resolver for the searchUsers field.
-func (r *queryResolver) SearchUsers(ctx context.Context, query string) ([]*models.User, error) {
-	return r.AuthService.SearchUsers(ctx, query)
+// Folder resolves the folder field for the ShareInvite type.
+// It returns null for file invites.
+func (r *shareInviteResolver) Folder(ctx context.Context, obj *models.ShareInvite) (*models.Folder, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+}
+
+// ExpiresAt resolves the expiresAt field for the ShareInvite type.
+func (r *shareInviteResolver) ExpiresAt(ctx context.Context, obj *models.ShareInvite) (string, error) {
+	return obj.ExpiresAt.String(), nil
+}
+
+// Status resolves the status field for the ShareInvite type.
+func (r *shareInviteResolver) Status(ctx context.Context, obj *models.ShareInvite) (models.ShareInviteStatus, error) {
+	return obj.Status(), nil
+}
+
+// AcceptedAt resolves the acceptedAt field for the ShareInvite type.
+// It returns null until the invite is accepted.
+func (r *shareInviteResolver) AcceptedAt(ctx context.Context, obj *models.ShareInvite) (*string, error) {
+	if obj.AcceptedAt == nil {
+		return nil, nil
+	}
+	acceptedAt := obj.AcceptedAt.String()
+	return &acceptedAt, nil
+}
+
+// AcceptedBy resolves the acceptedBy field for the ShareInvite type.
+// It returns null until the invite is accepted.
+func (r *shareInviteResolver) AcceptedBy(ctx context.Context, obj *models.ShareInvite) (*models.User, error) {
+	if obj.AcceptedByID == nil {
+		return nil, nil
+	}
+	return r.loaders(ctx).UserByID.Load(ctx, *obj.AcceptedByID)()
 }
 
 // StorageStatistics is the resolver for the storageStatistics field.
-func (r *subscriptionResolver) StorageStatistics(ctx context.Context, userID *string) (<-chan *models.StorageStatistics, error) {
+func (r *subscriptionResolver) StorageStatistics(ctx context.Context, userID *string, organizationID *string) (<-chan *models.StorageStatistics, error) {
 	currentUser, err := middleware.GetCurrentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.FileService.SubscribeToStorageStatistics(ctx, userID, currentUser)
+	return r.FileService.SubscribeToStorageStatistics(ctx, userID, organizationID, currentUser)
 }
 
 // FileDownloadCount is the resolver for the fileDownloadCount field.
@@ -527,6 +2299,33 @@ func (r *subscriptionResolver) FileDownloadCount(ctx context.Context, fileID str
 	return r.FileService.SubscribeToFileDownloads(ctx, fileID, user)
 }
 
+// AccessRequests is the resolver for the accessRequests field.
+func (r *subscriptionResolver) AccessRequests(ctx context.Context) (<-chan *models.AccessRequest, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AccessRequestService.SubscribeToAccessRequests(ctx, user)
+}
+
+// FileComments is the resolver for the fileComments field.
+func (r *subscriptionResolver) FileComments(ctx context.Context, fileID string) (<-chan *models.CommentEvent, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommentService.SubscribeToFileComments(ctx, fileID, user)
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *subscriptionResolver) Notifications(ctx context.Context) (<-chan *models.Notification, error) {
+	user, err := middleware.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.NotificationService.SubscribeToNotifications(ctx, user)
+}
+
 // ID resolves the id field for the User type.
 // It converts the numeric ID of the user object into a string.
 func (r *userResolver) ID(ctx context.Context, obj *models.User) (string, error) {
@@ -568,6 +2367,111 @@ func (r *userResolver) APIRateLimit(ctx context.Context, obj *models.User) (int3
 	return int32(obj.APIRateLimit), nil
 }
 
+// SuspendedAt resolves the suspendedAt field for the User type.
+// It returns the time the account was suspended, or null if it is active.
+func (r *userResolver) SuspendedAt(ctx context.Context, obj *models.User) (*string, error) {
+	if obj.SuspendedAt == nil {
+		return nil, nil
+	}
+	suspendedAt := obj.SuspendedAt.String()
+	return &suspendedAt, nil
+}
+
+// ID resolves the id field for the Webhook type.
+func (r *webhookResolver) ID(ctx context.Context, obj *models.Webhook) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the Webhook type.
+func (r *webhookResolver) CreatedAt(ctx context.Context, obj *models.Webhook) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// EventTypes resolves the eventTypes field for the Webhook type.
+// It splits the comma-separated list stored on the webhook.
+func (r *webhookResolver) EventTypes(ctx context.Context, obj *models.Webhook) ([]string, error) {
+	return strings.Split(obj.EventTypes, ","), nil
+}
+
+// Folder resolves the folder field for the Webhook type.
+// It returns null for webhooks that aren't limited to a folder, or whose folder was deleted.
+func (r *webhookResolver) Folder(ctx context.Context, obj *models.Webhook) (*models.Folder, error) {
+	if obj.FolderID == nil {
+		return nil, nil
+	}
+	folder, err := r.loaders(ctx).FolderByID.Load(ctx, *obj.FolderID)()
+	if err != nil {
+		return nil, nil
+	}
+	return folder, nil
+}
+
+// ID resolves the id field for the WebhookDelivery type.
+func (r *webhookDeliveryResolver) ID(ctx context.Context, obj *models.WebhookDelivery) (string, error) {
+	return strconv.FormatUint(uint64(obj.ID), 10), nil
+}
+
+// CreatedAt resolves the createdAt field for the WebhookDelivery type.
+func (r *webhookDeliveryResolver) CreatedAt(ctx context.Context, obj *models.WebhookDelivery) (string, error) {
+	return obj.CreatedAt.String(), nil
+}
+
+// WebhookID resolves the webhookID field for the WebhookDelivery type.
+func (r *webhookDeliveryResolver) WebhookID(ctx context.Context, obj *models.WebhookDelivery) (string, error) {
+	return strconv.FormatUint(uint64(obj.WebhookID), 10), nil
+}
+
+// Status resolves the status field for the WebhookDelivery type.
+func (r *webhookDeliveryResolver) Status(ctx context.Context, obj *models.WebhookDelivery) (models.WebhookDeliveryStatus, error) {
+	return models.WebhookDeliveryStatus(obj.Status), nil
+}
+
+// Attempts resolves the attempts field for the WebhookDelivery type.
+func (r *webhookDeliveryResolver) Attempts(ctx context.Context, obj *models.WebhookDelivery) (int32, error) {
+	return int32(obj.Attempts), nil
+}
+
+// NextAttemptAt resolves the nextAttemptAt field for the WebhookDelivery type.
+// It returns null once the delivery has succeeded or failed for good.
+func (r *webhookDeliveryResolver) NextAttemptAt(ctx context.Context, obj *models.WebhookDelivery) (*string, error) {
+	if obj.NextAttemptAt == nil {
+		return nil, nil
+	}
+	nextAttemptAt := obj.NextAttemptAt.String()
+	return &nextAttemptAt, nil
+}
+
+// LastStatusCode resolves the lastStatusCode field for the WebhookDelivery type.
+// It returns null if no attempt has received a response yet.
+func (r *webhookDeliveryResolver) LastStatusCode(ctx context.Context, obj *models.WebhookDelivery) (*int32, error) {
+	if obj.LastStatusCode == 0 {
+		return nil, nil
+	}
+	statusCode := int32(obj.LastStatusCode)
+	return &statusCode, nil
+}
+
+// DeliveredAt resolves the deliveredAt field for the WebhookDelivery type.
+func (r *webhookDeliveryResolver) DeliveredAt(ctx context.Context, obj *models.WebhookDelivery) (*string, error) {
+	if obj.DeliveredAt == nil {
+		return nil, nil
+	}
+	deliveredAt := obj.DeliveredAt.String()
+	return &deliveredAt, nil
+}
+
+// AccessRequest returns AccessRequestResolver implementation.
+func (r *Resolver) AccessRequest() AccessRequestResolver { return &accessRequestResolver{r} }
+
+// Activity returns ActivityResolver implementation.
+func (r *Resolver) Activity() ActivityResolver { return &activityResolver{r} }
+
+// AuditLogEntry returns AuditLogEntryResolver implementation.
+func (r *Resolver) AuditLogEntry() AuditLogEntryResolver { return &auditLogEntryResolver{r} }
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // DeduplicatedContent returns DeduplicatedContentResolver implementation.
 func (r *Resolver) DeduplicatedContent() DeduplicatedContentResolver {
 	return &deduplicatedContentResolver{r}
@@ -576,33 +2480,91 @@ func (r *Resolver) DeduplicatedContent() DeduplicatedContentResolver {
 // File returns FileResolver implementation.
 func (r *Resolver) File() FileResolver { return &fileResolver{r} }
 
+// FileGroupSharing returns FileGroupSharingResolver implementation.
+func (r *Resolver) FileGroupSharing() FileGroupSharingResolver { return &fileGroupSharingResolver{r} }
+
 // FileSharing returns FileSharingResolver implementation.
 func (r *Resolver) FileSharing() FileSharingResolver { return &fileSharingResolver{r} }
 
 // Folder returns FolderResolver implementation.
 func (r *Resolver) Folder() FolderResolver { return &folderResolver{r} }
 
+// FolderGroupSharing returns FolderGroupSharingResolver implementation.
+func (r *Resolver) FolderGroupSharing() FolderGroupSharingResolver {
+	return &folderGroupSharingResolver{r}
+}
+
 // FolderSharing returns FolderSharingResolver implementation.
 func (r *Resolver) FolderSharing() FolderSharingResolver { return &folderSharingResolver{r} }
 
+// Group returns GroupResolver implementation.
+func (r *Resolver) Group() GroupResolver { return &groupResolver{r} }
+
+// Membership returns MembershipResolver implementation.
+func (r *Resolver) Membership() MembershipResolver { return &membershipResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Notification returns NotificationResolver implementation.
+func (r *Resolver) Notification() NotificationResolver { return &notificationResolver{r} }
+
+// NotificationPreference returns NotificationPreferenceResolver implementation.
+func (r *Resolver) NotificationPreference() NotificationPreferenceResolver {
+	return &notificationPreferenceResolver{r}
+}
+
+// Organization returns OrganizationResolver implementation.
+func (r *Resolver) Organization() OrganizationResolver { return &organizationResolver{r} }
+
+// OwnershipTransfer returns OwnershipTransferResolver implementation.
+func (r *Resolver) OwnershipTransfer() OwnershipTransferResolver {
+	return &ownershipTransferResolver{r}
+}
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Root returns RootResolver implementation.
+func (r *Resolver) Root() RootResolver { return &rootResolver{r} }
+
+// ShareInvite returns ShareInviteResolver implementation.
+func (r *Resolver) ShareInvite() ShareInviteResolver { return &shareInviteResolver{r} }
+
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
 // User returns UserResolver implementation.
 func (r *Resolver) User() UserResolver { return &userResolver{r} }
 
+// Webhook returns WebhookResolver implementation.
+func (r *Resolver) Webhook() WebhookResolver { return &webhookResolver{r} }
+
+// WebhookDelivery returns WebhookDeliveryResolver implementation.
+func (r *Resolver) WebhookDelivery() WebhookDeliveryResolver { return &webhookDeliveryResolver{r} }
+
+type accessRequestResolver struct{ *Resolver }
+type activityResolver struct{ *Resolver }
+type auditLogEntryResolver struct{ *Resolver }
+type commentResolver struct{ *Resolver }
 type deduplicatedContentResolver struct{ *Resolver }
 type fileResolver struct{ *Resolver }
+type fileGroupSharingResolver struct{ *Resolver }
 type fileSharingResolver struct{ *Resolver }
 type folderResolver struct{ *Resolver }
+type folderGroupSharingResolver struct{ *Resolver }
 type folderSharingResolver struct{ *Resolver }
+type groupResolver struct{ *Resolver }
+type membershipResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type notificationResolver struct{ *Resolver }
+type notificationPreferenceResolver struct{ *Resolver }
+type organizationResolver struct{ *Resolver }
+type ownershipTransferResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type rootResolver struct{ *Resolver }
+type shareInviteResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
 type userResolver struct{ *Resolver }
+type webhookResolver struct{ *Resolver }
+type webhookDeliveryResolver struct{ *Resolver }
@@ -0,0 +1,13 @@
+package graphQL
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchUsersConnectionNeedsAuthentication(t *testing.T) {
+	r := &queryResolver{&Resolver{}}
+	if _, err := r.SearchUsersConnection(context.Background(), "a", nil, nil, nil); err == nil {
+		t.Error("an anonymous caller could search users")
+	}
+}
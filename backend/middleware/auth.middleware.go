@@ -38,7 +38,7 @@ func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.H
 			}
 
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			user, err := authService.GetUserFromToken(tokenString)
+			user, impersonation, err := authService.GetSessionFromToken(tokenString)
 
 			var ctx context.Context
 			if err != nil {
@@ -48,6 +48,9 @@ func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.H
 				// Put the user and role into the context on success
 				ctx = context.WithValue(r.Context(), UserCtxKey, user)
 				ctx = context.WithValue(ctx, RoleCtxKey, user.Role)
+				if impersonation != nil {
+					ctx = context.WithValue(ctx, ImpersonationCtxKey, impersonation)
+				}
 			}
 
 			r = r.WithContext(ctx)
@@ -67,8 +70,8 @@ func AdminOnly(next http.Handler) http.Handler {
 		}
 
 		// Check for admin role
-		role := r.Context().Value(RoleCtxKey)
-		if role == nil || role.(string) != "ADMIN" {
+		role, _ := r.Context().Value(RoleCtxKey).(models.UserRole)
+		if role != models.RoleAdmin {
 			http.Error(w, "Forbidden: Admins only", http.StatusForbidden)
 			return
 		}
@@ -93,3 +96,15 @@ func GetCurrentUser(ctx context.Context) (*models.User, error) {
 
 	return user, nil
 }
+
+// GetCurrentAdmin returns the current user if they are an admin, and an error otherwise.
+func GetCurrentAdmin(ctx context.Context) (*models.User, error) {
+	user, err := GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != models.RoleAdmin {
+		return nil, fmt.Errorf("access denied: admins only")
+	}
+	return user, nil
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPCtxKey is the key for storing the client's IP address in the context.
+var ClientIPCtxKey = &ContextKey{"client-ip"}
+
+// ClientIPMiddleware determines the IP address of the client and stores it in the request context.
+// When trustedHeader is set (e.g. "CF-Connecting-IP" behind Cloudflare), the first address in that
+// header is used, but only on connections from one of trustedProxies, as anyone else could forge
+// it. Otherwise the connection's remote address is used.
+// The header must be set by the outermost proxy: behind a tunnel and Nginx, Nginx's X-Real-IP is
+// the tunnel's address, which would put every client on one IP.
+func ClientIPMiddleware(trustedHeader string, trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			ip := host
+			if trustedHeader != "" && isTrustedProxy(host, trustedProxies) {
+				if value := r.Header.Get(trustedHeader); value != "" {
+					ip = strings.TrimSpace(strings.Split(value, ",")[0])
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), ClientIPCtxKey, ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// isTrustedProxy reports whether the address is in one of the trusted networks.
+func isTrustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses the addresses and CIDR ranges of trusted proxies, such as
+// "127.0.0.1" or "172.16.0.0/12".
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// GetClientIP returns the client IP address stored by ClientIPMiddleware, or "" if there is none.
+func GetClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ClientIPCtxKey).(string)
+	return ip
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPMiddleware(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies([]string{"172.16.0.0/12", "127.0.0.1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		want       string
+	}{
+		{name: "header from a trusted proxy", remoteAddr: "172.18.0.5:41000", header: "203.0.113.7", want: "203.0.113.7"},
+		{name: "first address in the header", remoteAddr: "127.0.0.1:41000", header: "203.0.113.7, 198.51.100.1", want: "203.0.113.7"},
+		{name: "IPv6 proxy", remoteAddr: "[::1]:41000", header: "2001:db8::7", want: "2001:db8::7"},
+		{name: "header from anyone else", remoteAddr: "198.51.100.9:41000", header: "203.0.113.7", want: "198.51.100.9"},
+		{name: "no header", remoteAddr: "172.18.0.5:41000", want: "172.18.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ClientIPMiddleware("CF-Connecting-IP", trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = GetClientIP(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set("CF-Connecting-IP", tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, value := range []string{"proxy.internal", "10.0.0.0/33", ""} {
+		if _, err := ParseTrustedProxies([]string{value}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services"
+	"gorm.io/gorm"
+)
+
+// LoadersCtxKey is the key for storing the request's dataloaders in the context.
+var LoadersCtxKey = &ContextKey{"loaders"}
+
+// loaderWait is how long a loader collects keys before running its batch query. Resolvers
+// for the items of a list run concurrently, so this is enough to gather a whole list.
+const loaderWait = 2 * time.Millisecond
+
+// Loaders batch the lookups field resolvers make while resolving one request, so that
+// resolving a field across a list of objects costs one WHERE id IN (...) query rather than
+// one query per object. Results are cached for the rest of the request.
+type Loaders struct {
+	UserByID                *dataloader.Loader[uint, *models.User]
+	FileByID                *dataloader.Loader[uint, *models.File]
+	FolderByID              *dataloader.Loader[uint, *models.Folder]
+	GroupByID               *dataloader.Loader[uint, *models.Group]
+	DeduplicatedContentByID *dataloader.Loader[uint, *models.DeduplicatedContent]
+	// FilesByFolderID and FoldersByParentID load the files and subfolders directly in folders.
+	FilesByFolderID   *dataloader.Loader[uint, []*models.File]
+	FoldersByParentID *dataloader.Loader[uint, []*models.Folder]
+	// FolderPathByID loads the path of folders, as services.FolderAncestors does.
+	FolderPathByID *dataloader.Loader[uint, []*models.Folder]
+	// FolderStatsByID loads the size and counts of everything in folders, as
+	// services.FolderStatistics does.
+	FolderStatsByID *dataloader.Loader[uint, *services.FolderStats]
+	// RepliesByCommentID and MentionsByCommentID load the replies to comments, in ID order,
+	// and the users they mention.
+	RepliesByCommentID  *dataloader.Loader[uint, []*models.Comment]
+	MentionsByCommentID *dataloader.Loader[uint, []*models.User]
+}
+
+// NewLoaders creates a new set of dataloaders with empty caches. Folder stats are read
+// through statsCache if it isn't nil.
+func NewLoaders(db *gorm.DB, statsCache *services.FolderStatsCache) *Loaders {
+	return &Loaders{
+		UserByID:                newLoader(byID(db, func(user *models.User) uint { return user.ID })),
+		FileByID:                newLoader(byID(db, func(file *models.File) uint { return file.ID })),
+		FolderByID:              newLoader(byID(db, func(folder *models.Folder) uint { return folder.ID })),
+		GroupByID:               newLoader(byID(db, func(group *models.Group) uint { return group.ID })),
+		DeduplicatedContentByID: newLoader(byID(db, func(content *models.DeduplicatedContent) uint { return content.ID })),
+		FilesByFolderID:         newLoader(childrenOf(db, "folder_id", func(file *models.File) *uint { return file.FolderID })),
+		FoldersByParentID:       newLoader(childrenOf(db, "parent_folder_id", func(folder *models.Folder) *uint { return folder.ParentFolderID })),
+		FolderPathByID:          newLoader(folderPaths(db)),
+		FolderStatsByID:         newLoader(folderStats(db, statsCache)),
+		RepliesByCommentID:      newLoader(childrenOf(db, "parent_comment_id", func(comment *models.Comment) *uint { return comment.ParentCommentID })),
+		MentionsByCommentID:     newLoader(commentMentions(db)),
+	}
+}
+
+func newLoader[V any](batch dataloader.BatchFunc[uint, V]) *dataloader.Loader[uint, V] {
+	return dataloader.NewBatchedLoader(batch, dataloader.WithWait[uint, V](loaderWait))
+}
+
+// byID returns a batch function that loads rows by primary key. Keys without a row get
+// gorm.ErrRecordNotFound, as First would return.
+func byID[T any](db *gorm.DB, idOf func(*T) uint) dataloader.BatchFunc[uint, *T] {
+	return func(ctx context.Context, keys []uint) []*dataloader.Result[*T] {
+		results := make([]*dataloader.Result[*T], len(keys))
+		var rows []*T
+		if err := db.WithContext(ctx).Where("id IN ?", keys).Find(&rows).Error; err != nil {
+			for i := range keys {
+				results[i] = &dataloader.Result[*T]{Error: err}
+			}
+			return results
+		}
+
+		byKey := make(map[uint]*T, len(rows))
+		for _, row := range rows {
+			byKey[idOf(row)] = row
+		}
+		for i, key := range keys {
+			if row, ok := byKey[key]; ok {
+				results[i] = &dataloader.Result[*T]{Data: row}
+			} else {
+				results[i] = &dataloader.Result[*T]{Error: gorm.ErrRecordNotFound}
+			}
+		}
+		return results
+	}
+}
+
+// childrenOf returns a batch function that loads the rows whose parent column holds each key,
+// in ID order.
+func childrenOf[T any](db *gorm.DB, column string, parentOf func(*T) *uint) dataloader.BatchFunc[uint, []*T] {
+	return func(ctx context.Context, keys []uint) []*dataloader.Result[[]*T] {
+		results := make([]*dataloader.Result[[]*T], len(keys))
+		var rows []*T
+		if err := db.WithContext(ctx).Where(column+" IN ?", keys).Order("id").Find(&rows).Error; err != nil {
+			for i := range keys {
+				results[i] = &dataloader.Result[[]*T]{Error: err}
+			}
+			return results
+		}
+
+		byParent := make(map[uint][]*T, len(keys))
+		for _, row := range rows {
+			if parent := parentOf(row); parent != nil {
+				byParent[*parent] = append(byParent[*parent], row)
+			}
+		}
+		for i, key := range keys {
+			results[i] = &dataloader.Result[[]*T]{Data: byParent[key]}
+		}
+		return results
+	}
+}
+
+// folderPaths returns a batch function that loads the paths of folders.
+func folderPaths(db *gorm.DB) dataloader.BatchFunc[uint, []*models.Folder] {
+	return func(ctx context.Context, keys []uint) []*dataloader.Result[[]*models.Folder] {
+		results := make([]*dataloader.Result[[]*models.Folder], len(keys))
+		paths, err := services.FolderAncestors(db.WithContext(ctx), keys)
+		for i, key := range keys {
+			switch {
+			case err != nil:
+				results[i] = &dataloader.Result[[]*models.Folder]{Error: err}
+			case paths[key] == nil:
+				results[i] = &dataloader.Result[[]*models.Folder]{Error: gorm.ErrRecordNotFound}
+			default:
+				results[i] = &dataloader.Result[[]*models.Folder]{Data: paths[key]}
+			}
+		}
+		return results
+	}
+}
+
+// folderStats returns a batch function that loads the stats of folders, from statsCache
+// if it isn't nil.
+func folderStats(db *gorm.DB, statsCache *services.FolderStatsCache) dataloader.BatchFunc[uint, *services.FolderStats] {
+	return func(ctx context.Context, keys []uint) []*dataloader.Result[*services.FolderStats] {
+		results := make([]*dataloader.Result[*services.FolderStats], len(keys))
+		var stats map[uint]*services.FolderStats
+		var err error
+		if statsCache != nil {
+			stats, err = statsCache.Statistics(ctx, db.WithContext(ctx), keys)
+		} else {
+			stats, err = services.FolderStatistics(db.WithContext(ctx), keys)
+		}
+		for i, key := range keys {
+			switch {
+			case err != nil:
+				results[i] = &dataloader.Result[*services.FolderStats]{Error: err}
+			case stats[key] == nil:
+				results[i] = &dataloader.Result[*services.FolderStats]{Error: gorm.ErrRecordNotFound}
+			default:
+				results[i] = &dataloader.Result[*services.FolderStats]{Data: stats[key]}
+			}
+		}
+		return results
+	}
+}
+
+// commentMentions returns a batch function that loads the users mentioned in comments, ordered
+// by username.
+func commentMentions(db *gorm.DB) dataloader.BatchFunc[uint, []*models.User] {
+	return func(ctx context.Context, keys []uint) []*dataloader.Result[[]*models.User] {
+		results := make([]*dataloader.Result[[]*models.User], len(keys))
+		fail := func(err error) []*dataloader.Result[[]*models.User] {
+			for i := range keys {
+				results[i] = &dataloader.Result[[]*models.User]{Error: err}
+			}
+			return results
+		}
+
+		var mentions []*models.CommentMention
+		if err := db.WithContext(ctx).Where("comment_id IN ?", keys).Find(&mentions).Error; err != nil {
+			return fail(err)
+		}
+		commentsByUser := make(map[uint][]uint)
+		var userIDs []uint
+		for _, mention := range mentions {
+			if _, ok := commentsByUser[mention.UserID]; !ok {
+				userIDs = append(userIDs, mention.UserID)
+			}
+			commentsByUser[mention.UserID] = append(commentsByUser[mention.UserID], mention.CommentID)
+		}
+
+		byComment := make(map[uint][]*models.User, len(keys))
+		if len(userIDs) > 0 {
+			var users []*models.User
+			if err := db.WithContext(ctx).Where("id IN ?", userIDs).Order("username").Find(&users).Error; err != nil {
+				return fail(err)
+			}
+			for _, user := range users {
+				for _, commentID := range commentsByUser[user.ID] {
+					byComment[commentID] = append(byComment[commentID], user)
+				}
+			}
+		}
+		for i, key := range keys {
+			results[i] = &dataloader.Result[[]*models.User]{Data: byComment[key]}
+		}
+		return results
+	}
+}
+
+// DataloaderMiddleware gives each request its own Loaders. WebSocket connections are
+// skipped: they serve many operations over a long time, and the loaders' cached results
+// would go stale, so resolvers create fresh loaders for them instead.
+func DataloaderMiddleware(db *gorm.DB, statsCache *services.FolderStatsCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), LoadersCtxKey, NewLoaders(db, statsCache))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// LoadersFromContext returns the request's dataloaders, or nil if the dataloader middleware
+// didn't add any.
+func LoadersFromContext(ctx context.Context) *Loaders {
+	loaders, _ := ctx.Value(LoadersCtxKey).(*Loaders)
+	return loaders
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/joel2607/FileVault/services"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ImpersonationCtxKey is the key for storing impersonation details in the context
+// when an admin is acting as another user.
+var ImpersonationCtxKey = &ContextKey{"impersonation"}
+
+// GetImpersonation returns the impersonation details for the request, or nil if
+// the request is not made by an admin impersonating a user.
+func GetImpersonation(ctx context.Context) *services.Impersonation {
+	impersonation, _ := ctx.Value(ImpersonationCtxKey).(*services.Impersonation)
+	return impersonation
+}
+
+// impersonationBlockedQueries are the queries a read-only impersonation session can't run,
+// as they have side effects: exportMyData hands over a copy of everything stored about the
+// user rather than showing it, and adminExportAuditLog records the export in the audit trail.
+var impersonationBlockedQueries = map[string]bool{
+	"exportMyData":        true,
+	"adminExportAuditLog": true,
+}
+
+// ReadOnlyImpersonation is a GraphQL operation interceptor that rejects mutations, and
+// queries with side effects, made with a read-only impersonation token, so admins can look
+// at a user's account without being able to change anything in it.
+func ReadOnlyImpersonation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	impersonation := GetImpersonation(ctx)
+	if impersonation == nil || !impersonation.ReadOnly {
+		return next(ctx)
+	}
+
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation != nil && (opCtx.Operation.Operation == ast.Mutation || hasBlockedQuery(opCtx)) {
+		return graphql.OneShot(&graphql.Response{
+			Errors: gqlerror.List{{
+				Message:    "impersonation sessions are read-only",
+				Extensions: map[string]interface{}{"code": "READ_ONLY_SESSION"},
+			}},
+		})
+	}
+	return next(ctx)
+}
+
+// hasBlockedQuery reports whether a query operation selects any of impersonationBlockedQueries.
+func hasBlockedQuery(opCtx *graphql.OperationContext) bool {
+	if opCtx.Operation.Operation != ast.Query {
+		return false
+	}
+	for _, field := range graphql.CollectFields(opCtx, opCtx.Operation.SelectionSet, []string{"Query"}) {
+		if impersonationBlockedQueries[field.Name] {
+			return true
+		}
+	}
+	return false
+}
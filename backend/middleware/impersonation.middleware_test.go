@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/joel2607/FileVault/services"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestReadOnlyImpersonation(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		type Query { me: String, exportMyData: String! }
+		type Mutation { deleteFile(id: ID!): Boolean! }
+	`})
+
+	tests := []struct {
+		name    string
+		query   string
+		blocked bool
+	}{
+		{name: "query", query: `{ me }`, blocked: false},
+		{name: "mutation", query: `mutation { deleteFile(id: "1") }`, blocked: true},
+		{name: "data export", query: `{ me exportMyData }`, blocked: true},
+		{name: "data export in a fragment", query: `{ ...export } fragment export on Query { exportMyData }`, blocked: true},
+		{name: "skipped data export", query: `{ me exportMyData @skip(if: true) }`, blocked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := gqlparser.MustLoadQuery(schema, tt.query)
+			opCtx := &graphql.OperationContext{Doc: doc, Operation: doc.Operations[0], Variables: map[string]interface{}{}}
+			ctx := graphql.WithOperationContext(context.Background(), opCtx)
+			ctx = context.WithValue(ctx, ImpersonationCtxKey, &services.Impersonation{AdminID: 1, ReadOnly: true})
+
+			ran := false
+			response := ReadOnlyImpersonation(ctx, func(ctx context.Context) graphql.ResponseHandler {
+				ran = true
+				return graphql.OneShot(&graphql.Response{})
+			})(ctx)
+			if ran == tt.blocked {
+				t.Errorf("ran the operation: %v, want %v", ran, !tt.blocked)
+			}
+			if tt.blocked && (len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != "READ_ONLY_SESSION") {
+				t.Errorf("got %v, want a read-only session error", response.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/joel2607/FileVault/models"
+	"github.com/spf13/viper"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// errDepthLimit is the error code of operations rejected for being nested too deeply.
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+func init() {
+	errcode.RegisterErrorType(errDepthLimit, errcode.KindProtocol)
+}
+
+// QueryLimit bounds the size of the GraphQL operations a caller may run.
+type QueryLimit struct {
+	MaxDepth      int // How deeply fields may be nested.
+	MaxComplexity int // The highest complexity score an operation may have.
+}
+
+// QueryLimits holds the query limits for each kind of caller.
+type QueryLimits struct {
+	Anonymous QueryLimit
+	User      QueryLimit
+	Admin     QueryLimit
+}
+
+// QueryLimitsFromConfig builds QueryLimits from the graphql.limits section of the
+// configuration, falling back to sane defaults.
+func QueryLimitsFromConfig() QueryLimits {
+	viper.SetDefault("graphql.limits.anonymous.max_depth", 8)
+	viper.SetDefault("graphql.limits.anonymous.max_complexity", 500)
+	viper.SetDefault("graphql.limits.user.max_depth", 12)
+	viper.SetDefault("graphql.limits.user.max_complexity", 5000)
+	viper.SetDefault("graphql.limits.admin.max_depth", 16)
+	viper.SetDefault("graphql.limits.admin.max_complexity", 20000)
+
+	limit := func(role string) QueryLimit {
+		return QueryLimit{
+			MaxDepth:      viper.GetInt("graphql.limits." + role + ".max_depth"),
+			MaxComplexity: viper.GetInt("graphql.limits." + role + ".max_complexity"),
+		}
+	}
+	return QueryLimits{Anonymous: limit("anonymous"), User: limit("user"), Admin: limit("admin")}
+}
+
+// For returns the query limit for the caller of a request.
+func (l QueryLimits) For(ctx context.Context) QueryLimit {
+	user, ok := ctx.Value(UserCtxKey).(*models.User)
+	switch {
+	case !ok || user == nil:
+		return l.Anonymous
+	case user.Role == models.RoleAdmin:
+		return l.Admin
+	default:
+		return l.User
+	}
+}
+
+// ComplexityLimit returns a GraphQL extension that rejects operations whose complexity
+// exceeds the caller's limit.
+func (l QueryLimits) ComplexityLimit() *extension.ComplexityLimit {
+	return &extension.ComplexityLimit{
+		Func: func(ctx context.Context, _ *graphql.OperationContext) int {
+			return l.For(ctx).MaxComplexity
+		},
+	}
+}
+
+// DepthLimit is a GraphQL extension that rejects operations whose fields are nested more
+// deeply than the caller's limit. Introspection fields aren't counted, so that tools can
+// still load the schema.
+type DepthLimit struct {
+	Limits QueryLimits
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = DepthLimit{}
+
+// ExtensionName returns the name of the extension.
+func (DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+// Validate checks the extension can be used with the schema.
+func (DepthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationContext rejects the operation if it is nested too deeply.
+func (d DepthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	if opCtx.Operation == nil {
+		return nil
+	}
+	limit := d.Limits.For(ctx).MaxDepth
+	if depth := selectionDepth(opCtx.Operation.SelectionSet); depth > limit {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, limit)
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns how deeply the fields of a selection set are nested, following
+// fragments.
+func selectionDepth(selections ast.SelectionSet) int {
+	deepest := 0
+	for _, selection := range selections {
+		depth := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionDepth(selection.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selectionDepth(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				depth = selectionDepth(selection.Definition.SelectionSet)
+			}
+		}
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+	return deepest
+}
+
+// QueryCost is a GraphQL extension that charges the caller's rate limit in proportion to
+// the complexity of each operation: one token per ComplexityPerToken points, and at least
+// one. It must be added after the ComplexityLimit extension, which scores the operation.
+type QueryCost struct {
+	Limiter            *RateLimiter
+	ComplexityPerToken int
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = QueryCost{}
+
+// ExtensionName returns the name of the extension.
+func (QueryCost) ExtensionName() string {
+	return "QueryCost"
+}
+
+// Validate checks the extension can be used with the schema.
+func (c QueryCost) Validate(graphql.ExecutableSchema) error {
+	if c.Limiter == nil {
+		return fmt.Errorf("QueryCost needs a rate limiter")
+	}
+	return nil
+}
+
+// MutateOperationContext charges the operation's cost, rejecting it if the caller doesn't
+// have enough tokens left.
+func (c QueryCost) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	user, ok := ctx.Value(UserCtxKey).(*models.User)
+	if !ok || user == nil {
+		return nil
+	}
+
+	complexity := 0
+	if stats, ok := opCtx.Stats.GetExtension(extension.ComplexityLimit{}.ExtensionName()).(*extension.ComplexityStats); ok {
+		complexity = stats.Complexity
+	}
+	tokens := 1
+	if c.ComplexityPerToken > 0 && complexity > c.ComplexityPerToken {
+		tokens = (complexity + c.ComplexityPerToken - 1) / c.ComplexityPerToken
+	}
+
+	allowed, retryAfter, err := c.Limiter.Take(ctx, user, tokens)
+	if err != nil || allowed {
+		return nil
+	}
+	return &gqlerror.Error{
+		Message: "You have exceeded the rate limit.",
+		Extensions: map[string]interface{}{
+			"code":       "RATE_LIMIT_EXCEEDED",
+			"cost":       tokens,
+			"retryAfter": retryAfter.Seconds(),
+		},
+	}
+}
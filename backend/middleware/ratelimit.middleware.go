@@ -1,91 +1,115 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
-	"github.com/joel2607/FileVault/models"
+
 	"github.com/go-redis/redis/v8"
-	"github.com/gorilla/websocket"
+	"github.com/joel2607/FileVault/models"
 )
 
-// RateLimitMiddleware provides a Redis-backed rate limiter.
-// It checks the number of requests from a user within a given time window
-// and blocks requests that exceed the limit.
-// It returns a structured GraphQL error if the request is for the GraphQL endpoint.
-func RateLimitMiddleware(rdb *redis.Client, defaultLimit int, defaultWindow time.Duration) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if websocket.IsWebSocketUpgrade(r) {
-                // If so, bypass the rate limiter
-                next.ServeHTTP(w, r)
-                return
-            }
-			// Try to get user from context
-			user, ok := r.Context().Value(UserCtxKey).(*models.User)
-			if !ok {
-				// If no user, proceed without rate limiting
-				next.ServeHTTP(w, r)
-				return
-			}
+// tokenBucketScript charges ARGV[4] tokens to the bucket at KEYS[1], which holds up to
+// ARGV[1] tokens and refills at ARGV[2] tokens per millisecond; ARGV[3] is the current time
+// in milliseconds. A charge goes through once the bucket holds the cost, or is full if the
+// cost is more than it can hold, and the whole cost is taken even if that leaves the bucket
+// in debt. It returns whether the charge went through and, if not, how many milliseconds
+// until it would.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
 
-			// Use user's API rate limit, or the default
-			limit := user.APIRateLimit
-			if limit == 0 {
-				limit = defaultLimit
-			}
-			window := defaultWindow
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
 
-			key := fmt.Sprintf("rate_limit:%d", user.ID)
-			now := time.Now().UnixNano()
-			windowStart := now - window.Nanoseconds()
+local needed = math.min(cost, capacity)
+local allowed = 0
+local wait = 0
+if tokens >= needed then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = math.ceil((needed - tokens) / rate)
+end
 
-			// Use a Redis pipeline for efficiency
-			pipe := rdb.TxPipeline()
-			pipe.ZRemRangeByScore(r.Context(), key, "0", fmt.Sprintf("%d", windowStart))
-			pipe.ZAdd(r.Context(), key, &redis.Z{Score: float64(now), Member: now})
-			pipe.ZCard(r.Context(), key)
-			pipe.Expire(r.Context(), key, window)
-			cmds, err := pipe.Exec(r.Context())
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], string.format('%d', math.max(1, math.ceil((capacity - tokens) / rate))))
+return {allowed, wait}
+`)
 
-			if err != nil {
-				// If Redis fails, it's better to let the request through than to block everyone
-				fmt.Printf("Redis error in rate limiter: %v", err)
-				next.ServeHTTP(w, r)
-				return
-			}
+// RateLimiter is a Redis-backed token bucket rate limiter. Each user has a bucket holding
+// their API rate limit in tokens, or the default limit, which refills completely every
+// window. Requests take tokens from the bucket and are refused when it runs dry.
+type RateLimiter struct {
+	RDB          *redis.Client
+	DefaultLimit int
+	Window       time.Duration
+}
 
-			count := cmds[2].(*redis.IntCmd).Val()
+// NewRateLimiter creates a new instance of RateLimiter.
+func NewRateLimiter(rdb *redis.Client, defaultLimit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{RDB: rdb, DefaultLimit: defaultLimit, Window: window}
+}
+
+// Take charges tokens to the user's bucket. If there aren't enough, it takes none and
+// returns how long until there will be. A request costing more than a full bucket runs
+// once the bucket is full and leaves it in debt, so later requests wait until the whole
+// cost has been paid back.
+func (l *RateLimiter) Take(ctx context.Context, user *models.User, tokens int) (bool, time.Duration, error) {
+	limit := user.APIRateLimit
+	if limit == 0 {
+		limit = l.DefaultLimit
+	}
+	rate := float64(limit) / float64(l.Window.Milliseconds())
 
-			if count > int64(limit) {
-				// Check if it's a GraphQL request
-				isGraphQL := r.Header.Get("Content-Type") == "application/json"
+	key := fmt.Sprintf("rate_limit_tokens:%d", user.ID)
+	result, err := tokenBucketScript.Run(ctx, l.RDB, []string{key},
+		limit, strconv.FormatFloat(rate, 'f', -1, 64), time.Now().UnixMilli(), tokens).Result()
+	if err != nil {
+		// If Redis fails, it's better to let the request through than to block everyone
+		fmt.Printf("Redis error in rate limiter: %v", err)
+		return true, 0, err
+	}
 
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return true, 0, fmt.Errorf("unexpected rate limiter result %v", result)
+	}
+	allowed, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
 
-				if isGraphQL {
-					// Send a proper GraphQL error response
-					response := map[string]interface{}{
-						"errors": []map[string]interface{}{
-							{
-								"message": "You have exceeded the rate limit.",
-								"extensions": map[string]string{
-									"code": "RATE_LIMIT_EXCEEDED",
-								},
-							},
-						},
-					}
-					json.NewEncoder(w).Encode(response)
-				} else {
-					// Send a simple JSON error for non-GraphQL requests
-					json.NewEncoder(w).Encode(map[string]string{"error": "You have exceeded the rate limit."})
-				}
-				return
-			}
+// Middleware returns an HTTP middleware that charges one token per request and blocks
+// requests once the user's bucket is empty. GraphQL requests are charged by the QueryCost
+// extension instead, in proportion to what they ask for. Anonymous requests aren't limited.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Try to get user from context
+		user, ok := r.Context().Value(UserCtxKey).(*models.User)
+		if !ok {
+			// If no user, proceed without rate limiting
+			next.ServeHTTP(w, r)
+			return
+		}
 
+		allowed, retryAfter, err := l.Take(r.Context(), user, 1)
+		if err != nil || allowed {
 			next.ServeHTTP(w, r)
-		})
-	}
-}
\ No newline at end of file
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": "You have exceeded the rate limit."})
+	})
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/models"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// newTestRateLimiter returns a limiter with buckets of limit tokens that take an hour to
+// refill, backed by an in-memory Redis.
+func newTestRateLimiter(t *testing.T, limit int) *RateLimiter {
+	t.Helper()
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewRateLimiter(rdb, limit, time.Hour)
+}
+
+// testUser returns a user with the given ID and API rate limit.
+func testUser(id uint, limit int) *models.User {
+	user := &models.User{APIRateLimit: limit}
+	user.ID = id
+	return user
+}
+
+func TestQueryCostChargesByComplexity(t *testing.T) {
+	cost := QueryCost{Limiter: newTestRateLimiter(t, 10), ComplexityPerToken: 100}
+	cheap := testUser(1, 0)
+	expensive := testUser(2, 0)
+
+	charge := func(user *models.User, complexity int) *gqlerror.Error {
+		ctx := context.WithValue(context.Background(), UserCtxKey, user)
+		opCtx := &graphql.OperationContext{}
+		opCtx.Stats.SetExtension(extension.ComplexityLimit{}.ExtensionName(), &extension.ComplexityStats{Complexity: complexity})
+		return cost.MutateOperationContext(ctx, opCtx)
+	}
+
+	if err := charge(cheap, 200); err != nil {
+		t.Fatalf("cheap query: %v", err)
+	}
+	if err := charge(expensive, 5000); err != nil {
+		t.Fatalf("expensive query on a full bucket: %v", err)
+	}
+
+	// The cheap query cost 2 of 10 tokens; the expensive one 50, leaving its user in debt.
+	if err := charge(cheap, 800); err != nil {
+		t.Errorf("the cheap query's user can't spend the rest of their budget: %v", err)
+	}
+	err := charge(expensive, 100)
+	if err == nil {
+		t.Fatal("the expensive query's user could run another query")
+	}
+	if err.Extensions["code"] != "RATE_LIMIT_EXCEEDED" {
+		t.Errorf("got %v, want a rate limit error", err)
+	}
+	if retryAfter, _ := err.Extensions["retryAfter"].(float64); retryAfter <= time.Hour.Seconds() {
+		t.Errorf("retry after %vs, want more than the hour a full refill takes", retryAfter)
+	}
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestRateLimiter(t, 10)
+	user := testUser(1, 4)
+
+	for i := 0; i < 4; i++ {
+		if allowed, _, err := limiter.Take(ctx, user, 1); err != nil || !allowed {
+			t.Fatalf("request %d: allowed %v, %v", i+1, allowed, err)
+		}
+	}
+	allowed, wait, err := limiter.Take(ctx, user, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed || wait <= 0 {
+		t.Errorf("request past the user's own limit: allowed %v, wait %v", allowed, wait)
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"log"
 	"strings"
 
-	"github.com/joel2607/FileVault/services"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/joel2607/FileVault/services"
 )
 
 // WebSocketInitFunc is used to authenticate WebSocket connections.
@@ -31,7 +31,7 @@ func WebSocketInitFunc(authService *services.AuthService) transport.WebsocketIni
 		}
 
 		// Validate the token and retrieve the user.
-		user, err := authService.GetUserFromToken(tokenStr)
+		user, impersonation, err := authService.GetSessionFromToken(tokenStr)
 		if err != nil {
 			// If the token is invalid or expired, we log the error but do not
 			// fail the connection. This maintains consistency with the HTTP middleware.
@@ -43,7 +43,10 @@ func WebSocketInitFunc(authService *services.AuthService) transport.WebsocketIni
 		// If authentication is successful, add the user and their role to the context.
 		ctx = context.WithValue(ctx, UserCtxKey, user)
 		ctx = context.WithValue(ctx, RoleCtxKey, user.Role)
+		if impersonation != nil {
+			ctx = context.WithValue(ctx, ImpersonationCtxKey, impersonation)
+		}
 
 		return ctx, &initPayload, nil
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,23 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// AccessRequest is a request from a user for access to a private file or folder,
+// addressed to its owner. Exactly one of FileID and FolderID is set. Approving
+// a request creates the corresponding FileSharing or FolderSharing row.
+type AccessRequest struct {
+	BaseModel
+	RequesterID     uint       `gorm:"not null;index"`
+	Requester       User       `gorm:"foreignkey:RequesterID"`
+	OwnerID         uint       `gorm:"not null;index"`
+	Owner           User       `gorm:"foreignkey:OwnerID"`
+	FileID          *uint      `gorm:"default:null;index"`
+	File            *File      `gorm:"foreignkey:FileID"`
+	FolderID        *uint      `gorm:"default:null;index"`
+	Folder          *Folder    `gorm:"foreignkey:FolderID"`
+	Message         string     `gorm:"type:text"`
+	PermissionLevel string     `gorm:"type:varchar(50)"`
+	Status          string     `gorm:"type:varchar(20);not null;default:'PENDING';index"`
+	DecidedAt       *time.Time `gorm:"default:null"`
+}
@@ -0,0 +1,25 @@
+// Package models defines the data structures used in the application.
+package models
+
+// Activity records something a user did to a file or folder: uploading, renaming, moving,
+// sharing, unsharing, downloading or deleting it. Entries are only ever appended. Exactly one
+// of FileID and FolderID is set; they are deliberately not foreign keys, so that the history
+// of an item outlives it. OwnerID is the item's owner and ParentFolderID the folder it was in
+// when the activity happened. Action holds an ActivityAction value.
+type Activity struct {
+	BaseModel
+	ActorID        *uint  `gorm:"index"`
+	Actor          *User  `gorm:"foreignkey:ActorID;constraint:OnDelete:SET NULL"`
+	OwnerID        uint   `gorm:"not null;index"`
+	Owner          User   `gorm:"foreignkey:OwnerID"`
+	Action         string `gorm:"type:varchar(20);not null"`
+	FileID         *uint  `gorm:"default:null;index"`
+	FolderID       *uint  `gorm:"default:null;index"`
+	ParentFolderID *uint  `gorm:"default:null;index"`
+	ItemName       string `gorm:"type:varchar(255);not null"`
+	TargetUserID   *uint  `gorm:"default:null"`
+	TargetUser     *User  `gorm:"foreignkey:TargetUserID;constraint:OnDelete:SET NULL"`
+	TargetGroupID  *uint  `gorm:"default:null"`
+	// Details is a short human-readable note, such as the old name of a renamed file.
+	Details string `gorm:"type:text"`
+}
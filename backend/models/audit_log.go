@@ -0,0 +1,18 @@
+// Package models defines the data structures used in the application.
+package models
+
+// AuditLog records a security-relevant action: an administrative change such as a user's
+// quota or role, a login attempt, a token being issued, or an admin reaching into another
+// user's data. Entries are only ever appended. Each entry's Hash covers its own fields and
+// PrevHash, the hash of the entry before it, so editing or deleting an entry breaks the chain.
+// ActorID is deliberately not a foreign key: it must not change when the actor's account is deleted.
+type AuditLog struct {
+	BaseModel
+	ActorID    *uint  `gorm:"index"`
+	Action     string `gorm:"type:varchar(100);not null;index"`
+	TargetType string `gorm:"type:varchar(50)"`
+	TargetID   *uint  `gorm:"index"`
+	Details    string `gorm:"type:jsonb;default:'{}'"`
+	PrevHash   string `gorm:"type:varchar(64);not null;default:''"`
+	Hash       string `gorm:"type:varchar(64);not null;default:''"`
+}
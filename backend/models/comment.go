@@ -0,0 +1,32 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// Comment is a note left on a file by a user who can read it. A comment with a
+// ParentCommentID is a reply in the thread started by that comment; threads are one
+// level deep, so replies always point at the top-level comment. Only top-level
+// comments are resolved.
+type Comment struct {
+	BaseModel
+	FileID          uint       `gorm:"not null;index"`
+	File            File       `gorm:"foreignkey:FileID"`
+	AuthorID        uint       `gorm:"not null;index"`
+	Author          User       `gorm:"foreignkey:AuthorID"`
+	ParentCommentID *uint      `gorm:"default:null;index"`
+	ParentComment   *Comment   `gorm:"foreignkey:ParentCommentID"`
+	Body            string     `gorm:"type:text;not null"`
+	EditedAt        *time.Time `gorm:"default:null"`
+	ResolvedAt      *time.Time `gorm:"default:null"`
+	ResolvedByID    *uint      `gorm:"default:null"`
+	ResolvedBy      *User      `gorm:"foreignkey:ResolvedByID;constraint:OnDelete:SET NULL"`
+}
+
+// CommentMention records a user @mentioned in a comment.
+type CommentMention struct {
+	BaseModel
+	CommentID uint    `gorm:"not null;uniqueIndex:idx_comment_mention"`
+	Comment   Comment `gorm:"foreignkey:CommentID"`
+	UserID    uint    `gorm:"not null;uniqueIndex:idx_comment_mention;index"`
+	User      User    `gorm:"foreignkey:UserID"`
+}
@@ -1,11 +1,26 @@
 // Package models defines the data structures used in the application.
 package models
 
+import "time"
+
 // DeduplicatedContent stores a single instance of file content for deduplication.
 // This table is central to the deduplication feature, tracking the hash of the content
 // and the number of files that reference it.
+// The content's searchable text is extracted once per hash and kept in ContentText.
 type DeduplicatedContent struct {
 	BaseModel
 	SHA256Hash     string `gorm:"type:varchar(128);unique;not null"`
 	ReferenceCount int    `gorm:"default:0"`
-}
\ No newline at end of file
+	// TextExtractedAt is set once text extraction has been attempted, whether or not any text was found.
+	TextExtractedAt *time.Time `gorm:"default:null;index"`
+}
+
+// ContentText holds the text extracted from a DeduplicatedContent. It has a table of its own
+// so that loading content, which happens on most file operations, doesn't load up to
+// search.max_extracted_text_kb of text with it. The text is indexed for full-text search
+// through the search_vector column, which the database generates from ExtractedText.
+type ContentText struct {
+	DeduplicatedContentID uint                `gorm:"primarykey;autoIncrement:false"`
+	DeduplicatedContent   DeduplicatedContent `gorm:"foreignkey:DeduplicatedContentID;constraint:OnDelete:CASCADE"`
+	ExtractedText         string              `gorm:"type:text;not null;default:''"`
+}
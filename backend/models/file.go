@@ -1,21 +1,29 @@
 // Package models defines the data structures used in the application.
 package models
 
+import "time"
+
 // File represents a file uploaded by a user.
 // This table stores metadata for each file, but not the file content itself.
 // It links to the user who uploaded it and the deduplicated content.
 type File struct {
 	BaseModel
-	UserID              uint      `gorm:"not null"`
-	User                User      `gorm:"foreignkey:UserID"`
-	FileName            string    `gorm:"type:varchar(255);not null"`
-	MIMEType            string    `gorm:"type:varchar(100);not null"`
-	Size                int64     `gorm:"not null"`
-	DeduplicationID     uint      `gorm:"not null"`
+	UserID              uint                `gorm:"not null"`
+	User                User                `gorm:"foreignkey:UserID"`
+	FileName            string              `gorm:"type:varchar(255);not null"`
+	MIMEType            string              `gorm:"type:varchar(100);not null"`
+	Size                int64               `gorm:"not null"`
+	DeduplicationID     uint                `gorm:"not null;index"`
 	DeduplicatedContent DeduplicatedContent `gorm:"foreignkey:DeduplicationID"`
-	IsPublic            bool      `gorm:"default:false"`
-	DownloadCount       int       `gorm:"default:0"`
-	Tags                string    `gorm:"type:jsonb;default:'[]'"`
-	FolderID            *uint     `gorm:"default:null"`
-	Folder              *Folder   `gorm:"foreignkey:FolderID"`
-}
\ No newline at end of file
+	IsPublic            bool                `gorm:"default:false"`
+	// PublicExpiresAt, if set, is when public visibility lapses and the file becomes private again.
+	PublicExpiresAt *time.Time `gorm:"default:null"`
+	DownloadCount   int        `gorm:"default:0"`
+	// Tags is a JSON array of lowercase tags, indexed for containment queries.
+	Tags     string  `gorm:"type:jsonb;default:'[]';index:idx_files_tags,type:gin"`
+	FolderID *uint   `gorm:"default:null"`
+	Folder   *Folder `gorm:"foreignkey:FolderID"`
+	// OrganizationID is set for files in team folders; their storage is charged to the organization.
+	OrganizationID *uint         `gorm:"default:null;index"`
+	Organization   *Organization `gorm:"foreignkey:OrganizationID"`
+}
@@ -1,6 +1,8 @@
 // Package models defines the data structures used in the application.
 package models
 
+import "time"
+
 // FileSharing manages file sharing with specific users.
 // This table is used for the optional feature of sharing files with
 // specific users and defining their permission levels.
@@ -11,4 +13,7 @@ type FileSharing struct {
 	SharedWithUserID uint   `gorm:"not null"`
 	SharedWithUser   User   `gorm:"foreignkey:SharedWithUserID"`
 	PermissionLevel  string `gorm:"type:varchar(50)"`
-}
\ No newline at end of file
+	// ExpiresAt, if set, is when the share lapses. Expired shares grant no access
+	// and are removed by the share expiry sweeper.
+	ExpiresAt *time.Time `gorm:"default:null;index"`
+}
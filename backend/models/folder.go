@@ -1,14 +1,21 @@
 // Package models defines the data structures used in the application.
 package models
 
+import "time"
+
 // Folder represents a folder for organizing files.
 // This table supports nested folders and public sharing of folders.
 type Folder struct {
 	BaseModel
-	UserID         uint   `gorm:"not null"`
-	User           User   `gorm:"foreignkey:UserID"`
-	FolderName     string `gorm:"type:varchar(255);not null"`
-	ParentFolderID *uint    `gorm:"default:null"`
-	ParentFolder   *Folder  `gorm:"foreignkey:ParentFolderID;references:ID"`
-	IsPublic       bool   `gorm:"default:false"`
-}
\ No newline at end of file
+	UserID         uint    `gorm:"not null"`
+	User           User    `gorm:"foreignkey:UserID"`
+	FolderName     string  `gorm:"type:varchar(255);not null"`
+	ParentFolderID *uint   `gorm:"default:null"`
+	ParentFolder   *Folder `gorm:"foreignkey:ParentFolderID;references:ID"`
+	IsPublic       bool    `gorm:"default:false"`
+	// PublicExpiresAt, if set, is when public visibility lapses and the folder becomes private again.
+	PublicExpiresAt *time.Time `gorm:"default:null"`
+	// OrganizationID is set for team folders, whose contents are charged to the organization.
+	OrganizationID *uint         `gorm:"default:null;index"`
+	Organization   *Organization `gorm:"foreignkey:OrganizationID"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // FolderSharing manages folder sharing with specific users.
 type FolderSharing struct {
 	BaseModel
@@ -8,4 +10,7 @@ type FolderSharing struct {
 	SharedWithUserID uint   `gorm:"not null"`
 	SharedWithUser   User   `gorm:"foreignkey:SharedWithUserID"`
 	PermissionLevel  string `gorm:"type:varchar(50)"`
-}
\ No newline at end of file
+	// ExpiresAt, if set, is when the share lapses. Expired shares grant no access
+	// and are removed by the share expiry sweeper.
+	ExpiresAt *time.Time `gorm:"default:null;index"`
+}
@@ -0,0 +1,24 @@
+// Package models defines the data structures used in the application.
+package models
+
+// Group is a user-defined set of users that files and folders can be shared with.
+// A group is owned either by a user or by an organization; exactly one of
+// OwnerUserID and OrganizationID is set. Because access is resolved through
+// group membership, adding or removing members takes effect on every share at once.
+type Group struct {
+	BaseModel
+	Name           string        `gorm:"type:varchar(255);not null"`
+	OwnerUserID    *uint         `gorm:"default:null;index"`
+	OwnerUser      *User         `gorm:"foreignkey:OwnerUserID"`
+	OrganizationID *uint         `gorm:"default:null;index"`
+	Organization   *Organization `gorm:"foreignkey:OrganizationID"`
+}
+
+// GroupMember links a user to a group.
+type GroupMember struct {
+	BaseModel
+	GroupID uint  `gorm:"not null;uniqueIndex:idx_group_member_group_user"`
+	Group   Group `gorm:"foreignkey:GroupID"`
+	UserID  uint  `gorm:"not null;uniqueIndex:idx_group_member_group_user;index"`
+	User    User  `gorm:"foreignkey:UserID"`
+}
@@ -0,0 +1,32 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// FileGroupSharing shares a file with every member of a group.
+// It is the group counterpart of FileSharing.
+type FileGroupSharing struct {
+	BaseModel
+	FileID          uint   `gorm:"not null;uniqueIndex:idx_file_group_sharing"`
+	File            File   `gorm:"foreignkey:FileID"`
+	GroupID         uint   `gorm:"not null;uniqueIndex:idx_file_group_sharing;index"`
+	Group           Group  `gorm:"foreignkey:GroupID"`
+	PermissionLevel string `gorm:"type:varchar(50)"`
+	// ExpiresAt, if set, is when the share lapses. Expired shares grant no access
+	// and are removed by the share expiry sweeper.
+	ExpiresAt *time.Time `gorm:"default:null;index"`
+}
+
+// FolderGroupSharing shares a folder with every member of a group.
+// It is the group counterpart of FolderSharing.
+type FolderGroupSharing struct {
+	BaseModel
+	FolderID        uint   `gorm:"not null;uniqueIndex:idx_folder_group_sharing"`
+	Folder          Folder `gorm:"foreignkey:FolderID"`
+	GroupID         uint   `gorm:"not null;uniqueIndex:idx_folder_group_sharing;index"`
+	Group           Group  `gorm:"foreignkey:GroupID"`
+	PermissionLevel string `gorm:"type:varchar(50)"`
+	// ExpiresAt, if set, is when the share lapses. Expired shares grant no access
+	// and are removed by the share expiry sweeper.
+	ExpiresAt *time.Time `gorm:"default:null;index"`
+}
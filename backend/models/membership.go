@@ -0,0 +1,12 @@
+// Package models defines the data structures used in the application.
+package models
+
+// Membership links a user to an organization with a role.
+type Membership struct {
+	BaseModel
+	OrganizationID uint             `gorm:"not null;uniqueIndex:idx_membership_org_user"`
+	Organization   Organization     `gorm:"foreignkey:OrganizationID"`
+	UserID         uint             `gorm:"not null;uniqueIndex:idx_membership_org_user;index"`
+	User           User             `gorm:"foreignkey:UserID"`
+	Role           OrganizationRole `gorm:"type:varchar(20);not null;default:'member'"`
+}
@@ -2,26 +2,130 @@
 
 package models
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Result of the deleteAccount mutation.
+type AccountDeletionResult struct {
+	Success bool `json:"success"`
+	// A JSON export of the account's data, taken just before deletion when requested.
+	Export *string `json:"export,omitempty"`
+}
+
+// A page of activity, newest first. Pass endCursor as after to get the next page.
+type ActivityPage struct {
+	Entries   []*Activity `json:"entries"`
+	EndCursor *string     `json:"endCursor,omitempty"`
+	HasMore   bool        `json:"hasMore"`
+}
+
+// Narrows the audit trail. An action ending in "." matches every action starting with it,
+// such as "login." for all login events. since and until are RFC 3339 times.
+type AuditLogFilter struct {
+	ActorID    *string `json:"actorID,omitempty"`
+	Action     *string `json:"action,omitempty"`
+	TargetType *string `json:"targetType,omitempty"`
+	TargetID   *string `json:"targetID,omitempty"`
+	Since      *string `json:"since,omitempty"`
+	Until      *string `json:"until,omitempty"`
+}
+
+// A page of audit trail entries, newest first.
+type AuditLogList struct {
+	Entries    []*AuditLog `json:"entries"`
+	TotalCount int32       `json:"totalCount"`
+}
+
+// The result of checking the audit trail's hash chain.
+type AuditLogVerification struct {
+	Valid          bool  `json:"valid"`
+	CheckedEntries int32 `json:"checkedEntries"`
+	// The first entry whose hash or link to the entry before it doesn't match, if any.
+	FirstInvalidEntryID *string `json:"firstInvalidEntryID,omitempty"`
+	Reason              *string `json:"reason,omitempty"`
+}
+
 // Response type for a successful login.
 type AuthResponse struct {
 	Token string `json:"token"`
 	User  *User  `json:"user"`
 }
 
+type CommentEvent struct {
+	Action    CommentAction `json:"action"`
+	CommentID string        `json:"commentID"`
+	// The comment after the change; null for deleted comments.
+	Comment *Comment `json:"comment,omitempty"`
+}
+
 type DownloadCountUpdate struct {
 	FileID        string `json:"fileID"`
 	DownloadCount int32  `json:"downloadCount"`
 }
 
+type FileConnection struct {
+	Edges      []*FileEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int32       `json:"totalCount"`
+}
+
+type FileEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *File  `json:"node"`
+}
+
 type FileFilterInput struct {
-	MimeTypes  []string `json:"mimeTypes,omitempty"`
-	MinSize    *int32   `json:"minSize,omitempty"`
-	MaxSize    *int32   `json:"maxSize,omitempty"`
-	StartDate  *string  `json:"startDate,omitempty"`
-	EndDate    *string  `json:"endDate,omitempty"`
-	Tags       []string `json:"tags,omitempty"`
-	UploaderID *string  `json:"uploaderID,omitempty"`
-	IsPublic   *bool    `json:"isPublic,omitempty"`
+	MimeTypes []string `json:"mimeTypes,omitempty"`
+	MinSize   *int32   `json:"minSize,omitempty"`
+	MaxSize   *int32   `json:"maxSize,omitempty"`
+	StartDate *string  `json:"startDate,omitempty"`
+	EndDate   *string  `json:"endDate,omitempty"`
+	// Only files with these tags: any of them, or all of them if tagMatch is ALL.
+	Tags       []string  `json:"tags,omitempty"`
+	TagMatch   *TagMatch `json:"tagMatch,omitempty"`
+	UploaderID *string   `json:"uploaderID,omitempty"`
+	IsPublic   *bool     `json:"isPublic,omitempty"`
+}
+
+// A file matching a search. snippet is set when the file's content matched: it is HTML-escaped
+// excerpts of the text, with the matching words wrapped in <mark> tags.
+type FileSearchResult struct {
+	File    *File   `json:"file"`
+	Rank    float64 `json:"rank"`
+	Snippet *string `json:"snippet,omitempty"`
+}
+
+type FileSort struct {
+	Field     FileSortField  `json:"field"`
+	Direction *SortDirection `json:"direction,omitempty"`
+}
+
+type FolderConnection struct {
+	Edges      []*FolderEdge `json:"edges"`
+	PageInfo   *PageInfo     `json:"pageInfo"`
+	TotalCount int32         `json:"totalCount"`
+}
+
+type FolderEdge struct {
+	Cursor string  `json:"cursor"`
+	Node   *Folder `json:"node"`
+}
+
+type FolderSort struct {
+	Field     FolderSortField `json:"field"`
+	Direction *SortDirection  `json:"direction,omitempty"`
+}
+
+// The files and folders one user has shared with the current user,
+// directly or through one of the current user's groups.
+type IncomingShares struct {
+	Sharer  *User     `json:"sharer"`
+	Files   []*File   `json:"files"`
+	Folders []*Folder `json:"folders"`
 }
 
 // Defines the mutations available in the API.
@@ -31,9 +135,39 @@ type Mutation struct {
 type NewFolder struct {
 	FolderName     string  `json:"folderName"`
 	ParentFolderID *string `json:"parentFolderID,omitempty"`
+	// Creates a top-level team folder in the organization. Subfolders of team folders
+	// always belong to the parent's organization.
+	OrganizationID *string `json:"organizationID,omitempty"`
+}
+
+// A file or folder the current user has shared. Exactly one of file and folder is set,
+// and exactly one of sharedWithUser and sharedWithGroup.
+type OutgoingShare struct {
+	CreatedAt       string  `json:"createdAt"`
+	File            *File   `json:"file,omitempty"`
+	Folder          *Folder `json:"folder,omitempty"`
+	SharedWithUser  *User   `json:"sharedWithUser,omitempty"`
+	SharedWithGroup *Group  `json:"sharedWithGroup,omitempty"`
+	PermissionLevel string  `json:"permissionLevel"`
+}
+
+// Connections return a page of items at a time: 50 unless first asks for another number, and
+// at most 200. Pass pageInfo.endCursor as after to get the next page. A cursor only works with
+// the sort order it was returned for.
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
 }
 
 // Defines the queries available in the API.
+//
+// Operations are limited in depth and complexity, with higher limits for signed-in users and
+// admins. Every field costs 1 plus the cost of its selections; list fields multiply the cost of
+// their selections by the page size they are asked for (first or limit), or by 50 for lists
+// without one. Operations over the limit fail with DEPTH_LIMIT_EXCEEDED or
+// COMPLEXITY_LIMIT_EXCEEDED. Automatic persisted queries are supported.
 type Query struct {
 }
 
@@ -44,12 +178,15 @@ type RegisterInput struct {
 	Password string `json:"password"`
 }
 
-type Root struct {
-	Files   []*File   `json:"files,omitempty"`
-	Folders []*Folder `json:"folders,omitempty"`
+// What a path resolves to: a folder, a file, or both if they share a name.
+type ResolvedPath struct {
+	Folder *Folder `json:"folder,omitempty"`
+	File   *File   `json:"file,omitempty"`
 }
 
 type StorageStatistics struct {
+	// Set when the statistics are for an organization's storage pool rather than a user's.
+	OrganizationID  *string `json:"organizationID,omitempty"`
 	UsedStorageKb   float64 `json:"usedStorageKB"`
 	SavedStorageKb  float64 `json:"savedStorageKB"`
 	PercentageSaved float64 `json:"percentageSaved"`
@@ -58,14 +195,765 @@ type StorageStatistics struct {
 type Subscription struct {
 }
 
+// A tag and the number of the current user's files that have it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int32  `json:"count"`
+}
+
+// Renames or moves a file. The target folder must be the user's own or a team folder of one of
+// their organizations. If the name is taken in the target folder, the change is rejected, the
+// name numbered as in "report (2).pdf", or the existing file replaced, depending on the
+// server's name collision policy. Uploads follow the same policy.
 type UpdateFile struct {
 	ID             string  `json:"id"`
 	FileName       *string `json:"fileName,omitempty"`
 	ParentFolderID *string `json:"parentFolderID,omitempty"`
 }
 
+// Renames or moves a folder. The target folder must be the user's own or a team folder of one
+// of their organizations, and can't be the folder itself or one of its subfolders. If the name
+// is taken in the target folder, the change is rejected or the name numbered, as in
+// "Reports (2)", depending on the server's name collision policy.
 type UpdateFolder struct {
 	ID             string  `json:"id"`
 	FolderName     *string `json:"folderName,omitempty"`
 	ParentFolderID *string `json:"parentFolderID,omitempty"`
 }
+
+// Input for the updateProfile mutation. Fields left null are not changed.
+// currentPassword is required when changing the email or the password.
+type UpdateProfileInput struct {
+	Username        *string `json:"username,omitempty"`
+	Email           *string `json:"email,omitempty"`
+	NewPassword     *string `json:"newPassword,omitempty"`
+	CurrentPassword *string `json:"currentPassword,omitempty"`
+}
+
+type UserConnection struct {
+	Edges      []*UserEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int32       `json:"totalCount"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
+}
+
+// A page of users, returned by the adminUsers query.
+type UserList struct {
+	Users      []*User `json:"users"`
+	TotalCount int32   `json:"totalCount"`
+}
+
+type UserSort struct {
+	Field     UserSortField  `json:"field"`
+	Direction *SortDirection `json:"direction,omitempty"`
+}
+
+type AccessRequestStatus string
+
+const (
+	AccessRequestStatusPending  AccessRequestStatus = "PENDING"
+	AccessRequestStatusApproved AccessRequestStatus = "APPROVED"
+	AccessRequestStatusDenied   AccessRequestStatus = "DENIED"
+)
+
+var AllAccessRequestStatus = []AccessRequestStatus{
+	AccessRequestStatusPending,
+	AccessRequestStatusApproved,
+	AccessRequestStatusDenied,
+}
+
+func (e AccessRequestStatus) IsValid() bool {
+	switch e {
+	case AccessRequestStatusPending, AccessRequestStatusApproved, AccessRequestStatusDenied:
+		return true
+	}
+	return false
+}
+
+func (e AccessRequestStatus) String() string {
+	return string(e)
+}
+
+func (e *AccessRequestStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AccessRequestStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AccessRequestStatus", str)
+	}
+	return nil
+}
+
+func (e AccessRequestStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AccessRequestStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AccessRequestStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ActivityAction string
+
+const (
+	ActivityActionCreated    ActivityAction = "CREATED"
+	ActivityActionUploaded   ActivityAction = "UPLOADED"
+	ActivityActionRenamed    ActivityAction = "RENAMED"
+	ActivityActionMoved      ActivityAction = "MOVED"
+	ActivityActionShared     ActivityAction = "SHARED"
+	ActivityActionUnshared   ActivityAction = "UNSHARED"
+	ActivityActionDownloaded ActivityAction = "DOWNLOADED"
+	ActivityActionDeleted    ActivityAction = "DELETED"
+)
+
+var AllActivityAction = []ActivityAction{
+	ActivityActionCreated,
+	ActivityActionUploaded,
+	ActivityActionRenamed,
+	ActivityActionMoved,
+	ActivityActionShared,
+	ActivityActionUnshared,
+	ActivityActionDownloaded,
+	ActivityActionDeleted,
+}
+
+func (e ActivityAction) IsValid() bool {
+	switch e {
+	case ActivityActionCreated, ActivityActionUploaded, ActivityActionRenamed, ActivityActionMoved, ActivityActionShared, ActivityActionUnshared, ActivityActionDownloaded, ActivityActionDeleted:
+		return true
+	}
+	return false
+}
+
+func (e ActivityAction) String() string {
+	return string(e)
+}
+
+func (e *ActivityAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ActivityAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ActivityAction", str)
+	}
+	return nil
+}
+
+func (e ActivityAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ActivityAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ActivityAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type CommentAction string
+
+const (
+	CommentActionCreated    CommentAction = "CREATED"
+	CommentActionUpdated    CommentAction = "UPDATED"
+	CommentActionDeleted    CommentAction = "DELETED"
+	CommentActionResolved   CommentAction = "RESOLVED"
+	CommentActionUnresolved CommentAction = "UNRESOLVED"
+)
+
+var AllCommentAction = []CommentAction{
+	CommentActionCreated,
+	CommentActionUpdated,
+	CommentActionDeleted,
+	CommentActionResolved,
+	CommentActionUnresolved,
+}
+
+func (e CommentAction) IsValid() bool {
+	switch e {
+	case CommentActionCreated, CommentActionUpdated, CommentActionDeleted, CommentActionResolved, CommentActionUnresolved:
+		return true
+	}
+	return false
+}
+
+func (e CommentAction) String() string {
+	return string(e)
+}
+
+func (e *CommentAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentAction", str)
+	}
+	return nil
+}
+
+func (e CommentAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CommentAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CommentAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type FileSortField string
+
+const (
+	FileSortFieldName          FileSortField = "NAME"
+	FileSortFieldSize          FileSortField = "SIZE"
+	FileSortFieldCreatedAt     FileSortField = "CREATED_AT"
+	FileSortFieldDownloadCount FileSortField = "DOWNLOAD_COUNT"
+	FileSortFieldMimeType      FileSortField = "MIME_TYPE"
+)
+
+var AllFileSortField = []FileSortField{
+	FileSortFieldName,
+	FileSortFieldSize,
+	FileSortFieldCreatedAt,
+	FileSortFieldDownloadCount,
+	FileSortFieldMimeType,
+}
+
+func (e FileSortField) IsValid() bool {
+	switch e {
+	case FileSortFieldName, FileSortFieldSize, FileSortFieldCreatedAt, FileSortFieldDownloadCount, FileSortFieldMimeType:
+		return true
+	}
+	return false
+}
+
+func (e FileSortField) String() string {
+	return string(e)
+}
+
+func (e *FileSortField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FileSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FileSortField", str)
+	}
+	return nil
+}
+
+func (e FileSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *FileSortField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e FileSortField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type FolderSortField string
+
+const (
+	FolderSortFieldName      FolderSortField = "NAME"
+	FolderSortFieldCreatedAt FolderSortField = "CREATED_AT"
+)
+
+var AllFolderSortField = []FolderSortField{
+	FolderSortFieldName,
+	FolderSortFieldCreatedAt,
+}
+
+func (e FolderSortField) IsValid() bool {
+	switch e {
+	case FolderSortFieldName, FolderSortFieldCreatedAt:
+		return true
+	}
+	return false
+}
+
+func (e FolderSortField) String() string {
+	return string(e)
+}
+
+func (e *FolderSortField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FolderSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FolderSortField", str)
+	}
+	return nil
+}
+
+func (e FolderSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *FolderSortField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e FolderSortField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationType string
+
+const (
+	NotificationTypeShareReceived   NotificationType = "SHARE_RECEIVED"
+	NotificationTypeAccessRevoked   NotificationType = "ACCESS_REVOKED"
+	NotificationTypeQuotaNearlyFull NotificationType = "QUOTA_NEARLY_FULL"
+	NotificationTypeAccessRequest   NotificationType = "ACCESS_REQUEST"
+	NotificationTypeCommentMention  NotificationType = "COMMENT_MENTION"
+	NotificationTypePublicDownload  NotificationType = "PUBLIC_DOWNLOAD"
+)
+
+var AllNotificationType = []NotificationType{
+	NotificationTypeShareReceived,
+	NotificationTypeAccessRevoked,
+	NotificationTypeQuotaNearlyFull,
+	NotificationTypeAccessRequest,
+	NotificationTypeCommentMention,
+	NotificationTypePublicDownload,
+}
+
+func (e NotificationType) IsValid() bool {
+	switch e {
+	case NotificationTypeShareReceived, NotificationTypeAccessRevoked, NotificationTypeQuotaNearlyFull, NotificationTypeAccessRequest, NotificationTypeCommentMention, NotificationTypePublicDownload:
+		return true
+	}
+	return false
+}
+
+func (e NotificationType) String() string {
+	return string(e)
+}
+
+func (e *NotificationType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationType", str)
+	}
+	return nil
+}
+
+func (e NotificationType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type OwnershipTransferStatus string
+
+const (
+	OwnershipTransferStatusPending   OwnershipTransferStatus = "PENDING"
+	OwnershipTransferStatusAccepted  OwnershipTransferStatus = "ACCEPTED"
+	OwnershipTransferStatusDeclined  OwnershipTransferStatus = "DECLINED"
+	OwnershipTransferStatusCancelled OwnershipTransferStatus = "CANCELLED"
+)
+
+var AllOwnershipTransferStatus = []OwnershipTransferStatus{
+	OwnershipTransferStatusPending,
+	OwnershipTransferStatusAccepted,
+	OwnershipTransferStatusDeclined,
+	OwnershipTransferStatusCancelled,
+}
+
+func (e OwnershipTransferStatus) IsValid() bool {
+	switch e {
+	case OwnershipTransferStatusPending, OwnershipTransferStatusAccepted, OwnershipTransferStatusDeclined, OwnershipTransferStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e OwnershipTransferStatus) String() string {
+	return string(e)
+}
+
+func (e *OwnershipTransferStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OwnershipTransferStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OwnershipTransferStatus", str)
+	}
+	return nil
+}
+
+func (e OwnershipTransferStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OwnershipTransferStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OwnershipTransferStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ShareInviteStatus string
+
+const (
+	ShareInviteStatusPending  ShareInviteStatus = "PENDING"
+	ShareInviteStatusAccepted ShareInviteStatus = "ACCEPTED"
+	ShareInviteStatusRevoked  ShareInviteStatus = "REVOKED"
+	ShareInviteStatusExpired  ShareInviteStatus = "EXPIRED"
+)
+
+var AllShareInviteStatus = []ShareInviteStatus{
+	ShareInviteStatusPending,
+	ShareInviteStatusAccepted,
+	ShareInviteStatusRevoked,
+	ShareInviteStatusExpired,
+}
+
+func (e ShareInviteStatus) IsValid() bool {
+	switch e {
+	case ShareInviteStatusPending, ShareInviteStatusAccepted, ShareInviteStatusRevoked, ShareInviteStatusExpired:
+		return true
+	}
+	return false
+}
+
+func (e ShareInviteStatus) String() string {
+	return string(e)
+}
+
+func (e *ShareInviteStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ShareInviteStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ShareInviteStatus", str)
+	}
+	return nil
+}
+
+func (e ShareInviteStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ShareInviteStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ShareInviteStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SortDirection string
+
+const (
+	SortDirectionAsc  SortDirection = "ASC"
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortDirection) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortDirection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+// How FileFilterInput.tags matches: files with ANY of the tags, or with ALL of them.
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "ANY"
+	TagMatchAll TagMatch = "ALL"
+)
+
+var AllTagMatch = []TagMatch{
+	TagMatchAny,
+	TagMatchAll,
+}
+
+func (e TagMatch) IsValid() bool {
+	switch e {
+	case TagMatchAny, TagMatchAll:
+		return true
+	}
+	return false
+}
+
+func (e TagMatch) String() string {
+	return string(e)
+}
+
+func (e *TagMatch) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TagMatch(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TagMatch", str)
+	}
+	return nil
+}
+
+func (e TagMatch) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *TagMatch) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e TagMatch) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+// Users are sorted by username (NAME) or by when they signed up (CREATED_AT).
+type UserSortField string
+
+const (
+	UserSortFieldName      UserSortField = "NAME"
+	UserSortFieldCreatedAt UserSortField = "CREATED_AT"
+)
+
+var AllUserSortField = []UserSortField{
+	UserSortFieldName,
+	UserSortFieldCreatedAt,
+}
+
+func (e UserSortField) IsValid() bool {
+	switch e {
+	case UserSortFieldName, UserSortFieldCreatedAt:
+		return true
+	}
+	return false
+}
+
+func (e UserSortField) String() string {
+	return string(e)
+}
+
+func (e *UserSortField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserSortField", str)
+	}
+	return nil
+}
+
+func (e UserSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *UserSortField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e UserSortField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusSucceeded,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSucceeded, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WebhookDeliveryStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
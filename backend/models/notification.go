@@ -0,0 +1,32 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// Notification is an in-app message for a user about something that happened to them
+// or their files. FileID, FolderID and ActorID point at what it is about and who caused it,
+// when that applies. Type holds a NotificationType value.
+type Notification struct {
+	BaseModel
+	UserID   uint       `gorm:"not null;index"`
+	User     User       `gorm:"foreignkey:UserID"`
+	Type     string     `gorm:"type:varchar(50);not null"`
+	Message  string     `gorm:"type:text;not null"`
+	ActorID  *uint      `gorm:"default:null"`
+	Actor    *User      `gorm:"foreignkey:ActorID;constraint:OnDelete:SET NULL"`
+	FileID   *uint      `gorm:"default:null;index"`
+	File     *File      `gorm:"foreignkey:FileID;constraint:OnDelete:SET NULL"`
+	FolderID *uint      `gorm:"default:null;index"`
+	Folder   *Folder    `gorm:"foreignkey:FolderID;constraint:OnDelete:SET NULL"`
+	ReadAt   *time.Time `gorm:"default:null;index"`
+}
+
+// NotificationPreference records whether a user wants notifications of a type.
+// Types without a preference row are enabled.
+type NotificationPreference struct {
+	BaseModel
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_notification_preference"`
+	User    User   `gorm:"foreignkey:UserID"`
+	Type    string `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_preference"`
+	Enabled bool   `gorm:"not null"`
+}
@@ -0,0 +1,56 @@
+// Package models defines the data structures used in the application.
+package models
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OrganizationRole defines the role a user has within an organization.
+type OrganizationRole string
+
+const (
+	// OrgRoleOwner can do everything, including managing admins and deleting the organization.
+	OrgRoleOwner OrganizationRole = "owner"
+	// OrgRoleAdmin can manage members and team folders.
+	OrgRoleAdmin OrganizationRole = "admin"
+	// OrgRoleMember can read and upload to team folders.
+	OrgRoleMember OrganizationRole = "member"
+)
+
+// UnmarshalGQL converts the GraphQL OrganizationRole enum (OWNER, ADMIN, MEMBER) into an OrganizationRole.
+func (r *OrganizationRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("OrganizationRole must be a string")
+	}
+	role := OrganizationRole(strings.ToLower(str))
+	if role != OrgRoleOwner && role != OrgRoleAdmin && role != OrgRoleMember {
+		return fmt.Errorf("%s is not a valid OrganizationRole", str)
+	}
+	*r = role
+	return nil
+}
+
+// MarshalGQL writes an OrganizationRole as its GraphQL enum value.
+func (r OrganizationRole) MarshalGQL(w io.Writer) {
+	io.WriteString(w, strconv.Quote(strings.ToUpper(string(r))))
+}
+
+// CanManage reports whether the role may manage members and team folders.
+func (r OrganizationRole) CanManage() bool {
+	return r == OrgRoleOwner || r == OrgRoleAdmin
+}
+
+// Organization represents a team of users sharing a storage pool.
+// Files in the organization's team folders are charged to the organization's
+// quota rather than to the uploader's.
+type Organization struct {
+	BaseModel
+	Name           string  `gorm:"type:varchar(255);not null"`
+	StorageQuotaKB float64 `gorm:"default:102400"`
+	UsedStorageKB  float64 `gorm:"default:0"`
+	SavedStorageKB float64 `gorm:"default:0"`
+}
@@ -0,0 +1,21 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// OwnershipTransfer is an offer from the owner of a file or folder to hand it over to
+// another user. Exactly one of FileID and FolderID is set. Nothing changes hands until
+// the recipient accepts; for a folder, the whole subtree the sender owns is transferred.
+type OwnershipTransfer struct {
+	BaseModel
+	FromUserID uint       `gorm:"not null;index"`
+	FromUser   User       `gorm:"foreignkey:FromUserID"`
+	ToUserID   uint       `gorm:"not null;index"`
+	ToUser     User       `gorm:"foreignkey:ToUserID"`
+	FileID     *uint      `gorm:"default:null;index"`
+	File       *File      `gorm:"foreignkey:FileID"`
+	FolderID   *uint      `gorm:"default:null;index"`
+	Folder     *Folder    `gorm:"foreignkey:FolderID"`
+	Status     string     `gorm:"type:varchar(20);not null;default:'PENDING';index"`
+	DecidedAt  *time.Time `gorm:"default:null"`
+}
@@ -0,0 +1,6 @@
+// Package models defines the data structures used in the application.
+package models
+
+// Root is the top level of the current user's file tree. Its files and folders are resolved
+// for the user making the request, when they are asked for.
+type Root struct{}
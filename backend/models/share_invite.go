@@ -0,0 +1,39 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// ShareInvite is a pending grant of access to a file or folder for an email address
+// that does not belong to a registered user yet. Exactly one of FileID and FolderID is set.
+// The invitee is emailed a signed token; when a user with the invited email accepts it,
+// the invite is turned into a FileSharing or FolderSharing row and marked as accepted.
+type ShareInvite struct {
+	BaseModel
+	Email           string     `gorm:"type:varchar(255);not null;index"`
+	InvitedByID     uint       `gorm:"not null;index"`
+	InvitedBy       User       `gorm:"foreignkey:InvitedByID"`
+	FileID          *uint      `gorm:"default:null;index"`
+	File            *File      `gorm:"foreignkey:FileID"`
+	FolderID        *uint      `gorm:"default:null;index"`
+	Folder          *Folder    `gorm:"foreignkey:FolderID"`
+	PermissionLevel string     `gorm:"type:varchar(50)"`
+	ExpiresAt       time.Time  `gorm:"not null"`
+	AcceptedAt      *time.Time `gorm:"default:null"`
+	AcceptedByID    *uint      `gorm:"default:null"`
+	AcceptedBy      *User      `gorm:"foreignkey:AcceptedByID;constraint:OnDelete:SET NULL"`
+	RevokedAt       *time.Time `gorm:"default:null"`
+}
+
+// Status returns the invite's current status.
+func (i *ShareInvite) Status() ShareInviteStatus {
+	switch {
+	case i.AcceptedAt != nil:
+		return ShareInviteStatusAccepted
+	case i.RevokedAt != nil:
+		return ShareInviteStatusRevoked
+	case time.Now().After(i.ExpiresAt):
+		return ShareInviteStatusExpired
+	default:
+		return ShareInviteStatusPending
+	}
+}
@@ -1,6 +1,14 @@
 // Package models defines the data structures used in the application.
 package models
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // UserRole defines the roles a user can have.
 type UserRole string
 
@@ -11,17 +19,43 @@ const (
 	RoleAdmin UserRole = "admin"
 )
 
+// UnmarshalGQL converts the GraphQL UserRole enum (USER, ADMIN) into a UserRole.
+func (r *UserRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("UserRole must be a string")
+	}
+	role := UserRole(strings.ToLower(str))
+	if role != RoleUser && role != RoleAdmin {
+		return fmt.Errorf("%s is not a valid UserRole", str)
+	}
+	*r = role
+	return nil
+}
+
+// MarshalGQL writes a UserRole as its GraphQL enum value (USER, ADMIN).
+func (r UserRole) MarshalGQL(w io.Writer) {
+	io.WriteString(w, strconv.Quote(strings.ToUpper(string(r))))
+}
+
 // User represents a user in the system.
 // This table stores user information, including authentication details,
 // storage quotas, and API rate limits.
 type User struct {
 	BaseModel
-	Username       string    `gorm:"type:varchar(255);unique;not null"`
-	Email          string    `gorm:"type:varchar(255);unique;not null"`
-	PasswordHash   string    `gorm:"type:varchar(255);not null"`
-	StorageQuotaKB float64   `gorm:"default:10240"`
-	UsedStorageKB  float64   `gorm:"default:0"`
-	SavedStorageKB float64   `gorm:"default:0"`
-	APIRateLimit   int       `gorm:"default:2"`
-	Role           UserRole  `gorm:"type:varchar(50);default:'user'"`
-}
\ No newline at end of file
+	Username       string   `gorm:"type:varchar(255);unique;not null"`
+	Email          string   `gorm:"type:varchar(255);unique;not null"`
+	PasswordHash   string   `gorm:"type:varchar(255);not null"`
+	StorageQuotaKB float64  `gorm:"default:10240"`
+	UsedStorageKB  float64  `gorm:"default:0"`
+	SavedStorageKB float64  `gorm:"default:0"`
+	APIRateLimit   int      `gorm:"default:2"`
+	Role           UserRole `gorm:"type:varchar(50);default:'user'"`
+	// SuspendedAt is set while an admin has suspended the account.
+	SuspendedAt *time.Time `gorm:"default:null"`
+	// TokenVersion is embedded in issued tokens; bumping it invalidates all of them.
+	TokenVersion int `gorm:"default:0"`
+	// DeletingAt is set once the user has asked for their account to be deleted. The account
+	// can't be used from then on, and the deletion is retried until it is complete.
+	DeletingAt *time.Time `gorm:"default:null;index"`
+}
@@ -0,0 +1,38 @@
+// Package models defines the data structures used in the application.
+package models
+
+import "time"
+
+// Webhook is an HTTP endpoint a user has registered to be told about file events.
+// A user's webhook receives events for files and folders they own or that land in
+// their folders; a global webhook, which only admins can register, receives every event.
+// If FolderID is set, only events in that folder are sent. EventTypes is a
+// comma-separated list of event types such as "file.uploaded,share.created".
+type Webhook struct {
+	BaseModel
+	UserID     uint   `gorm:"not null;index"`
+	User       User   `gorm:"foreignkey:UserID"`
+	URL        string `gorm:"type:text;not null"`
+	Secret     string `gorm:"type:varchar(64);not null"`
+	EventTypes string `gorm:"type:text;not null"`
+	// FolderID is deliberately not a foreign key: the webhook outlives the folder and simply stops matching.
+	FolderID *uint `gorm:"default:null;index"`
+	Global   bool  `gorm:"default:false"`
+	Active   bool  `gorm:"default:true"`
+}
+
+// WebhookDelivery is one attempt, with its retries, to send an event to a webhook.
+// Status holds a WebhookDeliveryStatus value.
+type WebhookDelivery struct {
+	BaseModel
+	WebhookID      uint       `gorm:"not null;index"`
+	Webhook        Webhook    `gorm:"foreignkey:WebhookID"`
+	EventType      string     `gorm:"type:varchar(50);not null"`
+	Payload        string     `gorm:"type:text;not null"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'PENDING';index"`
+	Attempts       int        `gorm:"default:0"`
+	NextAttemptAt  *time.Time `gorm:"default:null;index"`
+	LastStatusCode int        `gorm:"default:0"`
+	LastError      string     `gorm:"type:text"`
+	DeliveredAt    *time.Time `gorm:"default:null"`
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// This file centralizes the read-access rules for files and folders so that every
+// service checks access the same way. Admin overrides are left to the callers,
+// as not every operation grants them. Expired shares and expired public visibility
+// never grant access.
+
+// organizationIDsOf returns a subquery selecting the IDs of the organizations the user belongs to.
+func organizationIDsOf(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", userID)
+}
+
+// membershipOf returns the user's membership in the organization, or nil if they are not a member.
+func membershipOf(db *gorm.DB, organizationID uint, userID uint) *models.Membership {
+	var membership models.Membership
+	if err := db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).Error; err != nil {
+		return nil
+	}
+	return &membership
+}
+
+// groupIDsOf returns a subquery selecting the IDs of the groups the user belongs to.
+func groupIDsOf(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)
+}
+
+// activeShares restricts a query on a sharing table to shares that have not expired.
+func activeShares(query *gorm.DB) *gorm.DB {
+	return query.Where("(expires_at IS NULL OR expires_at > ?)", time.Now())
+}
+
+// isPubliclyVisible reports whether an item marked public still is, given when its
+// public visibility expires (nil meaning never).
+func isPubliclyVisible(isPublic bool, expiresAt *time.Time) bool {
+	return isPublic && (expiresAt == nil || expiresAt.After(time.Now()))
+}
+
+// sharedFileIDsFor returns a subquery selecting the IDs of files shared with the user directly.
+func sharedFileIDsFor(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.FileSharing{}).Scopes(activeShares).Select("file_id").Where("shared_with_user_id = ?", userID)
+}
+
+// groupSharedFileIDsFor returns a subquery selecting the IDs of files shared with a group the user belongs to.
+func groupSharedFileIDsFor(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.FileGroupSharing{}).Scopes(activeShares).Select("file_id").Where("group_id IN (?)", groupIDsOf(db, userID))
+}
+
+// sharedFolderIDsFor returns a subquery selecting the IDs of folders shared with the user directly.
+func sharedFolderIDsFor(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.FolderSharing{}).Scopes(activeShares).Select("folder_id").Where("shared_with_user_id = ?", userID)
+}
+
+// groupSharedFolderIDsFor returns a subquery selecting the IDs of folders shared with a group the user belongs to.
+func groupSharedFolderIDsFor(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.FolderGroupSharing{}).Scopes(activeShares).Select("folder_id").Where("group_id IN (?)", groupIDsOf(db, userID))
+}
+
+// sharedFolderTreeIDsFor returns a subquery selecting the IDs of the folders shared with the user
+// or one of their groups, and of every folder inside them.
+func sharedFolderTreeIDsFor(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Raw(`
+		WITH RECURSIVE shared AS (
+			SELECT id FROM folders WHERE id IN (?) OR id IN (?)
+			UNION
+			SELECT folders.id FROM folders JOIN shared ON folders.parent_folder_id = shared.id
+		)
+		SELECT id FROM shared`, sharedFolderIDsFor(db, userID), groupSharedFolderIDsFor(db, userID))
+}
+
+// readableFilesScope restricts a query on the files table to files the user may read:
+// their own files, files shared with them or with one of their groups, files in folders
+// shared that way, and files in their organizations' team folders.
+func readableFilesScope(db *gorm.DB, user *models.User) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("files.user_id = ? OR files.id IN (?) OR files.id IN (?) OR files.folder_id IN (?) OR files.organization_id IN (?)",
+			user.ID, sharedFileIDsFor(db, user.ID), groupSharedFileIDsFor(db, user.ID), sharedFolderTreeIDsFor(db, user.ID),
+			organizationIDsOf(db, user.ID))
+	}
+}
+
+// inSharedFolder reports whether the folder, or a folder above it, has been shared with the
+// user or one of their groups. Sharing a folder shares everything inside it.
+func inSharedFolder(db *gorm.DB, folderID uint, user *models.User) bool {
+	paths, err := FolderAncestors(db, []uint{folderID})
+	if err != nil || len(paths[folderID]) == 0 {
+		return false
+	}
+	folderIDs := make([]uint, 0, len(paths[folderID]))
+	for _, folder := range paths[folderID] {
+		folderIDs = append(folderIDs, folder.ID)
+	}
+
+	var count int64
+	db.Model(&models.FolderSharing{}).Scopes(activeShares).Where("folder_id IN ? AND shared_with_user_id = ?", folderIDs, user.ID).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	db.Model(&models.FolderGroupSharing{}).Scopes(activeShares).Where("folder_id IN ? AND group_id IN (?)", folderIDs, groupIDsOf(db, user.ID)).Count(&count)
+	return count > 0
+}
+
+// canReadFile reports whether the user may read the file: they own it, it is public,
+// it or a folder it is in has been shared with them or one of their groups, or it belongs
+// to one of their organizations.
+func canReadFile(db *gorm.DB, file *models.File, user *models.User) bool {
+	if file.UserID == user.ID || isPubliclyVisible(file.IsPublic, file.PublicExpiresAt) {
+		return true
+	}
+
+	var count int64
+	db.Model(&models.FileSharing{}).Scopes(activeShares).Where("file_id = ? AND shared_with_user_id = ?", file.ID, user.ID).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	db.Model(&models.FileGroupSharing{}).Scopes(activeShares).Where("file_id = ? AND group_id IN (?)", file.ID, groupIDsOf(db, user.ID)).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	if file.FolderID != nil && inSharedFolder(db, *file.FolderID, user) {
+		return true
+	}
+
+	return file.OrganizationID != nil && membershipOf(db, *file.OrganizationID, user.ID) != nil
+}
+
+// canReadFolder reports whether the user may read the folder: they own it, it is public,
+// it or a folder above it has been shared with them or one of their groups, or it is one of
+// their organizations' team folders.
+func canReadFolder(db *gorm.DB, folder *models.Folder, user *models.User) bool {
+	if folder.UserID == user.ID || isPubliclyVisible(folder.IsPublic, folder.PublicExpiresAt) {
+		return true
+	}
+
+	if inSharedFolder(db, folder.ID, user) {
+		return true
+	}
+
+	return folder.OrganizationID != nil && membershipOf(db, *folder.OrganizationID, user.ID) != nil
+}
+
+// canManageOrganization reports whether the user is an owner or admin of the organization.
+// A nil organization ID (personal storage) is never manageable through an organization.
+func canManageOrganization(db *gorm.DB, organizationID *uint, user *models.User) bool {
+	if organizationID == nil {
+		return false
+	}
+	membership := membershipOf(db, *organizationID, user.ID)
+	return membership != nil && membership.Role.CanManage()
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/database"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// accessRequestPermissions are the permission levels that can be requested. Shares only grant
+// read access, so that is all that can be asked for.
+var accessRequestPermissions = map[string]bool{"read": true}
+
+// AccessRequestService lets users ask the owner of a private file or folder for access.
+// Owners see pending requests in a query and over a subscription, and approve them
+// (which shares the item) or deny them. Requesters are told about the decision the same way.
+type AccessRequestService struct {
+	DB            *gorm.DB
+	RDB           *redis.Client
+	ShareService  *ShareService
+	Notifications *NotificationService
+}
+
+// NewAccessRequestService creates a new instance of AccessRequestService.
+func NewAccessRequestService(db *gorm.DB, rdb *redis.Client, shareService *ShareService, notifications *NotificationService) *AccessRequestService {
+	return &AccessRequestService{DB: db, RDB: rdb, ShareService: shareService, Notifications: notifications}
+}
+
+// RequestAccess creates a pending access request for a file or folder the user cannot read.
+// Exactly one of fileID and folderID must be given. If the user already has a pending
+// request for the item, that request is returned instead.
+func (s *AccessRequestService) RequestAccess(ctx context.Context, fileID *string, folderID *string, message *string, permission *string, user *models.User) (*models.AccessRequest, error) {
+	if (fileID == nil) == (folderID == nil) {
+		return nil, fmt.Errorf("exactly one of fileID and folderID is required")
+	}
+
+	permissionLevel := "read"
+	if permission != nil {
+		permissionLevel = strings.ToLower(strings.TrimSpace(*permission))
+		if !accessRequestPermissions[permissionLevel] {
+			return nil, fmt.Errorf("permission must be read")
+		}
+	}
+
+	request := &models.AccessRequest{
+		RequesterID:     user.ID,
+		PermissionLevel: permissionLevel,
+		Status:          string(models.AccessRequestStatusPending),
+	}
+	if message != nil {
+		request.Message = strings.TrimSpace(*message)
+	}
+
+	existing := s.DB.Where("requester_id = ? AND status = ?", user.ID, models.AccessRequestStatusPending)
+	var requestedFile *models.File
+	var requestedFolder *models.Folder
+	if fileID != nil {
+		id, err := strconv.ParseUint(*fileID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file ID")
+		}
+		var file models.File
+		if err := s.DB.First(&file, id).Error; err != nil {
+			return nil, fmt.Errorf("file not found")
+		}
+		if canReadFile(s.DB, &file, user) {
+			return nil, fmt.Errorf("you already have access to this file")
+		}
+		request.FileID = &file.ID
+		request.OwnerID = file.UserID
+		existing = existing.Where("file_id = ?", file.ID)
+		requestedFile = &file
+	} else {
+		id, err := strconv.ParseUint(*folderID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folder ID")
+		}
+		var folder models.Folder
+		if err := s.DB.First(&folder, id).Error; err != nil {
+			return nil, fmt.Errorf("folder not found")
+		}
+		if canReadFolder(s.DB, &folder, user) {
+			return nil, fmt.Errorf("you already have access to this folder")
+		}
+		request.FolderID = &folder.ID
+		request.OwnerID = folder.UserID
+		existing = existing.Where("folder_id = ?", folder.ID)
+		requestedFolder = &folder
+	}
+
+	var pending models.AccessRequest
+	if err := existing.First(&pending).Error; err == nil {
+		return &pending, nil
+	}
+
+	if err := s.DB.Create(request).Error; err != nil {
+		return nil, err
+	}
+	s.publishAccessRequest(request, request.OwnerID)
+	notification := resourceNotification(models.NotificationTypeAccessRequest, user, requestedFile, requestedFolder, "%s requested access to %s")
+	notification.UserID = request.OwnerID
+	s.Notifications.Notify(&notification)
+	return request, nil
+}
+
+// findOwnRequest loads a pending access request addressed to the user.
+func (s *AccessRequestService) findOwnRequest(requestID string, user *models.User) (*models.AccessRequest, error) {
+	id, err := strconv.ParseUint(requestID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access request ID")
+	}
+	var request models.AccessRequest
+	if err := s.DB.Preload("File").Preload("Folder").First(&request, "id = ? AND owner_id = ?", id, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("access request not found or access denied")
+	}
+	if request.Status != string(models.AccessRequestStatusPending) {
+		return nil, fmt.Errorf("this access request has already been %s", strings.ToLower(request.Status))
+	}
+	return &request, nil
+}
+
+// ApproveAccessRequest shares the requested item with the requester, until expiresAt
+// if given, and marks the request as approved. Only the owner can do this. An item made
+// public since the request was made can't be shared, and needs no share to be read, so
+// the request is approved without one.
+func (s *AccessRequestService) ApproveAccessRequest(ctx context.Context, requestID string, expiresAt *string, user *models.User) (*models.AccessRequest, error) {
+	request, err := s.findOwnRequest(requestID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	requesterID := strconv.FormatUint(uint64(request.RequesterID), 10)
+	if request.File != nil && !request.File.IsPublic {
+		if _, err := s.ShareService.ShareFileWithUser(ctx, strconv.FormatUint(uint64(request.File.ID), 10), requesterID, expiresAt, user); err != nil {
+			return nil, err
+		}
+	} else if request.Folder != nil && !request.Folder.IsPublic {
+		if _, err := s.ShareService.ShareFolderWithUser(ctx, strconv.FormatUint(uint64(request.Folder.ID), 10), requesterID, expiresAt, user); err != nil {
+			return nil, err
+		}
+	}
+
+	notification := resourceNotification(models.NotificationTypeAccessRequest, user, request.File, request.Folder, "%s approved your request for access to %s")
+	notification.UserID = request.RequesterID
+	return s.decide(request, models.AccessRequestStatusApproved, &notification)
+}
+
+// DenyAccessRequest marks a request as denied. Only the owner can do this.
+func (s *AccessRequestService) DenyAccessRequest(ctx context.Context, requestID string, user *models.User) (*models.AccessRequest, error) {
+	request, err := s.findOwnRequest(requestID, user)
+	if err != nil {
+		return nil, err
+	}
+	// The item isn't named, as the requester can't see it.
+	return s.decide(request, models.AccessRequestStatusDenied, &models.Notification{
+		UserID:  request.RequesterID,
+		Type:    string(models.NotificationTypeAccessRequest),
+		Message: fmt.Sprintf("%s denied your request for access", user.Username),
+		ActorID: &user.ID,
+	})
+}
+
+// decide records the owner's decision and tells the requester about it with notification.
+func (s *AccessRequestService) decide(request *models.AccessRequest, status models.AccessRequestStatus, notification *models.Notification) (*models.AccessRequest, error) {
+	now := time.Now()
+	if err := s.DB.Model(request).Updates(map[string]interface{}{
+		"status":     string(status),
+		"decided_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	request.Status = string(status)
+	request.DecidedAt = &now
+	s.publishAccessRequest(request, request.RequesterID)
+	s.Notifications.Notify(notification)
+	return request, nil
+}
+
+// ListIncoming returns the access requests addressed to the user, newest first,
+// optionally filtered by status.
+func (s *AccessRequestService) ListIncoming(ctx context.Context, status *models.AccessRequestStatus, user *models.User) ([]*models.AccessRequest, error) {
+	db := s.DB.Where("owner_id = ?", user.ID)
+	if status != nil {
+		db = db.Where("status = ?", string(*status))
+	}
+	var requests []*models.AccessRequest
+	err := db.Order("id DESC").Find(&requests).Error
+	return requests, err
+}
+
+// ListOutgoing returns the access requests the user has made, newest first.
+func (s *AccessRequestService) ListOutgoing(ctx context.Context, user *models.User) ([]*models.AccessRequest, error) {
+	var requests []*models.AccessRequest
+	err := s.DB.Where("requester_id = ?", user.ID).Order("id DESC").Find(&requests).Error
+	return requests, err
+}
+
+// CanSeeFile reports whether the user may see the file a request is for: the owner the request
+// was made to can, and anyone else only once they can read the file, so that a requester doesn't
+// learn the name of a private file before being let in.
+func (s *AccessRequestService) CanSeeFile(request *models.AccessRequest, file *models.File, user *models.User) bool {
+	return request.OwnerID == user.ID || canReadFile(s.DB, file, user)
+}
+
+// CanSeeFolder is CanSeeFile for the folder a request is for.
+func (s *AccessRequestService) CanSeeFolder(request *models.AccessRequest, folder *models.Folder, user *models.User) bool {
+	return request.OwnerID == user.ID || canReadFolder(s.DB, folder, user)
+}
+
+// publishAccessRequest notifies a user's subscribers about a new or decided request.
+func (s *AccessRequestService) publishAccessRequest(request *models.AccessRequest, userID uint) {
+	payload, err := json.Marshal(request.ID)
+	if err != nil {
+		return
+	}
+	channel := fmt.Sprintf("access_requests_%d", userID)
+	if err := s.RDB.Publish(database.Ctx, channel, payload).Err(); err != nil {
+		log.Printf("Error publishing access request %d to user %d: %v", request.ID, userID, err)
+	}
+}
+
+// SubscribeToAccessRequests streams new requests addressed to the user, and decisions
+// on requests the user has made.
+func (s *AccessRequestService) SubscribeToAccessRequests(ctx context.Context, user *models.User) (<-chan *models.AccessRequest, error) {
+	ch := make(chan *models.AccessRequest, 1)
+	channel := fmt.Sprintf("access_requests_%d", user.ID)
+	pubsub := s.RDB.Subscribe(ctx, channel)
+
+	go func() {
+		defer pubsub.Close()
+		defer close(ch)
+
+		for {
+			select {
+			case msg, ok := <-pubsub.Channel():
+				if !ok {
+					return
+				}
+				var requestID uint
+				if err := json.Unmarshal([]byte(msg.Payload), &requestID); err != nil {
+					continue
+				}
+				var request models.AccessRequest
+				if err := s.DB.First(&request, requestID).Error; err == nil {
+					ch <- &request
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+)
+
+// newTestAccessRequestService returns an AccessRequestService on an empty database, sharing
+// through a ShareService on the same database.
+func newTestAccessRequestService(t *testing.T) *AccessRequestService {
+	t.Helper()
+	db := testdb.Open(t, &models.User{}, &models.Organization{}, &models.Membership{}, &models.DeduplicatedContent{},
+		&models.Folder{}, &models.File{}, &models.FileSharing{}, &models.FolderSharing{}, &models.Group{},
+		&models.GroupMember{}, &models.FileGroupSharing{}, &models.FolderGroupSharing{}, &models.AccessRequest{},
+		&models.Notification{}, &models.NotificationPreference{}, &models.Webhook{}, &models.WebhookDelivery{},
+		&models.Activity{})
+	rdb := newTestRedis(t)
+	notifications := &NotificationService{DB: db, RDB: rdb}
+	shares := &ShareService{DB: db, Notifications: notifications, Webhooks: &WebhookService{DB: db}, Activity: &ActivityService{DB: db}}
+	return &AccessRequestService{DB: db, RDB: rdb, ShareService: shares, Notifications: notifications}
+}
+
+// notificationMessages returns the messages of the notifications of a type a user got.
+func notificationMessages(t *testing.T, s *AccessRequestService, user *models.User, kind models.NotificationType) []string {
+	t.Helper()
+	var messages []string
+	if err := s.DB.Model(&models.Notification{}).Where("user_id = ? AND type = ?", user.ID, string(kind)).
+		Order("id").Pluck("message", &messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestAccessRequestItemVisibility(t *testing.T) {
+	s := newTestAccessRequestService(t)
+	db := s.DB
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	salaries := createTestFile(t, db, alice, "salaries.xlsx", nil)
+	hr := createTestFolder(t, db, alice, "HR", nil)
+	fileRequest := &models.AccessRequest{RequesterID: bob.ID, OwnerID: alice.ID, FileID: &salaries.ID, PermissionLevel: "read"}
+	folderRequest := &models.AccessRequest{RequesterID: bob.ID, OwnerID: alice.ID, FolderID: &hr.ID, PermissionLevel: "read"}
+	create(t, db, fileRequest, folderRequest)
+
+	if !s.CanSeeFile(fileRequest, salaries, alice) || !s.CanSeeFolder(folderRequest, hr, alice) {
+		t.Error("the owner can't see the items requested from them")
+	}
+	if s.CanSeeFile(fileRequest, salaries, bob) || s.CanSeeFolder(folderRequest, hr, bob) {
+		t.Error("the requester can see the items before being given access")
+	}
+
+	// Once the owner shares the items, the requester sees them.
+	create(t, db,
+		&models.FileSharing{FileID: salaries.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"},
+		&models.FolderSharing{FolderID: hr.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"},
+	)
+	if !s.CanSeeFile(fileRequest, salaries, bob) || !s.CanSeeFolder(folderRequest, hr, bob) {
+		t.Error("the requester can't see the items they were given access to")
+	}
+	if s.CanSeeFile(fileRequest, salaries, carol) || s.CanSeeFolder(folderRequest, hr, carol) {
+		t.Error("another user can see the requested items")
+	}
+}
+
+func TestRequestAccess(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAccessRequestService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	salaries := createTestFile(t, s.DB, alice, "salaries.xlsx", nil)
+
+	request, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, nil, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.OwnerID != alice.ID || request.PermissionLevel != "read" || request.Status != string(models.AccessRequestStatusPending) {
+		t.Errorf("got a request to user %d for %q in status %s, want a pending read request to alice",
+			request.OwnerID, request.PermissionLevel, request.Status)
+	}
+	if got := notificationMessages(t, s, alice, models.NotificationTypeAccessRequest); len(got) != 1 {
+		t.Errorf("alice got %d access request notifications, want 1", len(got))
+	}
+
+	// Asking again returns the pending request rather than making another.
+	again, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, nil, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != request.ID {
+		t.Errorf("asking again made request %d, want the pending request %d", again.ID, request.ID)
+	}
+	var count int64
+	s.DB.Model(&models.AccessRequest{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d requests stored, want 1", count)
+	}
+
+	// Only read access can be asked for, as that is all a share grants.
+	write := "write"
+	if _, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, &write, bob); err == nil {
+		t.Error("asking for write access succeeded")
+	}
+
+	// Nobody can ask for what they can already read.
+	if _, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, nil, alice); err == nil || !strings.Contains(err.Error(), "already have access") {
+		t.Errorf("the owner asking for access: got %v, want an already have access error", err)
+	}
+	create(t, s.DB, &models.FileSharing{FileID: salaries.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"})
+	if _, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, nil, bob); err == nil || !strings.Contains(err.Error(), "already have access") {
+		t.Errorf("asking for a file shared with you: got %v, want an already have access error", err)
+	}
+}
+
+func TestApproveAccessRequest(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAccessRequestService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	carol := createTestUser(t, s.DB, "carol")
+	hr := createTestFolder(t, s.DB, alice, "HR", nil)
+	request, err := s.RequestAccess(ctx, nil, apiID(hr.ID), nil, nil, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the owner the request was made to can approve it.
+	if _, err := s.ApproveAccessRequest(ctx, *apiID(request.ID), nil, carol); err == nil {
+		t.Error("another user approved the request")
+	}
+
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	expiry := expiresAt.Format(time.RFC3339)
+	approved, err := s.ApproveAccessRequest(ctx, *apiID(request.ID), &expiry, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != string(models.AccessRequestStatusApproved) || approved.DecidedAt == nil {
+		t.Errorf("the request is %s, decided at %v, want approved now", approved.Status, approved.DecidedAt)
+	}
+	var share models.FolderSharing
+	if err := s.DB.First(&share, "folder_id = ? AND shared_with_user_id = ?", hr.ID, bob.ID).Error; err != nil {
+		t.Fatalf("no share was created: %v", err)
+	}
+	if share.PermissionLevel != "read" || share.ExpiresAt == nil || !share.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("shared %q until %v, want read until %v", share.PermissionLevel, share.ExpiresAt, expiresAt)
+	}
+	if !canReadFolder(s.DB, hr, bob) {
+		t.Error("bob can't read the folder after the request was approved")
+	}
+	want := []string{"alice approved your request for access to the folder HR"}
+	if got := notificationMessages(t, s, bob, models.NotificationTypeAccessRequest); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("bob was notified %q, want %q", got, want)
+	}
+
+	// A decided request can't be decided again.
+	if _, err := s.DenyAccessRequest(ctx, *apiID(request.ID), alice); err == nil {
+		t.Error("denied a request that was already approved")
+	}
+}
+
+func TestApproveAccessRequestForPublicItem(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAccessRequestService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	flyer := createTestFile(t, s.DB, alice, "flyer.pdf", nil)
+	request, err := s.RequestAccess(ctx, apiID(flyer.ID), nil, nil, nil, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file was made public after bob asked for it, so it can't be shared but bob can read it.
+	if err := s.DB.Model(flyer).Update("is_public", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	approved, err := s.ApproveAccessRequest(ctx, *apiID(request.ID), nil, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != string(models.AccessRequestStatusApproved) {
+		t.Errorf("the request is %s, want approved", approved.Status)
+	}
+	var count int64
+	s.DB.Model(&models.FileSharing{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d shares created for a public file, want none", count)
+	}
+}
+
+func TestDenyAccessRequest(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAccessRequestService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	salaries := createTestFile(t, s.DB, alice, "salaries.xlsx", nil)
+	request, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, nil, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denied, err := s.DenyAccessRequest(ctx, *apiID(request.ID), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denied.Status != string(models.AccessRequestStatusDenied) || denied.DecidedAt == nil {
+		t.Errorf("the request is %s, decided at %v, want denied now", denied.Status, denied.DecidedAt)
+	}
+	if canReadFile(s.DB, salaries, bob) {
+		t.Error("bob can read the file after the request was denied")
+	}
+
+	// Bob hears about the decision without learning the file's name.
+	got := notificationMessages(t, s, bob, models.NotificationTypeAccessRequest)
+	if len(got) != 1 || strings.Contains(got[0], salaries.FileName) {
+		t.Errorf("bob was notified %q, want one notification that doesn't name the file", got)
+	}
+
+	// Once the request is decided, bob can ask again.
+	again, err := s.RequestAccess(ctx, apiID(salaries.ID), nil, nil, nil, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID == request.ID {
+		t.Error("asking again after a denial returned the denied request")
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// Activity pages hold DefaultActivityPageSize entries unless a smaller page is asked for,
+// and never more than MaxActivityPageSize.
+const (
+	DefaultActivityPageSize = 20
+	MaxActivityPageSize     = 100
+)
+
+// ActivityService keeps the activity feed: an append-only log of what users do to files
+// and folders. Owners, and the owners and admins of an item's organization, can read the
+// history of an item.
+type ActivityService struct {
+	DB *gorm.DB
+}
+
+// NewActivityService creates a new instance of ActivityService.
+func NewActivityService(db *gorm.DB) *ActivityService {
+	return &ActivityService{DB: db}
+}
+
+// RecordFile appends an activity about a file. Failures are logged rather than returned,
+// so that recording never fails the operation being recorded.
+func (s *ActivityService) RecordFile(action models.ActivityAction, file *models.File, actor *models.User, details string) {
+	s.record(&models.Activity{
+		OwnerID:        file.UserID,
+		Action:         string(action),
+		FileID:         &file.ID,
+		ParentFolderID: file.FolderID,
+		ItemName:       file.FileName,
+		Details:        details,
+	}, actor)
+}
+
+// RecordFolder appends an activity about a folder.
+func (s *ActivityService) RecordFolder(action models.ActivityAction, folder *models.Folder, actor *models.User, details string) {
+	s.record(&models.Activity{
+		OwnerID:        folder.UserID,
+		Action:         string(action),
+		FolderID:       &folder.ID,
+		ParentFolderID: folder.ParentFolderID,
+		ItemName:       folder.FolderName,
+		Details:        details,
+	}, actor)
+}
+
+// RecordShare appends a SHARED or UNSHARED activity about a file or folder being shared
+// with, or unshared from, a user or a group.
+func (s *ActivityService) RecordShare(action models.ActivityAction, file *models.File, folder *models.Folder, userID *uint, groupID *uint, actor *models.User) {
+	entry := &models.Activity{Action: string(action), TargetUserID: userID, TargetGroupID: groupID}
+	if file != nil {
+		entry.OwnerID = file.UserID
+		entry.FileID = &file.ID
+		entry.ParentFolderID = file.FolderID
+		entry.ItemName = file.FileName
+	} else {
+		entry.OwnerID = folder.UserID
+		entry.FolderID = &folder.ID
+		entry.ParentFolderID = folder.ParentFolderID
+		entry.ItemName = folder.FolderName
+	}
+	s.record(entry, actor)
+}
+
+// record stores an activity caused by actor, or by the system if actor is nil.
+func (s *ActivityService) record(entry *models.Activity, actor *models.User) {
+	if actor != nil {
+		entry.ActorID = &actor.ID
+	}
+	if err := s.DB.Create(entry).Error; err != nil {
+		log.Printf("Failed to record %s activity on %q: %v", entry.Action, entry.ItemName, err)
+	}
+}
+
+// FileActivity returns a page of the history of a file, newest first.
+func (s *ActivityService) FileActivity(ctx context.Context, file *models.File, first *int32, after *string, user *models.User) (*models.ActivityPage, error) {
+	if file.UserID != user.ID && !canManageOrganization(s.DB, file.OrganizationID, user) {
+		return nil, fmt.Errorf("access denied: only the owner can see a file's activity")
+	}
+	return s.page(s.DB.Where("file_id = ?", file.ID), first, after)
+}
+
+// FolderActivity returns a page of the history of a folder and of everything currently in
+// it, at any depth, newest first.
+func (s *ActivityService) FolderActivity(ctx context.Context, folder *models.Folder, first *int32, after *string, user *models.User) (*models.ActivityPage, error) {
+	if folder.UserID != user.ID && !canManageOrganization(s.DB, folder.OrganizationID, user) {
+		return nil, fmt.Errorf("access denied: only the owner can see a folder's activity")
+	}
+	folderIDs, err := folderSubtreeIDs(s.DB, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.page(s.DB.Where("folder_id IN ? OR parent_folder_id IN ?", folderIDs, folderIDs), first, after)
+}
+
+// MyActivity returns a page of the activity on the user's items and of what the user did
+// to other users' items, newest first.
+func (s *ActivityService) MyActivity(ctx context.Context, first *int32, after *string, user *models.User) (*models.ActivityPage, error) {
+	return s.page(s.DB.Where("owner_id = ? OR actor_id = ?", user.ID, user.ID), first, after)
+}
+
+// page runs an activity query for the page of at most first entries after the given cursor.
+func (s *ActivityService) page(query *gorm.DB, first *int32, after *string) (*models.ActivityPage, error) {
+	pageSize := DefaultActivityPageSize
+	if first != nil && *first > 0 {
+		pageSize = int(*first)
+		if pageSize > MaxActivityPageSize {
+			pageSize = MaxActivityPageSize
+		}
+	}
+	if after != nil && *after != "" {
+		afterID, err := decodeActivityCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", afterID)
+	}
+
+	// Fetch one extra entry to learn whether there is another page.
+	var entries []*models.Activity
+	if err := query.Order("id DESC").Limit(pageSize + 1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	page := &models.ActivityPage{Entries: entries, HasMore: len(entries) > pageSize}
+	if page.HasMore {
+		page.Entries = entries[:pageSize]
+	}
+	if len(page.Entries) > 0 {
+		cursor := encodeActivityCursor(page.Entries[len(page.Entries)-1].ID)
+		page.EndCursor = &cursor
+	}
+	return page, nil
+}
+
+// encodeActivityCursor returns the opaque cursor pointing just past an activity.
+func encodeActivityCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("activity:" + strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodeActivityCursor returns the ID of the activity a cursor points just past.
+func decodeActivityCursor(cursor string) (uint, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	idPart, ok := strings.CutPrefix(string(decoded), "activity:")
+	if !ok {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return uint(id), nil
+}
+
+// folderSubtreeIDs returns the IDs of a folder and of every folder below it.
+func folderSubtreeIDs(db *gorm.DB, folderID uint) ([]uint, error) {
+	folderIDs := []uint{folderID}
+	for level := []uint{folderID}; len(level) > 0; {
+		var children []uint
+		if err := db.Model(&models.Folder{}).Where("parent_folder_id IN ?", level).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		folderIDs = append(folderIDs, children...)
+		level = children
+	}
+	return folderIDs, nil
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// AdminService provides user management operations for administrators.
+// Every change it makes is recorded in the audit trail.
+// Callers are responsible for checking that the acting user is an admin.
+type AdminService struct {
+	DB          *gorm.DB
+	AuthService *AuthService
+	Audit       *AuditService
+}
+
+// NewAdminService creates a new instance of AdminService.
+func NewAdminService(db *gorm.DB, authService *AuthService, audit *AuditService) *AdminService {
+	return &AdminService{DB: db, AuthService: authService, Audit: audit}
+}
+
+// Admin lists return DefaultAdminPageSize items unless limit asks for fewer or more, and
+// never more than MaxAdminPageSize.
+const (
+	DefaultAdminPageSize = 50
+	MaxAdminPageSize     = 200
+)
+
+// pageBounds applies defaults and limits to optional limit/offset arguments.
+func pageBounds(limit *int32, offset *int32) (int, int) {
+	l, o := DefaultAdminPageSize, 0
+	if limit != nil && *limit > 0 {
+		l = int(*limit)
+	}
+	if l > MaxAdminPageSize {
+		l = MaxAdminPageSize
+	}
+	if offset != nil && *offset > 0 {
+		o = int(*offset)
+	}
+	return l, o
+}
+
+// ListUsers returns a page of users, optionally filtered by a username or email
+// substring, along with the total number of matching users.
+func (s *AdminService) ListUsers(ctx context.Context, query string, limit *int32, offset *int32) (*models.UserList, error) {
+	db := s.DB.Model(&models.User{})
+	if query != "" {
+		searchQuery := "%" + query + "%"
+		db = db.Where("username ILIKE ? OR email ILIKE ?", searchQuery, searchQuery)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	l, o := pageBounds(limit, offset)
+	var users []*models.User
+	if err := db.Order("id").Limit(l).Offset(o).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.UserList{Users: users, TotalCount: int32(total)}, nil
+}
+
+// ListAuditLog returns a page of the audit trail entries matching the filter, newest first.
+func (s *AdminService) ListAuditLog(ctx context.Context, filter *models.AuditLogFilter, limit *int32, offset *int32) (*models.AuditLogList, error) {
+	l, o := pageBounds(limit, offset)
+	entries, total, err := s.Audit.List(ctx, filter, l, o)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuditLogList{Entries: entries, TotalCount: int32(total)}, nil
+}
+
+// ExportAuditLog returns the audit trail entries matching the filter as JSON Lines.
+// The export itself is recorded in the audit trail.
+func (s *AdminService) ExportAuditLog(ctx context.Context, admin *models.User, filter *models.AuditLogFilter) (string, error) {
+	export, err := s.Audit.Export(ctx, filter)
+	if err != nil {
+		return "", err
+	}
+	s.Audit.Record(ctx, admin, "audit_log.exported", "", 0, map[string]interface{}{
+		"filter": filter,
+	})
+	return export, nil
+}
+
+// VerifyAuditLog checks the hash chain of the whole audit trail.
+func (s *AdminService) VerifyAuditLog(ctx context.Context) (*models.AuditLogVerification, error) {
+	return s.Audit.Verify(ctx)
+}
+
+// findUser loads the user with the given string ID.
+func (s *AdminService) findUser(userID string) (*models.User, error) {
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	var user models.User
+	if err := s.DB.First(&user, uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserQuota changes a user's storage quota.
+func (s *AdminService) SetUserQuota(ctx context.Context, admin *models.User, userID string, quotaKB float64) (*models.User, error) {
+	if quotaKB < 0 {
+		return nil, fmt.Errorf("storage quota cannot be negative")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := user.StorageQuotaKB
+	if err := s.DB.Model(user).Update("storage_quota_kb", quotaKB).Error; err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, admin, "user.quota_changed", "user", user.ID, map[string]interface{}{
+		"from": previous,
+		"to":   quotaKB,
+	})
+	return user, nil
+}
+
+// SetUserRateLimit changes a user's API rate limit (requests per second).
+func (s *AdminService) SetUserRateLimit(ctx context.Context, admin *models.User, userID string, limit int) (*models.User, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("API rate limit must be at least 1")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := user.APIRateLimit
+	if err := s.DB.Model(user).Update("api_rate_limit", limit).Error; err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, admin, "user.rate_limit_changed", "user", user.ID, map[string]interface{}{
+		"from": previous,
+		"to":   limit,
+	})
+	return user, nil
+}
+
+// SetUserRole promotes or demotes a user. Admins cannot change their own role,
+// which also guarantees that at least one admin always remains.
+func (s *AdminService) SetUserRole(ctx context.Context, admin *models.User, userID string, role models.UserRole) (*models.User, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == admin.ID {
+		return nil, fmt.Errorf("admins cannot change their own role")
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	previous := user.Role
+	if err := s.DB.Model(user).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, admin, "user.role_changed", "user", user.ID, map[string]interface{}{
+		"from": previous,
+		"to":   role,
+	})
+	return user, nil
+}
+
+// SuspendUser blocks a user from logging in and revokes their existing tokens.
+func (s *AdminService) SuspendUser(ctx context.Context, admin *models.User, userID string, reason string) (*models.User, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == admin.ID {
+		return nil, fmt.Errorf("admins cannot suspend themselves")
+	}
+	if user.SuspendedAt != nil {
+		return user, nil
+	}
+
+	now := time.Now()
+	if err := s.DB.Model(user).Updates(map[string]interface{}{
+		"suspended_at":  now,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error; err != nil {
+		return nil, err
+	}
+	user.SuspendedAt = &now
+	s.Audit.Record(ctx, admin, "user.suspended", "user", user.ID, map[string]interface{}{
+		"reason": reason,
+	})
+	return user, nil
+}
+
+// ReactivateUser lifts a suspension.
+func (s *AdminService) ReactivateUser(ctx context.Context, admin *models.User, userID string) (*models.User, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt == nil {
+		return user, nil
+	}
+
+	if err := s.DB.Model(user).Update("suspended_at", nil).Error; err != nil {
+		return nil, err
+	}
+	user.SuspendedAt = nil
+	s.Audit.Record(ctx, admin, "user.reactivated", "user", user.ID, nil)
+	return user, nil
+}
+
+// ForceLogout revokes every token issued to a user so far.
+func (s *AdminService) ForceLogout(ctx context.Context, admin *models.User, userID string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DB.Model(user).Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	s.Audit.Record(ctx, admin, "user.forced_logout", "user", user.ID, nil)
+	return nil
+}
+
+// ImpersonateUser issues a short-lived, read-only token that lets an admin see
+// the application exactly as the given user does.
+func (s *AdminService) ImpersonateUser(ctx context.Context, admin *models.User, userID string) (string, *models.User, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if user.ID == admin.ID {
+		return "", nil, fmt.Errorf("admins cannot impersonate themselves")
+	}
+
+	token, err := s.AuthService.IssueToken(ctx, user, &Impersonation{AdminID: admin.ID, ReadOnly: true})
+	if err != nil {
+		return "", nil, err
+	}
+	s.Audit.Record(ctx, admin, "user.impersonated", "user", user.ID, map[string]interface{}{
+		"readOnly":         true,
+		"expiresInSeconds": int(impersonationTokenTTL.Seconds()),
+	})
+	return token, user, nil
+}
+
+// UnlockLogin clears login lockouts for an email address and/or a client IP.
+func (s *AdminService) UnlockLogin(ctx context.Context, admin *models.User, email string, clientIP string) error {
+	if err := s.AuthService.Throttle.Unlock(ctx, email, clientIP); err != nil {
+		return err
+	}
+	s.Audit.Record(ctx, admin, "login.unlocked", "", 0, map[string]interface{}{
+		"email": email,
+		"ip":    clientIP,
+	})
+	return nil
+}
+
+// SetOrganizationQuota changes the storage quota of an organization's shared pool.
+func (s *AdminService) SetOrganizationQuota(ctx context.Context, admin *models.User, organizationID string, quotaKB float64) (*models.Organization, error) {
+	if quotaKB < 0 {
+		return nil, fmt.Errorf("storage quota cannot be negative")
+	}
+	orgID, err := parseOrganizationID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	var organization models.Organization
+	if err := s.DB.First(&organization, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, err
+	}
+
+	previous := organization.StorageQuotaKB
+	if err := s.DB.Model(&organization).Update("storage_quota_kb", quotaKB).Error; err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, admin, "organization.quota_changed", "organization", organization.ID, map[string]interface{}{
+		"from": previous,
+		"to":   quotaKB,
+	})
+	return &organization, nil
+}
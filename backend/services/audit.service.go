@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// auditChainLockKey is the Postgres advisory lock taken while appending to the audit trail,
+// so that concurrent entries are chained one after the other.
+const auditChainLockKey = 7_361_021
+
+// auditBatchSize is how many entries are loaded at a time when exporting or verifying the trail.
+const auditBatchSize = 500
+
+// AuditService records security-relevant actions in an append-only, hash-chained audit trail.
+type AuditService struct {
+	DB *gorm.DB
+}
+
+// NewAuditService creates a new instance of AuditService.
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{DB: db}
+}
+
+// Record appends an entry to the audit trail, chained onto the latest entry.
+//
+// Inputs:
+// - ctx: The context for the request.
+// - actor: The user performing the action, or nil for system actions and anonymous login attempts.
+// - action: A short identifier for the action, e.g. "user.quota_changed".
+// - targetType: The kind of object acted upon, e.g. "user".
+// - targetID: The ID of the object acted upon, or 0 if there is none.
+// - details: Additional data about the action, stored as JSON.
+//
+// Outputs:
+// - An error if the entry could not be stored.
+func (s *AuditService) Record(ctx context.Context, actor *models.User, action string, targetType string, targetID uint, details map[string]interface{}) error {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		Details:    "{}",
+	}
+	if actor != nil {
+		entry.ActorID = &actor.ID
+	}
+	if targetID != 0 {
+		entry.TargetID = &targetID
+	}
+	if details != nil {
+		payload, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(payload)
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey).Error; err != nil {
+			return err
+		}
+		var last models.AuditLog
+		if err := tx.Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		entry.PrevHash = last.Hash
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		// Hash the entry as stored, since the database normalizes the JSON details and the timestamp.
+		var stored models.AuditLog
+		if err := tx.First(&stored, entry.ID).Error; err != nil {
+			return err
+		}
+		entry.Hash = auditEntryHash(&stored)
+		return tx.Model(&stored).UpdateColumn("hash", entry.Hash).Error
+	})
+	if err != nil {
+		log.Printf("Failed to record audit log entry %s: %v", action, err)
+		return err
+	}
+	return nil
+}
+
+// auditEntryHash returns the hex SHA-256 hash of an entry's PrevHash and fields.
+func auditEntryHash(entry *models.AuditLog) string {
+	optionalID := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		entry.PrevHash,
+		strconv.FormatUint(uint64(entry.ID), 10),
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		optionalID(entry.ActorID),
+		entry.Action,
+		entry.TargetType,
+		optionalID(entry.TargetID),
+		entry.Details,
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// filtered restricts a query on the audit trail to the entries matching a filter. An action
+// ending in "." matches every action starting with it, such as "login." for all login events.
+func (s *AuditService) filtered(filter *models.AuditLogFilter) (*gorm.DB, error) {
+	query := s.DB.Model(&models.AuditLog{})
+	if filter == nil {
+		return query.Session(&gorm.Session{}), nil
+	}
+	if filter.ActorID != nil {
+		id, err := strconv.ParseUint(*filter.ActorID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor ID")
+		}
+		query = query.Where("actor_id = ?", id)
+	}
+	if filter.Action != nil && *filter.Action != "" {
+		if strings.HasSuffix(*filter.Action, ".") {
+			query = query.Where("action LIKE ?", *filter.Action+"%")
+		} else {
+			query = query.Where("action = ?", *filter.Action)
+		}
+	}
+	if filter.TargetType != nil && *filter.TargetType != "" {
+		query = query.Where("target_type = ?", *filter.TargetType)
+	}
+	if filter.TargetID != nil {
+		id, err := strconv.ParseUint(*filter.TargetID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target ID")
+		}
+		query = query.Where("target_id = ?", id)
+	}
+	if filter.Since != nil && *filter.Since != "" {
+		since, err := time.Parse(time.RFC3339, *filter.Since)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since time: use RFC 3339, e.g. 2024-01-02T15:04:05Z")
+		}
+		query = query.Where("created_at >= ?", since)
+	}
+	if filter.Until != nil && *filter.Until != "" {
+		until, err := time.Parse(time.RFC3339, *filter.Until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid until time: use RFC 3339, e.g. 2024-01-02T15:04:05Z")
+		}
+		query = query.Where("created_at < ?", until)
+	}
+	return query.Session(&gorm.Session{}), nil
+}
+
+// List returns the audit trail entries matching the filter, newest first, along with the
+// total number of matching entries.
+func (s *AuditService) List(ctx context.Context, filter *models.AuditLogFilter, limit int, offset int) ([]*models.AuditLog, int64, error) {
+	query, err := s.filtered(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []*models.AuditLog
+	err = query.Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, total, err
+}
+
+// exportedAuditEntry is one line of an audit trail export.
+type exportedAuditEntry struct {
+	ID         uint            `json:"id"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	ActorID    *uint           `json:"actorId"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   *uint           `json:"targetId"`
+	Details    json.RawMessage `json:"details"`
+	PrevHash   string          `json:"prevHash"`
+	Hash       string          `json:"hash"`
+}
+
+// Export returns the audit trail entries matching the filter as JSON Lines, oldest first,
+// one entry per line. The hashes are included so the export can be checked independently.
+func (s *AuditService) Export(ctx context.Context, filter *models.AuditLogFilter) (string, error) {
+	query, err := s.filtered(filter)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	var batch []*models.AuditLog
+	err = query.FindInBatches(&batch, auditBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			if err := encoder.Encode(exportedAuditEntry{
+				ID:         entry.ID,
+				CreatedAt:  entry.CreatedAt.UTC(),
+				ActorID:    entry.ActorID,
+				Action:     entry.Action,
+				TargetType: entry.TargetType,
+				TargetID:   entry.TargetID,
+				Details:    json.RawMessage(entry.Details),
+				PrevHash:   entry.PrevHash,
+				Hash:       entry.Hash,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	return out.String(), err
+}
+
+// Verify walks the whole audit trail, oldest first, and checks that every entry's hash
+// matches its contents and that it chains onto the entry before it. Entries recorded before
+// the trail was hash-chained, which have no hash, are skipped if they come first.
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditLogVerification, error) {
+	result := &models.AuditLogVerification{Valid: true}
+	previousHash := ""
+	chainStarted := false
+
+	var batch []*models.AuditLog
+	err := s.DB.FindInBatches(&batch, auditBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			if !chainStarted && entry.Hash == "" && entry.PrevHash == "" {
+				continue
+			}
+			chainStarted = true
+			result.CheckedEntries++
+
+			problem := ""
+			switch {
+			case entry.PrevHash != previousHash:
+				problem = "the entry before it was changed or deleted"
+			case entry.Hash != auditEntryHash(entry):
+				problem = "the entry was changed"
+			}
+			if problem != "" {
+				id := strconv.FormatUint(uint64(entry.ID), 10)
+				result.Valid = false
+				result.FirstInvalidEntryID = &id
+				result.Reason = &problem
+				return errStopVerification
+			}
+			previousHash = entry.Hash
+		}
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errStopVerification) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// errStopVerification stops Verify's batch walk at the first broken link.
+var errStopVerification = errors.New("audit trail verification stopped")
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+)
+
+// newTestAuditService returns an AuditService on an empty database holding three entries.
+func newTestAuditService(t *testing.T) *AuditService {
+	t.Helper()
+	db := testdb.Open(t, &models.User{}, &models.AuditLog{})
+	s := NewAuditService(db)
+	admin := createTestUser(t, db, "admin")
+
+	ctx := context.Background()
+	entries := []struct {
+		action  string
+		details map[string]interface{}
+	}{
+		{"user.quota_changed", map[string]interface{}{"from": 10240, "to": 20480}},
+		{"user.role_changed", map[string]interface{}{"from": "user", "to": "admin"}},
+		{"user.suspended", nil},
+	}
+	for _, entry := range entries {
+		if err := s.Record(ctx, admin, entry.action, "user", 42, entry.details); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestAuditChainVerifies(t *testing.T) {
+	s := newTestAuditService(t)
+
+	var entries []models.AuditLog
+	s.DB.Order("id").Find(&entries)
+	for i, entry := range entries {
+		if entry.Hash == "" {
+			t.Errorf("entry %d has no hash", entry.ID)
+		}
+		if i > 0 && entry.PrevHash != entries[i-1].Hash {
+			t.Errorf("entry %d does not chain onto entry %d", entry.ID, entries[i-1].ID)
+		}
+	}
+
+	result, err := s.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.CheckedEntries != 3 {
+		t.Errorf("got valid=%v after checking %d entries, want a valid chain of 3", result.Valid, result.CheckedEntries)
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(s *AuditService, entries []models.AuditLog)
+		wantEntry  int
+		wantReason string
+	}{
+		{
+			name: "edited entry",
+			tamper: func(s *AuditService, entries []models.AuditLog) {
+				s.DB.Model(&entries[1]).UpdateColumn("details", `{"from": "user", "to": "user"}`)
+			},
+			wantEntry:  1,
+			wantReason: "the entry was changed",
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(s *AuditService, entries []models.AuditLog) {
+				entry := entries[1]
+				entry.Action = "user.unsuspended"
+				s.DB.Model(&entries[1]).UpdateColumns(map[string]interface{}{"action": entry.Action, "hash": auditEntryHash(&entry)})
+			},
+			wantEntry:  2,
+			wantReason: "the entry before it was changed or deleted",
+		},
+		{
+			name: "deleted entry",
+			tamper: func(s *AuditService, entries []models.AuditLog) {
+				s.DB.Unscoped().Delete(&entries[1])
+			},
+			wantEntry:  2,
+			wantReason: "the entry before it was changed or deleted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAuditService(t)
+			var entries []models.AuditLog
+			s.DB.Order("id").Find(&entries)
+			tt.tamper(s, entries)
+
+			result, err := s.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid {
+				t.Fatal("the tampered chain verified")
+			}
+			if want := *apiID(entries[tt.wantEntry].ID); result.FirstInvalidEntryID == nil || *result.FirstInvalidEntryID != want {
+				t.Errorf("first invalid entry %v, want %s", result.FirstInvalidEntryID, want)
+			}
+			if result.Reason == nil || *result.Reason != tt.wantReason {
+				t.Errorf("reason %v, want %q", result.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAuditChainSkipsEntriesFromBeforeChaining(t *testing.T) {
+	db := testdb.Open(t, &models.User{}, &models.AuditLog{})
+	s := NewAuditService(db)
+	if err := db.Create(&models.AuditLog{Action: "user.quota_changed", TargetType: "user", Details: "{}"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(context.Background(), nil, "login.failed", "user", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.CheckedEntries != 1 {
+		t.Errorf("got valid=%v after checking %d entries, want a valid chain of 1", result.Valid, result.CheckedEntries)
+	}
+}
@@ -3,10 +3,13 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
-	"github.com/joel2607/FileVault/models"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/validation"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -14,87 +17,242 @@ import (
 
 // AuthService provides methods for user authentication, including registration,
 // login, and token validation. It interacts with the database to manage user records.
+// Login attempts and issued tokens are recorded in the audit trail.
 type AuthService struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	Throttle       *LoginThrottleService
+	PasswordPolicy *validation.PasswordPolicy
+	Audit          *AuditService
 }
 
+// ErrInvalidCredentials is returned for any failed login, whether the email is
+// unknown or the password is wrong, so that accounts cannot be enumerated.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// dummyPasswordHash is compared against when the email is unknown so that a
+// failed lookup takes as long as a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("filevault-dummy-password"), bcrypt.DefaultCost)
+
 // NewAuthService creates and returns a new instance of AuthService.
-// It requires a GORM database connection.
+// It requires a GORM database connection, a login throttle and a password policy.
 //
 // Inputs:
 // - db: A pointer to a gorm.DB instance.
+// - throttle: The LoginThrottleService used to slow down brute-force attempts.
+// - passwordPolicy: The policy new passwords are checked against.
+// - audit: The AuditService login attempts and issued tokens are recorded with.
 //
 // Outputs:
 // - A pointer to the newly created AuthService.
-func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{DB: db}
+func NewAuthService(db *gorm.DB, throttle *LoginThrottleService, passwordPolicy *validation.PasswordPolicy, audit *AuditService) *AuthService {
+	return &AuthService{DB: db, Throttle: throttle, PasswordPolicy: passwordPolicy, Audit: audit}
 }
 
 // Register handles the creation of a new user account.
-// It hashes the user's password for security before storing it in the database.
+// It validates the input, then hashes the user's password for security before
+// storing it in the database.
 //
 // Inputs:
-// - input: A models.RegisterInput struct containing the new user's details
-//          (username, email, password).
+//   - input: A models.RegisterInput struct containing the new user's details
+//     (username, email, password).
 //
 // Outputs:
-// - A pointer to the newly created models.User object.
-// - An error if password hashing or database creation fails.
+//   - A pointer to the newly created models.User object.
+//   - A validation.Errors listing every invalid field, or an error if password
+//     hashing or database creation fails.
 func (s *AuthService) Register(input models.RegisterInput) (*models.User, error) {
+	username := strings.TrimSpace(input.Username)
+	email := validation.NormalizeEmail(input.Email)
+
+	var errs validation.Errors
+	validation.Username(&errs, "input.username", username)
+	validation.Email(&errs, "input.email", email)
+	s.PasswordPolicy.Password(&errs, "input.password", input.Password, username, email)
+	if len(errs) == 0 {
+		s.checkUsernameAvailable(&errs, "input.username", username, 0)
+		s.checkEmailAvailable(&errs, "input.email", email, 0)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
 	user := models.User{
-		Username:     input.Username,
-		Email:        input.Email,
+		Username:     username,
+		Email:        email,
 		PasswordHash: string(hashedPassword),
 	}
 
 	if err := s.DB.Create(&user).Error; err != nil {
-		return nil, err
+		return nil, uniqueViolationToFieldError(err, "input")
 	}
 
 	return &user, nil
 }
 
+// checkUsernameAvailable adds an error if another user already has the username.
+// Usernames are compared case-insensitively. excludeUserID is ignored in the check
+// so that a user's own username does not count as taken.
+func (s *AuthService) checkUsernameAvailable(errs *validation.Errors, field, username string, excludeUserID uint) {
+	var count int64
+	s.DB.Model(&models.User{}).Where("LOWER(username) = LOWER(?) AND id <> ?", username, excludeUserID).Count(&count)
+	if count > 0 {
+		errs.Add(field, validation.CodeAlreadyTaken, "username is already taken")
+	}
+}
+
+// checkEmailAvailable adds an error if another user is already registered with the email.
+func (s *AuthService) checkEmailAvailable(errs *validation.Errors, field, email string, excludeUserID uint) {
+	var count int64
+	s.DB.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", email, excludeUserID).Count(&count)
+	if count > 0 {
+		errs.Add(field, validation.CodeAlreadyTaken, "email is already registered")
+	}
+}
+
+// uniqueViolationToFieldError converts a Postgres unique violation on the users
+// table into a field error, covering the race between the availability checks
+// and the insert. Other errors are returned unchanged.
+func uniqueViolationToFieldError(err error, fieldPrefix string) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+	var errs validation.Errors
+	switch {
+	case strings.Contains(pgErr.ConstraintName, "username"):
+		errs.Add(fieldPrefix+".username", validation.CodeAlreadyTaken, "username is already taken")
+	case strings.Contains(pgErr.ConstraintName, "email"):
+		errs.Add(fieldPrefix+".email", validation.CodeAlreadyTaken, "email is already registered")
+	default:
+		return err
+	}
+	return errs
+}
+
 // Login authenticates a user based on their email and password.
 // If the credentials are valid, it generates and returns a JWT.
+// Failed attempts are counted per email and per client IP; once too many have
+// failed, further attempts are rejected with a *LoginThrottledError.
 //
 // Inputs:
+// - ctx: The context for the request.
 // - email: The user's email address.
 // - password: The user's plain-text password.
+// - clientIP: The IP address the attempt came from, or "" if unknown.
 //
 // Outputs:
 // - A string containing the signed JWT.
 // - A pointer to the authenticated models.User object.
-// - An error if the user is not found, the password is invalid, or JWT signing fails.
-func (s *AuthService) Login(email string, password string) (string, *models.User, error) {
+// - An error if the attempt is throttled, the credentials are invalid, or JWT signing fails.
+func (s *AuthService) Login(ctx context.Context, email string, password string, clientIP string) (string, *models.User, error) {
+	if err := s.Throttle.Check(ctx, email, clientIP); err != nil {
+		s.recordLoginFailure(ctx, nil, email, clientIP, "throttled")
+		return "", nil, err
+	}
+
 	var user models.User
-	if err := s.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return "", nil, errors.New("user not found")
+	if err := s.DB.Where("LOWER(email) = ?", validation.NormalizeEmail(email)).First(&user).Error; err != nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		s.recordLoginFailure(ctx, nil, email, clientIP, "unknown email")
+		return "", nil, ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return "", nil, errors.New("invalid password")
+		s.recordLoginFailure(ctx, &user, email, clientIP, "wrong password")
+		return "", nil, ErrInvalidCredentials
 	}
+	s.Throttle.RecordSuccess(ctx, email, clientIP)
 
-	expirationHours := viper.GetInt("auth.jwt_expiration_hours")
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"role": user.Role,
-		"exp": time.Now().Add(time.Hour * time.Duration(expirationHours)).Unix(),
-	})
+	if user.SuspendedAt != nil {
+		s.recordLoginFailure(ctx, &user, email, clientIP, "account suspended")
+		return "", nil, ErrAccountSuspended
+	}
+	if user.DeletingAt != nil {
+		s.recordLoginFailure(ctx, &user, email, clientIP, "account being deleted")
+		return "", nil, ErrAccountDeleted
+	}
 
-	token, err := claims.SignedString([]byte(viper.GetString("JWT_AUTH_SECRET")))
+	token, err := s.IssueToken(ctx, &user, nil)
 	if err != nil {
 		return "", nil, err
 	}
+	s.Audit.Record(ctx, &user, "login.succeeded", "user", user.ID, map[string]interface{}{
+		"ip": clientIP,
+	})
 
 	return token, &user, nil
 }
 
+// recordLoginFailure records a failed login attempt in the audit trail. user is the
+// account the email belongs to, or nil if there is none.
+func (s *AuthService) recordLoginFailure(ctx context.Context, user *models.User, email string, clientIP string, reason string) {
+	var targetID uint
+	if user != nil {
+		targetID = user.ID
+	}
+	s.Audit.Record(ctx, nil, "login.failed", "user", targetID, map[string]interface{}{
+		"email":  email,
+		"ip":     clientIP,
+		"reason": reason,
+	})
+}
+
+// ErrAccountSuspended is returned when a suspended user logs in or uses an existing token.
+var ErrAccountSuspended = errors.New("account is suspended")
+
+// ErrAccountDeleted is returned when a user whose account is being deleted logs in or uses an
+// existing token.
+var ErrAccountDeleted = errors.New("account has been deleted")
+
+// Impersonation describes an admin acting as another user. It is carried in the
+// token issued by AdminService.ImpersonateUser.
+type Impersonation struct {
+	AdminID  uint
+	ReadOnly bool
+}
+
+// impersonationTokenTTL bounds how long an impersonation token is valid.
+const impersonationTokenTTL = time.Hour
+
+// IssueToken signs a JWT for the user. The token embeds the user's TokenVersion,
+// so bumping the version invalidates every token issued before.
+// When impersonation is not nil, the token is a short-lived impersonation token.
+// Every issued token is recorded in the audit trail.
+func (s *AuthService) IssueToken(ctx context.Context, user *models.User, impersonation *Impersonation) (string, error) {
+	expiresAt := time.Now().Add(time.Hour * time.Duration(viper.GetInt("auth.jwt_expiration_hours")))
+	claims := jwt.MapClaims{
+		"sub":  user.ID,
+		"role": user.Role,
+		"ver":  user.TokenVersion,
+	}
+	if impersonation != nil {
+		claims["imp"] = impersonation.AdminID
+		claims["ro"] = impersonation.ReadOnly
+		expiresAt = time.Now().Add(impersonationTokenTTL)
+	}
+	claims["exp"] = expiresAt.Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(viper.GetString("JWT_AUTH_SECRET")))
+	if err != nil {
+		return "", err
+	}
+
+	details := map[string]interface{}{"expiresAt": expiresAt.UTC().Format(time.RFC3339)}
+	actor := user
+	if impersonation != nil {
+		details["impersonatedBy"] = impersonation.AdminID
+		details["readOnly"] = impersonation.ReadOnly
+		actor = &models.User{BaseModel: models.BaseModel{ID: impersonation.AdminID}}
+	}
+	s.Audit.Record(ctx, actor, "token.issued", "user", user.ID, details)
+	return token, nil
+}
+
 // GetUserFromToken validates a JWT string and retrieves the corresponding user from the database.
 // It checks for token expiration and validity before fetching the user.
 //
@@ -103,35 +261,60 @@ func (s *AuthService) Login(email string, password string) (string, *models.User
 //
 // Outputs:
 // - A pointer to the models.User object associated with the token.
-// - An error if the token is expired, invalid, or the user is not found.
+// - An error if the token is expired, invalid, revoked, or the user is not found or suspended.
 func (s *AuthService) GetUserFromToken(tokenString string) (*models.User, error) {
+	user, _, err := s.GetSessionFromToken(tokenString)
+	return user, err
+}
+
+// GetSessionFromToken works like GetUserFromToken, and additionally returns the
+// impersonation details if the token was issued to an admin impersonating the user.
+func (s *AuthService) GetSessionFromToken(tokenString string) (*models.User, *Impersonation, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return []byte(viper.GetString("JWT_AUTH_SECRET")), nil
 	})
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, errors.New("token is expired")
+			return nil, nil, errors.New("token is expired")
 		}
-		return nil, errors.New("could not parse token")
+		return nil, nil, errors.New("could not parse token")
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		// Extract user ID from claims
 		sub, ok := claims["sub"].(float64)
 		if !ok {
-			return nil, errors.New("invalid token: sub claim is not a number")
+			return nil, nil, errors.New("invalid token: sub claim is not a number")
 		}
 		userID := uint(sub)
 
 		var user models.User
 		if err := s.DB.First(&user, userID).Error; err != nil {
-			return nil, errors.New("user not found")
+			return nil, nil, errors.New("user not found")
+		}
+
+		// Tokens issued before the user's last forced logout are rejected.
+		version, _ := claims["ver"].(float64)
+		if int(version) != user.TokenVersion {
+			return nil, nil, errors.New("token has been revoked")
 		}
-		return &user, nil
+		if user.SuspendedAt != nil {
+			return nil, nil, ErrAccountSuspended
+		}
+		if user.DeletingAt != nil {
+			return nil, nil, ErrAccountDeleted
+		}
+
+		var impersonation *Impersonation
+		if adminID, ok := claims["imp"].(float64); ok {
+			readOnly, _ := claims["ro"].(bool)
+			impersonation = &Impersonation{AdminID: uint(adminID), ReadOnly: readOnly}
+		}
+		return &user, impersonation, nil
 	}
 
-	return nil, errors.New("invalid token")
+	return nil, nil, errors.New("invalid token")
 }
 
 // SearchUsers performs a case-insensitive search for users with usernames or emails that contain the query string.
@@ -141,3 +324,11 @@ func (s *AuthService) SearchUsers(ctx context.Context, query string) ([]*models.
 	err := s.DB.Where("username ILIKE ? OR email ILIKE ?", searchQuery, searchQuery).Find(&users).Error
 	return users, err
 }
+
+// SearchUsersConnection runs a search like SearchUsers and returns a page of the results,
+// sorted by username unless sort says otherwise.
+func (s *AuthService) SearchUsersConnection(ctx context.Context, query string, first *int32, after *string, sort *models.UserSort) (*models.UserConnection, error) {
+	searchQuery := "%" + query + "%"
+	db := s.DB.Model(&models.User{}).Where("users.username ILIKE ? OR users.email ILIKE ?", searchQuery, searchQuery)
+	return usersConnection(db, userOrder(sort), first, after)
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/database"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// maxCommentLength is the maximum length of a comment body, in characters.
+const maxCommentLength = 10000
+
+// mentionPattern matches @username mentions; usernames start with a letter or digit.
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9][A-Za-z0-9_.\-]*)`)
+
+// commentEvent is the payload published on a file's comment channel.
+type commentEvent struct {
+	Action    models.CommentAction `json:"action"`
+	CommentID uint                 `json:"commentId"`
+}
+
+// CommentService manages comment threads on files. Anyone who can read a file can
+// comment on it, reply, and resolve threads; authors can edit and delete their own
+// comments and file owners can delete any comment on their files.
+type CommentService struct {
+	DB            *gorm.DB
+	RDB           *redis.Client
+	Notifications *NotificationService
+}
+
+// NewCommentService creates a new instance of CommentService.
+func NewCommentService(db *gorm.DB, rdb *redis.Client, notifications *NotificationService) *CommentService {
+	return &CommentService{DB: db, RDB: rdb, Notifications: notifications}
+}
+
+// readableFile loads a file and checks that the user can read it.
+func (s *CommentService) readableFile(fileID uint, user *models.User) (*models.File, error) {
+	var file models.File
+	if err := s.DB.First(&file, fileID).Error; err != nil {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+	if !canReadFile(s.DB, &file, user) {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+	return &file, nil
+}
+
+// findComment loads a comment on a file the user can read.
+func (s *CommentService) findComment(commentID string, user *models.User) (*models.Comment, *models.File, error) {
+	id, err := strconv.ParseUint(commentID, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid comment ID")
+	}
+	var comment models.Comment
+	if err := s.DB.First(&comment, id).Error; err != nil {
+		return nil, nil, fmt.Errorf("comment not found or access denied")
+	}
+	file, err := s.readableFile(comment.FileID, user)
+	if err != nil {
+		return nil, nil, fmt.Errorf("comment not found or access denied")
+	}
+	return &comment, file, nil
+}
+
+// normalizeCommentBody trims a comment body and checks its length.
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("comment cannot be empty")
+	}
+	if len([]rune(body)) > maxCommentLength {
+		return "", fmt.Errorf("comment must be at most %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// AddComment adds a comment to a file, or a reply to a thread if parentCommentID is given.
+// Users @mentioned in the body who can read the file are recorded and notified.
+func (s *CommentService) AddComment(ctx context.Context, fileID string, body string, parentCommentID *string, user *models.User) (*models.Comment, error) {
+	id, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+	file, err := s.readableFile(uint(id), user)
+	if err != nil {
+		return nil, err
+	}
+	body, err = normalizeCommentBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &models.Comment{FileID: file.ID, AuthorID: user.ID, Body: body}
+	if parentCommentID != nil {
+		parent, _, err := s.findComment(*parentCommentID, user)
+		if err != nil {
+			return nil, err
+		}
+		if parent.FileID != file.ID {
+			return nil, fmt.Errorf("the parent comment belongs to a different file")
+		}
+		// Replies to replies join the thread of the top-level comment.
+		if parent.ParentCommentID != nil {
+			comment.ParentCommentID = parent.ParentCommentID
+		} else {
+			comment.ParentCommentID = &parent.ID
+		}
+	}
+
+	var mentioned []models.User
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		mentioned, err = s.saveMentions(tx, comment, file, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishCommentEvent(comment.FileID, models.CommentActionCreated, comment.ID)
+	for _, mentionedUser := range mentioned {
+		s.notifyMention(&mentionedUser, file, user)
+	}
+	return comment, nil
+}
+
+// saveMentions records the users @mentioned in a comment who can read the file, other than
+// its author, and returns those not mentioned in it before.
+func (s *CommentService) saveMentions(tx *gorm.DB, comment *models.Comment, file *models.File, author *models.User) ([]models.User, error) {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(comment.Body, -1) {
+		usernames = append(usernames, strings.TrimRight(match[1], ".-"))
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	var users []models.User
+	if err := tx.Where("username IN ? AND id <> ?", usernames, author.ID).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	var added []models.User
+	for _, user := range users {
+		if !canReadFile(tx, file, &user) {
+			continue
+		}
+		var count int64
+		tx.Model(&models.CommentMention{}).Where("comment_id = ? AND user_id = ?", comment.ID, user.ID).Count(&count)
+		if count > 0 {
+			continue
+		}
+		if err := tx.Create(&models.CommentMention{CommentID: comment.ID, UserID: user.ID}).Error; err != nil {
+			return nil, err
+		}
+		added = append(added, user)
+	}
+	return added, nil
+}
+
+// notifyMention tells a user that they were mentioned in a comment.
+func (s *CommentService) notifyMention(mentioned *models.User, file *models.File, author *models.User) {
+	notification := resourceNotification(models.NotificationTypeCommentMention, author, file, nil, "%s mentioned you in a comment on %s")
+	notification.UserID = mentioned.ID
+	s.Notifications.Notify(&notification)
+}
+
+// UpdateComment changes the body of a comment. Only its author can do this.
+// Users newly @mentioned are recorded and notified.
+func (s *CommentService) UpdateComment(ctx context.Context, commentID string, body string, user *models.User) (*models.Comment, error) {
+	comment, file, err := s.findComment(commentID, user)
+	if err != nil {
+		return nil, err
+	}
+	if comment.AuthorID != user.ID {
+		return nil, fmt.Errorf("only the author can edit a comment")
+	}
+	body, err = normalizeCommentBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	comment.Body = body
+	comment.EditedAt = &now
+	var mentioned []models.User
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(comment).Updates(map[string]interface{}{"body": body, "edited_at": now}).Error; err != nil {
+			return err
+		}
+		mentioned, err = s.saveMentions(tx, comment, file, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishCommentEvent(comment.FileID, models.CommentActionUpdated, comment.ID)
+	for _, mentionedUser := range mentioned {
+		s.notifyMention(&mentionedUser, file, user)
+	}
+	return comment, nil
+}
+
+// DeleteComment deletes a comment, and its replies if it starts a thread.
+// The author and the file's owner can do this.
+func (s *CommentService) DeleteComment(ctx context.Context, commentID string, user *models.User) (bool, error) {
+	comment, file, err := s.findComment(commentID, user)
+	if err != nil {
+		return false, err
+	}
+	if comment.AuthorID != user.ID && file.UserID != user.ID {
+		return false, fmt.Errorf("only the author or the file owner can delete a comment")
+	}
+
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteComments(tx, tx.Model(&models.Comment{}).Select("id").Where("id = ?", comment.ID))
+	}); err != nil {
+		return false, err
+	}
+	s.publishCommentEvent(comment.FileID, models.CommentActionDeleted, comment.ID)
+	return true, nil
+}
+
+// deleteComments deletes the comments selected by the ids subquery, their replies, and their mentions.
+func deleteComments(tx *gorm.DB, ids *gorm.DB) error {
+	var commentIDs []uint
+	if err := tx.Model(&models.Comment{}).Where("id IN (?) OR parent_comment_id IN (?)", ids, ids).Pluck("id", &commentIDs).Error; err != nil {
+		return err
+	}
+	if len(commentIDs) == 0 {
+		return nil
+	}
+	if err := tx.Where("comment_id IN ?", commentIDs).Delete(&models.CommentMention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("id IN ? AND parent_comment_id IS NOT NULL", commentIDs).Delete(&models.Comment{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Comment{}, commentIDs).Error
+}
+
+// SetCommentResolved resolves or reopens a thread. Anyone who can read the file can do this.
+func (s *CommentService) SetCommentResolved(ctx context.Context, commentID string, resolved bool, user *models.User) (*models.Comment, error) {
+	comment, _, err := s.findComment(commentID, user)
+	if err != nil {
+		return nil, err
+	}
+	if comment.ParentCommentID != nil {
+		return nil, fmt.Errorf("only top-level comments can be resolved")
+	}
+
+	action := models.CommentActionUnresolved
+	comment.ResolvedAt, comment.ResolvedByID = nil, nil
+	if resolved {
+		now := time.Now()
+		action = models.CommentActionResolved
+		comment.ResolvedAt, comment.ResolvedByID = &now, &user.ID
+	}
+	if err := s.DB.Model(comment).Updates(map[string]interface{}{
+		"resolved_at":    comment.ResolvedAt,
+		"resolved_by_id": comment.ResolvedByID,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	s.publishCommentEvent(comment.FileID, action, comment.ID)
+	return comment, nil
+}
+
+// ListFileComments returns the top-level comments on a file, oldest first. Resolved threads
+// are left out unless includeResolved is true.
+func (s *CommentService) ListFileComments(ctx context.Context, fileID string, includeResolved bool, user *models.User) ([]*models.Comment, error) {
+	id, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+	if _, err := s.readableFile(uint(id), user); err != nil {
+		return nil, err
+	}
+
+	db := s.DB.Where("file_id = ? AND parent_comment_id IS NULL", id)
+	if !includeResolved {
+		db = db.Where("resolved_at IS NULL")
+	}
+	var comments []*models.Comment
+	err = db.Order("created_at").Find(&comments).Error
+	return comments, err
+}
+
+// publishCommentEvent tells subscribers of a file's comments that one changed.
+func (s *CommentService) publishCommentEvent(fileID uint, action models.CommentAction, commentID uint) {
+	payload, err := json.Marshal(commentEvent{Action: action, CommentID: commentID})
+	if err != nil {
+		return
+	}
+	channel := fmt.Sprintf("comment_updates_%d", fileID)
+	if err := s.RDB.Publish(database.Ctx, channel, payload).Err(); err != nil {
+		log.Printf("Error publishing comment event for file %d: %v", fileID, err)
+	}
+}
+
+// SubscribeToFileComments streams changes to the comments on a file the user can read.
+// Events for deleted comments carry only the comment ID.
+func (s *CommentService) SubscribeToFileComments(ctx context.Context, fileID string, user *models.User) (<-chan *models.CommentEvent, error) {
+	id, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+	file, err := s.readableFile(uint(id), user)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *models.CommentEvent, 1)
+	channel := fmt.Sprintf("comment_updates_%d", file.ID)
+	pubsub := s.RDB.Subscribe(ctx, channel)
+
+	go func() {
+		defer pubsub.Close()
+		defer close(ch)
+
+		for {
+			select {
+			case msg, ok := <-pubsub.Channel():
+				if !ok {
+					return
+				}
+				var event commentEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					continue
+				}
+				update := &models.CommentEvent{
+					Action:    event.Action,
+					CommentID: strconv.FormatUint(uint64(event.CommentID), 10),
+				}
+				if event.Action != models.CommentActionDeleted {
+					var comment models.Comment
+					if err := s.DB.First(&comment, event.CommentID).Error; err != nil {
+						continue
+					}
+					update.Comment = &comment
+				}
+				ch <- update
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
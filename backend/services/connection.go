@@ -0,0 +1,405 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// Connections return DefaultConnectionPageSize items unless first asks for fewer or more,
+// and never more than MaxConnectionPageSize.
+const (
+	DefaultConnectionPageSize = 50
+	MaxConnectionPageSize     = 200
+)
+
+// connectionPageSize returns the page size asked for by first.
+func connectionPageSize(first *int32) (int, error) {
+	if first == nil {
+		return DefaultConnectionPageSize, nil
+	}
+	if *first < 0 {
+		return 0, fmt.Errorf("first must not be negative")
+	}
+	if *first > MaxConnectionPageSize {
+		return MaxConnectionPageSize, nil
+	}
+	return int(*first), nil
+}
+
+// connectionOrder is how a connection is ordered: by an SQL expression, with the ID
+// breaking ties in the same direction. Name is recorded in cursors, so that a cursor can't
+// be used with a different order.
+type connectionOrder struct {
+	Name string
+	Expr string
+	Args []interface{}
+	Desc bool
+}
+
+// connectionCursor is the position just past an item in a connection: the value the item
+// was ordered by, and its ID.
+type connectionCursor struct {
+	Order string          `json:"o"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// encodeConnectionCursor returns the opaque cursor for an item, given the value it was
+// ordered by.
+func encodeConnectionCursor(order connectionOrder, value interface{}, id uint) (string, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(connectionCursor{Order: order.Name, Value: raw, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// decodeConnectionCursor parses a cursor made for the order, decoding its value into target,
+// which must point to a value of the type the order's expression has.
+func decodeConnectionCursor(cursor string, order connectionOrder, target interface{}) (uint, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	var parsed connectionCursor
+	if err := json.Unmarshal(decoded, &parsed); err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	if parsed.Order != order.Name {
+		return 0, fmt.Errorf("the cursor is for a different sort order")
+	}
+	if err := json.Unmarshal(parsed.Value, target); err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return parsed.ID, nil
+}
+
+// cursorValue returns the value a decode target points to, for use as a query parameter.
+func cursorValue(target interface{}) interface{} {
+	switch value := target.(type) {
+	case *string:
+		return *value
+	case *int64:
+		return *value
+	case *float64:
+		return *value
+	case *time.Time:
+		return *value
+	}
+	return target
+}
+
+// keysetPage orders a query and keeps the rows after the cursor, if any, limiting it to
+// one row more than the page size so that the caller can tell whether there is a next page.
+// after is nil, or the decoded cursor value followed by its ID.
+func keysetPage(query *gorm.DB, order connectionOrder, idColumn string, after []interface{}, pageSize int) *gorm.DB {
+	op, direction := ">", "ASC"
+	if order.Desc {
+		op, direction = "<", "DESC"
+	}
+	if after != nil {
+		args := append(append([]interface{}{}, order.Args...), after...)
+		query = query.Where("("+order.Expr+", "+idColumn+") "+op+" (?, ?)", args...)
+	}
+	return query.Order(gorm.Expr(order.Expr+" "+direction+", "+idColumn+" "+direction, order.Args...)).Limit(pageSize + 1)
+}
+
+// pageInfo describes a page of a connection: hasMore is whether the query returned more rows
+// than the page holds, and after the cursor the page was asked for after.
+func pageInfo(startCursor string, endCursor string, hasMore bool, after *string) *models.PageInfo {
+	info := &models.PageInfo{HasNextPage: hasMore, HasPreviousPage: after != nil && *after != ""}
+	if startCursor != "" {
+		info.StartCursor = &startCursor
+		info.EndCursor = &endCursor
+	}
+	return info
+}
+
+// sortDirectionDesc reports whether a sort direction is descending.
+func sortDirectionDesc(direction *models.SortDirection) bool {
+	return direction != nil && *direction == models.SortDirectionDesc
+}
+
+// fileOrder returns the order for a file sort, defaulting to name.
+func fileOrder(sort *models.FileSort) connectionOrder {
+	field := models.FileSortFieldName
+	desc := false
+	if sort != nil {
+		field = sort.Field
+		desc = sortDirectionDesc(sort.Direction)
+	}
+	order := connectionOrder{Name: string(field), Desc: desc}
+	switch field {
+	case models.FileSortFieldSize:
+		order.Expr = "files.size"
+	case models.FileSortFieldCreatedAt:
+		order.Expr = "files.created_at"
+	case models.FileSortFieldDownloadCount:
+		order.Expr = "files.download_count"
+	case models.FileSortFieldMimeType:
+		order.Expr = "files.mime_type"
+	default:
+		order.Name = string(models.FileSortFieldName)
+		order.Expr = "files.file_name"
+	}
+	return order
+}
+
+// relevanceOrder orders search results by how well they match the search text, best first.
+func relevanceOrder(text string) connectionOrder {
+	return connectionOrder{Name: "RELEVANCE", Expr: searchRankExpr, Args: []interface{}{text}, Desc: true}
+}
+
+// fileSortTarget returns a pointer to decode a files cursor value into.
+func fileSortTarget(order connectionOrder) interface{} {
+	switch order.Name {
+	case string(models.FileSortFieldSize), string(models.FileSortFieldDownloadCount):
+		return new(int64)
+	case string(models.FileSortFieldCreatedAt):
+		return new(time.Time)
+	case "RELEVANCE":
+		return new(float64)
+	}
+	return new(string)
+}
+
+// filesConnection returns a page of the files a query matches. The query must be on the files
+// table; relevance orders also need it joined with content_texts.
+func filesConnection(query *gorm.DB, order connectionOrder, first *int32, after *string) (*models.FileConnection, error) {
+	pageSize, err := connectionPageSize(first)
+	if err != nil {
+		return nil, err
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var afterKey []interface{}
+	if after != nil && *after != "" {
+		target := fileSortTarget(order)
+		id, err := decodeConnectionCursor(*after, order, target)
+		if err != nil {
+			return nil, err
+		}
+		afterKey = []interface{}{cursorValue(target), id}
+	}
+
+	var files []*models.File
+	if err := keysetPage(query.Select("files.*"), order, "files.id", afterKey, pageSize).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	hasMore := len(files) > pageSize
+	if hasMore {
+		files = files[:pageSize]
+	}
+
+	ranks, err := fileRanks(query, order, files)
+	if err != nil {
+		return nil, err
+	}
+	connection := &models.FileConnection{Edges: make([]*models.FileEdge, len(files)), TotalCount: int32(total)}
+	startCursor, endCursor := "", ""
+	for i, file := range files {
+		var value interface{}
+		switch order.Name {
+		case string(models.FileSortFieldSize):
+			value = file.Size
+		case string(models.FileSortFieldCreatedAt):
+			value = file.CreatedAt
+		case string(models.FileSortFieldDownloadCount):
+			value = int64(file.DownloadCount)
+		case string(models.FileSortFieldMimeType):
+			value = file.MIMEType
+		case "RELEVANCE":
+			value = ranks[file.ID]
+		default:
+			value = file.FileName
+		}
+		cursor, err := encodeConnectionCursor(order, value, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		connection.Edges[i] = &models.FileEdge{Cursor: cursor, Node: file}
+		if i == 0 {
+			startCursor = cursor
+		}
+		endCursor = cursor
+	}
+	connection.PageInfo = pageInfo(startCursor, endCursor, hasMore, after)
+	return connection, nil
+}
+
+// fileRanks looks up the relevance of the files on a page ordered by relevance, which isn't
+// stored on the files. For other orders it returns nil.
+func fileRanks(query *gorm.DB, order connectionOrder, files []*models.File) (map[uint]float64, error) {
+	if order.Name != "RELEVANCE" || len(files) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	var rows []struct {
+		ID   uint
+		Rank float64
+	}
+	args := append([]interface{}{}, order.Args...)
+	if err := query.Select("files.id, "+order.Expr+" AS rank", args...).Where("files.id IN ?", ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	ranks := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		ranks[row.ID] = row.Rank
+	}
+	return ranks, nil
+}
+
+// folderOrder returns the order for a folder sort, defaulting to name.
+func folderOrder(sort *models.FolderSort) connectionOrder {
+	order := connectionOrder{Name: string(models.FolderSortFieldName), Expr: "folders.folder_name"}
+	if sort != nil {
+		order.Desc = sortDirectionDesc(sort.Direction)
+		if sort.Field == models.FolderSortFieldCreatedAt {
+			order.Name = string(models.FolderSortFieldCreatedAt)
+			order.Expr = "folders.created_at"
+		}
+	}
+	return order
+}
+
+// foldersConnection returns a page of the folders a query on the folders table matches.
+func foldersConnection(query *gorm.DB, order connectionOrder, first *int32, after *string) (*models.FolderConnection, error) {
+	pageSize, err := connectionPageSize(first)
+	if err != nil {
+		return nil, err
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var afterKey []interface{}
+	if after != nil && *after != "" {
+		var target interface{} = new(string)
+		if order.Name == string(models.FolderSortFieldCreatedAt) {
+			target = new(time.Time)
+		}
+		id, err := decodeConnectionCursor(*after, order, target)
+		if err != nil {
+			return nil, err
+		}
+		afterKey = []interface{}{cursorValue(target), id}
+	}
+
+	var folders []*models.Folder
+	if err := keysetPage(query.Select("folders.*"), order, "folders.id", afterKey, pageSize).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	hasMore := len(folders) > pageSize
+	if hasMore {
+		folders = folders[:pageSize]
+	}
+
+	connection := &models.FolderConnection{Edges: make([]*models.FolderEdge, len(folders)), TotalCount: int32(total)}
+	startCursor, endCursor := "", ""
+	for i, folder := range folders {
+		var value interface{} = folder.FolderName
+		if order.Name == string(models.FolderSortFieldCreatedAt) {
+			value = folder.CreatedAt
+		}
+		cursor, err := encodeConnectionCursor(order, value, folder.ID)
+		if err != nil {
+			return nil, err
+		}
+		connection.Edges[i] = &models.FolderEdge{Cursor: cursor, Node: folder}
+		if i == 0 {
+			startCursor = cursor
+		}
+		endCursor = cursor
+	}
+	connection.PageInfo = pageInfo(startCursor, endCursor, hasMore, after)
+	return connection, nil
+}
+
+// userOrder returns the order for a user sort, defaulting to username.
+func userOrder(sort *models.UserSort) connectionOrder {
+	order := connectionOrder{Name: string(models.UserSortFieldName), Expr: "users.username"}
+	if sort != nil {
+		order.Desc = sortDirectionDesc(sort.Direction)
+		if sort.Field == models.UserSortFieldCreatedAt {
+			order.Name = string(models.UserSortFieldCreatedAt)
+			order.Expr = "users.created_at"
+		}
+	}
+	return order
+}
+
+// usersConnection returns a page of the users a query on the users table matches.
+func usersConnection(query *gorm.DB, order connectionOrder, first *int32, after *string) (*models.UserConnection, error) {
+	pageSize, err := connectionPageSize(first)
+	if err != nil {
+		return nil, err
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var afterKey []interface{}
+	if after != nil && *after != "" {
+		var target interface{} = new(string)
+		if order.Name == string(models.UserSortFieldCreatedAt) {
+			target = new(time.Time)
+		}
+		id, err := decodeConnectionCursor(*after, order, target)
+		if err != nil {
+			return nil, err
+		}
+		afterKey = []interface{}{cursorValue(target), id}
+	}
+
+	var users []*models.User
+	if err := keysetPage(query.Select("users.*"), order, "users.id", afterKey, pageSize).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	hasMore := len(users) > pageSize
+	if hasMore {
+		users = users[:pageSize]
+	}
+
+	connection := &models.UserConnection{Edges: make([]*models.UserEdge, len(users)), TotalCount: int32(total)}
+	startCursor, endCursor := "", ""
+	for i, user := range users {
+		var value interface{} = user.Username
+		if order.Name == string(models.UserSortFieldCreatedAt) {
+			value = user.CreatedAt
+		}
+		cursor, err := encodeConnectionCursor(order, value, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		connection.Edges[i] = &models.UserEdge{Cursor: cursor, Node: user}
+		if i == 0 {
+			startCursor = cursor
+		}
+		endCursor = cursor
+	}
+	connection.PageInfo = pageInfo(startCursor, endCursor, hasMore, after)
+	return connection, nil
+}
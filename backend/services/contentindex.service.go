@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/textextract"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContentIndexService extracts the text of uploaded content in the background so that
+// searches can match what is inside files. Text is extracted once per deduplicated content,
+// and the database indexes it for full-text search. Content uploaded before the index existed
+// is picked up the same way. The text is kept in content_texts, apart from the content itself.
+type ContentIndexService struct {
+	DB *gorm.DB
+	// MaxTextBytes bounds how much text is kept per content; Postgres can't index much more than 1MB.
+	MaxTextBytes int
+	Interval     time.Duration
+	BatchSize    int
+}
+
+// NewContentIndexService creates a new instance of ContentIndexService, configured from
+// search.max_extracted_text_kb, search.index_interval_seconds and search.index_batch_size.
+func NewContentIndexService(db *gorm.DB) *ContentIndexService {
+	viper.SetDefault("search.max_extracted_text_kb", 512)
+	viper.SetDefault("search.index_interval_seconds", 15)
+	viper.SetDefault("search.index_batch_size", 20)
+	return &ContentIndexService{
+		DB:           db,
+		MaxTextBytes: viper.GetInt("search.max_extracted_text_kb") * 1024,
+		Interval:     time.Duration(viper.GetInt("search.index_interval_seconds")) * time.Second,
+		BatchSize:    viper.GetInt("search.index_batch_size"),
+	}
+}
+
+// Run extracts the text of new content every Interval until ctx is cancelled.
+func (s *ContentIndexService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Work through any backlog a batch at a time, then wait for the next tick.
+		for ctx.Err() == nil && s.indexBatch() {
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// indexBatch extracts the text of up to BatchSize contents that haven't been indexed yet.
+// It reports whether there may be more to do: the batch was full and all of it was stored.
+func (s *ContentIndexService) indexBatch() bool {
+	var contents []models.DeduplicatedContent
+	if err := s.DB.Where("text_extracted_at IS NULL").Order("id").Limit(s.BatchSize).Find(&contents).Error; err != nil {
+		log.Printf("Failed to load content to index: %v", err)
+		return false
+	}
+	stored := 0
+	for i := range contents {
+		if s.index(&contents[i]) {
+			stored++
+		}
+	}
+	return len(contents) == s.BatchSize && stored == len(contents)
+}
+
+// index extracts and stores the text of one content. The MIME type and name of the oldest
+// file with the content decide how it is read. Failures are logged and the content is marked
+// as done, so that a file that can't be read isn't retried forever. It reports whether the
+// result was stored.
+func (s *ContentIndexService) index(content *models.DeduplicatedContent) bool {
+	text := ""
+	var file models.File
+	err := s.DB.Where("deduplication_id = ?", content.ID).Order("id").First(&file).Error
+	if err != nil && time.Since(content.CreatedAt) < time.Minute {
+		// The upload that created the content may not have created its file yet.
+		return false
+	}
+	if err == nil {
+		extracted, err := textextract.Extract(filepath.Join("./uploads", content.SHA256Hash), file.MIMEType, file.FileName, s.MaxTextBytes)
+		if err == nil {
+			text = extracted
+		} else if !errors.Is(err, textextract.ErrUnsupported) {
+			log.Printf("Failed to extract the text of content %d (%s): %v", content.ID, file.FileName, err)
+		}
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		contentText := models.ContentText{DeduplicatedContentID: content.ID, ExtractedText: text}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&contentText).Error; err != nil {
+			return err
+		}
+		return tx.Model(content).UpdateColumn("text_extracted_at", time.Now()).Error
+	})
+	if err != nil {
+		log.Printf("Failed to store the text of content %d: %v", content.ID, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+)
+
+func TestContentIndexKeepsTextApart(t *testing.T) {
+	db := testdb.Open(t, &models.DeduplicatedContent{}, &models.ContentText{}, &models.File{})
+	s := &ContentIndexService{DB: db, MaxTextBytes: 1024, BatchSize: 10}
+	// No file uses the content any more, so it is indexed as having no text.
+	content := &models.DeduplicatedContent{BaseModel: models.BaseModel{CreatedAt: time.Now().Add(-time.Hour)}, SHA256Hash: "orphan"}
+	create(t, db, content)
+
+	if s.indexBatch() {
+		t.Error("indexBatch reported more to do after indexing everything")
+	}
+	var contentText models.ContentText
+	if err := db.First(&contentText, "deduplicated_content_id = ?", content.ID).Error; err != nil {
+		t.Fatalf("no text stored for the content: %v", err)
+	}
+	var reloaded models.DeduplicatedContent
+	if err := db.First(&reloaded, content.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TextExtractedAt == nil {
+		t.Error("the content isn't marked as indexed")
+	}
+
+	// Indexing again replaces the text rather than adding another row.
+	if !s.index(&reloaded) {
+		t.Fatal("indexing the content again failed")
+	}
+	var count int64
+	db.Model(&models.ContentText{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d rows of text after indexing twice, want 1", count)
+	}
+}
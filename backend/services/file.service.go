@@ -14,24 +14,46 @@ import (
 	"strconv"
 
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/go-redis/redis/v8"
 	"github.com/joel2607/FileVault/database"
 	"github.com/joel2607/FileVault/models"
 	"github.com/joel2607/FileVault/services/storage"
-	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
 	"gorm.io/gorm"
 )
 
 // FileService provides methods for file management, including uploads,
 // deletions, and storage statistics.
 type FileService struct {
-	DB      *gorm.DB
-	RDB     *redis.Client
-	Storage storage.FileStorageProvider
+	DB            *gorm.DB
+	RDB           *redis.Client
+	Storage       storage.FileStorageProvider
+	Notifications *NotificationService
+	Webhooks      *WebhookService
+	Activity      *ActivityService
+	Audit         *AuditService
+	// QuotaWarningPercent is the share of a storage quota, in percent, at which its users are warned.
+	QuotaWarningPercent float64
+	// NameCollision is what happens when a file or folder would get the same name as a sibling.
+	NameCollision NameCollisionPolicy
 }
 
-// NewFileService creates a new instance of FileService.
-func NewFileService(db *gorm.DB, rdb *redis.Client, storage storage.FileStorageProvider) *FileService {
-	return &FileService{DB: db, RDB: rdb, Storage: storage}
+// NewFileService creates a new instance of FileService, reading the quota warning
+// threshold from notifications.quota_warning_percent and the name collision policy from
+// files.name_collision.
+func NewFileService(db *gorm.DB, rdb *redis.Client, storage storage.FileStorageProvider, notifications *NotificationService, webhooks *WebhookService, activity *ActivityService, audit *AuditService) *FileService {
+	viper.SetDefault("notifications.quota_warning_percent", 90)
+	return &FileService{
+		DB:                  db,
+		RDB:                 rdb,
+		Storage:             storage,
+		Notifications:       notifications,
+		Webhooks:            webhooks,
+		Activity:            activity,
+		Audit:               audit,
+		QuotaWarningPercent: viper.GetFloat64("notifications.quota_warning_percent"),
+		NameCollision:       NameCollisionPolicyFromConfig(),
+	}
 }
 
 func (s *FileService) GetStorageStatistics(userID uint) (*models.StorageStatistics, error) {
@@ -69,6 +91,45 @@ func (s *FileService) publishStorageUpdate(user *models.User) {
 	s.RDB.Publish(database.Ctx, channel, payload)
 }
 
+// GetOrganizationStorageStatistics returns the storage usage and deduplication savings
+// of an organization's shared storage pool.
+func (s *FileService) GetOrganizationStorageStatistics(organizationID uint) (*models.StorageStatistics, error) {
+	var organization models.Organization
+	if err := s.DB.First(&organization, organizationID).Error; err != nil {
+		return nil, err
+	}
+
+	var percentageSaved float64
+	if organization.UsedStorageKB > 0 {
+		percentageSaved = (organization.SavedStorageKB / organization.UsedStorageKB) * 100
+	}
+
+	orgID := strconv.FormatUint(uint64(organization.ID), 10)
+	return &models.StorageStatistics{
+		OrganizationID:  &orgID,
+		UsedStorageKb:   organization.UsedStorageKB,
+		SavedStorageKb:  organization.SavedStorageKB,
+		PercentageSaved: percentageSaved,
+	}, nil
+}
+
+func (s *FileService) publishOrganizationStorageUpdate(organizationID uint) {
+	stats, err := s.GetOrganizationStorageStatistics(organizationID)
+	if err != nil {
+		log.Printf("Error getting storage statistics for organization %d: %v", organizationID, err)
+		return
+	}
+
+	payload, err := json.Marshal(stats)
+	if err != nil {
+		log.Printf("Error marshalling storage statistics for organization %d: %v", organizationID, err)
+		return
+	}
+
+	channel := fmt.Sprintf("org_storage_updates_%d", organizationID)
+	s.RDB.Publish(database.Ctx, channel, payload)
+}
+
 // GenerateDownloadURL handles the logic for creating a secure, temporary download link for a file.
 // It checks for user permissions, increments the file's download count, and then delegates
 // the actual URL creation to the configured storage provider.
@@ -84,16 +145,9 @@ func (s *FileService) GenerateDownloadURL(ctx context.Context, fileID string, us
 		return "", fmt.Errorf("file not found")
 	}
 
-	// Authorization check: user must be the owner, the file must be public, or it must be shared with the user.
-	isOwner := file.UserID == user.ID
-	isPublic := file.IsPublic
-	var isShared bool
-	var fileShare models.FileSharing
-	if err := s.DB.Where("file_id = ? AND shared_with_user_id = ?", file.ID, user.ID).First(&fileShare).Error; err == nil {
-		isShared = true
-	}
-
-	if !isOwner && !isPublic && !isShared {
+	// Authorization check: user must be the owner, the file must be public, shared with the user,
+	// or in one of the user's organizations.
+	if !canReadFile(s.DB, &file, user) {
 		return "", fmt.Errorf("access denied: you do not have permission to download this file")
 	}
 
@@ -105,6 +159,13 @@ func (s *FileService) GenerateDownloadURL(ctx context.Context, fileID string, us
 		// Publish the update to Redis
 		s.PublishDownloadCountUpdate(file.ID, int32(file.DownloadCount+1))
 	}
+	s.Webhooks.Emit(FileEvent(EventFileDownloaded, &file, user))
+	s.Activity.RecordFile(models.ActivityActionDownloaded, &file, user, "")
+	if file.UserID != user.ID && isPubliclyVisible(file.IsPublic, file.PublicExpiresAt) {
+		notification := resourceNotification(models.NotificationTypePublicDownload, user, &file, nil, "%s downloaded %s, which you made public")
+		notification.UserID = file.UserID
+		s.Notifications.Notify(&notification)
+	}
 
 	var content models.DeduplicatedContent
 	if err := s.DB.First(&content, file.DeduplicationID).Error; err != nil {
@@ -146,18 +207,15 @@ func (s *FileService) SubscribeToFileDownloads(ctx context.Context, fileID strin
 		return nil, fmt.Errorf("file not found")
 	}
 
-	// Authorization check
-	isOwner := file.UserID == user.ID
-	isPublic := file.IsPublic
-	var isShared bool
-	var fileShare models.FileSharing
-	if err := s.DB.Where("file_id = ? AND shared_with_user_id = ?", file.ID, user.ID).First(&fileShare).Error; err == nil {
-		isShared = true
-	}
-	isAdmin := user.Role == "ADMIN"
-
-	if !isOwner && !isPublic && !isShared && !isAdmin {
-		return nil, fmt.Errorf("access denied")
+	// Authorization check. Admins can watch any file, but doing so is audited.
+	if !canReadFile(s.DB, &file, user) {
+		if user.Role != models.RoleAdmin {
+			return nil, fmt.Errorf("access denied")
+		}
+		s.Audit.Record(ctx, user, "admin.file_read", "file", file.ID, map[string]interface{}{
+			"ownerId": file.UserID,
+			"via":     "fileDownloadCount",
+		})
 	}
 
 	// Create channel and subscribe
@@ -196,10 +254,28 @@ func (s *FileService) SubscribeToFileDownloads(ctx context.Context, fileID strin
 }
 
 // SubscribeToStorageStatistics handles the business logic for subscribing to storage statistics updates.
-func (s *FileService) SubscribeToStorageStatistics(ctx context.Context, userID *string, currentUser *models.User) (<-chan *models.StorageStatistics, error) {
+// If an organization ID is given, the statistics of that organization's storage pool are streamed instead;
+// only its members and admins may subscribe to them.
+func (s *FileService) SubscribeToStorageStatistics(ctx context.Context, userID *string, organizationID *string, currentUser *models.User) (<-chan *models.StorageStatistics, error) {
+	if organizationID != nil {
+		id, err := strconv.ParseUint(*organizationID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid organization ID")
+		}
+		orgID := uint(id)
+		if membershipOf(s.DB, orgID, currentUser.ID) == nil {
+			if currentUser.Role != models.RoleAdmin {
+				return nil, fmt.Errorf("organization not found or access denied")
+			}
+			s.Audit.Record(ctx, currentUser, "admin.storage_subscribed", "organization", orgID, nil)
+		}
+		initialStats, err := s.GetOrganizationStorageStatistics(orgID)
+		return s.subscribeToStorageChannel(ctx, fmt.Sprintf("org_storage_updates_%d", orgID), initialStats, err), nil
+	}
+
 	var targetUserID uint
 	if userID != nil {
-		if currentUser.Role != "ADMIN" {
+		if currentUser.Role != models.RoleAdmin {
 			return nil, fmt.Errorf("only admins can view other users' statistics")
 		}
 		id, err := strconv.ParseUint(*userID, 10, 64)
@@ -207,17 +283,25 @@ func (s *FileService) SubscribeToStorageStatistics(ctx context.Context, userID *
 			return nil, fmt.Errorf("invalid user ID")
 		}
 		targetUserID = uint(id)
+		if targetUserID != currentUser.ID {
+			s.Audit.Record(ctx, currentUser, "admin.storage_subscribed", "user", targetUserID, nil)
+		}
 	} else {
 		targetUserID = currentUser.ID
 	}
 
+	initialStats, err := s.GetStorageStatistics(targetUserID)
+	return s.subscribeToStorageChannel(ctx, fmt.Sprintf("storage_updates_%d", targetUserID), initialStats, err), nil
+}
+
+// subscribeToStorageChannel streams the storage statistics published on a Redis channel,
+// starting with the initial statistics if they could be loaded.
+func (s *FileService) subscribeToStorageChannel(ctx context.Context, channel string, initialStats *models.StorageStatistics, initialErr error) <-chan *models.StorageStatistics {
 	ch := make(chan *models.StorageStatistics, 1)
-	channel := fmt.Sprintf("storage_updates_%d", targetUserID)
 	pubsub := s.RDB.Subscribe(ctx, channel)
 
 	// Send initial data
-	initialStats, err := s.GetStorageStatistics(targetUserID)
-	if err == nil {
+	if initialErr == nil {
 		ch <- initialStats
 	}
 
@@ -241,7 +325,7 @@ func (s *FileService) SubscribeToStorageStatistics(ctx context.Context, userID *
 		}
 	}()
 
-	return ch, nil
+	return ch
 }
 
 // UploadFile handles the entire file upload process.
@@ -254,17 +338,43 @@ func (s *FileService) SubscribeToStorageStatistics(ctx context.Context, userID *
 // - file: The graphql.Upload object containing the file data and metadata.
 // - user: The user model of the person uploading the file.
 // - parentFolderID: An optional string pointer to the ID of the folder where the file should be placed.
+// - tags: Tags to give the file; they are trimmed and lowercased.
 //
 // Outputs:
 // - A pointer to the created models.File object if successful.
 // - An error if any part of the process fails.
-func (s *FileService) UploadFile(ctx context.Context, file graphql.Upload, user *models.User, parentFolderID *string) (*models.File, error) {
+func (s *FileService) UploadFile(ctx context.Context, file graphql.Upload, user *models.User, parentFolderID *string, tags []string) (*models.File, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > maxTagsPerFile {
+		return nil, fmt.Errorf("a file can have at most %d tags", maxTagsPerFile)
+	}
 
-	// Check for whether the user has enough storage quota
-	if user.UsedStorageKB - user.SavedStorageKB + float64(file.Size)/1024 > user.StorageQuotaKB {
-		return nil, fmt.Errorf("storage quota exceeded")
+	// Work out the target folder and, for team folders, the organization the file is charged to
+	folderID, organization, err := s.resolveUploadFolder(parentFolderID, user)
+	if err != nil {
+		return nil, err
+	}
+	fileName, replaced, err := s.placeFile(s.fileSiblings(folderID, user.ID, 0), file.Filename, user)
+	if err != nil {
+		return nil, err
 	}
 
+	// Check for whether the user (or the organization, for team folders) has enough storage quota,
+	// counting the storage freed by the files the upload replaces
+	freedKB, err := s.replacedStorageKB(replaced)
+	if err != nil {
+		return nil, err
+	}
+	if organization != nil {
+		if organization.UsedStorageKB-organization.SavedStorageKB-freedKB+float64(file.Size)/1024 > organization.StorageQuotaKB {
+			return nil, fmt.Errorf("organization storage quota exceeded")
+		}
+	} else if user.UsedStorageKB-user.SavedStorageKB-freedKB+float64(file.Size)/1024 > user.StorageQuotaKB {
+		return nil, fmt.Errorf("storage quota exceeded")
+	}
 
 	log.Printf("Uploading file: %s of size %d bytes\n", file.Filename, file.Size)
 	// 1. Hashing
@@ -281,15 +391,15 @@ func (s *FileService) UploadFile(ctx context.Context, file graphql.Upload, user
 		// Content exists, create new file metadata and point to existing content
 		newFile := &models.File{
 			UserID:          user.ID,
-			FileName:        file.Filename,
+			FileName:        fileName,
 			MIMEType:        file.ContentType,
 			Size:            file.Size,
 			DeduplicationID: existingContent.ID,
+			Tags:            encodeTags(tags),
 		}
-		if parentFolderID != nil {
-			id, _ := strconv.ParseUint(*parentFolderID, 10, 64)
-			uid := uint(id)
-			newFile.FolderID = &uid
+		newFile.FolderID = folderID
+		if organization != nil {
+			newFile.OrganizationID = &organization.ID
 		}
 		if err := s.DB.Create(newFile).Error; err != nil {
 			return nil, err
@@ -297,13 +407,12 @@ func (s *FileService) UploadFile(ctx context.Context, file graphql.Upload, user
 		existingContent.ReferenceCount++
 		s.DB.Save(&existingContent)
 
-		// Update user's storage usage. They save space by not uploading duplicate data.
+		// Update storage usage. They save space by not uploading duplicate data.
 		storageChangeKB := float64(file.Size) / 1024
-		user.SavedStorageKB += storageChangeKB
-		user.UsedStorageKB += storageChangeKB
-		log.Printf("User used storage(saved): %f", user.UsedStorageKB)
-		s.DB.Save(user)
-		s.publishStorageUpdate(user)
+		s.chargeStorage(user, newFile.OrganizationID, storageChangeKB, storageChangeKB)
+		s.Webhooks.Emit(FileEvent(EventFileUploaded, newFile, user))
+		s.Activity.RecordFile(models.ActivityActionUploaded, newFile, user, "")
+		s.replaceFiles(ctx, replaced, user)
 		return newFile, nil
 	}
 
@@ -341,30 +450,125 @@ func (s *FileService) UploadFile(ctx context.Context, file graphql.Upload, user
 	}
 	newFile := &models.File{
 		UserID:          user.ID,
-		FileName:        file.Filename,
+		FileName:        fileName,
 		MIMEType:        file.ContentType,
 		Size:            file.Size,
 		DeduplicationID: newContent.ID,
+		Tags:            encodeTags(tags),
 	}
-	if parentFolderID != nil {
-		id, _ := strconv.ParseUint(*parentFolderID, 10, 64)
-		uid := uint(id)
-		newFile.FolderID = &uid
+	newFile.FolderID = folderID
+	if organization != nil {
+		newFile.OrganizationID = &organization.ID
 	}
 	if err := s.DB.Create(newFile).Error; err != nil {
 		return nil, err
 	}
 
-	// Update user's storage usage. They dont save space as this is new data.
-	user.UsedStorageKB += float64(file.Size) / 1024
+	// Update storage usage. They dont save space as this is new data.
+	s.chargeStorage(user, newFile.OrganizationID, float64(file.Size)/1024, 0)
+	s.Webhooks.Emit(FileEvent(EventFileUploaded, newFile, user))
+	s.Activity.RecordFile(models.ActivityActionUploaded, newFile, user, "")
+	s.replaceFiles(ctx, replaced, user)
+
+	return newFile, nil
+}
+
+// resolveUploadFolder parses and checks the optional parent folder ID of an upload. If the
+// folder is a team folder, it returns the organization, whose storage pool the upload is
+// charged to.
+func (s *FileService) resolveUploadFolder(parentFolderID *string, user *models.User) (*uint, *models.Organization, error) {
+	if parentFolderID == nil {
+		return nil, nil, nil
+	}
+	folder, err := s.destinationFolder(*parentFolderID, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if folder.OrganizationID == nil {
+		return &folder.ID, nil, nil
+	}
+
+	var organization models.Organization
+	if err := s.DB.First(&organization, *folder.OrganizationID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &folder.ID, &organization, nil
+}
+
+// chargeStorage adds to the used and saved storage of the pool a file is charged to:
+// the organization for team files, or the user otherwise. Negative values release storage.
+// The new statistics are then published to subscribers.
+func (s *FileService) chargeStorage(user *models.User, organizationID *uint, usedKB float64, savedKB float64) {
+	if organizationID != nil {
+		if err := s.DB.Model(&models.Organization{}).Where("id = ?", *organizationID).Updates(map[string]interface{}{
+			"used_storage_kb":  gorm.Expr("used_storage_kb + ?", usedKB),
+			"saved_storage_kb": gorm.Expr("saved_storage_kb + ?", savedKB),
+		}).Error; err != nil {
+			log.Printf("Failed to update storage usage for organization %d: %v", *organizationID, err)
+		}
+		s.publishOrganizationStorageUpdate(*organizationID)
+
+		var organization models.Organization
+		if err := s.DB.First(&organization, *organizationID).Error; err == nil &&
+			s.crossesQuotaWarning(organization.UsedStorageKB-organization.SavedStorageKB, usedKB-savedKB, organization.StorageQuotaKB) {
+			var managerIDs []uint
+			s.DB.Model(&models.Membership{}).Where("organization_id = ? AND role IN ?", organization.ID, []models.OrganizationRole{models.OrgRoleOwner, models.OrgRoleAdmin}).
+				Pluck("user_id", &managerIDs)
+			s.Notifications.NotifyAll(managerIDs, models.Notification{
+				Type:    string(models.NotificationTypeQuotaNearlyFull),
+				Message: fmt.Sprintf("The organization %s has used %.0f%% of its storage quota", organization.Name, s.QuotaWarningPercent),
+			})
+		}
+		return
+	}
+
+	// Only the storage columns are written, so a suspension, forced logout, role or quota change
+	// made by an admin while the request was running isn't overwritten with stale values.
+	if err := s.DB.Model(user).Updates(map[string]interface{}{
+		"used_storage_kb":  gorm.Expr("used_storage_kb + ?", usedKB),
+		"saved_storage_kb": gorm.Expr("saved_storage_kb + ?", savedKB),
+	}).Error; err != nil {
+		log.Printf("Failed to update storage usage for user %d: %v", user.ID, err)
+	}
+	s.DB.Select("used_storage_kb", "saved_storage_kb").Take(user, user.ID)
 	log.Printf("User used storage: %f", user.UsedStorageKB)
-	s.DB.Save(user)
 	s.publishStorageUpdate(user)
 
-	return newFile, nil
+	if s.crossesQuotaWarning(user.UsedStorageKB-user.SavedStorageKB, usedKB-savedKB, user.StorageQuotaKB) {
+		s.Notifications.Notify(&models.Notification{
+			UserID:  user.ID,
+			Type:    string(models.NotificationTypeQuotaNearlyFull),
+			Message: fmt.Sprintf("You have used %.0f%% of your storage quota", s.QuotaWarningPercent),
+		})
+	}
+}
+
+// crossesQuotaWarning reports whether a change of changeKB in net storage use brought usage
+// of usedKB up past the quota warning threshold.
+func (s *FileService) crossesQuotaWarning(usedKB float64, changeKB float64, quotaKB float64) bool {
+	threshold := quotaKB * s.QuotaWarningPercent / 100
+	return changeKB > 0 && usedKB >= threshold && usedKB-changeKB < threshold
+}
+
+// sameStoragePool reports whether two organization IDs (nil meaning personal storage)
+// refer to the same storage pool.
+func sameStoragePool(a *uint, b *uint) bool {
+	return sameOptionalID(a, b)
+}
+
+// sameOptionalID reports whether two optional IDs are equal, two nils being equal.
+func sameOptionalID(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
 }
 
 // CreateFolder creates a new folder for a given user.
+// A folder created inside a team folder, or with an organization ID at the top level,
+// is a team folder of that organization; the user must be a member of it. Other subfolders
+// can only be created in the user's own folders. A name taken in the parent folder is
+// handled by the name collision policy.
 //
 // Inputs:
 // - ctx: The context for the request.
@@ -380,16 +584,40 @@ func (s *FileService) CreateFolder(ctx context.Context, input models.NewFolder,
 		FolderName: input.FolderName,
 	}
 	if input.ParentFolderID != nil {
-		id, _ := strconv.ParseUint(*input.ParentFolderID, 10, 64)
-		uid := uint(id)
-		folder.ParentFolderID = &uid
+		parent, err := s.destinationFolder(*input.ParentFolderID, user)
+		if err != nil {
+			return nil, err
+		}
+		folder.ParentFolderID = &parent.ID
+		// Subfolders of team folders belong to the same organization
+		folder.OrganizationID = parent.OrganizationID
+	} else if input.OrganizationID != nil {
+		id, err := strconv.ParseUint(*input.OrganizationID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid organization ID")
+		}
+		organizationID := uint(id)
+		if membershipOf(s.DB, organizationID, user.ID) == nil {
+			return nil, fmt.Errorf("access denied: you are not a member of this organization")
+		}
+		folder.OrganizationID = &organizationID
 	}
-	err := s.DB.Create(folder).Error
-	return folder, err
+	name, err := s.placeFolder(s.folderSiblings(folder.ParentFolderID, user.ID, folder.OrganizationID, 0), folder.FolderName)
+	if err != nil {
+		return nil, err
+	}
+	folder.FolderName = name
+	if err := s.DB.Create(folder).Error; err != nil {
+		return nil, err
+	}
+	s.Webhooks.Emit(FolderEvent(EventFolderCreated, folder, user))
+	s.Activity.RecordFolder(models.ActivityActionCreated, folder, user, "")
+	return folder, nil
 }
 
 // UpdateFile modifies an existing file's metadata, such as its name or parent folder.
-// It ensures that the user attempting the update is the owner of the file.
+// It ensures that the user attempting the update is the owner of the file and may add to the
+// target folder. A name taken in the target folder is handled by the name collision policy.
 //
 // Inputs:
 // - ctx: The context for the request.
@@ -408,22 +636,43 @@ func (s *FileService) UpdateFile(ctx context.Context, input models.UpdateFile, u
 	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
 		return nil, err
 	}
+	previous := file
 	if input.FileName != nil {
 		file.FileName = *input.FileName
 	}
 	if input.ParentFolderID != nil {
-		id, _ := strconv.ParseUint(*input.ParentFolderID, 10, 64)
-		parsedID := uint(id)
-		file.FolderID = &parsedID
+		target, err := s.destinationFolder(*input.ParentFolderID, user)
+		if err != nil {
+			return nil, err
+		}
+		if !sameStoragePool(target.OrganizationID, file.OrganizationID) {
+			return nil, fmt.Errorf("cannot move files between personal and organization storage")
+		}
+		file.FolderID = &target.ID
 	}
-	err = s.DB.Save(&file).Error
-	return &file, err
+	var replaced []uint
+	if file.FileName != previous.FileName || !sameOptionalID(file.FolderID, previous.FolderID) {
+		if file.FileName, replaced, err = s.placeFile(s.fileSiblings(file.FolderID, file.UserID, file.ID), file.FileName, user); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.DB.Save(&file).Error; err != nil {
+		return &file, err
+	}
+	s.replaceFiles(ctx, replaced, user)
+	if file.FileName != previous.FileName {
+		s.Activity.RecordFile(models.ActivityActionRenamed, &file, user, "renamed from "+previous.FileName)
+	}
+	if !sameOptionalID(file.FolderID, previous.FolderID) {
+		s.Activity.RecordFile(models.ActivityActionMoved, &file, user, movedFrom(previous.FolderID))
+	}
+	return &file, nil
 }
 
 // DeleteFile removes a file's metadata from the database and handles the deduplication logic.
-// It decrements the reference count of the associated content. If the count reaches zero,
-// it deletes the actual file from storage, the content record from the database and
-// updates the user's storage usage statistics.
+// It removes any shares of the file and decrements the reference count of the associated
+// content. If the count reaches zero, it deletes the actual file from storage, the content
+// record from the database and updates the user's storage usage statistics.
 //
 // Inputs:
 // - ctx: The context for the request.
@@ -434,49 +683,100 @@ func (s *FileService) UpdateFile(ctx context.Context, input models.UpdateFile, u
 // - A boolean indicating whether the deletion was successful.
 // - An error if the database operation fails.
 func (s *FileService) DeleteFile(ctx context.Context, id string, user *models.User) (*models.File, error) {
-	uid, _ := strconv.ParseUint(id, 10, 64)
+	uid, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
 	var file models.File
-	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+	if err := s.DB.First(&file, uid).Error; err != nil {
 		return nil, err
 	}
+	// Team files may also be deleted by the organization's owner and admins
+	if file.UserID != user.ID && !canManageOrganization(s.DB, file.OrganizationID, user) {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
 
 	fileSizeKB := float64(file.Size) / 1024
 
-	// First delete file record to prevent deduplicated content delete fail.
-	err := s.DB.Delete(&file).Error
+	// The file, what refers to it and its share of the content go in one transaction, so a
+	// failure leaves the file as it was and the deletion can be retried.
+	var content models.DeduplicatedContent
+	contentFound, contentOrphaned := false, false
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Remove any shares of the file so they don't block the delete.
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileSharing{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileGroupSharing{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.ShareInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.AccessRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.OwnershipTransfer{}).Error; err != nil {
+			return err
+		}
+		if err := deleteComments(tx, tx.Model(&models.Comment{}).Select("id").Where("file_id = ?", file.ID)); err != nil {
+			return err
+		}
+
+		// First delete file record to prevent deduplicated content delete fail.
+		if err := tx.Delete(&file).Error; err != nil {
+			return err
+		}
+		// Decrement reference count
+		decrement := tx.Model(&models.DeduplicatedContent{}).Where("id = ?", file.DeduplicationID).
+			Update("reference_count", gorm.Expr("reference_count - 1"))
+		if decrement.Error != nil {
+			return decrement.Error
+		}
+		if decrement.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.First(&content, file.DeduplicationID).Error; err != nil {
+			return err
+		}
+		contentFound = true
+		if content.ReferenceCount <= 0 {
+			contentOrphaned = true
+			return tx.Delete(&content).Error
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	// Decrement reference count
-	var content models.DeduplicatedContent
-	if err := s.DB.First(&content, file.DeduplicationID).Error; err == nil {
-		content.ReferenceCount--
-		s.DB.Save(&content)
-		// Update user's storage usage
-		user.UsedStorageKB -= fileSizeKB
-		s.DB.Save(user)
-		if content.ReferenceCount <= 0 {
-			// Delete file from storage
-			if err := s.DB.Delete(&content).Error; err != nil {
-				log.Println(err)
-				log.Println("Failed to delete deduplicated content with hash:", content.SHA256Hash)
-			}
+
+	if contentFound {
+		savedChangeKB := 0.0
+		if contentOrphaned {
+			// Delete file from storage once nothing in the database refers to it
 			filePath := filepath.Join("./uploads", content.SHA256Hash)
-			os.Remove(filePath)
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				log.Println("Failed to delete stored content with hash:", content.SHA256Hash, err)
+			}
 		} else {
-			// Update user's storage usage. They save less space now as one less reference.
-			user.SavedStorageKB -= fileSizeKB
-			s.DB.Save(user)
+			// They save less space now as one less reference.
+			savedChangeKB = fileSizeKB
 		}
+		// Update the storage usage of the user, or of the organization for team files
+		s.chargeStorage(user, file.OrganizationID, -fileSizeKB, -savedChangeKB)
 	}
 
-	s.publishStorageUpdate(user)
+	s.Webhooks.Emit(FileEvent(EventFileDeleted, &file, user))
+	s.Activity.RecordFile(models.ActivityActionDeleted, &file, user, "")
+
 	return &file, nil
 
 }
 
 // UpdateFolder modifies an existing folder's properties, such as its name or parent folder.
-// It ensures that the user attempting the update is the owner of the folder.
+// It ensures that the user attempting the update is the owner of the folder and may add to the
+// target folder, and that the folder isn't moved into itself or one of its subfolders.
+// A name taken in the target folder is handled by the name collision policy.
 //
 // Inputs:
 // - ctx: The context for the request.
@@ -495,22 +795,41 @@ func (s *FileService) UpdateFolder(ctx context.Context, input models.UpdateFolde
 	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
 		return nil, err
 	}
+	previous := folder
 	if input.FolderName != nil {
 		folder.FolderName = *input.FolderName
 	}
 	if input.ParentFolderID != nil {
-		id, _ := strconv.ParseUint(*input.ParentFolderID, 10, 64)
-		parsedID := uint(id)
-		if parsedID == folder.ID {
-			return nil, fmt.Errorf("cannot move a folder into itself")
+		target, err := s.destinationFolder(*input.ParentFolderID, user)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkFolderMove(&folder, target); err != nil {
+			return nil, err
+		}
+		if !sameStoragePool(target.OrganizationID, folder.OrganizationID) {
+			return nil, fmt.Errorf("cannot move folders between personal and organization storage")
+		}
+		folder.ParentFolderID = &target.ID
+	}
+	if folder.FolderName != previous.FolderName || !sameOptionalID(folder.ParentFolderID, previous.ParentFolderID) {
+		if folder.FolderName, err = s.placeFolder(s.folderSiblings(folder.ParentFolderID, folder.UserID, folder.OrganizationID, folder.ID), folder.FolderName); err != nil {
+			return nil, err
 		}
-		folder.ParentFolderID = &parsedID
 	}
-	err = s.DB.Save(&folder).Error
-	return &folder, err
+	if err := s.DB.Save(&folder).Error; err != nil {
+		return &folder, err
+	}
+	if folder.FolderName != previous.FolderName {
+		s.Activity.RecordFolder(models.ActivityActionRenamed, &folder, user, "renamed from "+previous.FolderName)
+	}
+	if !sameOptionalID(folder.ParentFolderID, previous.ParentFolderID) {
+		s.Activity.RecordFolder(models.ActivityActionMoved, &folder, user, movedFrom(previous.ParentFolderID))
+	}
+	return &folder, nil
 }
 
-// DeleteFolder removes a folder from the database.
+// DeleteFolder removes a folder, its files, its subfolders and any shares of it from the database.
 // It ensures that the user attempting the deletion is the owner of the folder.
 // Note: This is a simple implementation. A production system would need to handle orphaned files or subfolders.
 //
@@ -523,11 +842,18 @@ func (s *FileService) UpdateFolder(ctx context.Context, input models.UpdateFolde
 // - A boolean indicating whether the deletion was successful.
 // - An error if the database operation fails.
 func (s *FileService) DeleteFolder(ctx context.Context, id string, user *models.User) (*models.Folder, error) {
-	uid, _ := strconv.ParseUint(id, 10, 64)
+	uid, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder ID")
+	}
 	var folder models.Folder
-	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+	if err := s.DB.First(&folder, uid).Error; err != nil {
 		return nil, err
 	}
+	// Team folders may also be deleted by the organization's owner and admins
+	if folder.UserID != user.ID && !canManageOrganization(s.DB, folder.OrganizationID, user) {
+		return nil, fmt.Errorf("folder not found or access denied")
+	}
 
 	// Find all files in the folder
 	var files []*models.File
@@ -557,13 +883,42 @@ func (s *FileService) DeleteFolder(ctx context.Context, id string, user *models.
 		}
 	}
 
-	err := s.DB.Delete(&folder).Error
+	// The folder and what refers to it go together, so a failure leaves the folder in place
+	// and the deletion can be retried.
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("folder_id = ?", folder.ID).Delete(&models.FolderSharing{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("folder_id = ?", folder.ID).Delete(&models.FolderGroupSharing{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("folder_id = ?", folder.ID).Delete(&models.ShareInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("folder_id = ?", folder.ID).Delete(&models.AccessRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("folder_id = ?", folder.ID).Delete(&models.OwnershipTransfer{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&folder).Error
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.Webhooks.Emit(FolderEvent(EventFolderDeleted, &folder, user))
+	s.Activity.RecordFolder(models.ActivityActionDeleted, &folder, user, "")
 	return &folder, nil
 }
 
+// movedFrom describes where a moved item came from, for its MOVED activity.
+func movedFrom(folderID *uint) string {
+	if folderID == nil {
+		return "moved from the root folder"
+	}
+	return fmt.Sprintf("moved from folder %d", *folderID)
+}
+
 // isValidMIME validates the actual content type of a file against its declared MIME type.
 // It reads the first 512 bytes of the file to determine the real MIME type and also checks
 // the file extension as a fallback.
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChargeStorageKeepsAdminChanges(t *testing.T) {
+	db := openPlacementTestDB(t)
+	s := &FileService{DB: db, RDB: newTestRedis(t), QuotaWarningPercent: 90}
+	alice := createTestUser(t, db, "alice")
+
+	// An admin suspends alice while one of her uploads is running with the user it loaded earlier.
+	stale := reloadUser(t, db, alice)
+	now := time.Now()
+	if err := db.Model(alice).Updates(map[string]interface{}{"suspended_at": now, "token_version": 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.chargeStorage(stale, nil, 100, 40)
+
+	got := reloadUser(t, db, alice)
+	if got.SuspendedAt == nil || got.TokenVersion != 1 {
+		t.Errorf("suspended at %v with token version %d after charging storage, want the suspension kept", got.SuspendedAt, got.TokenVersion)
+	}
+	if got.UsedStorageKB != 100 || got.SavedStorageKB != 40 {
+		t.Errorf("used %v KB and saved %v KB, want 100 and 40", got.UsedStorageKB, got.SavedStorageKB)
+	}
+	if stale.UsedStorageKB != 100 || stale.SavedStorageKB != 40 {
+		t.Errorf("the request's user has used %v KB and saved %v KB, want 100 and 40", stale.UsedStorageKB, stale.SavedStorageKB)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// newTestRedis returns a client for an in-memory Redis that lives as long as the test.
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+// createTestUser creates a user with the default quota.
+func createTestUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTestFolder creates a folder owned by owner, inside parent if it isn't nil. Folders in
+// team folders belong to the same organization.
+func createTestFolder(t *testing.T, db *gorm.DB, owner *models.User, name string, parent *models.Folder) *models.Folder {
+	t.Helper()
+	folder := &models.Folder{UserID: owner.ID, FolderName: name}
+	if parent != nil {
+		folder.ParentFolderID = &parent.ID
+		folder.OrganizationID = parent.OrganizationID
+	}
+	if err := db.Create(folder).Error; err != nil {
+		t.Fatal(err)
+	}
+	return folder
+}
+
+// createTestFile creates a one-byte file with content of its own, inside folder if it isn't nil.
+func createTestFile(t *testing.T, db *gorm.DB, owner *models.User, name string, folder *models.Folder) *models.File {
+	t.Helper()
+	content := &models.DeduplicatedContent{SHA256Hash: name + "-" + owner.Username, ReferenceCount: 1}
+	if err := db.Create(content).Error; err != nil {
+		t.Fatal(err)
+	}
+	file := &models.File{UserID: owner.ID, FileName: name, MIMEType: "application/pdf", Size: 1, DeduplicationID: content.ID, Tags: "[]"}
+	if folder != nil {
+		file.FolderID = &folder.ID
+		file.OrganizationID = folder.OrganizationID
+	}
+	if err := db.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// create inserts rows, failing the test if that doesn't work.
+func create(t *testing.T, db *gorm.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// reloadUser returns a user as currently stored.
+func reloadUser(t *testing.T, db *gorm.DB, user *models.User) *models.User {
+	t.Helper()
+	var reloaded models.User
+	if err := db.First(&reloaded, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &reloaded
+}
+
+// reloadFile returns a file as currently stored.
+func reloadFile(t *testing.T, db *gorm.DB, file *models.File) *models.File {
+	t.Helper()
+	var reloaded models.File
+	if err := db.First(&reloaded, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &reloaded
+}
+
+// apiID formats an ID as the API takes it.
+func apiID(value uint) *string {
+	s := strconv.FormatUint(uint64(value), 10)
+	return &s
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// FolderStats sums up everything in a folder at any depth.
+type FolderStats struct {
+	FolderID uint
+	// TotalSize is the size of the files, in bytes.
+	TotalSize int64
+	FileCount int
+	// FolderCount is the number of subfolders, not counting the folder itself.
+	FolderCount int
+	// DedupSavedBytes is the size of the files whose content was already stored when they were
+	// uploaded, and so took no extra space.
+	DedupSavedBytes int64
+	// LastModified is when the folder or anything in it was last created or changed.
+	LastModified time.Time
+}
+
+// FolderStatistics computes the stats of each folder with one recursive query. Folders that
+// don't exist get no stats.
+func FolderStatistics(db *gorm.DB, folderIDs []uint) (map[uint]*FolderStats, error) {
+	var rows []*FolderStats
+	// UNION rather than UNION ALL stops at folders already visited, so a cycle can't make
+	// the query run forever. A file saved space if it isn't the first file, by ID, to use its
+	// content; copies are numbered across all files with that content, through the index on
+	// files.deduplication_id, rather than only those in the subtree.
+	err := db.Raw(`
+		WITH RECURSIVE subtree AS (
+			SELECT folders.id AS root_id, folders.id
+			FROM folders WHERE folders.id IN ?
+			UNION
+			SELECT subtree.root_id, folders.id
+			FROM folders JOIN subtree ON folders.parent_folder_id = subtree.id
+		),
+		subtree_files AS (
+			SELECT subtree.root_id, files.id, files.size, files.deduplication_id, files.updated_at
+			FROM subtree JOIN files ON files.folder_id = subtree.id
+		),
+		copies AS (
+			SELECT files.id, ROW_NUMBER() OVER (PARTITION BY files.deduplication_id ORDER BY files.id) AS copy
+			FROM files WHERE files.deduplication_id IN (SELECT deduplication_id FROM subtree_files)
+		),
+		folder_stats AS (
+			SELECT subtree.root_id, COUNT(*) - 1 AS folder_count, MAX(folders.updated_at) AS last_modified
+			FROM subtree JOIN folders ON folders.id = subtree.id
+			GROUP BY subtree.root_id
+		),
+		file_stats AS (
+			SELECT subtree_files.root_id,
+				COUNT(*) AS file_count,
+				SUM(subtree_files.size)::bigint AS total_size,
+				SUM(CASE WHEN copies.copy > 1 THEN subtree_files.size ELSE 0 END)::bigint AS dedup_saved_bytes,
+				MAX(subtree_files.updated_at) AS last_modified
+			FROM subtree_files JOIN copies ON copies.id = subtree_files.id
+			GROUP BY subtree_files.root_id
+		)
+		SELECT folder_stats.root_id AS folder_id,
+			folder_stats.folder_count,
+			COALESCE(file_stats.file_count, 0) AS file_count,
+			COALESCE(file_stats.total_size, 0) AS total_size,
+			COALESCE(file_stats.dedup_saved_bytes, 0) AS dedup_saved_bytes,
+			GREATEST(folder_stats.last_modified, file_stats.last_modified) AS last_modified
+		FROM folder_stats LEFT JOIN file_stats ON file_stats.root_id = folder_stats.root_id`, folderIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[uint]*FolderStats, len(rows))
+	for _, row := range rows {
+		stats[row.FolderID] = row
+	}
+	return stats, nil
+}
+
+// folderStatsGenerationKey holds a counter that is bumped on every write to files or folders.
+// Cached stats are stored under the generation they were computed in, so a write makes
+// everything cached before it unreachable, however far up the tree it reaches.
+const folderStatsGenerationKey = "folder_stats:generation"
+
+// FolderStatsCache keeps folder stats in Redis, so that FolderStatistics only runs again once
+// files or folders have changed. Entries also expire after TTL, which bounds how long stats
+// computed while a write was still uncommitted can be served.
+type FolderStatsCache struct {
+	RDB *redis.Client
+	TTL time.Duration
+	// compute computes stats that aren't cached; FolderStatistics if nil.
+	compute func(db *gorm.DB, folderIDs []uint) (map[uint]*FolderStats, error)
+}
+
+// NewFolderStatsCache creates a FolderStatsCache keeping stats for the
+// files.stats_cache_seconds setting.
+func NewFolderStatsCache(rdb *redis.Client) *FolderStatsCache {
+	viper.SetDefault("files.stats_cache_seconds", 60)
+	return &FolderStatsCache{RDB: rdb, TTL: time.Duration(viper.GetInt("files.stats_cache_seconds")) * time.Second}
+}
+
+// RegisterCallbacks makes every create, update and delete of files or folders through db
+// invalidate the cached stats.
+func (c *FolderStatsCache) RegisterCallbacks(db *gorm.DB) error {
+	invalidate := func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+		if table := db.Statement.Schema.Table; table == "files" || table == "folders" {
+			c.Invalidate(db.Statement.Context)
+		}
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("folder_stats:invalidate", invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("folder_stats:invalidate", invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("folder_stats:invalidate", invalidate)
+}
+
+// Invalidate makes all cached stats stale.
+func (c *FolderStatsCache) Invalidate(ctx context.Context) {
+	if err := c.RDB.Incr(ctx, folderStatsGenerationKey).Err(); err != nil {
+		log.Printf("Failed to invalidate cached folder stats: %v", err)
+	}
+}
+
+// Statistics returns the stats of each folder as FolderStatistics does, computing only those
+// that aren't cached. If Redis is unavailable, every folder's stats are computed.
+func (c *FolderStatsCache) Statistics(ctx context.Context, db *gorm.DB, folderIDs []uint) (map[uint]*FolderStats, error) {
+	compute := c.compute
+	if compute == nil {
+		compute = FolderStatistics
+	}
+	generation, err := c.RDB.Get(ctx, folderStatsGenerationKey).Result()
+	if err == redis.Nil {
+		generation = "0"
+	} else if err != nil {
+		return compute(db, folderIDs)
+	}
+	key := func(folderID uint) string {
+		return fmt.Sprintf("folder_stats:%s:%d", generation, folderID)
+	}
+
+	keys := make([]string, len(folderIDs))
+	for i, folderID := range folderIDs {
+		keys[i] = key(folderID)
+	}
+	cached, err := c.RDB.MGet(ctx, keys...).Result()
+	if err != nil {
+		return compute(db, folderIDs)
+	}
+
+	stats := make(map[uint]*FolderStats, len(folderIDs))
+	var missing []uint
+	for i, folderID := range folderIDs {
+		value, ok := cached[i].(string)
+		var entry FolderStats
+		if !ok || json.Unmarshal([]byte(value), &entry) != nil {
+			missing = append(missing, folderID)
+			continue
+		}
+		stats[folderID] = &entry
+	}
+	if len(missing) == 0 {
+		return stats, nil
+	}
+
+	computed, err := compute(db, missing)
+	if err != nil {
+		return nil, err
+	}
+	pipe := c.RDB.Pipeline()
+	for folderID, entry := range computed {
+		stats[folderID] = entry
+		if value, err := json.Marshal(entry); err == nil {
+			pipe.Set(ctx, key(folderID), value, c.TTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to cache folder stats: %v", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestFolderStatsCache(t *testing.T) {
+	ctx := context.Background()
+	db := openShareTestDB(t)
+	// SQLite can't run the stats query, so stats are made up: each folder's file count is
+	// how many times it has been computed.
+	var computed [][]uint
+	cache := &FolderStatsCache{RDB: newTestRedis(t), TTL: time.Minute,
+		compute: func(db *gorm.DB, folderIDs []uint) (map[uint]*FolderStats, error) {
+			computed = append(computed, folderIDs)
+			stats := make(map[uint]*FolderStats, len(folderIDs))
+			for _, folderID := range folderIDs {
+				stats[folderID] = &FolderStats{FolderID: folderID, FileCount: len(computed)}
+			}
+			return stats, nil
+		}}
+	if err := cache.RegisterCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	alice := createTestUser(t, db, "alice")
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	drafts := createTestFolder(t, db, alice, "Drafts", projects)
+
+	load := func(folderIDs ...uint) map[uint]*FolderStats {
+		t.Helper()
+		stats, err := cache.Statistics(ctx, db, folderIDs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	load(projects.ID)
+	stats := load(projects.ID, drafts.ID)
+	if want := [][]uint{{projects.ID}, {drafts.ID}}; !reflect.DeepEqual(computed, want) {
+		t.Errorf("computed %v, want only what wasn't cached: %v", computed, want)
+	}
+	if stats[projects.ID].FileCount != 1 || stats[drafts.ID].FileCount != 2 {
+		t.Errorf("got %+v and %+v, want the cached stats of Projects", stats[projects.ID], stats[drafts.ID])
+	}
+
+	// Any change to files or folders makes the cached stats stale.
+	createTestFile(t, db, alice, "report.pdf", drafts)
+	stats = load(projects.ID)
+	if len(computed) != 3 || stats[projects.ID].FileCount != 3 {
+		t.Errorf("got %+v after adding a file, want fresh stats", stats[projects.ID])
+	}
+	db.Model(drafts).Update("folder_name", "Old drafts")
+	load(projects.ID)
+	db.Delete(drafts)
+	load(projects.ID)
+	if len(computed) != 5 {
+		t.Errorf("stats were computed %d times, want again after the rename and the delete", len(computed))
+	}
+}
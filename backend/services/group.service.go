@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// GroupService manages user groups, which files and folders can be shared with.
+// A group is owned by a user, who manages it, or by an organization, whose owner
+// and admins manage it. Members of an organization's groups must belong to the organization.
+type GroupService struct {
+	DB *gorm.DB
+}
+
+// NewGroupService creates a new instance of GroupService.
+func NewGroupService(db *gorm.DB) *GroupService {
+	return &GroupService{DB: db}
+}
+
+// findGroup loads the group with the given string ID.
+func findGroup(db *gorm.DB, groupID string) (*models.Group, error) {
+	id, err := strconv.ParseUint(groupID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group ID")
+	}
+	var group models.Group
+	if err := db.First(&group, id).Error; err != nil {
+		return nil, fmt.Errorf("group not found or access denied")
+	}
+	return &group, nil
+}
+
+// canManageGroup reports whether the user may rename, delete or change the members of the group.
+func canManageGroup(db *gorm.DB, group *models.Group, user *models.User) bool {
+	if group.OwnerUserID != nil {
+		return *group.OwnerUserID == user.ID
+	}
+	return canManageOrganization(db, group.OrganizationID, user)
+}
+
+// canUseGroup reports whether the user may see the group and share with it: they manage it,
+// they are a member of it, or it belongs to one of their organizations.
+func canUseGroup(db *gorm.DB, group *models.Group, user *models.User) bool {
+	if canManageGroup(db, group, user) {
+		return true
+	}
+	if group.OrganizationID != nil && membershipOf(db, *group.OrganizationID, user.ID) != nil {
+		return true
+	}
+	var count int64
+	db.Model(&models.GroupMember{}).Where("group_id = ? AND user_id = ?", group.ID, user.ID).Count(&count)
+	return count > 0
+}
+
+// CreateGroup creates a group owned by the user or, if an organization ID is given,
+// by that organization. Only organization owners and admins can create organization groups.
+func (s *GroupService) CreateGroup(ctx context.Context, name string, organizationID *string, user *models.User) (*models.Group, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("group name is required")
+	}
+
+	group := &models.Group{Name: name}
+	if organizationID != nil {
+		orgID, err := parseOrganizationID(*organizationID)
+		if err != nil {
+			return nil, err
+		}
+		if !canManageOrganization(s.DB, &orgID, user) {
+			return nil, fmt.Errorf("only organization owners and admins can create organization groups")
+		}
+		group.OrganizationID = &orgID
+	} else {
+		group.OwnerUserID = &user.ID
+	}
+
+	if err := s.DB.Create(group).Error; err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// GetGroup returns a group the user can see.
+func (s *GroupService) GetGroup(ctx context.Context, groupID string, user *models.User) (*models.Group, error) {
+	group, err := findGroup(s.DB, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !canUseGroup(s.DB, group, user) {
+		return nil, fmt.Errorf("group not found or access denied")
+	}
+	return group, nil
+}
+
+// ListMyGroups returns the groups the user owns or belongs to, and the groups
+// of the organizations they are a member of.
+func (s *GroupService) ListMyGroups(ctx context.Context, user *models.User) ([]*models.Group, error) {
+	var groups []*models.Group
+	err := s.DB.Where("owner_user_id = ? OR id IN (?) OR organization_id IN (?)",
+		user.ID, groupIDsOf(s.DB, user.ID), organizationIDsOf(s.DB, user.ID)).Order("name").Find(&groups).Error
+	return groups, err
+}
+
+// ListMembers returns the users in a group.
+func (s *GroupService) ListMembers(ctx context.Context, groupID uint) ([]*models.User, error) {
+	var users []*models.User
+	err := s.DB.Where("id IN (?)", s.DB.Model(&models.GroupMember{}).Select("user_id").Where("group_id = ?", groupID)).
+		Order("username").Find(&users).Error
+	return users, err
+}
+
+// AddMember adds a user to a group. The user immediately gains access to everything
+// shared with the group.
+func (s *GroupService) AddMember(ctx context.Context, groupID string, userID string, actor *models.User) (*models.Group, error) {
+	group, err := findGroup(s.DB, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageGroup(s.DB, group, actor) {
+		return nil, fmt.Errorf("group not found or access denied")
+	}
+
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	var member models.User
+	if err := s.DB.First(&member, uid).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if group.OrganizationID != nil && membershipOf(s.DB, *group.OrganizationID, member.ID) == nil {
+		return nil, fmt.Errorf("only members of the organization can be added to its groups")
+	}
+
+	var count int64
+	s.DB.Model(&models.GroupMember{}).Where("group_id = ? AND user_id = ?", group.ID, member.ID).Count(&count)
+	if count > 0 {
+		return group, nil
+	}
+	if err := s.DB.Create(&models.GroupMember{GroupID: group.ID, UserID: member.ID}).Error; err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// RemoveMember removes a user from a group. Members can remove themselves.
+// The user immediately loses the access they had through the group.
+func (s *GroupService) RemoveMember(ctx context.Context, groupID string, userID string, actor *models.User) (bool, error) {
+	group, err := findGroup(s.DB, groupID)
+	if err != nil {
+		return false, err
+	}
+
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID")
+	}
+	if uint(uid) != actor.ID && !canManageGroup(s.DB, group, actor) {
+		return false, fmt.Errorf("group not found or access denied")
+	}
+
+	if err := s.DB.Where("group_id = ? AND user_id = ?", group.ID, uid).Delete(&models.GroupMember{}).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteGroup deletes a group along with its memberships and everything shared with it.
+func (s *GroupService) DeleteGroup(ctx context.Context, groupID string, actor *models.User) (bool, error) {
+	group, err := findGroup(s.DB, groupID)
+	if err != nil {
+		return false, err
+	}
+	if !canManageGroup(s.DB, group, actor) {
+		return false, fmt.Errorf("group not found or access denied")
+	}
+
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteGroups(tx, []uint{group.ID})
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteGroups deletes the groups with the given IDs, together with their memberships and shares.
+func deleteGroups(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("group_id IN ?", ids).Delete(&models.FileGroupSharing{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("group_id IN ?", ids).Delete(&models.FolderGroupSharing{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("group_id IN ?", ids).Delete(&models.GroupMember{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Group{}, ids).Error
+}
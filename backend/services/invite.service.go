@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/mailer"
+	"github.com/joel2607/FileVault/services/validation"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// inviteTokenPurpose marks invitation tokens so they cannot be confused with auth tokens.
+const inviteTokenPurpose = "share_invite"
+
+// InviteService lets users share files and folders with email addresses that don't
+// belong to a registered user yet. Each invite is a pending grant plus a signed
+// invitation token sent through the configured mailer. Pending grants become real
+// shares when a user with the invited email accepts the token. Registering with the email
+// isn't enough, as nothing proves that the person registering can read its mail.
+type InviteService struct {
+	DB            *gorm.DB
+	Mailer        mailer.Mailer
+	BaseURL       string        // The base URL of the frontend, used to build invitation links.
+	DefaultExpiry time.Duration // How long invites last when no expiry is given.
+	MaxExpiry     time.Duration // Upper bound for a requested expiry.
+}
+
+// NewInviteService creates a new instance of InviteService, reading the invites
+// section of the configuration.
+func NewInviteService(db *gorm.DB, m mailer.Mailer, baseURL string) *InviteService {
+	viper.SetDefault("invites.expiry_hours", 168)
+	viper.SetDefault("invites.max_expiry_hours", 720)
+
+	return &InviteService{
+		DB:            db,
+		Mailer:        m,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		DefaultExpiry: time.Duration(viper.GetInt("invites.expiry_hours")) * time.Hour,
+		MaxExpiry:     time.Duration(viper.GetInt("invites.max_expiry_hours")) * time.Hour,
+	}
+}
+
+// inviteTokenSecret returns the key invitation tokens are signed with.
+// It falls back to the auth secret when no dedicated secret is configured.
+func inviteTokenSecret() []byte {
+	if secret := viper.GetString("INVITE_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(viper.GetString("JWT_AUTH_SECRET"))
+}
+
+// InviteToFile invites an email address to a file the user owns.
+func (s *InviteService) InviteToFile(ctx context.Context, fileID string, email string, expiresInHours *int32, user *models.User) (*models.ShareInvite, error) {
+	var file models.File
+	uid, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+	if file.IsPublic {
+		return nil, fmt.Errorf("cannot share a public file")
+	}
+
+	invite := &models.ShareInvite{FileID: &file.ID}
+	return s.createInvite(ctx, invite, email, expiresInHours, "the file "+file.FileName, user)
+}
+
+// InviteToFolder invites an email address to a folder the user owns.
+func (s *InviteService) InviteToFolder(ctx context.Context, folderID string, email string, expiresInHours *int32, user *models.User) (*models.ShareInvite, error) {
+	var folder models.Folder
+	uid, err := strconv.ParseUint(folderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder ID")
+	}
+	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("folder not found or access denied")
+	}
+	if folder.IsPublic {
+		return nil, fmt.Errorf("cannot share a public folder")
+	}
+
+	invite := &models.ShareInvite{FolderID: &folder.ID}
+	return s.createInvite(ctx, invite, email, expiresInHours, "the folder "+folder.FolderName, user)
+}
+
+// createInvite validates the email and expiry, stores the invite and emails the invitation.
+// If the email cannot be sent, the invite is removed again.
+func (s *InviteService) createInvite(ctx context.Context, invite *models.ShareInvite, email string, expiresInHours *int32, resourceName string, user *models.User) (*models.ShareInvite, error) {
+	var errs validation.Errors
+	email = validation.NormalizeEmail(email)
+	validation.Email(&errs, "email", email)
+
+	expiry := s.DefaultExpiry
+	if expiresInHours != nil {
+		expiry = time.Duration(*expiresInHours) * time.Hour
+		if expiry <= 0 || expiry > s.MaxExpiry {
+			errs.Add("expiresInHours", validation.CodeInvalidFormat, fmt.Sprintf("expiry must be between 1 and %d hours", int(s.MaxExpiry.Hours())))
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	s.DB.Model(&models.User{}).Where("LOWER(email) = ?", email).Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("this email belongs to a registered user; share with them directly")
+	}
+
+	pending := s.DB.Model(&models.ShareInvite{}).Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", email, time.Now())
+	if invite.FileID != nil {
+		pending = pending.Where("file_id = ?", *invite.FileID)
+	} else {
+		pending = pending.Where("folder_id = ?", *invite.FolderID)
+	}
+	pending.Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("a pending invite already exists for this email")
+	}
+
+	invite.Email = email
+	invite.InvitedByID = user.ID
+	invite.PermissionLevel = "read"
+	invite.ExpiresAt = time.Now().Add(expiry)
+	if err := s.DB.Create(invite).Error; err != nil {
+		return nil, err
+	}
+
+	token, err := s.issueToken(invite)
+	if err == nil {
+		err = s.Mailer.Send(ctx, mailer.Message{
+			To:      email,
+			Subject: fmt.Sprintf("%s shared %s with you on FileVault", user.Username, resourceName),
+			Body: fmt.Sprintf("%s has shared %s with you on FileVault.\n\n"+
+				"To get access, create an account or log in with this email address and open this link:\n%s/invite?token=%s\n\n"+
+				"This invitation expires on %s.\n",
+				user.Username, resourceName, s.BaseURL, token, invite.ExpiresAt.UTC().Format(time.RFC1123)),
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to send share invite %d: %v", invite.ID, err)
+		s.DB.Delete(invite)
+		return nil, fmt.Errorf("failed to send the invitation email")
+	}
+	return invite, nil
+}
+
+// issueToken signs an invitation token for the invite, valid until the invite expires.
+func (s *InviteService) issueToken(invite *models.ShareInvite) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":     strconv.FormatUint(uint64(invite.ID), 10),
+		"email":   invite.Email,
+		"purpose": inviteTokenPurpose,
+		"exp":     invite.ExpiresAt.Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(inviteTokenSecret())
+}
+
+// parseToken validates an invitation token and returns the invite ID and email it was issued for.
+func (s *InviteService) parseToken(tokenString string) (uint, string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return inviteTokenSecret(), nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, "", fmt.Errorf("this invitation has expired")
+		}
+		return 0, "", fmt.Errorf("invalid invitation token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["purpose"] != inviteTokenPurpose {
+		return 0, "", fmt.Errorf("invalid invitation token")
+	}
+	sub, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	id, err := strconv.ParseUint(sub, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid invitation token")
+	}
+	return uint(id), email, nil
+}
+
+// AcceptInvite turns the invite behind an invitation token into a share for the user.
+// The user's email must be the one the invite was sent to.
+func (s *InviteService) AcceptInvite(ctx context.Context, token string, user *models.User) (*models.ShareInvite, error) {
+	inviteID, email, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if validation.NormalizeEmail(user.Email) != email {
+		return nil, fmt.Errorf("this invitation was sent to a different email address")
+	}
+
+	var invite models.ShareInvite
+	if err := s.DB.First(&invite, inviteID).Error; err != nil {
+		return nil, fmt.Errorf("invitation not found")
+	}
+	if err := inviteStatusError(&invite); err != nil {
+		return nil, err
+	}
+
+	if err := s.grant(&invite, user); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// inviteStatusError explains why an invite can't be accepted, or returns nil if it is pending.
+func inviteStatusError(invite *models.ShareInvite) error {
+	switch invite.Status() {
+	case models.ShareInviteStatusAccepted:
+		return fmt.Errorf("this invitation has already been accepted")
+	case models.ShareInviteStatusRevoked:
+		return fmt.Errorf("this invitation has been revoked")
+	case models.ShareInviteStatusExpired:
+		return fmt.Errorf("this invitation has expired")
+	}
+	return nil
+}
+
+// grant marks an invite as accepted and creates the share it stands for. The invite is only
+// accepted if it is still pending when it is marked, so that two accepts racing each other
+// can't both create a share. Nothing is created if the user already has access through a
+// share that hasn't expired, or owns the resource.
+func (s *InviteService) grant(invite *models.ShareInvite, user *models.User) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.ShareInvite{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invite.ID, now).
+			Updates(map[string]interface{}{
+				"accepted_at":    now,
+				"accepted_by_id": user.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.First(invite, invite.ID).Error; err != nil {
+				return fmt.Errorf("invitation not found")
+			}
+			if err := inviteStatusError(invite); err != nil {
+				return err
+			}
+			return fmt.Errorf("this invitation can no longer be accepted")
+		}
+		invite.AcceptedAt = &now
+		invite.AcceptedByID = &user.ID
+
+		if invite.FileID != nil {
+			var count int64
+			tx.Model(&models.FileSharing{}).Scopes(activeShares).Where("file_id = ? AND shared_with_user_id = ?", *invite.FileID, user.ID).Count(&count)
+			if count == 0 && invite.InvitedByID != user.ID {
+				return tx.Create(&models.FileSharing{
+					FileID:           *invite.FileID,
+					SharedWithUserID: user.ID,
+					PermissionLevel:  invite.PermissionLevel,
+				}).Error
+			}
+		} else if invite.FolderID != nil {
+			var count int64
+			tx.Model(&models.FolderSharing{}).Scopes(activeShares).Where("folder_id = ? AND shared_with_user_id = ?", *invite.FolderID, user.ID).Count(&count)
+			if count == 0 && invite.InvitedByID != user.ID {
+				return tx.Create(&models.FolderSharing{
+					FolderID:         *invite.FolderID,
+					SharedWithUserID: user.ID,
+					PermissionLevel:  invite.PermissionLevel,
+				}).Error
+			}
+		}
+		return nil
+	})
+}
+
+// RevokeInvite cancels a pending invite. Only the user who sent it can do this.
+// Invites that were already accepted are unaffected; remove the share instead.
+func (s *InviteService) RevokeInvite(ctx context.Context, inviteID string, user *models.User) (*models.ShareInvite, error) {
+	uid, err := strconv.ParseUint(inviteID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invite ID")
+	}
+
+	var invite models.ShareInvite
+	if err := s.DB.First(&invite, "id = ? AND invited_by_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("invite not found or access denied")
+	}
+	if invite.Status() == models.ShareInviteStatusAccepted {
+		return nil, fmt.Errorf("this invitation has already been accepted")
+	}
+	if invite.RevokedAt != nil {
+		return &invite, nil
+	}
+
+	now := time.Now()
+	if err := s.DB.Model(&invite).Update("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+	invite.RevokedAt = &now
+	return &invite, nil
+}
+
+// ListSentInvites returns the invites the user has sent, newest first.
+func (s *InviteService) ListSentInvites(ctx context.Context, user *models.User) ([]*models.ShareInvite, error) {
+	var invites []*models.ShareInvite
+	err := s.DB.Where("invited_by_id = ?", user.ID).Order("id DESC").Find(&invites).Error
+	return invites, err
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+	"github.com/spf13/viper"
+)
+
+// invitationToken finds the token in an invitation email.
+var invitationToken = regexp.MustCompile(`/invite\?token=(\S+)`)
+
+// newTestInviteService returns an InviteService on an empty database that records the emails it sends.
+func newTestInviteService(t *testing.T) (*InviteService, *recordingMailer) {
+	t.Helper()
+	viper.Set("INVITE_TOKEN_SECRET", "invite-test-secret")
+	t.Cleanup(func() { viper.Set("INVITE_TOKEN_SECRET", "") })
+	db := testdb.Open(t, &models.User{}, &models.DeduplicatedContent{}, &models.Folder{}, &models.File{},
+		&models.FileSharing{}, &models.FolderSharing{}, &models.ShareInvite{})
+	mail := &recordingMailer{}
+	return &InviteService{DB: db, Mailer: mail, BaseURL: "https://vault.example.com", DefaultExpiry: time.Hour, MaxExpiry: 24 * time.Hour}, mail
+}
+
+func TestAcceptInvite(t *testing.T) {
+	ctx := context.Background()
+	s, mail := newTestInviteService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	report := createTestFile(t, s.DB, alice, "report.pdf", nil)
+
+	invite, err := s.InviteToFile(ctx, *apiID(report.ID), "Dana@Example.com", nil, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mail.sent) != 1 || mail.sent[0].To != "dana@example.com" {
+		t.Fatalf("sent %+v, want one email to the invited address", mail.sent)
+	}
+	match := invitationToken.FindStringSubmatch(mail.sent[0].Body)
+	if match == nil {
+		t.Fatalf("the email has no invitation link:\n%s", mail.sent[0].Body)
+	}
+	token := match[1]
+
+	// Registering with the invited email doesn't grant anything without the token.
+	dana := createTestUser(t, s.DB, "dana")
+	var shares int64
+	s.DB.Model(&models.FileSharing{}).Where("shared_with_user_id = ?", dana.ID).Count(&shares)
+	if shares != 0 {
+		t.Fatalf("dana got %d shares before accepting the invitation", shares)
+	}
+
+	// The token only works for the invited email.
+	eve := createTestUser(t, s.DB, "eve")
+	if _, err := s.AcceptInvite(ctx, token, eve); err == nil || !strings.Contains(err.Error(), "different email") {
+		t.Errorf("accepting someone else's invitation: got %v, want an error", err)
+	}
+	if _, err := s.AcceptInvite(ctx, "not-a-token", dana); err == nil {
+		t.Error("accepted an invalid token")
+	}
+
+	// An accept racing this one has already checked that the invite is pending.
+	var raced models.ShareInvite
+	if err := s.DB.First(&raced, invite.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	accepted, err := s.AcceptInvite(ctx, token, dana)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.ID != invite.ID || accepted.Status() != models.ShareInviteStatusAccepted {
+		t.Errorf("got invite %d with status %s, want %d accepted", accepted.ID, accepted.Status(), invite.ID)
+	}
+	s.DB.Model(&models.FileSharing{}).Where("file_id = ? AND shared_with_user_id = ?", report.ID, dana.ID).Count(&shares)
+	if shares != 1 {
+		t.Errorf("dana has %d shares of the file, want 1", shares)
+	}
+	if _, err := s.AcceptInvite(ctx, token, dana); err == nil || !strings.Contains(err.Error(), "already been accepted") {
+		t.Errorf("accepting twice: got %v, want an error", err)
+	}
+	if err := s.grant(&raced, dana); err == nil || !strings.Contains(err.Error(), "already been accepted") {
+		t.Errorf("the racing accept: got %v, want an error", err)
+	}
+	s.DB.Model(&models.FileSharing{}).Where("file_id = ? AND shared_with_user_id = ?", report.ID, dana.ID).Count(&shares)
+	if shares != 1 {
+		t.Errorf("dana has %d shares of the file after the racing accept, want 1", shares)
+	}
+}
+
+func TestInviteRegisteredEmail(t *testing.T) {
+	ctx := context.Background()
+	s, mail := newTestInviteService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	report := createTestFile(t, s.DB, alice, "report.pdf", nil)
+	create(t, s.DB, &models.User{Username: "grace", Email: "Grace@Example.com", PasswordHash: "x"})
+
+	// Emails are compared without case, as they are when logging in.
+	if _, err := s.InviteToFile(ctx, *apiID(report.ID), "grace@example.com", nil, alice); err == nil || !strings.Contains(err.Error(), "registered user") {
+		t.Errorf("inviting a registered user's email: got %v, want an error", err)
+	}
+	if len(mail.sent) != 0 {
+		t.Errorf("sent %d invitations to a registered user", len(mail.sent))
+	}
+}
+
+func TestAcceptRevokedInvite(t *testing.T) {
+	ctx := context.Background()
+	s, mail := newTestInviteService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	projects := createTestFolder(t, s.DB, alice, "Projects", nil)
+
+	invite, err := s.InviteToFolder(ctx, *apiID(projects.ID), "frank@example.com", nil, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RevokeInvite(ctx, *apiID(invite.ID), alice); err != nil {
+		t.Fatal(err)
+	}
+	frank := createTestUser(t, s.DB, "frank")
+	token := invitationToken.FindStringSubmatch(mail.sent[0].Body)[1]
+	if _, err := s.AcceptInvite(ctx, token, frank); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("accepting a revoked invitation: got %v, want an error", err)
+	}
+	var shares int64
+	s.DB.Model(&models.FolderSharing{}).Where("shared_with_user_id = ?", frank.ID).Count(&shares)
+	if shares != 0 {
+		t.Errorf("frank got %d shares from a revoked invitation", shares)
+	}
+}
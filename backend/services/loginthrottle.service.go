@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/services/validation"
+	"github.com/spf13/viper"
+)
+
+// LoginThrottlePolicy describes how aggressively failed logins are throttled.
+// Failures are counted separately per email address and per client IP.
+type LoginThrottlePolicy struct {
+	MaxEmailFailures int           // Failures for one email before it is locked out.
+	MaxIPFailures    int           // Failures from one IP before it is locked out; 0 doesn't throttle IPs.
+	FreeAttempts     int           // Failures allowed before backoff starts.
+	BackoffBase      time.Duration // Delay after the first failure past FreeAttempts; doubles each time.
+	BackoffMax       time.Duration // Upper bound for the backoff delay.
+	LockoutDuration  time.Duration // How long a lockout lasts.
+	Window           time.Duration // How long failure counters are remembered.
+}
+
+// LoginThrottlePolicyFromConfig builds a LoginThrottlePolicy from the
+// auth.login_throttle section of the configuration, falling back to sane defaults.
+func LoginThrottlePolicyFromConfig() LoginThrottlePolicy {
+	viper.SetDefault("auth.login_throttle.max_email_failures", 5)
+	viper.SetDefault("auth.login_throttle.max_ip_failures", 20)
+	viper.SetDefault("auth.login_throttle.free_attempts", 3)
+	viper.SetDefault("auth.login_throttle.backoff_base_seconds", 1)
+	viper.SetDefault("auth.login_throttle.backoff_max_seconds", 60)
+	viper.SetDefault("auth.login_throttle.lockout_minutes", 15)
+	viper.SetDefault("auth.login_throttle.window_minutes", 15)
+
+	return LoginThrottlePolicy{
+		MaxEmailFailures: viper.GetInt("auth.login_throttle.max_email_failures"),
+		MaxIPFailures:    viper.GetInt("auth.login_throttle.max_ip_failures"),
+		FreeAttempts:     viper.GetInt("auth.login_throttle.free_attempts"),
+		BackoffBase:      time.Duration(viper.GetInt("auth.login_throttle.backoff_base_seconds")) * time.Second,
+		BackoffMax:       time.Duration(viper.GetInt("auth.login_throttle.backoff_max_seconds")) * time.Second,
+		LockoutDuration:  time.Duration(viper.GetInt("auth.login_throttle.lockout_minutes")) * time.Minute,
+		Window:           time.Duration(viper.GetInt("auth.login_throttle.window_minutes")) * time.Minute,
+	}
+}
+
+// LoginThrottledError is returned when a login attempt is rejected because
+// the email address or client IP is in backoff or locked out.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	seconds := int(e.RetryAfter.Round(time.Second).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, try again in %d seconds", seconds)
+	}
+	return fmt.Sprintf("please wait %d seconds before trying to log in again", seconds)
+}
+
+// LoginThrottleService keeps Redis-backed counters of failed login attempts and
+// applies progressive backoff and temporary lockouts to brute-force attempts.
+type LoginThrottleService struct {
+	RDB    *redis.Client
+	Policy LoginThrottlePolicy
+}
+
+// NewLoginThrottleService creates a new instance of LoginThrottleService.
+func NewLoginThrottleService(rdb *redis.Client, policy LoginThrottlePolicy) *LoginThrottleService {
+	return &LoginThrottleService{RDB: rdb, Policy: policy}
+}
+
+// throttleSubject identifies one of the two dimensions failures are counted on.
+type throttleSubject struct {
+	kind  string // "email" or "ip"
+	value string
+	max   int
+}
+
+func (t throttleSubject) failuresKey() string {
+	return fmt.Sprintf("login_failures:%s:%s", t.kind, t.value)
+}
+
+func (t throttleSubject) backoffKey() string {
+	return fmt.Sprintf("login_backoff:%s:%s", t.kind, t.value)
+}
+
+func (t throttleSubject) lockKey() string {
+	return fmt.Sprintf("login_lock:%s:%s", t.kind, t.value)
+}
+
+func (s *LoginThrottleService) subjects(email, clientIP string) []throttleSubject {
+	subjects := []throttleSubject{{kind: "email", value: validation.NormalizeEmail(email), max: s.Policy.MaxEmailFailures}}
+	if clientIP != "" && s.Policy.MaxIPFailures > 0 {
+		subjects = append(subjects, throttleSubject{kind: "ip", value: clientIP, max: s.Policy.MaxIPFailures})
+	}
+	return subjects
+}
+
+// reserveAttemptScript checks and reserves a login attempt in one step. KEYS holds the
+// failures, backoff and lock keys of each subject; ARGV the failure window, lockout,
+// free attempts, backoff base and backoff maximum, then the lockout threshold of each
+// subject, with durations in milliseconds. If any subject is locked out or in backoff it
+// returns {1, ms} or {2, ms} with the time left. Otherwise it counts the attempt as a
+// failure up front, starting a backoff or lockout for later attempts once a threshold is
+// crossed, and returns {0, 0}. Counting before the password is checked means parallel
+// guesses can't all get in before the first failure is recorded.
+var reserveAttemptScript = redis.NewScript(`
+local window, lockout, free, base, cap = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[5])
+local subjects = #KEYS / 3
+for i = 0, subjects - 1 do
+	local locked = redis.call('PTTL', KEYS[i * 3 + 3])
+	if locked > 0 then
+		return {1, locked}
+	end
+	local backoff = redis.call('PTTL', KEYS[i * 3 + 2])
+	if backoff > 0 then
+		return {2, backoff}
+	end
+end
+for i = 0, subjects - 1 do
+	local failures = redis.call('INCR', KEYS[i * 3 + 1])
+	redis.call('PEXPIRE', KEYS[i * 3 + 1], window)
+	local max = tonumber(ARGV[6 + i])
+	if max > 0 and failures >= max then
+		redis.call('SET', KEYS[i * 3 + 3], failures, 'PX', lockout)
+	elseif failures > free then
+		local delay = base
+		for j = free + 2, failures do
+			delay = math.min(delay * 2, cap)
+		end
+		redis.call('SET', KEYS[i * 3 + 2], failures, 'PX', delay)
+	end
+end
+return {0, 0}
+`)
+
+// releaseAttemptScript gives back an attempt reserved by reserveAttemptScript once it
+// succeeded. The email's failure history is cleared; the IP's failure count only loses
+// the reserved attempt, so that one valid account cannot reset a stuffing run, and the
+// backoff or lockout the reservation started is lifted if the remaining failures are
+// below the thresholds. KEYS holds the email's failures, backoff and lock keys and then
+// the IP's, if any; ARGV the free attempts and the IP lockout threshold.
+var releaseAttemptScript = redis.NewScript(`
+redis.call('DEL', KEYS[1], KEYS[2], KEYS[3])
+if #KEYS > 3 then
+	local failures = redis.call('DECR', KEYS[4])
+	if failures <= 0 then
+		redis.call('DEL', KEYS[4])
+	end
+	if failures <= tonumber(ARGV[1]) then
+		redis.call('DEL', KEYS[5])
+	end
+	local max = tonumber(ARGV[2])
+	if max <= 0 or failures < max then
+		redis.call('DEL', KEYS[6])
+	end
+end
+return 0
+`)
+
+// Check reports whether a login attempt for the given email from the given IP may proceed.
+// It returns a *LoginThrottledError if either is currently locked out or in backoff.
+// An attempt that may proceed is counted as a failure straight away, in the same Redis
+// call, and must be given back with RecordSuccess if the password turns out to be right.
+// Redis failures are logged and the attempt is allowed, mirroring the rate limiter.
+func (s *LoginThrottleService) Check(ctx context.Context, email, clientIP string) error {
+	subjects := s.subjects(email, clientIP)
+	var keys []string
+	args := []interface{}{
+		s.Policy.Window.Milliseconds(),
+		s.Policy.LockoutDuration.Milliseconds(),
+		s.Policy.FreeAttempts,
+		s.Policy.BackoffBase.Milliseconds(),
+		s.Policy.BackoffMax.Milliseconds(),
+	}
+	for _, subject := range subjects {
+		keys = append(keys, subject.failuresKey(), subject.backoffKey(), subject.lockKey())
+		args = append(args, subject.max)
+	}
+
+	result, err := reserveAttemptScript.Run(ctx, s.RDB, keys, args...).Result()
+	if err != nil {
+		log.Printf("Redis error in login throttle: %v", err)
+		return nil
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		log.Printf("Unexpected login throttle result: %v", result)
+		return nil
+	}
+	state, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+	switch state {
+	case 1:
+		return &LoginThrottledError{RetryAfter: time.Duration(wait) * time.Millisecond, Locked: true}
+	case 2:
+		return &LoginThrottledError{RetryAfter: time.Duration(wait) * time.Millisecond}
+	}
+	return nil
+}
+
+// RecordSuccess gives back the attempt Check reserved after a successful login, clearing the
+// failure history of the email address. IP counters only lose the one attempt, so that
+// one valid account cannot reset a stuffing run.
+func (s *LoginThrottleService) RecordSuccess(ctx context.Context, email, clientIP string) {
+	var keys []string
+	ipMax := 0
+	for _, subject := range s.subjects(email, clientIP) {
+		keys = append(keys, subject.failuresKey(), subject.backoffKey(), subject.lockKey())
+		if subject.kind == "ip" {
+			ipMax = subject.max
+		}
+	}
+	if err := releaseAttemptScript.Run(ctx, s.RDB, keys, s.Policy.FreeAttempts, ipMax).Err(); err != nil {
+		log.Printf("Redis error in login throttle: %v", err)
+	}
+}
+
+// Unlock removes any lockout, backoff and failure history for an email address
+// and/or a client IP. It is used by admins to let a locked-out user back in.
+func (s *LoginThrottleService) Unlock(ctx context.Context, email, clientIP string) error {
+	var subjects []throttleSubject
+	if email != "" {
+		subjects = append(subjects, throttleSubject{kind: "email", value: validation.NormalizeEmail(email)})
+	}
+	if clientIP != "" {
+		subjects = append(subjects, throttleSubject{kind: "ip", value: clientIP})
+	}
+	if len(subjects) == 0 {
+		return fmt.Errorf("an email or an IP address is required")
+	}
+
+	var keys []string
+	for _, subject := range subjects {
+		keys = append(keys, subject.failuresKey(), subject.backoffKey(), subject.lockKey())
+	}
+	return s.RDB.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLoginThrottle returns a LoginThrottleService backed by an in-memory Redis.
+func newTestLoginThrottle(t *testing.T, policy LoginThrottlePolicy) *LoginThrottleService {
+	t.Helper()
+	return NewLoginThrottleService(newTestRedis(t), policy)
+}
+
+// throttled returns the *LoginThrottledError in err, or nil if it is something else.
+func throttled(err error) *LoginThrottledError {
+	var throttledErr *LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		return throttledErr
+	}
+	return nil
+}
+
+var testThrottlePolicy = LoginThrottlePolicy{
+	MaxEmailFailures: 5,
+	MaxIPFailures:    20,
+	FreeAttempts:     2,
+	BackoffBase:      time.Minute,
+	BackoffMax:       time.Hour,
+	LockoutDuration:  15 * time.Minute,
+	Window:           15 * time.Minute,
+}
+
+func TestLoginThrottleReservesAttempts(t *testing.T) {
+	ctx := context.Background()
+	throttle := newTestLoginThrottle(t, testThrottlePolicy)
+
+	// Every attempt counts as a failure until it is given back, so the free attempts run
+	// out and the one after them starts a backoff.
+	for i := 0; i <= testThrottlePolicy.FreeAttempts; i++ {
+		if err := throttle.Check(ctx, "alice@example.com", "203.0.113.7"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	err := throttled(throttle.Check(ctx, "alice@example.com", "203.0.113.7"))
+	if err == nil || err.Locked {
+		t.Fatalf("got %v, want a backoff", err)
+	}
+	if err.RetryAfter <= 0 || err.RetryAfter > testThrottlePolicy.BackoffBase {
+		t.Errorf("retry after %v, want at most %v", err.RetryAfter, testThrottlePolicy.BackoffBase)
+	}
+
+	// The backoff applies to the email from any IP and to the IP with any email.
+	if err := throttled(throttle.Check(ctx, "Alice@Example.com", "198.51.100.1")); err == nil {
+		t.Error("the backoff did not apply to the same email from another IP")
+	}
+	if err := throttled(throttle.Check(ctx, "bob@example.com", "203.0.113.7")); err == nil {
+		t.Error("the backoff did not apply to another email from the same IP")
+	}
+	if err := throttle.Check(ctx, "bob@example.com", "198.51.100.2"); err != nil {
+		t.Errorf("another email from another IP was throttled: %v", err)
+	}
+}
+
+func TestLoginThrottleReleasesSuccessfulAttempts(t *testing.T) {
+	ctx := context.Background()
+	throttle := newTestLoginThrottle(t, testThrottlePolicy)
+
+	for i := 0; i < 3*testThrottlePolicy.MaxEmailFailures; i++ {
+		if err := throttle.Check(ctx, "alice@example.com", "203.0.113.7"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		throttle.RecordSuccess(ctx, "alice@example.com", "203.0.113.7")
+	}
+
+	// A success clears the email's failures, so the backoff starts over.
+	for i := 0; i < testThrottlePolicy.FreeAttempts; i++ {
+		if err := throttle.Check(ctx, "alice@example.com", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	throttle.RecordSuccess(ctx, "alice@example.com", "")
+	for i := 0; i <= testThrottlePolicy.FreeAttempts; i++ {
+		if err := throttle.Check(ctx, "alice@example.com", ""); err != nil {
+			t.Fatalf("attempt %d after a success: %v", i+1, err)
+		}
+	}
+}
+
+func TestLoginThrottleSuccessKeepsIPFailures(t *testing.T) {
+	ctx := context.Background()
+	policy := testThrottlePolicy
+	policy.MaxIPFailures = 4
+	policy.FreeAttempts = 10
+	throttle := newTestLoginThrottle(t, policy)
+
+	// Three failures against different accounts, then a valid login from the same IP.
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		if err := throttle.Check(ctx, email, "203.0.113.7"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	throttle.RecordSuccess(ctx, "d@example.com", "203.0.113.7")
+
+	// The success only gave back its own attempt: one more failure locks the IP out.
+	if err := throttle.Check(ctx, "e@example.com", "203.0.113.7"); err != nil {
+		t.Fatal(err)
+	}
+	err := throttled(throttle.Check(ctx, "f@example.com", "203.0.113.7"))
+	if err == nil || !err.Locked {
+		t.Fatalf("got %v, want the IP locked out", err)
+	}
+	if err := throttle.Check(ctx, "f@example.com", "198.51.100.1"); err != nil {
+		t.Errorf("another IP was throttled: %v", err)
+	}
+}
+
+func TestLoginThrottleLocksOut(t *testing.T) {
+	ctx := context.Background()
+	policy := testThrottlePolicy
+	policy.FreeAttempts = 10
+	throttle := newTestLoginThrottle(t, policy)
+
+	for i := 0; i < policy.MaxEmailFailures; i++ {
+		if err := throttle.Check(ctx, "alice@example.com", ""); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	err := throttled(throttle.Check(ctx, "alice@example.com", ""))
+	if err == nil || !err.Locked {
+		t.Fatalf("got %v, want a lockout", err)
+	}
+
+	if err := throttle.Unlock(ctx, "alice@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Check(ctx, "alice@example.com", ""); err != nil {
+		t.Errorf("still throttled after an unlock: %v", err)
+	}
+}
+
+func TestLoginThrottleWithoutIPLimit(t *testing.T) {
+	ctx := context.Background()
+	policy := testThrottlePolicy
+	policy.MaxIPFailures = 0
+	throttle := newTestLoginThrottle(t, policy)
+
+	// Behind a proxy that hides client addresses everyone shares one IP, which must not
+	// throttle logins for other emails.
+	for _, email := range []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"} {
+		for i := 0; i <= policy.FreeAttempts; i++ {
+			if err := throttle.Check(ctx, email, "10.0.0.2"); err != nil {
+				t.Fatalf("%s, attempt %d: %v", email, i+1, err)
+			}
+		}
+	}
+	if err := throttled(throttle.Check(ctx, "alice@example.com", "10.0.0.2")); err == nil {
+		t.Error("the email is no longer throttled")
+	}
+}
+
+func TestLoginThrottleParallelAttempts(t *testing.T) {
+	ctx := context.Background()
+	throttle := newTestLoginThrottle(t, testThrottlePolicy)
+
+	// However many guesses arrive at once, only the free attempts and the one that starts
+	// the backoff get through.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := throttle.Check(ctx, "alice@example.com", ""); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if want := testThrottlePolicy.FreeAttempts + 1; allowed != want {
+		t.Errorf("%d parallel attempts got through, want %d", allowed, want)
+	}
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import "context"
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer defines the interface for an email delivery backend.
+// This abstraction allows for interchangeable delivery mechanisms (e.g., SMTP, a
+// transactional email API, or logging in development) without changing the core business logic.
+type Mailer interface {
+	// Send delivers a message, returning an error if it could not be handed off.
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/joel2607/FileVault/database"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// defaultNotificationLimit is how many notifications are listed when no limit, or a larger one, is given.
+const defaultNotificationLimit = 50
+
+// NotificationService stores in-app notifications and pushes them to each user's
+// notifications_%d Redis channel, which the notifications subscription listens on.
+// Users can turn each type of notification off.
+type NotificationService struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+// NewNotificationService creates a new instance of NotificationService.
+func NewNotificationService(db *gorm.DB, rdb *redis.Client) *NotificationService {
+	return &NotificationService{DB: db, RDB: rdb}
+}
+
+// Notify stores a notification for notification.UserID and publishes it, unless the user
+// has turned that type off or caused it themselves. Failures are logged rather than
+// returned, so that notifying never fails the operation that triggered it.
+func (s *NotificationService) Notify(notification *models.Notification) {
+	if notification.ActorID != nil && *notification.ActorID == notification.UserID {
+		return
+	}
+	if !s.enabled(notification.UserID, models.NotificationType(notification.Type)) {
+		return
+	}
+	if err := s.DB.Create(notification).Error; err != nil {
+		log.Printf("Failed to store %s notification for user %d: %v", notification.Type, notification.UserID, err)
+		return
+	}
+
+	payload, err := json.Marshal(notification.ID)
+	if err != nil {
+		return
+	}
+	channel := fmt.Sprintf("notifications_%d", notification.UserID)
+	if err := s.RDB.Publish(database.Ctx, channel, payload).Err(); err != nil {
+		log.Printf("Error publishing notification %d to user %d: %v", notification.ID, notification.UserID, err)
+	}
+}
+
+// NotifyAll sends a copy of a notification to each of the given users.
+func (s *NotificationService) NotifyAll(userIDs []uint, notification models.Notification) {
+	for _, userID := range userIDs {
+		n := notification
+		n.UserID = userID
+		s.Notify(&n)
+	}
+}
+
+// enabled reports whether the user wants notifications of the given type.
+func (s *NotificationService) enabled(userID uint, kind models.NotificationType) bool {
+	var preference models.NotificationPreference
+	if err := s.DB.First(&preference, "user_id = ? AND type = ?", userID, string(kind)).Error; err != nil {
+		return true
+	}
+	return preference.Enabled
+}
+
+// ListNotifications returns the user's notifications, newest first. Only unread ones are
+// returned if unreadOnly is true.
+func (s *NotificationService) ListNotifications(ctx context.Context, unreadOnly bool, limit *int32, user *models.User) ([]*models.Notification, error) {
+	db := s.DB.Where("user_id = ?", user.ID)
+	if unreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	pageSize := defaultNotificationLimit
+	if limit != nil && *limit > 0 && *limit < defaultNotificationLimit {
+		pageSize = int(*limit)
+	}
+	var notifications []*models.Notification
+	err := db.Order("id DESC").Limit(pageSize).Find(&notifications).Error
+	return notifications, err
+}
+
+// UnreadCount returns the number of notifications the user hasn't read.
+func (s *NotificationService) UnreadCount(ctx context.Context, user *models.User) (int32, error) {
+	var count int64
+	err := s.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", user.ID).Count(&count).Error
+	return int32(count), err
+}
+
+// MarkRead marks the given notifications of the user as read and returns how many changed.
+func (s *NotificationService) MarkRead(ctx context.Context, notificationIDs []string, user *models.User) (int32, error) {
+	ids := make([]uint64, 0, len(notificationIDs))
+	for _, notificationID := range notificationIDs {
+		id, err := strconv.ParseUint(notificationID, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid notification ID %q", notificationID)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := s.DB.Model(&models.Notification{}).Where("id IN ? AND user_id = ? AND read_at IS NULL", ids, user.ID).
+		Update("read_at", time.Now())
+	return int32(result.RowsAffected), result.Error
+}
+
+// MarkAllRead marks all of the user's notifications as read and returns how many changed.
+func (s *NotificationService) MarkAllRead(ctx context.Context, user *models.User) (int32, error) {
+	result := s.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", user.ID).
+		Update("read_at", time.Now())
+	return int32(result.RowsAffected), result.Error
+}
+
+// GetPreferences returns the user's preference for every notification type.
+func (s *NotificationService) GetPreferences(ctx context.Context, user *models.User) ([]*models.NotificationPreference, error) {
+	var saved []models.NotificationPreference
+	if err := s.DB.Where("user_id = ?", user.ID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byType := make(map[string]models.NotificationPreference, len(saved))
+	for _, preference := range saved {
+		byType[preference.Type] = preference
+	}
+
+	preferences := make([]*models.NotificationPreference, 0, len(models.AllNotificationType))
+	for _, kind := range models.AllNotificationType {
+		preference, ok := byType[string(kind)]
+		if !ok {
+			preference = models.NotificationPreference{UserID: user.ID, Type: string(kind), Enabled: true}
+		}
+		preferences = append(preferences, &preference)
+	}
+	return preferences, nil
+}
+
+// SetPreference turns a type of notification on or off for the user.
+func (s *NotificationService) SetPreference(ctx context.Context, kind models.NotificationType, enabled bool, user *models.User) (*models.NotificationPreference, error) {
+	preference := models.NotificationPreference{UserID: user.ID, Type: string(kind)}
+	if err := s.DB.Where(preference).FirstOrInit(&preference).Error; err != nil {
+		return nil, err
+	}
+	preference.Enabled = enabled
+	if err := s.DB.Save(&preference).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// SubscribeToNotifications streams the user's new notifications as they are created.
+func (s *NotificationService) SubscribeToNotifications(ctx context.Context, user *models.User) (<-chan *models.Notification, error) {
+	ch := make(chan *models.Notification, 1)
+	channel := fmt.Sprintf("notifications_%d", user.ID)
+	pubsub := s.RDB.Subscribe(ctx, channel)
+
+	go func() {
+		defer pubsub.Close()
+		defer close(ch)
+
+		for {
+			select {
+			case msg, ok := <-pubsub.Channel():
+				if !ok {
+					return
+				}
+				var notificationID uint
+				if err := json.Unmarshal([]byte(msg.Payload), &notificationID); err != nil {
+					continue
+				}
+				var notification models.Notification
+				if err := s.DB.First(&notification, notificationID).Error; err == nil {
+					ch <- &notification
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// resourceNotification builds a notification of the given type about a file or folder, caused
+// by actor. The message is format filled in with the actor's username and the item, such as
+// "the file report.pdf".
+func resourceNotification(kind models.NotificationType, actor *models.User, file *models.File, folder *models.Folder, format string) models.Notification {
+	notification := models.Notification{Type: string(kind)}
+	actorName := "Someone"
+	if actor != nil {
+		notification.ActorID = &actor.ID
+		actorName = actor.Username
+	}
+	var item string
+	if file != nil {
+		notification.FileID = &file.ID
+		item = "the file " + file.FileName
+	} else {
+		notification.FolderID = &folder.ID
+		item = "the folder " + folder.FolderName
+	}
+	notification.Message = fmt.Sprintf(format, actorName, item)
+	return notification
+}
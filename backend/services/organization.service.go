@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// OrganizationService manages organizations (teams) and their memberships.
+// Owners and admins manage members; only the owner can manage admins,
+// transfer ownership or delete the organization. Role changes are recorded in the audit trail.
+type OrganizationService struct {
+	DB    *gorm.DB
+	Audit *AuditService
+}
+
+// NewOrganizationService creates a new instance of OrganizationService.
+func NewOrganizationService(db *gorm.DB, audit *AuditService) *OrganizationService {
+	return &OrganizationService{DB: db, Audit: audit}
+}
+
+// parseOrganizationID parses a string organization ID.
+func parseOrganizationID(organizationID string) (uint, error) {
+	id, err := strconv.ParseUint(organizationID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid organization ID")
+	}
+	return uint(id), nil
+}
+
+// requireMembership returns the user's membership in the organization, or an error
+// if they are not a member. Non-members cannot tell whether the organization exists.
+func (s *OrganizationService) requireMembership(organizationID string, user *models.User) (*models.Membership, error) {
+	orgID, err := parseOrganizationID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	membership := membershipOf(s.DB, orgID, user.ID)
+	if membership == nil {
+		return nil, fmt.Errorf("organization not found or access denied")
+	}
+	return membership, nil
+}
+
+// CreateOrganization creates a new organization with the user as its owner.
+func (s *OrganizationService) CreateOrganization(ctx context.Context, name string, user *models.User) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("organization name is required")
+	}
+
+	organization := &models.Organization{Name: name}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{
+			OrganizationID: organization.ID,
+			UserID:         user.ID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+// GetOrganization returns an organization the user is a member of.
+// Admins may view any organization.
+func (s *OrganizationService) GetOrganization(ctx context.Context, organizationID string, user *models.User) (*models.Organization, error) {
+	orgID, err := parseOrganizationID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != models.RoleAdmin && membershipOf(s.DB, orgID, user.ID) == nil {
+		return nil, fmt.Errorf("organization not found or access denied")
+	}
+
+	var organization models.Organization
+	if err := s.DB.First(&organization, orgID).Error; err != nil {
+		return nil, fmt.Errorf("organization not found or access denied")
+	}
+	return &organization, nil
+}
+
+// ListMyOrganizations returns the organizations the user is a member of.
+func (s *OrganizationService) ListMyOrganizations(ctx context.Context, user *models.User) ([]*models.Organization, error) {
+	var organizations []*models.Organization
+	err := s.DB.Where("id IN (?)", organizationIDsOf(s.DB, user.ID)).Order("name").Find(&organizations).Error
+	return organizations, err
+}
+
+// ListMembers returns the memberships of an organization.
+func (s *OrganizationService) ListMembers(ctx context.Context, organizationID uint) ([]*models.Membership, error) {
+	var memberships []*models.Membership
+	err := s.DB.Where("organization_id = ?", organizationID).Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+// ListTeamFolders returns the top-level team folders of an organization.
+func (s *OrganizationService) ListTeamFolders(ctx context.Context, organizationID uint) ([]*models.Folder, error) {
+	var folders []*models.Folder
+	err := s.DB.Where("organization_id = ? AND parent_folder_id IS NULL", organizationID).Find(&folders).Error
+	return folders, err
+}
+
+// AddMember adds a user to an organization. Owners and admins can add members;
+// only the owner can add admins. Ownership can only be given with UpdateMemberRole.
+func (s *OrganizationService) AddMember(ctx context.Context, organizationID string, userID string, role models.OrganizationRole, actor *models.User) (*models.Membership, error) {
+	actorMembership, err := s.requireMembership(organizationID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !actorMembership.Role.CanManage() {
+		return nil, fmt.Errorf("only organization owners and admins can add members")
+	}
+	if role == models.OrgRoleOwner {
+		return nil, fmt.Errorf("an organization can only have one owner; transfer ownership instead")
+	}
+	if role == models.OrgRoleAdmin && actorMembership.Role != models.OrgRoleOwner {
+		return nil, fmt.Errorf("only the organization owner can add admins")
+	}
+
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	var member models.User
+	if err := s.DB.First(&member, uid).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if membershipOf(s.DB, actorMembership.OrganizationID, member.ID) != nil {
+		return nil, fmt.Errorf("user is already a member of this organization")
+	}
+
+	membership := &models.Membership{
+		OrganizationID: actorMembership.OrganizationID,
+		UserID:         member.ID,
+		Role:           role,
+	}
+	if err := s.DB.Create(membership).Error; err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, actor, "organization.member_added", "organization", membership.OrganizationID, map[string]interface{}{
+		"userId": member.ID,
+		"role":   role,
+	})
+	return membership, nil
+}
+
+// UpdateMemberRole changes a member's role. Only the owner can do this.
+// Making another member the owner transfers ownership: the current owner becomes an admin.
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, organizationID string, userID string, role models.OrganizationRole, actor *models.User) (*models.Membership, error) {
+	actorMembership, err := s.requireMembership(organizationID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if actorMembership.Role != models.OrgRoleOwner {
+		return nil, fmt.Errorf("only the organization owner can change member roles")
+	}
+
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	membership := membershipOf(s.DB, actorMembership.OrganizationID, uint(uid))
+	if membership == nil {
+		return nil, fmt.Errorf("user is not a member of this organization")
+	}
+	if membership.ID == actorMembership.ID {
+		return nil, fmt.Errorf("the owner cannot change their own role; transfer ownership instead")
+	}
+
+	previous := membership.Role
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if role == models.OrgRoleOwner {
+			if err := tx.Model(actorMembership).Update("role", models.OrgRoleAdmin).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(membership).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, actor, "organization.member_role_changed", "organization", membership.OrganizationID, map[string]interface{}{
+		"userId": membership.UserID,
+		"from":   previous,
+		"to":     role,
+	})
+	return membership, nil
+}
+
+// RemoveMember removes a user from an organization. Members can remove themselves,
+// owners and admins can remove members, and only the owner can remove admins.
+// The owner cannot leave without transferring ownership first.
+// Files the user uploaded to team folders stay in the organization, and the user is
+// removed from the organization's groups.
+func (s *OrganizationService) RemoveMember(ctx context.Context, organizationID string, userID string, actor *models.User) (bool, error) {
+	actorMembership, err := s.requireMembership(organizationID, actor)
+	if err != nil {
+		return false, err
+	}
+
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID")
+	}
+	membership := membershipOf(s.DB, actorMembership.OrganizationID, uint(uid))
+	if membership == nil {
+		return false, fmt.Errorf("user is not a member of this organization")
+	}
+
+	switch {
+	case membership.Role == models.OrgRoleOwner:
+		return false, fmt.Errorf("the organization owner cannot be removed; transfer ownership first")
+	case membership.ID == actorMembership.ID:
+		// Members can always leave.
+	case membership.Role == models.OrgRoleAdmin && actorMembership.Role != models.OrgRoleOwner:
+		return false, fmt.Errorf("only the organization owner can remove admins")
+	case !actorMembership.Role.CanManage():
+		return false, fmt.Errorf("only organization owners and admins can remove members")
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Leaving the organization also removes the user from its groups.
+		orgGroupIDs := tx.Model(&models.Group{}).Select("id").Where("organization_id = ?", membership.OrganizationID)
+		if err := tx.Where("user_id = ? AND group_id IN (?)", membership.UserID, orgGroupIDs).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := handOverTeamItems(tx, membership.OrganizationID, membership.UserID); err != nil {
+			return err
+		}
+		return tx.Delete(membership).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// handOverTeamItems passes a member's team folders and files to the organization owner, so
+// they aren't orphaned when the member leaves. Team storage is charged to the organization,
+// so no storage moves.
+func handOverTeamItems(db *gorm.DB, organizationID uint, userID uint) error {
+	var ownerMembership models.Membership
+	if err := db.First(&ownerMembership, "organization_id = ? AND role = ?", organizationID, models.OrgRoleOwner).Error; err != nil {
+		return err
+	}
+	teamItems := "organization_id = ? AND user_id = ?"
+	if err := db.Model(&models.Folder{}).Where(teamItems, organizationID, userID).Update("user_id", ownerMembership.UserID).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.File{}).Where(teamItems, organizationID, userID).Update("user_id", ownerMembership.UserID).Error
+}
+
+// DeleteOrganization deletes an organization, its memberships and its groups. Only the owner can
+// do this, and only once all team folders have been deleted or moved out.
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, organizationID string, actor *models.User) (bool, error) {
+	actorMembership, err := s.requireMembership(organizationID, actor)
+	if err != nil {
+		return false, err
+	}
+	if actorMembership.Role != models.OrgRoleOwner {
+		return false, fmt.Errorf("only the organization owner can delete the organization")
+	}
+
+	var count int64
+	s.DB.Model(&models.Folder{}).Where("organization_id = ?", actorMembership.OrganizationID).Count(&count)
+	if count > 0 {
+		return false, fmt.Errorf("delete the organization's team folders first")
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var groupIDs []uint
+		if err := tx.Model(&models.Group{}).Where("organization_id = ?", actorMembership.OrganizationID).Pluck("id", &groupIDs).Error; err != nil {
+			return err
+		}
+		if err := deleteGroups(tx, groupIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", actorMembership.OrganizationID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, actorMembership.OrganizationID).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
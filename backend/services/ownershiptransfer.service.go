@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// OwnershipTransferService hands files and folders over from one user to another.
+// The owner offers a transfer, and the recipient accepts or declines it. Accepting
+// moves ownership of the file, or of everything the sender owns in the folder's
+// subtree, along with its storage charge. Shares of the transferred items are kept.
+type OwnershipTransferService struct {
+	DB          *gorm.DB
+	FileService *FileService
+}
+
+// NewOwnershipTransferService creates a new instance of OwnershipTransferService.
+func NewOwnershipTransferService(db *gorm.DB, fileService *FileService) *OwnershipTransferService {
+	return &OwnershipTransferService{DB: db, FileService: fileService}
+}
+
+// transferContents lists the items an accepted transfer moves: the file, or the folder and
+// every folder and file beneath it that the sender owns. It also returns the personal storage
+// those files use and save, which moves with them; team files stay charged to their organization.
+func transferContents(db *gorm.DB, transfer *models.OwnershipTransfer) (fileIDs []uint, folderIDs []uint, usedKB float64, savedKB float64, err error) {
+	var files []models.File
+	if transfer.FileID != nil {
+		if err := db.Where("id = ? AND user_id = ?", *transfer.FileID, transfer.FromUserID).Find(&files).Error; err != nil {
+			return nil, nil, 0, 0, err
+		}
+	} else {
+		pending := []uint{*transfer.FolderID}
+		for len(pending) > 0 {
+			var folders []models.Folder
+			if err := db.Where("id IN ?", pending).Find(&folders).Error; err != nil {
+				return nil, nil, 0, 0, err
+			}
+			for _, folder := range folders {
+				if folder.UserID == transfer.FromUserID {
+					folderIDs = append(folderIDs, folder.ID)
+				}
+			}
+			var children []uint
+			if err := db.Model(&models.Folder{}).Where("parent_folder_id IN ?", pending).Pluck("id", &children).Error; err != nil {
+				return nil, nil, 0, 0, err
+			}
+			var batch []models.File
+			if err := db.Where("folder_id IN ? AND user_id = ?", pending, transfer.FromUserID).Find(&batch).Error; err != nil {
+				return nil, nil, 0, 0, err
+			}
+			files = append(files, batch...)
+			pending = children
+		}
+	}
+
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+		if file.OrganizationID != nil {
+			continue
+		}
+		sizeKB := float64(file.Size) / 1024
+		usedKB += sizeKB
+		// As when deleting, a file whose content is referenced elsewhere counts as saved storage.
+		var content models.DeduplicatedContent
+		if err := db.First(&content, file.DeduplicationID).Error; err == nil && content.ReferenceCount > 1 {
+			savedKB += sizeKB
+		}
+	}
+	return fileIDs, folderIDs, usedKB, savedKB, nil
+}
+
+// checkTransferQuota returns an error if the recipient's quota can't take the storage being moved.
+func checkTransferQuota(recipient *models.User, usedKB float64, savedKB float64) error {
+	if recipient.UsedStorageKB-recipient.SavedStorageKB+usedKB-savedKB > recipient.StorageQuotaKB {
+		return fmt.Errorf("the new owner does not have enough storage quota for this transfer")
+	}
+	return nil
+}
+
+// TransferOwnership offers a file or folder owned by the user to another user. Exactly one
+// of fileID and folderID must be given. The new owner's quota is checked now and again on
+// acceptance. Team items can only be transferred to members of their organization.
+func (s *OwnershipTransferService) TransferOwnership(ctx context.Context, fileID *string, folderID *string, newOwnerID string, user *models.User) (*models.OwnershipTransfer, error) {
+	if (fileID == nil) == (folderID == nil) {
+		return nil, fmt.Errorf("exactly one of fileID and folderID is required")
+	}
+
+	uid, err := strconv.ParseUint(newOwnerID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	if uint(uid) == user.ID {
+		return nil, fmt.Errorf("you already own this item")
+	}
+	var recipient models.User
+	if err := s.DB.First(&recipient, uid).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	transfer := &models.OwnershipTransfer{
+		FromUserID: user.ID,
+		ToUserID:   recipient.ID,
+		Status:     string(models.OwnershipTransferStatusPending),
+	}
+	var organizationID *uint
+	if fileID != nil {
+		id, err := strconv.ParseUint(*fileID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file ID")
+		}
+		var file models.File
+		if err := s.DB.First(&file, "id = ? AND user_id = ?", id, user.ID).Error; err != nil {
+			return nil, fmt.Errorf("file not found or access denied")
+		}
+		transfer.FileID = &file.ID
+		organizationID = file.OrganizationID
+	} else {
+		id, err := strconv.ParseUint(*folderID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folder ID")
+		}
+		var folder models.Folder
+		if err := s.DB.First(&folder, "id = ? AND user_id = ?", id, user.ID).Error; err != nil {
+			return nil, fmt.Errorf("folder not found or access denied")
+		}
+		transfer.FolderID = &folder.ID
+		organizationID = folder.OrganizationID
+	}
+	if organizationID != nil && membershipOf(s.DB, *organizationID, recipient.ID) == nil {
+		return nil, fmt.Errorf("team items can only be transferred to members of the organization")
+	}
+
+	_, _, usedKB, savedKB, err := transferContents(s.DB, transfer)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTransferQuota(&recipient, usedKB, savedKB); err != nil {
+		return nil, err
+	}
+
+	pending := s.DB.Model(&models.OwnershipTransfer{}).Where("status = ?", models.OwnershipTransferStatusPending)
+	if transfer.FileID != nil {
+		pending = pending.Where("file_id = ?", *transfer.FileID)
+	} else {
+		pending = pending.Where("folder_id = ?", *transfer.FolderID)
+	}
+	var count int64
+	pending.Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("this item already has a pending ownership transfer")
+	}
+
+	if err := s.DB.Create(transfer).Error; err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// findPendingTransfer loads a pending transfer that the user is the sender or recipient of.
+func (s *OwnershipTransferService) findPendingTransfer(transferID string, user *models.User) (*models.OwnershipTransfer, error) {
+	id, err := strconv.ParseUint(transferID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ownership transfer ID")
+	}
+	var transfer models.OwnershipTransfer
+	if err := s.DB.First(&transfer, "id = ? AND (from_user_id = ? OR to_user_id = ?)", id, user.ID, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("ownership transfer not found or access denied")
+	}
+	if transfer.Status != string(models.OwnershipTransferStatusPending) {
+		return nil, fmt.Errorf("ownership transfer is no longer pending")
+	}
+	return &transfer, nil
+}
+
+// AcceptOwnershipTransfer makes the user, who must be the recipient, the owner of the transferred
+// items. Their personal storage charge moves from the sender to the user. A transferred item in
+// someone's personal folders is moved to the user's top level, and any share of the transferred
+// items with the user is dropped since they now own them.
+func (s *OwnershipTransferService) AcceptOwnershipTransfer(ctx context.Context, transferID string, user *models.User) (*models.OwnershipTransfer, error) {
+	transfer, err := s.findPendingTransfer(transferID, user)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ToUserID != user.ID {
+		return nil, fmt.Errorf("only the recipient can accept an ownership transfer")
+	}
+
+	now := time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		fileIDs, folderIDs, usedKB, savedKB, err := transferContents(tx, transfer)
+		if err != nil {
+			return err
+		}
+		if len(fileIDs) == 0 && len(folderIDs) == 0 {
+			return fmt.Errorf("the sender no longer owns this item")
+		}
+		var recipient, sender models.User
+		if err := tx.First(&recipient, transfer.ToUserID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&sender, transfer.FromUserID).Error; err != nil {
+			return err
+		}
+		if err := checkTransferQuota(&recipient, usedKB, savedKB); err != nil {
+			return err
+		}
+		if savedKB > sender.SavedStorageKB {
+			savedKB = sender.SavedStorageKB
+		}
+
+		if len(folderIDs) > 0 {
+			if err := tx.Model(&models.Folder{}).Where("id IN ?", folderIDs).Update("user_id", recipient.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("folder_id IN ? AND shared_with_user_id = ?", folderIDs, recipient.ID).Delete(&models.FolderSharing{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.AccessRequest{}).Where("folder_id IN ? AND status = ?", folderIDs, models.AccessRequestStatusPending).
+				Update("owner_id", recipient.ID).Error; err != nil {
+				return err
+			}
+		}
+		if len(fileIDs) > 0 {
+			if err := tx.Model(&models.File{}).Where("id IN ?", fileIDs).Update("user_id", recipient.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("file_id IN ? AND shared_with_user_id = ?", fileIDs, recipient.ID).Delete(&models.FileSharing{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.AccessRequest{}).Where("file_id IN ? AND status = ?", fileIDs, models.AccessRequestStatusPending).
+				Update("owner_id", recipient.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := s.detachFromSenderFolders(tx, transfer, recipient.ID); err != nil {
+			return err
+		}
+
+		if usedKB > 0 {
+			if err := tx.Model(&sender).Updates(map[string]interface{}{
+				"used_storage_kb":  gorm.Expr("used_storage_kb - ?", usedKB),
+				"saved_storage_kb": gorm.Expr("saved_storage_kb - ?", savedKB),
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&recipient).Updates(map[string]interface{}{
+				"used_storage_kb":  gorm.Expr("used_storage_kb + ?", usedKB),
+				"saved_storage_kb": gorm.Expr("saved_storage_kb + ?", savedKB),
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(transfer).Updates(map[string]interface{}{
+			"status":     string(models.OwnershipTransferStatusAccepted),
+			"decided_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	transfer.Status = string(models.OwnershipTransferStatusAccepted)
+	transfer.DecidedAt = &now
+
+	s.FileService.publishStorageUpdate(&models.User{BaseModel: models.BaseModel{ID: transfer.FromUserID}})
+	s.FileService.publishStorageUpdate(&models.User{BaseModel: models.BaseModel{ID: transfer.ToUserID}})
+	return transfer, nil
+}
+
+// detachFromSenderFolders moves a transferred personal item to the recipient's top level
+// unless it already sits in one of the recipient's folders. Team items keep their place,
+// since the organization's members can all see team folders.
+func (s *OwnershipTransferService) detachFromSenderFolders(tx *gorm.DB, transfer *models.OwnershipTransfer, recipientID uint) error {
+	var parentID *uint
+	var organizationID *uint
+	var model interface{}
+	column := "folder_id"
+	if transfer.FileID != nil {
+		var file models.File
+		if err := tx.First(&file, *transfer.FileID).Error; err != nil {
+			return err
+		}
+		parentID, organizationID, model = file.FolderID, file.OrganizationID, &file
+	} else {
+		var folder models.Folder
+		if err := tx.First(&folder, *transfer.FolderID).Error; err != nil {
+			return err
+		}
+		parentID, organizationID, model = folder.ParentFolderID, folder.OrganizationID, &folder
+		column = "parent_folder_id"
+	}
+	if parentID == nil || organizationID != nil {
+		return nil
+	}
+	var count int64
+	tx.Model(&models.Folder{}).Where("id = ? AND user_id = ?", *parentID, recipientID).Count(&count)
+	if count > 0 {
+		return nil
+	}
+	return tx.Model(model).Update(column, nil).Error
+}
+
+// DeclineOwnershipTransfer lets the recipient turn down a transfer.
+func (s *OwnershipTransferService) DeclineOwnershipTransfer(ctx context.Context, transferID string, user *models.User) (*models.OwnershipTransfer, error) {
+	transfer, err := s.findPendingTransfer(transferID, user)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ToUserID != user.ID {
+		return nil, fmt.Errorf("only the recipient can decline an ownership transfer")
+	}
+	return s.close(transfer, models.OwnershipTransferStatusDeclined)
+}
+
+// CancelOwnershipTransfer lets the sender withdraw a transfer before it is accepted.
+func (s *OwnershipTransferService) CancelOwnershipTransfer(ctx context.Context, transferID string, user *models.User) (*models.OwnershipTransfer, error) {
+	transfer, err := s.findPendingTransfer(transferID, user)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.FromUserID != user.ID {
+		return nil, fmt.Errorf("only the sender can cancel an ownership transfer")
+	}
+	return s.close(transfer, models.OwnershipTransferStatusCancelled)
+}
+
+// close records that a transfer was declined or cancelled.
+func (s *OwnershipTransferService) close(transfer *models.OwnershipTransfer, status models.OwnershipTransferStatus) (*models.OwnershipTransfer, error) {
+	now := time.Now()
+	if err := s.DB.Model(transfer).Updates(map[string]interface{}{
+		"status":     string(status),
+		"decided_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	transfer.Status = string(status)
+	transfer.DecidedAt = &now
+	return transfer, nil
+}
+
+// ListIncoming returns the transfers offered to the user, newest first, optionally filtered by status.
+func (s *OwnershipTransferService) ListIncoming(ctx context.Context, status *models.OwnershipTransferStatus, user *models.User) ([]*models.OwnershipTransfer, error) {
+	db := s.DB.Where("to_user_id = ?", user.ID)
+	if status != nil {
+		db = db.Where("status = ?", string(*status))
+	}
+	var transfers []*models.OwnershipTransfer
+	err := db.Order("id DESC").Find(&transfers).Error
+	return transfers, err
+}
+
+// ListOutgoing returns the transfers the user has offered, newest first.
+func (s *OwnershipTransferService) ListOutgoing(ctx context.Context, user *models.User) ([]*models.OwnershipTransfer, error) {
+	var transfers []*models.OwnershipTransfer
+	err := s.DB.Where("from_user_id = ?", user.ID).Order("id DESC").Find(&transfers).Error
+	return transfers, err
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// newTestOwnershipTransferService returns an OwnershipTransferService on an empty database.
+func newTestOwnershipTransferService(t *testing.T) *OwnershipTransferService {
+	t.Helper()
+	db := testdb.Open(t, &models.User{}, &models.Organization{}, &models.Membership{}, &models.DeduplicatedContent{},
+		&models.Folder{}, &models.File{}, &models.FileSharing{}, &models.FolderSharing{}, &models.AccessRequest{},
+		&models.OwnershipTransfer{})
+	return NewOwnershipTransferService(db, &FileService{DB: db, RDB: newTestRedis(t)})
+}
+
+// setStorage sets a user's storage quota and charges.
+func setStorage(t *testing.T, db *gorm.DB, user *models.User, quotaKB, usedKB, savedKB float64) {
+	t.Helper()
+	if err := db.Model(user).Updates(map[string]interface{}{
+		"storage_quota_kb": quotaKB,
+		"used_storage_kb":  usedKB,
+		"saved_storage_kb": savedKB,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOwnershipTransferQuota(t *testing.T) {
+	ctx := context.Background()
+	s := newTestOwnershipTransferService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	file := createTestFile(t, s.DB, alice, "video.mp4", nil)
+	if err := s.DB.Model(file).Update("size", 8*1024*1024).Error; err != nil {
+		t.Fatal(err)
+	}
+	setStorage(t, s.DB, alice, 10240, 8192, 0)
+
+	setStorage(t, s.DB, bob, 10240, 4096, 0)
+	_, err := s.TransferOwnership(ctx, apiID(file.ID), nil, *apiID(bob.ID), reloadUser(t, s.DB, alice))
+	if err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Fatalf("offering more than the recipient's free quota: got %v, want a quota error", err)
+	}
+
+	setStorage(t, s.DB, bob, 10240, 1024, 0)
+	transfer, err := s.TransferOwnership(ctx, apiID(file.ID), nil, *apiID(bob.ID), reloadUser(t, s.DB, alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The quota is checked again when the transfer is accepted.
+	setStorage(t, s.DB, bob, 10240, 3072, 0)
+	_, err = s.AcceptOwnershipTransfer(ctx, *apiID(transfer.ID), reloadUser(t, s.DB, bob))
+	if err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Fatalf("accepting beyond the recipient's quota: got %v, want a quota error", err)
+	}
+	if owner := reloadFile(t, s.DB, file).UserID; owner != alice.ID {
+		t.Errorf("the file moved to user %d after a failed acceptance", owner)
+	}
+}
+
+func TestOwnershipTransferMovesFolderAndStorage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestOwnershipTransferService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	carol := createTestUser(t, s.DB, "carol")
+
+	home := createTestFolder(t, s.DB, alice, "Home", nil)
+	project := createTestFolder(t, s.DB, alice, "Project", home)
+	drafts := createTestFolder(t, s.DB, alice, "Drafts", project)
+	plan := createTestFile(t, s.DB, alice, "plan.pdf", project)
+	draft := createTestFile(t, s.DB, alice, "draft.pdf", drafts)
+	notes := createTestFile(t, s.DB, carol, "notes.pdf", project)
+	other := createTestFile(t, s.DB, alice, "other.pdf", home)
+
+	// plan.pdf is 2 KB; draft.pdf is 4 KB and its content is stored once for two files, so
+	// it counts as saved storage.
+	s.DB.Model(plan).Update("size", 2048)
+	s.DB.Model(draft).Update("size", 4096)
+	s.DB.Model(&models.DeduplicatedContent{}).Where("id = ?", draft.DeduplicationID).Update("reference_count", 2)
+	setStorage(t, s.DB, alice, 10240, 7, 4)
+	setStorage(t, s.DB, bob, 10240, 1, 0)
+
+	// Bob could already read the project through a share, which he no longer needs.
+	if err := s.DB.Create(&models.FolderSharing{FolderID: project.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	transfer, err := s.TransferOwnership(ctx, nil, apiID(project.ID), *apiID(bob.ID), reloadUser(t, s.DB, alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AcceptOwnershipTransfer(ctx, *apiID(transfer.ID), reloadUser(t, s.DB, bob)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, folder := range []*models.Folder{project, drafts} {
+		var reloaded models.Folder
+		s.DB.First(&reloaded, folder.ID)
+		if reloaded.UserID != bob.ID {
+			t.Errorf("folder %s is owned by user %d, want bob", folder.FolderName, reloaded.UserID)
+		}
+		if folder == project && reloaded.ParentFolderID != nil {
+			t.Errorf("the project stayed in alice's folder %d", *reloaded.ParentFolderID)
+		}
+	}
+	wantOwners := map[*models.File]uint{plan: bob.ID, draft: bob.ID, notes: carol.ID, other: alice.ID}
+	for file, want := range wantOwners {
+		if got := reloadFile(t, s.DB, file).UserID; got != want {
+			t.Errorf("file %s is owned by user %d, want %d", file.FileName, got, want)
+		}
+	}
+
+	aliceAfter, bobAfter := reloadUser(t, s.DB, alice), reloadUser(t, s.DB, bob)
+	if aliceAfter.UsedStorageKB != 1 || aliceAfter.SavedStorageKB != 0 {
+		t.Errorf("alice uses %v KB and saves %v KB, want 1 and 0", aliceAfter.UsedStorageKB, aliceAfter.SavedStorageKB)
+	}
+	if bobAfter.UsedStorageKB != 7 || bobAfter.SavedStorageKB != 4 {
+		t.Errorf("bob uses %v KB and saves %v KB, want 7 and 4", bobAfter.UsedStorageKB, bobAfter.SavedStorageKB)
+	}
+
+	var shares int64
+	s.DB.Model(&models.FolderSharing{}).Where("shared_with_user_id = ?", bob.ID).Count(&shares)
+	if shares != 0 {
+		t.Errorf("bob still has %d folder shares", shares)
+	}
+}
+
+func TestOwnershipTransferOfTeamItems(t *testing.T) {
+	ctx := context.Background()
+	s := newTestOwnershipTransferService(t)
+	alice := createTestUser(t, s.DB, "alice")
+	bob := createTestUser(t, s.DB, "bob")
+	organization := &models.Organization{Name: "Acme"}
+	if err := s.DB.Create(organization).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.Create(&models.Membership{OrganizationID: organization.ID, UserID: alice.ID, Role: models.OrgRoleOwner}).Error; err != nil {
+		t.Fatal(err)
+	}
+	team := &models.Folder{UserID: alice.ID, FolderName: "Team", OrganizationID: &organization.ID}
+	if err := s.DB.Create(team).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.TransferOwnership(ctx, nil, apiID(team.ID), *apiID(bob.ID), alice)
+	if err == nil || !strings.Contains(err.Error(), "members") {
+		t.Fatalf("transferring a team folder to a non-member: got %v, want an error", err)
+	}
+
+	if err := s.DB.Create(&models.Membership{OrganizationID: organization.ID, UserID: bob.ID, Role: models.OrgRoleMember}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TransferOwnership(ctx, nil, apiID(team.ID), *apiID(bob.ID), alice); err != nil {
+		t.Errorf("transferring a team folder to a member: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// maxFolderDepth bounds how far up the folder tree ancestors are followed, so that a
+// corrupted tree with a cycle in it can't make the lookup run forever.
+const maxFolderDepth = 1000
+
+// FolderAncestors loads the path of each folder: the folder and the folders above it, starting
+// from the top-level folder. Folders that don't exist get no path.
+// All the paths are loaded with one recursive query.
+func FolderAncestors(db *gorm.DB, folderIDs []uint) (map[uint][]*models.Folder, error) {
+	var rows []struct {
+		models.Folder
+		StartID uint
+		Depth   int
+	}
+	err := db.Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT folders.*, folders.id AS start_id, 0 AS depth
+			FROM folders WHERE folders.id IN ?
+			UNION ALL
+			SELECT folders.*, ancestors.start_id, ancestors.depth + 1
+			FROM folders JOIN ancestors ON folders.id = ancestors.parent_folder_id
+			WHERE ancestors.depth < ?
+		)
+		SELECT * FROM ancestors ORDER BY start_id, depth DESC`, folderIDs, maxFolderDepth).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[uint][]*models.Folder, len(folderIDs))
+	for i := range rows {
+		paths[rows[i].StartID] = append(paths[rows[i].StartID], &rows[i].Folder)
+	}
+	return paths, nil
+}
+
+// VisiblePath trims a path loaded by FolderAncestors to the part the user may see. Owners and
+// admins see the whole path; users a folder was shared with see it from the highest folder
+// they can read without a gap, so the names of the owner's other folders aren't revealed.
+func (s *ShareService) VisiblePath(ctx context.Context, path []*models.Folder, user *models.User) []*models.Folder {
+	if user.Role == models.RoleAdmin {
+		return path
+	}
+	start := len(path)
+	for start > 0 && canReadFolder(s.DB, path[start-1], user) {
+		start--
+	}
+	return path[start:]
+}
+
+// PathString joins the names of a path, and optionally a file name, into a path such as
+// /Projects/2024/report.pdf.
+func PathString(path []*models.Folder, fileName string) string {
+	names := make([]string, 0, len(path)+1)
+	for _, folder := range path {
+		names = append(names, folder.FolderName)
+	}
+	if fileName != "" {
+		names = append(names, fileName)
+	}
+	return "/" + strings.Join(names, "/")
+}
+
+// ResolvePath finds the folder or file at a path such as /Projects/2024/report.pdf in the
+// user's own files. The last name can match a folder, a file, or both. A path ending in a
+// slash only matches a folder.
+func (s *ShareService) ResolvePath(ctx context.Context, path string, user *models.User) (*models.ResolvedPath, error) {
+	trimmed := strings.TrimRight(strings.TrimSpace(path), "/")
+	folderOnly := trimmed != strings.TrimSpace(path)
+	slash := strings.LastIndex(trimmed, "/")
+	name := strings.TrimSpace(trimmed[slash+1:])
+	if name == "" {
+		return nil, fmt.Errorf("path must name a folder or file")
+	}
+
+	var parentID *uint
+	if parentPath := trimmed[:slash+1]; strings.Trim(parentPath, "/ ") != "" {
+		parent, err := folderByPath(s.DB, user.ID, parentPath)
+		if err != nil {
+			return nil, fmt.Errorf("path not found")
+		}
+		parentID = &parent.ID
+	}
+
+	resolved := &models.ResolvedPath{}
+	var folder models.Folder
+	query := s.DB.Where("user_id = ? AND folder_name = ?", user.ID, name)
+	if parentID == nil {
+		query = query.Where("parent_folder_id IS NULL")
+	} else {
+		query = query.Where("parent_folder_id = ?", *parentID)
+	}
+	if err := query.Order("id").Limit(1).Find(&folder).Error; err != nil {
+		return nil, err
+	} else if folder.ID != 0 {
+		resolved.Folder = &folder
+	}
+
+	if !folderOnly {
+		var file models.File
+		query := s.DB.Where("user_id = ? AND file_name = ?", user.ID, name)
+		if parentID == nil {
+			query = query.Where("folder_id IS NULL")
+		} else {
+			query = query.Where("folder_id = ?", *parentID)
+		}
+		if err := query.Order("id").Limit(1).Find(&file).Error; err != nil {
+			return nil, err
+		} else if file.ID != 0 {
+			resolved.File = &file
+		}
+	}
+
+	if resolved.Folder == nil && resolved.File == nil {
+		return nil, fmt.Errorf("path not found")
+	}
+	return resolved, nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joel2607/FileVault/models"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// NameCollisionPolicy decides what happens when a file or folder would get the same name as
+// another one in the same folder, on upload, creation, rename or move.
+type NameCollisionPolicy string
+
+const (
+	// NameCollisionReject refuses the change.
+	NameCollisionReject NameCollisionPolicy = "reject"
+	// NameCollisionRename numbers the new name, as in "report (2).pdf".
+	NameCollisionRename NameCollisionPolicy = "rename"
+	// NameCollisionReplace deletes the existing file. Folders are never replaced, as that
+	// would delete everything in them; folder collisions are rejected instead.
+	NameCollisionReplace NameCollisionPolicy = "replace"
+)
+
+// NameCollisionPolicyFromConfig reads the policy from files.name_collision, which defaults to
+// rename. Unknown values reject collisions.
+func NameCollisionPolicyFromConfig() NameCollisionPolicy {
+	viper.SetDefault("files.name_collision", string(NameCollisionRename))
+	switch policy := NameCollisionPolicy(strings.ToLower(viper.GetString("files.name_collision"))); policy {
+	case NameCollisionRename, NameCollisionReplace:
+		return policy
+	}
+	return NameCollisionReject
+}
+
+// numberedName matches a name that has already been numbered, such as "report (2)".
+var numberedName = regexp.MustCompile(`^(.*) \(\d+\)$`)
+
+// maxNameNumber bounds the numbers tried when renaming.
+const maxNameNumber = 10000
+
+// destinationFolder parses and loads the folder a file or folder is being put in, checking
+// that the user may add to it: personal folders only take their owner's files and folders,
+// and team folders those of the organization's members.
+func (s *FileService) destinationFolder(folderID string, user *models.User) (*models.Folder, error) {
+	id, err := strconv.ParseUint(folderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent folder ID")
+	}
+	var folder models.Folder
+	if err := s.DB.First(&folder, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("parent folder not found or access denied")
+		}
+		return nil, err
+	}
+	if folder.OrganizationID != nil {
+		if membershipOf(s.DB, *folder.OrganizationID, user.ID) == nil {
+			return nil, fmt.Errorf("access denied: you are not a member of this folder's organization")
+		}
+		return &folder, nil
+	}
+	if folder.UserID != user.ID {
+		return nil, fmt.Errorf("parent folder not found or access denied")
+	}
+	return &folder, nil
+}
+
+// checkFolderMove returns an error if moving folder into target would put it inside itself,
+// which would cut it and its subfolders off from the rest of the tree.
+func (s *FileService) checkFolderMove(folder *models.Folder, target *models.Folder) error {
+	paths, err := FolderAncestors(s.DB, []uint{target.ID})
+	if err != nil {
+		return err
+	}
+	for _, ancestor := range paths[target.ID] {
+		if ancestor.ID == folder.ID {
+			return fmt.Errorf("cannot move a folder into itself or one of its subfolders")
+		}
+	}
+	return nil
+}
+
+// fileSiblings returns a query for the files in the same place as a file owned by ownerID
+// going into folderID, leaving out the file itself.
+func (s *FileService) fileSiblings(folderID *uint, ownerID uint, exceptID uint) *gorm.DB {
+	query := s.DB.Model(&models.File{}).Where("id <> ?", exceptID)
+	if folderID == nil {
+		return query.Where("user_id = ? AND folder_id IS NULL", ownerID)
+	}
+	return query.Where("folder_id = ?", *folderID)
+}
+
+// folderSiblings returns a query for the folders in the same place as a folder going into
+// parentID, leaving out the folder itself. Top-level team folders are siblings of the other
+// top-level folders of their organization; other top-level folders of their owner's.
+func (s *FileService) folderSiblings(parentID *uint, ownerID uint, organizationID *uint, exceptID uint) *gorm.DB {
+	query := s.DB.Model(&models.Folder{}).Where("id <> ?", exceptID)
+	switch {
+	case parentID != nil:
+		return query.Where("parent_folder_id = ?", *parentID)
+	case organizationID != nil:
+		return query.Where("organization_id = ? AND parent_folder_id IS NULL", *organizationID)
+	default:
+		return query.Where("user_id = ? AND organization_id IS NULL AND parent_folder_id IS NULL", ownerID)
+	}
+}
+
+// placeFile applies the name collision policy to a file named name going among siblings.
+// It returns the name to give the file and, under the replace policy, the files to delete
+// once the new file is in place. If the user couldn't delete one of them, the change is rejected.
+func (s *FileService) placeFile(siblings *gorm.DB, name string, user *models.User) (string, []uint, error) {
+	var taken []*models.File
+	if err := siblings.Session(&gorm.Session{}).Where("file_name = ?", name).Find(&taken).Error; err != nil {
+		return "", nil, err
+	}
+	if len(taken) == 0 {
+		return name, nil, nil
+	}
+	switch s.NameCollision {
+	case NameCollisionReplace:
+		replaced := make([]uint, len(taken))
+		for i, file := range taken {
+			if file.UserID != user.ID && !canManageOrganization(s.DB, file.OrganizationID, user) {
+				return "", nil, fmt.Errorf("a file named %q already exists in this folder and you cannot replace it", name)
+			}
+			replaced[i] = file.ID
+		}
+		return name, replaced, nil
+	case NameCollisionRename:
+		renamed, err := freeName(siblings, "file_name", name, true)
+		return renamed, nil, err
+	}
+	return "", nil, fmt.Errorf("a file named %q already exists in this folder", name)
+}
+
+// placeFolder applies the name collision policy to a folder named name going among siblings,
+// returning the name to give the folder.
+func (s *FileService) placeFolder(siblings *gorm.DB, name string) (string, error) {
+	var count int64
+	if err := siblings.Session(&gorm.Session{}).Where("folder_name = ?", name).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return name, nil
+	}
+	if s.NameCollision == NameCollisionRename {
+		return freeName(siblings, "folder_name", name, false)
+	}
+	return "", fmt.Errorf("a folder named %q already exists in this folder", name)
+}
+
+// freeName returns the first numbered variant of name, such as "report (2).pdf", that none of
+// the siblings has. File names keep their extension at the end.
+func freeName(siblings *gorm.DB, column string, name string, keepExtension bool) (string, error) {
+	stem, extension := name, ""
+	if keepExtension {
+		if ext := filepath.Ext(name); ext != name {
+			stem, extension = strings.TrimSuffix(name, ext), ext
+		}
+	}
+	if match := numberedName.FindStringSubmatch(stem); match != nil {
+		stem = match[1]
+	}
+
+	var names []string
+	if err := siblings.Session(&gorm.Session{}).Where(column+" LIKE ? ESCAPE '\\'", escapeLike(stem)+" (%").Pluck(column, &names).Error; err != nil {
+		return "", err
+	}
+	taken := make(map[string]bool, len(names))
+	for _, existing := range names {
+		taken[existing] = true
+	}
+	for n := 2; n <= maxNameNumber; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, n, extension)
+		if !taken[candidate] {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("too many items named %q in this folder", name)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// replacedStorageKB returns the storage, in KB, that deleting the files a new file replaces
+// frees: the size of those whose content no other file uses.
+func (s *FileService) replacedStorageKB(fileIDs []uint) (float64, error) {
+	if len(fileIDs) == 0 {
+		return 0, nil
+	}
+	var size int64
+	err := s.DB.Model(&models.File{}).
+		Joins("JOIN deduplicated_contents ON deduplicated_contents.id = files.deduplication_id").
+		Where("files.id IN ? AND deduplicated_contents.reference_count <= 1", fileIDs).
+		Select("COALESCE(SUM(files.size), 0)").Scan(&size).Error
+	return float64(size) / 1024, err
+}
+
+// replaceFiles deletes the files a new file replaced under the replace policy. The new file
+// is already in place, so a file that can't be deleted is logged and left next to it rather
+// than failing the change.
+func (s *FileService) replaceFiles(ctx context.Context, fileIDs []uint, user *models.User) {
+	for _, id := range fileIDs {
+		if _, err := s.DeleteFile(ctx, strconv.FormatUint(uint64(id), 10), user); err != nil {
+			log.Printf("Failed to delete file %d replaced by user %d: %v", id, user.ID, err)
+		}
+	}
+}
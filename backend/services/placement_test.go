@@ -0,0 +1,165 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// openPlacementTestDB opens an empty database with the tables placement works on.
+func openPlacementTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testdb.Open(t, &models.User{}, &models.Organization{}, &models.Membership{}, &models.DeduplicatedContent{}, &models.Folder{}, &models.File{})
+}
+
+func TestCheckFolderMove(t *testing.T) {
+	db := openPlacementTestDB(t)
+	s := &FileService{DB: db}
+	alice := createTestUser(t, db, "alice")
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	year := createTestFolder(t, db, alice, "2024", projects)
+	reports := createTestFolder(t, db, alice, "Reports", year)
+	archive := createTestFolder(t, db, alice, "Archive", nil)
+
+	tests := []struct {
+		name    string
+		folder  *models.Folder
+		target  *models.Folder
+		wantErr bool
+	}{
+		{name: "into itself", folder: projects, target: projects, wantErr: true},
+		{name: "into its child", folder: projects, target: year, wantErr: true},
+		{name: "into a deeper descendant", folder: projects, target: reports, wantErr: true},
+		{name: "into its parent", folder: reports, target: year, wantErr: false},
+		{name: "into an ancestor", folder: reports, target: projects, wantErr: false},
+		{name: "into another tree", folder: year, target: archive, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkFolderMove(tt.folder, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkFolderMove(%s, %s) = %v, want error: %v", tt.folder.FolderName, tt.target.FolderName, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlaceFileRename(t *testing.T) {
+	db := openPlacementTestDB(t)
+	s := &FileService{DB: db, NameCollision: NameCollisionRename}
+	alice := createTestUser(t, db, "alice")
+	folder := createTestFolder(t, db, alice, "Documents", nil)
+	for _, name := range []string{"report.pdf", "report (2).pdf", "report (4).pdf", "notes", "archive.tar.gz"} {
+		createTestFile(t, db, alice, name, folder)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "report (2).pdf", want: "report (3).pdf"},
+		{name: "report.pdf", want: "report (3).pdf"},
+		{name: "report.docx", want: "report.docx"},
+		{name: "notes", want: "notes (2)"},
+		{name: "archive.tar.gz", want: "archive.tar (2).gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, replaced, err := s.placeFile(s.fileSiblings(&folder.ID, alice.ID, 0), tt.name, alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || len(replaced) != 0 {
+				t.Errorf("placeFile(%q) = %q, %v, want %q and nothing replaced", tt.name, got, replaced, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceFileReplaceAndReject(t *testing.T) {
+	db := openPlacementTestDB(t)
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	organization := &models.Organization{Name: "Acme"}
+	if err := db.Create(organization).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, membership := range []*models.Membership{
+		{OrganizationID: organization.ID, UserID: alice.ID, Role: models.OrgRoleOwner},
+		{OrganizationID: organization.ID, UserID: bob.ID, Role: models.OrgRoleMember},
+	} {
+		if err := db.Create(membership).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	team := &models.Folder{UserID: alice.ID, FolderName: "Team", OrganizationID: &organization.ID}
+	if err := db.Create(team).Error; err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, db, alice, "plan.pdf", team)
+	bobsFile := createTestFile(t, db, bob, "budget.pdf", team)
+
+	tests := []struct {
+		name         string
+		policy       NameCollisionPolicy
+		fileName     string
+		user         *models.User
+		wantReplaced []uint
+		wantErr      string
+	}{
+		{name: "replace own file", policy: NameCollisionReplace, fileName: "budget.pdf", user: bob, wantReplaced: []uint{bobsFile.ID}},
+		{name: "admin replaces a member's file", policy: NameCollisionReplace, fileName: "budget.pdf", user: alice, wantReplaced: []uint{bobsFile.ID}},
+		{name: "member cannot replace another's file", policy: NameCollisionReplace, fileName: "plan.pdf", user: bob, wantErr: "you cannot replace it"},
+		{name: "replace with no collision", policy: NameCollisionReplace, fileName: "new.pdf", user: bob},
+		{name: "reject", policy: NameCollisionReject, fileName: "plan.pdf", user: alice, wantErr: "already exists"},
+		{name: "reject with no collision", policy: NameCollisionReject, fileName: "new.pdf", user: alice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &FileService{DB: db, NameCollision: tt.policy}
+			name, replaced, err := s.placeFile(s.fileSiblings(&team.ID, tt.user.ID, 0), tt.fileName, tt.user)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("placeFile(%q) error = %v, want one containing %q", tt.fileName, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.fileName {
+				t.Errorf("placeFile(%q) renamed the file to %q", tt.fileName, name)
+			}
+			if len(replaced) != len(tt.wantReplaced) || (len(replaced) > 0 && replaced[0] != tt.wantReplaced[0]) {
+				t.Errorf("placeFile(%q) replaces %v, want %v", tt.fileName, replaced, tt.wantReplaced)
+			}
+		})
+	}
+}
+
+func TestReplacedStorageKB(t *testing.T) {
+	db := openPlacementTestDB(t)
+	s := &FileService{DB: db, NameCollision: NameCollisionReplace}
+	alice := createTestUser(t, db, "alice")
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	db.Model(report).Update("size", 2048)
+	shared := createTestFile(t, db, alice, "shared.pdf", nil)
+	copy := createTestFile(t, db, alice, "copy.pdf", nil)
+	db.Model(copy).Update("deduplication_id", shared.DeduplicationID)
+	db.Model(&models.DeduplicatedContent{}).Where("id = ?", shared.DeduplicationID).Update("reference_count", 2)
+
+	// Replacing report.pdf frees its 2 KB, so a new version of the same size fits in the quota.
+	freed, err := s.replacedStorageKB([]uint{report.ID, shared.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freed != 2 {
+		t.Errorf("replacing frees %v KB, want 2: shared.pdf's content is still used by copy.pdf", freed)
+	}
+	if freed, err := s.replacedStorageKB(nil); err != nil || freed != 0 {
+		t.Errorf("replacing nothing frees %v KB (err %v), want 0", freed, err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package searchquery parses the query language of file searches: free text mixed with
+// key:value filters, for example
+//
+//	report type:pdf size:>10MB owner:alice tag:invoice shared:yes in:"Projects/2024"
+//
+// Values containing spaces are quoted. The filters are:
+//
+//	type:    a file extension (pdf), a MIME type (application/pdf, image/*) or a kind (image, video, audio, text)
+//	size:    a size with an optional comparison and unit: >10MB, <=1.5GB, 500KB
+//	owner:   the uploader's username, or "me"
+//	tag:     a tag the file has
+//	shared:  yes for files that are public or shared with someone, no for the others
+//	in:      a folder path from the root, such as "Projects/2024"; subfolders are included
+//	after:   files uploaded on or after a date (2024-01-31) or time (RFC 3339)
+//	before:  files uploaded before a date or time
+//
+// Repeated type: and owner: filters match any of their values; all other filters must all
+// match. Everything else is free text, kept as written so that it can be passed on to the
+// text search.
+package searchquery
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter keys.
+const (
+	KeyType   = "type"
+	KeySize   = "size"
+	KeyOwner  = "owner"
+	KeyTag    = "tag"
+	KeyShared = "shared"
+	KeyIn     = "in"
+	KeyAfter  = "after"
+	KeyBefore = "before"
+)
+
+// keys are the filter keys in the order they are listed in error messages.
+var keys = []string{KeyType, KeySize, KeyOwner, KeyTag, KeyShared, KeyIn, KeyAfter, KeyBefore}
+
+// Error describes one problem with a query, pointing at the offending term.
+type Error struct {
+	Offset  int    // Byte offset of the term in the query.
+	Term    string // The term as written, e.g. `size:>ten`.
+	Message string // Human-readable explanation.
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s (at %d): %s", e.Term, e.Offset, e.Message)
+}
+
+// Errors is a list of query errors. It implements error so that every problem with a query
+// is reported at once.
+type Errors []Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid search query: " + strings.Join(messages, "; ")
+}
+
+// Term is a key:value filter as written in the query.
+type Term struct {
+	Key    string
+	Value  string // The value with any quotes removed.
+	Raw    string
+	Offset int
+}
+
+// Err returns an Error pointing at the term.
+func (t Term) Err(format string, args ...interface{}) Error {
+	return Error{Offset: t.Offset, Term: t.Raw, Message: fmt.Sprintf(format, args...)}
+}
+
+// SizeFilter compares the file size, in bytes, with Bytes using Op: one of >, >=, <, <= or =.
+type SizeFilter struct {
+	Op    string
+	Bytes int64
+}
+
+// Query is a parsed search query.
+type Query struct {
+	// Text is the free text of the query, as written.
+	Text   string
+	Types  []string
+	Sizes  []SizeFilter
+	Owners []string
+	Tags   []string
+	// Shared is nil unless the query has a shared: filter.
+	Shared *bool
+	// Folder is the in: filter, which is resolved against the searching user's folders.
+	Folder *Term
+	After  *time.Time
+	Before *time.Time
+}
+
+var (
+	keyPattern  = regexp.MustCompile(`^([A-Za-z]+):(.*)$`)
+	typePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*(/([a-z0-9][a-z0-9.+-]*|\*))?$`)
+	sizePattern = regexp.MustCompile(`^(>=|<=|>|<|=)?([0-9]+(?:\.[0-9]+)?)([a-z]*)$`)
+)
+
+// sizeUnits are the size units, as multiples of a byte.
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+// Parse parses a search query. It returns Errors listing every invalid term.
+func Parse(input string) (*Query, error) {
+	query := &Query{}
+	var errs Errors
+	var text []string
+
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, Errors{*err}
+	}
+	for _, token := range tokens {
+		match := keyPattern.FindStringSubmatch(token.raw)
+		if match == nil {
+			text = append(text, token.raw)
+			continue
+		}
+		term := Term{Key: strings.ToLower(match[1]), Value: unquote(match[2]), Raw: token.raw, Offset: token.offset}
+		if err := query.add(term); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	query.Text = strings.Join(text, " ")
+	return query, nil
+}
+
+// add applies a filter term to the query.
+func (q *Query) add(term Term) *Error {
+	if !isKey(term.Key) {
+		err := term.Err("unknown filter %q; the filters are %s", term.Key, strings.Join(keys, ", "))
+		return &err
+	}
+	value := strings.TrimSpace(term.Value)
+	if value == "" {
+		err := term.Err("%s: needs a value", term.Key)
+		return &err
+	}
+
+	var err Error
+	switch term.Key {
+	case KeyType:
+		value = strings.ToLower(strings.TrimPrefix(value, "."))
+		if !typePattern.MatchString(value) {
+			err = term.Err("invalid type %q: use an extension such as pdf, a MIME type such as image/png or image/*, or image, video, audio or text", value)
+			break
+		}
+		q.Types = append(q.Types, value)
+		return nil
+	case KeySize:
+		size, ok := parseSize(value)
+		if !ok {
+			err = term.Err("invalid size %q: use a size such as >10MB, <=1.5GB or 500KB", value)
+			break
+		}
+		q.Sizes = append(q.Sizes, size)
+		return nil
+	case KeyOwner:
+		q.Owners = append(q.Owners, value)
+		return nil
+	case KeyTag:
+		q.Tags = append(q.Tags, value)
+		return nil
+	case KeyShared:
+		shared, ok := parseYesNo(value)
+		if !ok {
+			err = term.Err("invalid value %q: use shared:yes or shared:no", value)
+			break
+		}
+		if q.Shared != nil && *q.Shared != shared {
+			err = term.Err("conflicts with an earlier shared: filter")
+			break
+		}
+		q.Shared = &shared
+		return nil
+	case KeyIn:
+		if q.Folder != nil {
+			err = term.Err("only one in: filter can be given")
+			break
+		}
+		term.Value = value
+		q.Folder = &term
+		return nil
+	case KeyAfter, KeyBefore:
+		at, ok := parseTime(value)
+		if !ok {
+			err = term.Err("invalid date %q: use a date such as 2024-01-31 or an RFC 3339 time", value)
+			break
+		}
+		if term.Key == KeyAfter {
+			q.After = &at
+		} else {
+			q.Before = &at
+		}
+		return nil
+	}
+	return &err
+}
+
+func isKey(key string) bool {
+	for _, known := range keys {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+// token is a run of the query separated by whitespace outside quotes.
+type token struct {
+	raw    string
+	offset int
+}
+
+// tokenize splits the query on whitespace, keeping quoted text together.
+func tokenize(input string) ([]token, *Error) {
+	var tokens []token
+	start := -1
+	quoteAt := -1
+	for i, r := range input {
+		switch {
+		case r == '"':
+			if start < 0 {
+				start = i
+			}
+			if quoteAt < 0 {
+				quoteAt = i
+			} else {
+				quoteAt = -1
+			}
+		case quoteAt < 0 && (r == ' ' || r == '\t' || r == '\n' || r == '\r'):
+			if start >= 0 {
+				tokens = append(tokens, token{raw: input[start:i], offset: start})
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if quoteAt >= 0 {
+		return nil, &Error{Offset: quoteAt, Term: input[start:], Message: "unterminated quote"}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{raw: input[start:], offset: start})
+	}
+	return tokens, nil
+}
+
+// unquote removes the double quotes from a value.
+func unquote(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
+
+// parseSize parses a size filter value such as >10MB.
+func parseSize(value string) (SizeFilter, bool) {
+	match := sizePattern.FindStringSubmatch(strings.ToLower(strings.ReplaceAll(value, " ", "")))
+	if match == nil {
+		return SizeFilter{}, false
+	}
+	unit, ok := sizeUnits[match[3]]
+	if !ok {
+		return SizeFilter{}, false
+	}
+	number, err := strconv.ParseFloat(match[2], 64)
+	if err != nil || number*float64(unit) > math.MaxInt64 {
+		return SizeFilter{}, false
+	}
+	op := match[1]
+	if op == "" {
+		op = "="
+	}
+	return SizeFilter{Op: op, Bytes: int64(math.Round(number * float64(unit)))}, true
+}
+
+// parseYesNo parses yes/no and true/false.
+func parseYesNo(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "yes", "true":
+		return true, true
+	case "no", "false":
+		return false, true
+	}
+	return false, false
+}
+
+// parseTime parses a date (midnight UTC) or an RFC 3339 time.
+func parseTime(value string) (time.Time, bool) {
+	if at, err := time.Parse("2006-01-02", value); err == nil {
+		return at, true
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	return at, err == nil
+}
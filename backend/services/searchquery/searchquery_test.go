@@ -0,0 +1,125 @@
+package searchquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  SizeFilter
+		ok    bool
+	}{
+		{value: ">=1.5mb", want: SizeFilter{Op: ">=", Bytes: 1572864}, ok: true},
+		{value: ">10MB", want: SizeFilter{Op: ">", Bytes: 10 << 20}, ok: true},
+		{value: "<= 1.5 GiB", want: SizeFilter{Op: "<=", Bytes: 3 << 29}, ok: true},
+		{value: "500KB", want: SizeFilter{Op: "=", Bytes: 500 << 10}, ok: true},
+		{value: "<2048", want: SizeFilter{Op: "<", Bytes: 2048}, ok: true},
+		{value: ">ten", ok: false},
+		{value: "10parsecs", ok: false},
+		{value: "=>10mb", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseSize(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("parseSize(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseSize(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name  string
+		input string
+		want  *Query
+	}{
+		{
+			name:  "size filter",
+			input: "size:>=1.5mb",
+			want:  &Query{Sizes: []SizeFilter{{Op: ">=", Bytes: 1572864}}},
+		},
+		{
+			name:  "text and filters",
+			input: `quarterly report type:PDF owner:alice tag:"tax 2024" shared:yes`,
+			want: &Query{
+				Text:   "quarterly report",
+				Types:  []string{"pdf"},
+				Owners: []string{"alice"},
+				Tags:   []string{"tax 2024"},
+				Shared: &yes,
+			},
+		},
+		{
+			name:  "folder",
+			input: `in:"Projects/2024" budget`,
+			want: &Query{
+				Text:   "budget",
+				Folder: &Term{Key: KeyIn, Value: "Projects/2024", Raw: `in:"Projects/2024"`, Offset: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Errors
+	}{
+		{
+			name:  "unterminated quote",
+			input: `report in:"Projects 2024`,
+			want:  Errors{{Offset: 10, Term: `in:"Projects 2024`, Message: "unterminated quote"}},
+		},
+		{
+			name:  "unterminated quote in free text",
+			input: `size:>1mb "annual report`,
+			want:  Errors{{Offset: 10, Term: `"annual report`, Message: "unterminated quote"}},
+		},
+		{
+			name:  "every invalid term",
+			input: "size:>ten colour:red shared:maybe",
+			want: Errors{
+				{Offset: 0, Term: "size:>ten", Message: `invalid size ">ten": use a size such as >10MB, <=1.5GB or 500KB`},
+				{Offset: 10, Term: "colour:red", Message: `unknown filter "colour"; the filters are type, size, owner, tag, shared, in, after, before`},
+				{Offset: 21, Term: "shared:maybe", Message: `invalid value "maybe": use shared:yes or shared:no`},
+			},
+		},
+		{
+			name:  "missing value",
+			input: "report tag:",
+			want:  Errors{{Offset: 7, Term: "tag:", Message: "tag: needs a value"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Parse(%q) returned %v, want Errors", tt.input, err)
+			}
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("Parse(%q) errors = %+v, want %+v", tt.input, errs, tt.want)
+			}
+		})
+	}
+}
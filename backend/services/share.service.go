@@ -4,41 +4,143 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/searchquery"
+	"github.com/joel2607/FileVault/services/validation"
 	"gorm.io/gorm"
 )
 
 // ShareService handles business logic related to file and folder sharing.
+// Users gaining or losing access are notified, and every change is recorded in the activity feed.
+// Admins opening other users' files and folders are recorded in the audit trail.
 type ShareService struct {
-	DB *gorm.DB
+	DB            *gorm.DB
+	Notifications *NotificationService
+	Webhooks      *WebhookService
+	Activity      *ActivityService
+	Audit         *AuditService
 }
 
 // NewShareService creates and returns a new ShareService instance.
-func NewShareService(db *gorm.DB) *ShareService {
-	return &ShareService{DB: db}
+func NewShareService(db *gorm.DB, notifications *NotificationService, webhooks *WebhookService, activity *ActivityService, audit *AuditService) *ShareService {
+	return &ShareService{DB: db, Notifications: notifications, Webhooks: webhooks, Activity: activity, Audit: audit}
 }
 
-// GetRoot retrieves the top-level files and folders for a user.
+// notifyShared tells users that a file or folder was shared with them directly, and emits a
+// share.created webhook event and records a SHARED activity for each of them.
+func (s *ShareService) notifyShared(userIDs []uint, file *models.File, folder *models.Folder, user *models.User) {
+	s.Notifications.NotifyAll(userIDs, resourceNotification(models.NotificationTypeShareReceived, user, file, folder, "%s shared %s with you"))
+	for i := range userIDs {
+		s.Webhooks.Emit(ShareEvent(EventShareCreated, file, folder, &userIDs[i], nil, user))
+		s.Activity.RecordShare(models.ActivityActionShared, file, folder, &userIDs[i], nil, user)
+	}
+}
+
+// notifyRevoked tells users that their direct access to a file or folder was removed, and
+// emits a share.revoked webhook event and records an UNSHARED activity for each of them.
+func (s *ShareService) notifyRevoked(userIDs []uint, file *models.File, folder *models.Folder, user *models.User) {
+	s.Notifications.NotifyAll(userIDs, resourceNotification(models.NotificationTypeAccessRevoked, user, file, folder, "%s removed your access to %s"))
+	for i := range userIDs {
+		s.Webhooks.Emit(ShareEvent(EventShareRevoked, file, folder, &userIDs[i], nil, user))
+		s.Activity.RecordShare(models.ActivityActionUnshared, file, folder, &userIDs[i], nil, user)
+	}
+}
+
+// notifyGroupShared tells a group's members that a file or folder was shared with the group,
+// and emits a share.created webhook event and records a SHARED activity for the group.
+func (s *ShareService) notifyGroupShared(groupID uint, file *models.File, folder *models.Folder, user *models.User) {
+	s.Notifications.NotifyAll(s.groupMemberIDs(groupID), resourceNotification(models.NotificationTypeShareReceived, user, file, folder, "%s shared %s with your group"))
+	s.Webhooks.Emit(ShareEvent(EventShareCreated, file, folder, nil, &groupID, user))
+	s.Activity.RecordShare(models.ActivityActionShared, file, folder, nil, &groupID, user)
+}
+
+// notifyGroupRevoked tells a group's members that the group's access to a file or folder was
+// removed, and emits a share.revoked webhook event and records an UNSHARED activity for the group.
+func (s *ShareService) notifyGroupRevoked(groupID uint, file *models.File, folder *models.Folder, user *models.User) {
+	s.Notifications.NotifyAll(s.groupMemberIDs(groupID), resourceNotification(models.NotificationTypeAccessRevoked, user, file, folder, "%s removed your group's access to %s"))
+	s.Webhooks.Emit(ShareEvent(EventShareRevoked, file, folder, nil, &groupID, user))
+	s.Activity.RecordShare(models.ActivityActionUnshared, file, folder, nil, &groupID, user)
+}
+
+// groupMemberIDs returns the IDs of the members of a group.
+func (s *ShareService) groupMemberIDs(groupID uint) []uint {
+	var memberIDs []uint
+	s.DB.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Pluck("user_id", &memberIDs)
+	return memberIDs
+}
+
+// parseShareExpiry parses an optional RFC 3339 expiry time for a share or public link.
+// The time must be in the future.
+func parseShareExpiry(expiresAt *string) (*time.Time, error) {
+	if expiresAt == nil || *expiresAt == "" {
+		return nil, nil
+	}
+	expiry, err := time.Parse(time.RFC3339, *expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiresAt: expected an RFC 3339 timestamp such as 2025-01-31T17:00:00Z")
+	}
+	if !expiry.After(time.Now()) {
+		return nil, fmt.Errorf("expiresAt must be in the future")
+	}
+	return &expiry, nil
+}
+
+// GetRoot returns the root of the user's file tree. Its files and folders are loaded by
+// RootFiles, RootFolders and their connection counterparts when they are asked for.
 func (s *ShareService) GetRoot(ctx context.Context, user *models.User) (*models.Root, error) {
+	return &models.Root{}, nil
+}
+
+// RootFiles retrieves all the top-level files of a user.
+func (s *ShareService) RootFiles(ctx context.Context, user *models.User) ([]*models.File, error) {
 	var files []*models.File
+	err := s.DB.Where("user_id = ? AND folder_id IS NULL", user.ID).Find(&files).Error
+	return files, err
+}
+
+// RootFolders retrieves all the top-level folders of a user.
+func (s *ShareService) RootFolders(ctx context.Context, user *models.User) ([]*models.Folder, error) {
 	var folders []*models.Folder
+	err := s.DB.Where("user_id = ? AND parent_folder_id IS NULL", user.ID).Find(&folders).Error
+	return folders, err
+}
 
-	if err := s.DB.Where("user_id = ? AND folder_id IS NULL", user.ID).Find(&files).Error; err != nil {
-		return nil, err
-	}
-	if err := s.DB.Where("user_id = ? AND parent_folder_id IS NULL", user.ID).Find(&folders).Error; err != nil {
-		return nil, err
-	}
+// RootFilesConnection returns a page of the user's top-level files.
+func (s *ShareService) RootFilesConnection(ctx context.Context, first *int32, after *string, sort *models.FileSort, user *models.User) (*models.FileConnection, error) {
+	query := s.DB.Model(&models.File{}).Where("files.user_id = ? AND files.folder_id IS NULL", user.ID)
+	return filesConnection(query, fileOrder(sort), first, after)
+}
+
+// RootFoldersConnection returns a page of the user's top-level folders.
+func (s *ShareService) RootFoldersConnection(ctx context.Context, first *int32, after *string, sort *models.FolderSort, user *models.User) (*models.FolderConnection, error) {
+	query := s.DB.Model(&models.Folder{}).Where("folders.user_id = ? AND folders.parent_folder_id IS NULL", user.ID)
+	return foldersConnection(query, folderOrder(sort), first, after)
+}
 
-	return &models.Root{Files: files, Folders: folders}, nil
+// FolderFilesConnection returns a page of the files directly in a folder the user has
+// already been allowed to read.
+func (s *ShareService) FolderFilesConnection(ctx context.Context, folder *models.Folder, first *int32, after *string, sort *models.FileSort) (*models.FileConnection, error) {
+	query := s.DB.Model(&models.File{}).Where("files.folder_id = ?", folder.ID)
+	return filesConnection(query, fileOrder(sort), first, after)
+}
+
+// FolderFoldersConnection returns a page of the subfolders directly in a folder the user has
+// already been allowed to read.
+func (s *ShareService) FolderFoldersConnection(ctx context.Context, folder *models.Folder, first *int32, after *string, sort *models.FolderSort) (*models.FolderConnection, error) {
+	query := s.DB.Model(&models.Folder{}).Where("folders.parent_folder_id = ?", folder.ID)
+	return foldersConnection(query, folderOrder(sort), first, after)
 }
 
 // GetFolder retrieves a specific folder by its ID, enforcing access control.
 // Admins can access any folder.
-// Regular users can access folders they own or public folders.
+// Regular users can access folders they own, public folders, folders shared with them,
+// and their organizations' team folders.
 func (s *ShareService) GetFolder(ctx context.Context, id string, user *models.User) (*models.Folder, error) {
 	var folder models.Folder
 	uid, err := strconv.ParseUint(id, 10, 64)
@@ -53,18 +155,16 @@ func (s *ShareService) GetFolder(ctx context.Context, id string, user *models.Us
 		return nil, err
 	}
 
-	// Admins can access any folder
-	if user.Role == models.RoleAdmin {
+	// Owners, sharees, organization members and anyone for public folders
+	if canReadFolder(s.DB, &folder, user) {
 		return &folder, nil
 	}
 
-	// Public folders are accessible to anyone
-	if folder.IsPublic {
-		return &folder, nil
-	}
-
-	// Users can access their own folders
-	if folder.UserID == user.ID {
+	// Admins can access any folder, but doing so is audited
+	if user.Role == models.RoleAdmin {
+		s.Audit.Record(ctx, user, "admin.folder_read", "folder", folder.ID, map[string]interface{}{
+			"ownerId": folder.UserID,
+		})
 		return &folder, nil
 	}
 
@@ -73,7 +173,8 @@ func (s *ShareService) GetFolder(ctx context.Context, id string, user *models.Us
 
 // GetFile retrieves a specific file by its ID, enforcing access control.
 // Admins can access any file.
-// Regular users can access files they own, public files, or files shared with them.
+// Regular users can access files they own, public files, files shared with them,
+// or files in their organizations' team folders.
 func (s *ShareService) GetFile(ctx context.Context, id string, user *models.User) (*models.File, error) {
 	var file models.File
 	uid, err := strconv.ParseUint(id, 10, 64)
@@ -88,24 +189,16 @@ func (s *ShareService) GetFile(ctx context.Context, id string, user *models.User
 		return nil, err
 	}
 
-	// Admins can access any file
-	if user.Role == models.RoleAdmin {
-		return &file, nil
-	}
-
-	// Public files are accessible to anyone
-	if file.IsPublic {
+	// Owners, sharees, organization members and anyone for public files
+	if canReadFile(s.DB, &file, user) {
 		return &file, nil
 	}
 
-	// Users can access their own files
-	if file.UserID == user.ID {
-		return &file, nil
-	}
-
-	// Check if the file is explicitly shared with the user
-	var shareRecord models.FileSharing
-	if err := s.DB.Where("file_id = ? AND shared_with_user_id = ?", file.ID, user.ID).First(&shareRecord).Error; err == nil {
+	// Admins can access any file, but doing so is audited
+	if user.Role == models.RoleAdmin {
+		s.Audit.Record(ctx, user, "admin.file_read", "file", file.ID, map[string]interface{}{
+			"ownerId": file.UserID,
+		})
 		return &file, nil
 	}
 
@@ -113,21 +206,28 @@ func (s *ShareService) GetFile(ctx context.Context, id string, user *models.User
 }
 
 // SetFilePublic makes a file public. Only the file owner can perform this action.
-func (s *ShareService) SetFilePublic(ctx context.Context, fileID string, user *models.User) (*models.File, error) {
+// If expiresAt is given, the file becomes private again at that time.
+func (s *ShareService) SetFilePublic(ctx context.Context, fileID string, expiresAt *string, user *models.User) (*models.File, error) {
 	var file models.File
 	uid, err := strconv.ParseUint(fileID, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid file ID")
 	}
+	expiry, err := parseShareExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
 		return nil, fmt.Errorf("file not found or access denied")
 	}
 
 	file.IsPublic = true
+	file.PublicExpiresAt = expiry
 	if err := s.DB.Save(&file).Error; err != nil {
 		return nil, err
 	}
+	s.Activity.RecordFile(models.ActivityActionShared, &file, user, "made public")
 
 	return &file, nil
 }
@@ -145,16 +245,19 @@ func (s *ShareService) SetFilePrivate(ctx context.Context, fileID string, user *
 	}
 
 	file.IsPublic = false
+	file.PublicExpiresAt = nil
 	if err := s.DB.Save(&file).Error; err != nil {
 		return nil, err
 	}
+	s.Activity.RecordFile(models.ActivityActionUnshared, &file, user, "made private")
 
 	return &file, nil
 }
 
-// ShareFileWithUser grants a user access to a private file.
+// ShareFileWithUser grants a user access to a private file, until expiresAt if given.
+// Sharing again with the same user updates the expiry of the existing share.
 // It returns an error if the file is public.
-func (s *ShareService) ShareFileWithUser(ctx context.Context, fileID string, shareWithUserID string, user *models.User) (*models.FileSharing, error) {
+func (s *ShareService) ShareFileWithUser(ctx context.Context, fileID string, shareWithUserID string, expiresAt *string, user *models.User) (*models.FileSharing, error) {
 	var file models.File
 	uid, err := strconv.ParseUint(fileID, 10, 64)
 	if err != nil {
@@ -174,15 +277,31 @@ func (s *ShareService) ShareFileWithUser(ctx context.Context, fileID string, sha
 		return nil, fmt.Errorf("invalid user ID to share with")
 	}
 
+	expiry, err := parseShareExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.FileSharing
+	if err := s.DB.Where("file_id = ? AND shared_with_user_id = ?", uid, shareWithUID).First(&existing).Error; err == nil {
+		existing.ExpiresAt = expiry
+		if err := s.DB.Model(&existing).Update("expires_at", expiry).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
 	share := &models.FileSharing{
 		FileID:           uint(uid),
 		SharedWithUserID: uint(shareWithUID),
 		PermissionLevel:  "read", // Or any other permission level you want to implement
+		ExpiresAt:        expiry,
 	}
 
 	if err := s.DB.Create(share).Error; err != nil {
 		return nil, err
 	}
+	s.notifyShared([]uint{share.SharedWithUserID}, &file, nil, user)
 
 	return share, nil
 }
@@ -205,15 +324,19 @@ func (s *ShareService) RemoveFileAccess(ctx context.Context, fileID string, user
 		return false, fmt.Errorf("invalid user ID to remove access from")
 	}
 
-	if err := s.DB.Where("file_id = ? AND shared_with_user_id = ?", uid, userIDToRemove_uid).Delete(&models.FileSharing{}).Error; err != nil {
-		return false, err
+	result := s.DB.Where("file_id = ? AND shared_with_user_id = ?", uid, userIDToRemove_uid).Delete(&models.FileSharing{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.notifyRevoked([]uint{uint(userIDToRemove_uid)}, &file, nil, user)
 	}
 
 	return true, nil
 }
 
 // GetUsersWithAccess returns a list of users who have been explicitly granted access to a file.
-// It excludes the file owner.
+// It excludes the file owner and shares that have expired.
 // Only the file owner can perform this action.
 func (s *ShareService) GetUsersWithAccess(ctx context.Context, fileID string, user *models.User) ([]*models.User, error) {
 	var file models.File
@@ -228,7 +351,7 @@ func (s *ShareService) GetUsersWithAccess(ctx context.Context, fileID string, us
 
 	var users []*models.User
 	var shares []models.FileSharing
-	if err := s.DB.Preload("SharedWithUser").Where("file_id = ?", uid).Find(&shares).Error; err != nil {
+	if err := s.DB.Preload("SharedWithUser").Scopes(activeShares).Where("file_id = ?", uid).Find(&shares).Error; err != nil {
 		return nil, err
 	}
 
@@ -239,25 +362,70 @@ func (s *ShareService) GetUsersWithAccess(ctx context.Context, fileID string, us
 	return users, nil
 }
 
-// SearchFiles searches for files based on a query string and a set of filters.
-// The query string is matched against file names, tags, and the uploader's username.
-// Admins can search all files, while regular users can only search their own files and files shared with them.
-func (s *ShareService) SearchFiles(ctx context.Context, query string, filter *models.FileFilterInput, user *models.User) ([]*models.File, error) {
-	var files []*models.File
-	db := s.DB.Joins("JOIN users ON users.id = files.user_id")
+// GetUsersWithAccessConnection returns a page of the users a file has been shared with,
+// like GetUsersWithAccess. Only the file owner can perform this action.
+func (s *ShareService) GetUsersWithAccessConnection(ctx context.Context, fileID string, first *int32, after *string, sort *models.UserSort, user *models.User) (*models.UserConnection, error) {
+	uid, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+
+	var file models.File
+	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+
+	sharedWith := s.DB.Model(&models.FileSharing{}).Scopes(activeShares).Select("shared_with_user_id").Where("file_id = ?", uid)
+	return usersConnection(s.DB.Model(&models.User{}).Where("users.id IN (?)", sharedWith), userOrder(sort), first, after)
+}
+
+// searchRankExpr ranks a file against a websearch_to_tsquery search, weighting words in
+// the file name above words in its content. Content whose text hasn't been extracted yet has
+// no content_texts row.
+const searchRankExpr = "ts_rank(setweight(to_tsvector('english', files.file_name), 'A') || COALESCE(content_texts.search_vector, ''::tsvector), websearch_to_tsquery('english', ?))"
+
+// Snippets are marked up with these control characters by Postgres, then HTML-escaped and
+// given <mark> tags, so that nothing in a file's text can inject markup.
+const (
+	snippetMarkStart = "\x01"
+	snippetMarkStop  = "\x02"
+)
+
+// Search results hold DefaultSearchResultLimit results unless fewer are asked for, and never
+// more than MaxSearchResultLimit.
+const (
+	DefaultSearchResultLimit = 20
+	MaxSearchResultLimit     = 100
+)
+
+// searchFilesQuery builds the query behind SearchFiles and SearchFileContents: the files the
+// user may read that match the parsed search query and the filter. The free text matches file
+// names, uploader usernames and tags by substring, and file contents by full-text search. Tags
+// in the filter must match exactly, all of them or any of them depending on filter.TagMatch.
+// Invalid filter fields are reported as validation errors.
+func (s *ShareService) searchFilesQuery(query *searchquery.Query, filter *models.FileFilterInput, user *models.User) (*gorm.DB, error) {
+	db := s.DB.Model(&models.File{}).
+		Joins("JOIN users ON users.id = files.user_id").
+		Joins("LEFT JOIN content_texts ON content_texts.deduplicated_content_id = files.deduplication_id")
 
 	if user.Role != models.RoleAdmin {
-		// Regular user: can search own files and files shared with them
-		sharedFileIDs := s.DB.Model(&models.FileSharing{}).Select("file_id").Where("shared_with_user_id = ?", user.ID)
-		db = db.Where("files.user_id = ? OR files.id IN (?)", user.ID, sharedFileIDs)
+		// Regular user: can search own files, files shared with them and team files
+		db = db.Scopes(readableFilesScope(s.DB, user))
+	}
+
+	if query.Text != "" {
+		searchQuery := "%" + query.Text + "%"
+		db = db.Where("files.file_name ILIKE ? OR users.username ILIKE ? OR files.tags::text ILIKE ? OR content_texts.search_vector @@ websearch_to_tsquery('english', ?)",
+			searchQuery, searchQuery, searchQuery, query.Text)
 	}
 
-	if query != "" {
-		searchQuery := "%" + query + "%"
-		db = db.Where("files.file_name ILIKE ? OR users.username ILIKE ? OR files.tags::text ILIKE ?", searchQuery, searchQuery, searchQuery)
+	db, err := s.applySearchFilters(db, query, user)
+	if err != nil {
+		return nil, err
 	}
 
 	if filter != nil {
+		var errs validation.Errors
 		if len(filter.MimeTypes) > 0 {
 			db = db.Where("files.mime_type IN (?)", filter.MimeTypes)
 		}
@@ -267,23 +435,185 @@ func (s *ShareService) SearchFiles(ctx context.Context, query string, filter *mo
 		if filter.MaxSize != nil {
 			db = db.Where("files.size <= ?", *filter.MaxSize)
 		}
-		if filter.StartDate != nil {
+		if filter.StartDate != nil && *filter.StartDate != "" {
 			st, err := time.Parse(time.RFC3339, *filter.StartDate)
-			if err == nil {
+			if err != nil {
+				errs.Add("filter.startDate", validation.CodeInvalidFormat, "must be an RFC 3339 time, e.g. 2024-01-02T15:04:05Z")
+			} else {
 				db = db.Where("files.created_at >= ?", st)
 			}
 		}
-		if filter.EndDate != nil {
+		if filter.EndDate != nil && *filter.EndDate != "" {
 			et, err := time.Parse(time.RFC3339, *filter.EndDate)
-			if err == nil {
+			if err != nil {
+				errs.Add("filter.endDate", validation.CodeInvalidFormat, "must be an RFC 3339 time, e.g. 2024-01-02T15:04:05Z")
+			} else {
 				db = db.Where("files.created_at <= ?", et)
 			}
 		}
+		if filter.UploaderID != nil {
+			uploaderID, err := strconv.ParseUint(*filter.UploaderID, 10, 64)
+			if err != nil {
+				errs.Add("filter.uploaderID", validation.CodeInvalidFormat, "must be a user ID")
+			} else {
+				db = db.Where("files.user_id = ?", uploaderID)
+			}
+		}
 		if filter.IsPublic != nil {
 			db = db.Where("files.is_public = ?", *filter.IsPublic)
 		}
+		if len(filter.Tags) > 0 {
+			matchAll := filter.TagMatch != nil && *filter.TagMatch == models.TagMatchAll
+			db = db.Scopes(tagsFilterScope(filter.Tags, matchAll))
+		}
+		if err := errs.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// mimeKinds are the type: values that match a whole family of MIME types.
+var mimeKinds = map[string]bool{"image": true, "video": true, "audio": true, "text": true}
+
+// sharedFileCondition matches files that are public or shared with a user or a group.
+// It takes the current time three times.
+const sharedFileCondition = "(files.is_public AND (files.public_expires_at IS NULL OR files.public_expires_at > ?)) OR " +
+	"EXISTS (SELECT 1 FROM file_sharings WHERE file_sharings.file_id = files.id AND (file_sharings.expires_at IS NULL OR file_sharings.expires_at > ?)) OR " +
+	"EXISTS (SELECT 1 FROM file_group_sharings WHERE file_group_sharings.file_id = files.id AND (file_group_sharings.expires_at IS NULL OR file_group_sharings.expires_at > ?))"
+
+// applySearchFilters adds the key:value filters of a parsed search query to a query on the
+// files table joined with users. A folder that doesn't exist is reported as a query error.
+func (s *ShareService) applySearchFilters(db *gorm.DB, query *searchquery.Query, user *models.User) (*gorm.DB, error) {
+	if len(query.Types) > 0 {
+		var conditions []string
+		var args []interface{}
+		for _, fileType := range query.Types {
+			switch {
+			case strings.HasSuffix(fileType, "/*"):
+				conditions = append(conditions, "files.mime_type ILIKE ?")
+				args = append(args, strings.TrimSuffix(fileType, "*")+"%")
+			case strings.Contains(fileType, "/"):
+				conditions = append(conditions, "files.mime_type ILIKE ?")
+				args = append(args, fileType)
+			case mimeKinds[fileType]:
+				conditions = append(conditions, "files.mime_type ILIKE ?")
+				args = append(args, fileType+"/%")
+			default:
+				// An extension, such as pdf, matches either the MIME subtype or the file name.
+				conditions = append(conditions, "files.mime_type ILIKE ? OR files.file_name ILIKE ?")
+				args = append(args, "%/"+fileType, "%."+fileType)
+			}
+		}
+		db = db.Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	for _, size := range query.Sizes {
+		// Op is one of the comparison operators the parser accepts.
+		db = db.Where("files.size "+size.Op+" ?", size.Bytes)
+	}
+
+	if len(query.Owners) > 0 {
+		var conditions []string
+		var args []interface{}
+		for _, owner := range query.Owners {
+			if strings.EqualFold(owner, "me") {
+				conditions = append(conditions, "files.user_id = ?")
+				args = append(args, user.ID)
+			} else {
+				conditions = append(conditions, "LOWER(users.username) = LOWER(?)")
+				args = append(args, owner)
+			}
+		}
+		db = db.Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	if len(query.Tags) > 0 {
+		db = db.Scopes(tagsFilterScope(query.Tags, true))
+	}
+
+	if query.Shared != nil {
+		now := time.Now()
+		if *query.Shared {
+			db = db.Where(sharedFileCondition, now, now, now)
+		} else {
+			db = db.Where("NOT ("+sharedFileCondition+")", now, now, now)
+		}
+	}
+
+	if query.Folder != nil {
+		folder, err := folderByPath(s.DB, user.ID, query.Folder.Value)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, searchquery.Errors{query.Folder.Err("you have no folder %q", query.Folder.Value)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		folderIDs, err := folderSubtreeIDs(s.DB, folder.ID)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("files.folder_id IN ?", folderIDs)
+	}
+
+	if query.After != nil {
+		db = db.Where("files.created_at >= ?", *query.After)
+	}
+	if query.Before != nil {
+		db = db.Where("files.created_at < ?", *query.Before)
+	}
+	return db, nil
+}
+
+// folderByPath finds the user's folder at a path of folder names from their root, such as
+// "Projects/2024". It returns gorm.ErrRecordNotFound if there is no such folder.
+func folderByPath(db *gorm.DB, userID uint, path string) (*models.Folder, error) {
+	var folder *models.Folder
+	for _, name := range strings.Split(path, "/") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		query := db.Where("user_id = ? AND folder_name = ?", userID, name)
+		if folder == nil {
+			query = query.Where("parent_folder_id IS NULL")
+		} else {
+			query = query.Where("parent_folder_id = ?", folder.ID)
+		}
+		var next models.Folder
+		if err := query.Order("id").First(&next).Error; err != nil {
+			return nil, err
+		}
+		folder = &next
+	}
+	if folder == nil {
+		return nil, gorm.ErrRecordNotFound
 	}
+	return folder, nil
+}
 
+// SearchFiles searches for files based on a query string and a set of filters.
+// The query string is parsed with the searchquery package: its key:value filters, such as
+// type:pdf or owner:alice, narrow the results, and its free text is matched against file names,
+// tags, the uploader's username and, through the full-text index, the files' contents; the best
+// matches come first. Invalid queries are reported as searchquery.Errors.
+// Admins can search all files, while regular users can only search their own files, files shared
+// with them and files in their organizations' team folders.
+func (s *ShareService) SearchFiles(ctx context.Context, query string, filter *models.FileFilterInput, user *models.User) ([]*models.File, error) {
+	parsed, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	db, err := s.searchFilesQuery(parsed, filter, user)
+	if err != nil {
+		return nil, err
+	}
+	db = db.Select("files.*")
+	if parsed.Text != "" {
+		db = db.Order(gorm.Expr(searchRankExpr+" DESC, files.id", parsed.Text))
+	}
+
+	var files []*models.File
 	if err := db.Find(&files).Error; err != nil {
 		return nil, err
 	}
@@ -291,22 +621,117 @@ func (s *ShareService) SearchFiles(ctx context.Context, query string, filter *mo
 	return files, nil
 }
 
+// SearchFilesConnection runs a search like SearchFiles and returns a page of the results.
+// Without a sort, results are ordered by relevance when the query has free text, and by name
+// otherwise.
+func (s *ShareService) SearchFilesConnection(ctx context.Context, query string, filter *models.FileFilterInput, first *int32, after *string, sort *models.FileSort, user *models.User) (*models.FileConnection, error) {
+	parsed, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	db, err := s.searchFilesQuery(parsed, filter, user)
+	if err != nil {
+		return nil, err
+	}
+	order := fileOrder(sort)
+	if sort == nil && parsed.Text != "" {
+		order = relevanceOrder(parsed.Text)
+	}
+	return filesConnection(db, order, first, after)
+}
+
+// SearchFileContents runs a ranked search like SearchFiles, returning at most limit results
+// with their rank and, for files whose content matched, a highlighted excerpt.
+func (s *ShareService) SearchFileContents(ctx context.Context, query string, filter *models.FileFilterInput, limit *int32, user *models.User) ([]*models.FileSearchResult, error) {
+	parsed, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Text == "" {
+		return nil, fmt.Errorf("a search query with text to match is required")
+	}
+	pageSize := DefaultSearchResultLimit
+	if limit != nil && *limit > 0 {
+		pageSize = int(*limit)
+		if pageSize > MaxSearchResultLimit {
+			pageSize = MaxSearchResultLimit
+		}
+	}
+
+	var hits []struct {
+		ID      uint
+		Rank    float64
+		Snippet string
+	}
+	headlineOptions := "StartSel=" + snippetMarkStart + ", StopSel=" + snippetMarkStop + ", MaxWords=35, MinWords=15, MaxFragments=2"
+	db, err := s.searchFilesQuery(parsed, filter, user)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Select("files.id, "+searchRankExpr+" AS rank, ts_headline('english', COALESCE(content_texts.extracted_text, ''), websearch_to_tsquery('english', ?), ?) AS snippet",
+		parsed.Text, parsed.Text, headlineOptions).
+		Order("rank DESC, files.id").Limit(pageSize).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return []*models.FileSearchResult{}, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var files []*models.File
+	if err := s.DB.Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.File, len(files))
+	for _, file := range files {
+		byID[file.ID] = file
+	}
+
+	results := make([]*models.FileSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		file, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		result := &models.FileSearchResult{File: file, Rank: hit.Rank}
+		if strings.Contains(hit.Snippet, snippetMarkStart) {
+			snippet := html.EscapeString(hit.Snippet)
+			snippet = strings.ReplaceAll(snippet, snippetMarkStart, "<mark>")
+			snippet = strings.ReplaceAll(snippet, snippetMarkStop, "</mark>")
+			result.Snippet = &snippet
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
 // SetFolderPublic makes a folder public. Only the folder owner can perform this action.
-func (s *ShareService) SetFolderPublic(ctx context.Context, folderID string, user *models.User) (*models.Folder, error) {
+// If expiresAt is given, the folder becomes private again at that time.
+func (s *ShareService) SetFolderPublic(ctx context.Context, folderID string, expiresAt *string, user *models.User) (*models.Folder, error) {
 	var folder models.Folder
 	uid, err := strconv.ParseUint(folderID, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid folder ID")
 	}
+	expiry, err := parseShareExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
 		return nil, fmt.Errorf("folder not found or access denied")
 	}
 
 	folder.IsPublic = true
+	folder.PublicExpiresAt = expiry
 	if err := s.DB.Save(&folder).Error; err != nil {
 		return nil, err
 	}
+	s.Activity.RecordFolder(models.ActivityActionShared, &folder, user, "made public")
 
 	return &folder, nil
 }
@@ -324,16 +749,19 @@ func (s *ShareService) SetFolderPrivate(ctx context.Context, folderID string, us
 	}
 
 	folder.IsPublic = false
+	folder.PublicExpiresAt = nil
 	if err := s.DB.Save(&folder).Error; err != nil {
 		return nil, err
 	}
+	s.Activity.RecordFolder(models.ActivityActionUnshared, &folder, user, "made private")
 
 	return &folder, nil
 }
 
-// ShareFolderWithUser grants a user access to a private folder.
+// ShareFolderWithUser grants a user access to a private folder, until expiresAt if given.
+// Sharing again with the same user updates the expiry of the existing share.
 // It returns an error if the folder is public.
-func (s *ShareService) ShareFolderWithUser(ctx context.Context, folderID string, shareWithUserID string, user *models.User) (*models.FolderSharing, error) {
+func (s *ShareService) ShareFolderWithUser(ctx context.Context, folderID string, shareWithUserID string, expiresAt *string, user *models.User) (*models.FolderSharing, error) {
 	var folder models.Folder
 	uid, err := strconv.ParseUint(folderID, 10, 64)
 	if err != nil {
@@ -353,15 +781,31 @@ func (s *ShareService) ShareFolderWithUser(ctx context.Context, folderID string,
 		return nil, fmt.Errorf("invalid user ID to share with")
 	}
 
+	expiry, err := parseShareExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.FolderSharing
+	if err := s.DB.Where("folder_id = ? AND shared_with_user_id = ?", uid, shareWithUID).First(&existing).Error; err == nil {
+		existing.ExpiresAt = expiry
+		if err := s.DB.Model(&existing).Update("expires_at", expiry).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
 	share := &models.FolderSharing{
 		FolderID:         uint(uid),
 		SharedWithUserID: uint(shareWithUID),
 		PermissionLevel:  "read", // Or any other permission level you want to implement
+		ExpiresAt:        expiry,
 	}
 
 	if err := s.DB.Create(share).Error; err != nil {
 		return nil, err
 	}
+	s.notifyShared([]uint{share.SharedWithUserID}, nil, &folder, user)
 
 	return share, nil
 }
@@ -384,9 +828,497 @@ func (s *ShareService) RemoveFolderAccess(ctx context.Context, folderID string,
 		return false, fmt.Errorf("invalid user ID to remove access from")
 	}
 
-	if err := s.DB.Where("folder_id = ? AND shared_with_user_id = ?", uid, userIDToRemove_uid).Delete(&models.FolderSharing{}).Error; err != nil {
-		return false, err
+	result := s.DB.Where("folder_id = ? AND shared_with_user_id = ?", uid, userIDToRemove_uid).Delete(&models.FolderSharing{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.notifyRevoked([]uint{uint(userIDToRemove_uid)}, nil, &folder, user)
 	}
 
 	return true, nil
-}
\ No newline at end of file
+}
+
+// teamMemberIDs returns the IDs of the members of an organization other than the sharing user,
+// who must be a member themselves.
+func (s *ShareService) teamMemberIDs(organizationID string, user *models.User) ([]uint, error) {
+	orgID, err := parseOrganizationID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if membershipOf(s.DB, orgID, user.ID) == nil {
+		return nil, fmt.Errorf("organization not found or access denied")
+	}
+
+	var memberIDs []uint
+	err = s.DB.Model(&models.Membership{}).Where("organization_id = ? AND user_id <> ?", orgID, user.ID).Pluck("user_id", &memberIDs).Error
+	return memberIDs, err
+}
+
+// ShareFileWithOrganization shares a private file with every member of an organization
+// the user belongs to, in one operation. Members who already have access through a share
+// that hasn't expired are skipped, and expired shares are renewed without an expiry.
+// It returns the shares created or renewed.
+func (s *ShareService) ShareFileWithOrganization(ctx context.Context, fileID string, organizationID string, user *models.User) ([]*models.FileSharing, error) {
+	var file models.File
+	uid, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+
+	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+
+	if file.IsPublic {
+		return nil, fmt.Errorf("cannot share a public file")
+	}
+
+	memberIDs, err := s.teamMemberIDs(organizationID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []*models.FileSharing
+	if err := s.DB.Where("file_id = ?", file.ID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	existingByUser := make(map[uint]*models.FileSharing, len(existing))
+	for _, share := range existing {
+		existingByUser[share.SharedWithUserID] = share
+	}
+
+	// A member whose share has expired gets it back without an expiry, rather than a second row.
+	shares := []*models.FileSharing{}
+	var created []*models.FileSharing
+	var renewedIDs, sharedWith []uint
+	for _, memberID := range memberIDs {
+		if share, ok := existingByUser[memberID]; ok {
+			if share.ExpiresAt == nil || share.ExpiresAt.After(time.Now()) {
+				continue
+			}
+			share.ExpiresAt = nil
+			shares = append(shares, share)
+			renewedIDs = append(renewedIDs, share.ID)
+		} else {
+			share := &models.FileSharing{
+				FileID:           file.ID,
+				SharedWithUserID: memberID,
+				PermissionLevel:  "read",
+			}
+			shares = append(shares, share)
+			created = append(created, share)
+		}
+		sharedWith = append(sharedWith, memberID)
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if len(renewedIDs) > 0 {
+			if err := tx.Model(&models.FileSharing{}).Where("id IN ?", renewedIDs).Update("expires_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		if len(created) > 0 {
+			return tx.Create(&created).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyShared(sharedWith, &file, nil, user)
+	return shares, nil
+}
+
+// ShareFolderWithOrganization shares a private folder with every member of an organization
+// the user belongs to, in one operation. Members who already have access through a share
+// that hasn't expired are skipped, and expired shares are renewed without an expiry.
+// It returns the shares created or renewed.
+func (s *ShareService) ShareFolderWithOrganization(ctx context.Context, folderID string, organizationID string, user *models.User) ([]*models.FolderSharing, error) {
+	var folder models.Folder
+	uid, err := strconv.ParseUint(folderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder ID")
+	}
+
+	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("folder not found or access denied")
+	}
+
+	if folder.IsPublic {
+		return nil, fmt.Errorf("cannot share a public folder")
+	}
+
+	memberIDs, err := s.teamMemberIDs(organizationID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []*models.FolderSharing
+	if err := s.DB.Where("folder_id = ?", folder.ID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	existingByUser := make(map[uint]*models.FolderSharing, len(existing))
+	for _, share := range existing {
+		existingByUser[share.SharedWithUserID] = share
+	}
+
+	// A member whose share has expired gets it back without an expiry, rather than a second row.
+	shares := []*models.FolderSharing{}
+	var created []*models.FolderSharing
+	var renewedIDs, sharedWith []uint
+	for _, memberID := range memberIDs {
+		if share, ok := existingByUser[memberID]; ok {
+			if share.ExpiresAt == nil || share.ExpiresAt.After(time.Now()) {
+				continue
+			}
+			share.ExpiresAt = nil
+			shares = append(shares, share)
+			renewedIDs = append(renewedIDs, share.ID)
+		} else {
+			share := &models.FolderSharing{
+				FolderID:         folder.ID,
+				SharedWithUserID: memberID,
+				PermissionLevel:  "read",
+			}
+			shares = append(shares, share)
+			created = append(created, share)
+		}
+		sharedWith = append(sharedWith, memberID)
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if len(renewedIDs) > 0 {
+			if err := tx.Model(&models.FolderSharing{}).Where("id IN ?", renewedIDs).Update("expires_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		if len(created) > 0 {
+			return tx.Create(&created).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyShared(sharedWith, nil, &folder, user)
+	return shares, nil
+}
+
+// ShareFileWithGroup grants every member of a group access to a private file, until
+// expiresAt if it is given. The user must own the file and be able to use the group.
+// Access follows group membership, so members added to the group later gain access
+// automatically. Sharing again with the same group updates the expiry.
+func (s *ShareService) ShareFileWithGroup(ctx context.Context, fileID string, groupID string, expiresAt *string, user *models.User) (*models.FileGroupSharing, error) {
+	var file models.File
+	uid, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+
+	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+
+	if file.IsPublic {
+		return nil, fmt.Errorf("cannot share a public file")
+	}
+
+	group, err := findGroup(s.DB, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !canUseGroup(s.DB, group, user) {
+		return nil, fmt.Errorf("group not found or access denied")
+	}
+
+	expiry, err := parseShareExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.FileGroupSharing
+	if err := s.DB.Where("file_id = ? AND group_id = ?", file.ID, group.ID).First(&existing).Error; err == nil {
+		existing.ExpiresAt = expiry
+		if err := s.DB.Model(&existing).Update("expires_at", expiry).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	share := &models.FileGroupSharing{
+		FileID:          file.ID,
+		GroupID:         group.ID,
+		PermissionLevel: "read",
+		ExpiresAt:       expiry,
+	}
+	if err := s.DB.Create(share).Error; err != nil {
+		return nil, err
+	}
+	s.notifyGroupShared(group.ID, &file, nil, user)
+	return share, nil
+}
+
+// RemoveFileGroupAccess removes a group's access to a shared file.
+// Only the file owner can perform this action.
+func (s *ShareService) RemoveFileGroupAccess(ctx context.Context, fileID string, groupID string, user *models.User) (bool, error) {
+	var file models.File
+	uid, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid file ID")
+	}
+
+	if err := s.DB.First(&file, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return false, fmt.Errorf("file not found or access denied")
+	}
+
+	gid, err := strconv.ParseUint(groupID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid group ID")
+	}
+
+	result := s.DB.Where("file_id = ? AND group_id = ?", file.ID, gid).Delete(&models.FileGroupSharing{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.notifyGroupRevoked(uint(gid), &file, nil, user)
+	}
+	return true, nil
+}
+
+// ShareFolderWithGroup grants every member of a group access to a private folder, until
+// expiresAt if it is given. The user must own the folder and be able to use the group.
+// Access follows group membership, so members added to the group later gain access
+// automatically. Sharing again with the same group updates the expiry.
+func (s *ShareService) ShareFolderWithGroup(ctx context.Context, folderID string, groupID string, expiresAt *string, user *models.User) (*models.FolderGroupSharing, error) {
+	var folder models.Folder
+	uid, err := strconv.ParseUint(folderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder ID")
+	}
+
+	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("folder not found or access denied")
+	}
+
+	if folder.IsPublic {
+		return nil, fmt.Errorf("cannot share a public folder")
+	}
+
+	group, err := findGroup(s.DB, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !canUseGroup(s.DB, group, user) {
+		return nil, fmt.Errorf("group not found or access denied")
+	}
+
+	expiry, err := parseShareExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.FolderGroupSharing
+	if err := s.DB.Where("folder_id = ? AND group_id = ?", folder.ID, group.ID).First(&existing).Error; err == nil {
+		existing.ExpiresAt = expiry
+		if err := s.DB.Model(&existing).Update("expires_at", expiry).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	share := &models.FolderGroupSharing{
+		FolderID:        folder.ID,
+		GroupID:         group.ID,
+		PermissionLevel: "read",
+		ExpiresAt:       expiry,
+	}
+	if err := s.DB.Create(share).Error; err != nil {
+		return nil, err
+	}
+	s.notifyGroupShared(group.ID, nil, &folder, user)
+	return share, nil
+}
+
+// RemoveFolderGroupAccess removes a group's access to a shared folder.
+// Only the folder owner can perform this action.
+func (s *ShareService) RemoveFolderGroupAccess(ctx context.Context, folderID string, groupID string, user *models.User) (bool, error) {
+	var folder models.Folder
+	uid, err := strconv.ParseUint(folderID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid folder ID")
+	}
+
+	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return false, fmt.Errorf("folder not found or access denied")
+	}
+
+	gid, err := strconv.ParseUint(groupID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid group ID")
+	}
+
+	result := s.DB.Where("folder_id = ? AND group_id = ?", folder.ID, gid).Delete(&models.FolderGroupSharing{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.notifyGroupRevoked(uint(gid), nil, &folder, user)
+	}
+	return true, nil
+}
+
+// SharedWithMe returns the files and folders other users have shared with the user,
+// either directly or through one of the user's groups, grouped by the user who shared them.
+// Sharers are ordered by username.
+func (s *ShareService) SharedWithMe(ctx context.Context, user *models.User) ([]*models.IncomingShares, error) {
+	var files []*models.File
+	if err := s.DB.Where("user_id <> ? AND (id IN (?) OR id IN (?))", user.ID, sharedFileIDsFor(s.DB, user.ID), groupSharedFileIDsFor(s.DB, user.ID)).
+		Order("file_name").Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	var folders []*models.Folder
+	if err := s.DB.Where("user_id <> ? AND (id IN (?) OR id IN (?))", user.ID, sharedFolderIDsFor(s.DB, user.ID), groupSharedFolderIDsFor(s.DB, user.ID)).
+		Order("folder_name").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	bySharer := make(map[uint]*models.IncomingShares)
+	var sharerIDs []uint
+	entryFor := func(sharerID uint) *models.IncomingShares {
+		entry, ok := bySharer[sharerID]
+		if !ok {
+			entry = &models.IncomingShares{Files: []*models.File{}, Folders: []*models.Folder{}}
+			bySharer[sharerID] = entry
+			sharerIDs = append(sharerIDs, sharerID)
+		}
+		return entry
+	}
+	for _, file := range files {
+		entry := entryFor(file.UserID)
+		entry.Files = append(entry.Files, file)
+	}
+	for _, folder := range folders {
+		entry := entryFor(folder.UserID)
+		entry.Folders = append(entry.Folders, folder)
+	}
+
+	result := []*models.IncomingShares{}
+	if len(sharerIDs) == 0 {
+		return result, nil
+	}
+
+	var sharers []*models.User
+	if err := s.DB.Where("id IN ?", sharerIDs).Order("username").Find(&sharers).Error; err != nil {
+		return nil, err
+	}
+	for _, sharer := range sharers {
+		entry := bySharer[sharer.ID]
+		entry.Sharer = sharer
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// OutgoingShares lists everything the user has shared with other users and groups,
+// newest first, with the recipient and permission level of each share. Expired shares
+// are left out.
+func (s *ShareService) OutgoingShares(ctx context.Context, user *models.User) ([]*models.OutgoingShare, error) {
+	ownFileIDs := s.DB.Model(&models.File{}).Select("id").Where("user_id = ?", user.ID)
+	ownFolderIDs := s.DB.Model(&models.Folder{}).Select("id").Where("user_id = ?", user.ID)
+
+	var fileShares []models.FileSharing
+	if err := s.DB.Preload("File").Preload("SharedWithUser").Scopes(activeShares).Where("file_id IN (?)", ownFileIDs).Find(&fileShares).Error; err != nil {
+		return nil, err
+	}
+	var fileGroupShares []models.FileGroupSharing
+	if err := s.DB.Preload("File").Preload("Group").Scopes(activeShares).Where("file_id IN (?)", ownFileIDs).Find(&fileGroupShares).Error; err != nil {
+		return nil, err
+	}
+	var folderShares []models.FolderSharing
+	if err := s.DB.Preload("Folder").Preload("SharedWithUser").Scopes(activeShares).Where("folder_id IN (?)", ownFolderIDs).Find(&folderShares).Error; err != nil {
+		return nil, err
+	}
+	var folderGroupShares []models.FolderGroupSharing
+	if err := s.DB.Preload("Folder").Preload("Group").Scopes(activeShares).Where("folder_id IN (?)", ownFolderIDs).Find(&folderGroupShares).Error; err != nil {
+		return nil, err
+	}
+
+	type datedShare struct {
+		createdAt time.Time
+		share     *models.OutgoingShare
+	}
+	var shares []datedShare
+	for i := range fileShares {
+		share := &fileShares[i]
+		shares = append(shares, datedShare{share.CreatedAt, &models.OutgoingShare{
+			CreatedAt:       share.CreatedAt.String(),
+			File:            &share.File,
+			SharedWithUser:  &share.SharedWithUser,
+			PermissionLevel: share.PermissionLevel,
+		}})
+	}
+	for i := range fileGroupShares {
+		share := &fileGroupShares[i]
+		shares = append(shares, datedShare{share.CreatedAt, &models.OutgoingShare{
+			CreatedAt:       share.CreatedAt.String(),
+			File:            &share.File,
+			SharedWithGroup: &share.Group,
+			PermissionLevel: share.PermissionLevel,
+		}})
+	}
+	for i := range folderShares {
+		share := &folderShares[i]
+		shares = append(shares, datedShare{share.CreatedAt, &models.OutgoingShare{
+			CreatedAt:       share.CreatedAt.String(),
+			Folder:          &share.Folder,
+			SharedWithUser:  &share.SharedWithUser,
+			PermissionLevel: share.PermissionLevel,
+		}})
+	}
+	for i := range folderGroupShares {
+		share := &folderGroupShares[i]
+		shares = append(shares, datedShare{share.CreatedAt, &models.OutgoingShare{
+			CreatedAt:       share.CreatedAt.String(),
+			Folder:          &share.Folder,
+			SharedWithGroup: &share.Group,
+			PermissionLevel: share.PermissionLevel,
+		}})
+	}
+
+	sort.SliceStable(shares, func(i, j int) bool {
+		return shares[i].createdAt.After(shares[j].createdAt)
+	})
+	result := make([]*models.OutgoingShare, 0, len(shares))
+	for _, share := range shares {
+		result = append(result, share.share)
+	}
+	return result, nil
+}
+
+// GetUsersWithFolderAccess returns a list of users who have been explicitly granted access to a folder.
+// It excludes the folder owner and shares that have expired.
+// Only the folder owner can perform this action.
+func (s *ShareService) GetUsersWithFolderAccess(ctx context.Context, folderID string, user *models.User) ([]*models.User, error) {
+	var folder models.Folder
+	uid, err := strconv.ParseUint(folderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder ID")
+	}
+
+	if err := s.DB.First(&folder, "id = ? AND user_id = ?", uid, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("folder not found or access denied")
+	}
+
+	var users []*models.User
+	var shares []models.FolderSharing
+	if err := s.DB.Preload("SharedWithUser").Scopes(activeShares).Where("folder_id = ?", uid).Find(&shares).Error; err != nil {
+		return nil, err
+	}
+
+	for _, share := range shares {
+		users = append(users, &share.SharedWithUser)
+	}
+
+	return users, nil
+}
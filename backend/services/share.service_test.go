@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joel2607/FileVault/database/testdb"
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// openShareTestDB opens an empty database with the tables sharing works on.
+func openShareTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testdb.Open(t, &models.User{}, &models.Organization{}, &models.Membership{}, &models.DeduplicatedContent{},
+		&models.Folder{}, &models.File{}, &models.FileSharing{}, &models.FolderSharing{}, &models.Group{},
+		&models.GroupMember{}, &models.FileGroupSharing{}, &models.FolderGroupSharing{})
+}
+
+// createTestGroup creates a group owned by owner with the given members.
+func createTestGroup(t *testing.T, db *gorm.DB, owner *models.User, name string, members ...*models.User) *models.Group {
+	t.Helper()
+	group := &models.Group{Name: name, OwnerUserID: &owner.ID}
+	if err := db.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range members {
+		if err := db.Create(&models.GroupMember{GroupID: group.ID, UserID: member.ID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return group
+}
+
+func TestSharedWithMe(t *testing.T) {
+	db := openShareTestDB(t)
+	s := &ShareService{DB: db}
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	team := createTestGroup(t, db, alice, "Team", bob)
+	ownGroup := createTestGroup(t, db, bob, "Mine", bob)
+
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	notes := createTestFile(t, db, carol, "notes.txt", nil)
+	createTestFile(t, db, carol, "private.txt", nil)
+	own := createTestFile(t, db, bob, "own.txt", nil)
+	create(t, db,
+		&models.FileSharing{FileID: report.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"},
+		&models.FolderGroupSharing{FolderID: projects.ID, GroupID: team.ID, PermissionLevel: "read"},
+		&models.FileSharing{FileID: notes.ID, SharedWithUserID: bob.ID, PermissionLevel: "write"},
+		&models.FileGroupSharing{FileID: own.ID, GroupID: ownGroup.ID, PermissionLevel: "read"},
+	)
+
+	entries, err := s.SharedWithMe(context.Background(), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d sharers, want alice and carol", len(entries))
+	}
+	if entries[0].Sharer.ID != alice.ID || len(entries[0].Files) != 1 || entries[0].Files[0].ID != report.ID ||
+		len(entries[0].Folders) != 1 || entries[0].Folders[0].ID != projects.ID {
+		t.Errorf("alice's shares: got %+v", entries[0])
+	}
+	if entries[1].Sharer.ID != carol.ID || len(entries[1].Files) != 1 || entries[1].Files[0].ID != notes.ID ||
+		len(entries[1].Folders) != 0 {
+		t.Errorf("carol's shares: got %+v", entries[1])
+	}
+
+	entries, err = s.SharedWithMe(context.Background(), carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("carol got %d sharers, want none", len(entries))
+	}
+}
+
+func TestOutgoingShares(t *testing.T) {
+	db := openShareTestDB(t)
+	s := &ShareService{DB: db}
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	team := createTestGroup(t, db, alice, "Team", bob)
+
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	bobsFile := createTestFile(t, db, bob, "bob.txt", nil)
+	create(t, db,
+		&models.FileSharing{FileID: report.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"},
+		&models.FileGroupSharing{FileID: report.ID, GroupID: team.ID, PermissionLevel: "read"},
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: bob.ID, PermissionLevel: "write"},
+		&models.FolderGroupSharing{FolderID: projects.ID, GroupID: team.ID, PermissionLevel: "read"},
+		&models.FileSharing{FileID: bobsFile.ID, SharedWithUserID: alice.ID, PermissionLevel: "read"},
+	)
+
+	shares, err := s.OutgoingShares(context.Background(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 4 {
+		t.Fatalf("got %d shares, want alice's 4", len(shares))
+	}
+	// Newest first: the folder's group share was made last.
+	first := shares[0]
+	if first.Folder == nil || first.Folder.ID != projects.ID || first.SharedWithGroup == nil || first.SharedWithGroup.ID != team.ID {
+		t.Errorf("first share: got %+v, want the folder's group share", first)
+	}
+	for _, share := range shares {
+		if (share.File == nil) == (share.Folder == nil) || (share.SharedWithUser == nil) == (share.SharedWithGroup == nil) {
+			t.Errorf("share %+v should have one item and one recipient", share)
+		}
+		if share.File != nil && share.File.ID == bobsFile.ID {
+			t.Error("bob's share with alice was listed as alice's")
+		}
+	}
+}
+
+func TestGetUsersWithFolderAccess(t *testing.T) {
+	db := openShareTestDB(t)
+	s := &ShareService{DB: db}
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	create(t, db,
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"},
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: carol.ID, PermissionLevel: "write"},
+	)
+
+	users, err := s.GetUsersWithFolderAccess(context.Background(), *apiID(projects.ID), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Errorf("got %d users, want bob and carol", len(users))
+	}
+	if _, err := s.GetUsersWithFolderAccess(context.Background(), *apiID(projects.ID), bob); err == nil {
+		t.Error("a user the folder is shared with could list who else it is shared with")
+	}
+}
+
+func TestListingsIgnoreExpiredShares(t *testing.T) {
+	db := openShareTestDB(t)
+	s := &ShareService{DB: db}
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+
+	past := time.Now().Add(-time.Minute)
+	create(t, db,
+		&models.FileSharing{FileID: report.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past},
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past},
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: carol.ID, PermissionLevel: "read"},
+	)
+
+	users, err := s.GetUsersWithFolderAccess(context.Background(), *apiID(projects.ID), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != carol.ID {
+		t.Errorf("got %d users with access, want only carol", len(users))
+	}
+	shares, err := s.OutgoingShares(context.Background(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 1 || shares[0].SharedWithUser == nil || shares[0].SharedWithUser.ID != carol.ID {
+		t.Errorf("got %d outgoing shares, want only the one with carol", len(shares))
+	}
+	entries, err := s.SharedWithMe(context.Background(), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("bob still sees %d sharers", len(entries))
+	}
+}
+
+func TestFolderShareCoversContents(t *testing.T) {
+	db := openShareTestDB(t)
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	team := createTestGroup(t, db, alice, "Team", carol)
+
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	drafts := createTestFolder(t, db, alice, "Drafts", projects)
+	plan := createTestFile(t, db, alice, "plan.pdf", drafts)
+	private := createTestFolder(t, db, alice, "Private", nil)
+	secret := createTestFile(t, db, alice, "secret.pdf", private)
+	create(t, db,
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: bob.ID, PermissionLevel: "read"},
+		&models.FolderGroupSharing{FolderID: drafts.ID, GroupID: team.ID, PermissionLevel: "read"},
+	)
+
+	for _, user := range []*models.User{bob, carol} {
+		if !canReadFolder(db, drafts, user) {
+			t.Errorf("%s can't read a folder inside a folder shared with them", user.Username)
+		}
+		if !canReadFile(db, plan, user) {
+			t.Errorf("%s can't read a file inside a folder shared with them", user.Username)
+		}
+		if canReadFile(db, secret, user) {
+			t.Errorf("%s can read a file in a folder nobody shared", user.Username)
+		}
+
+		var ids []uint
+		if err := db.Model(&models.File{}).Scopes(readableFilesScope(db, user)).Pluck("files.id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 1 || ids[0] != plan.ID {
+			t.Errorf("%s's readable files: got %v, want only plan.pdf", user.Username, ids)
+		}
+	}
+	if canReadFolder(db, projects, carol) {
+		t.Error("sharing a subfolder with carol's group let her read the folder above it")
+	}
+}
+
+func TestShareWithOrganizationRenewsExpiredShares(t *testing.T) {
+	ctx := context.Background()
+	db := openShareTestDB(t)
+	if err := db.AutoMigrate(&models.Notification{}, &models.NotificationPreference{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.Activity{}); err != nil {
+		t.Fatal(err)
+	}
+	s := &ShareService{DB: db, Notifications: &NotificationService{DB: db, RDB: newTestRedis(t)}, Webhooks: &WebhookService{DB: db}, Activity: &ActivityService{DB: db}}
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	dave := createTestUser(t, db, "dave")
+	acme := &models.Organization{Name: "Acme"}
+	create(t, db, acme)
+	for _, member := range []*models.User{alice, bob, carol, dave} {
+		create(t, db, &models.Membership{OrganizationID: acme.ID, UserID: member.ID, Role: models.OrgRoleMember})
+	}
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	create(t, db,
+		&models.FileSharing{FileID: report.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past},
+		&models.FileSharing{FileID: report.ID, SharedWithUserID: carol.ID, PermissionLevel: "read", ExpiresAt: &future},
+		&models.FolderSharing{FolderID: projects.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past},
+	)
+
+	// Bob's expired share is renewed, carol's active one is left alone and dave gets a new one.
+	fileShares, err := s.ShareFileWithOrganization(ctx, *apiID(report.ID), *apiID(acme.ID), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileShares) != 2 {
+		t.Errorf("got %d file shares, want bob's and dave's", len(fileShares))
+	}
+	folderShares, err := s.ShareFolderWithOrganization(ctx, *apiID(projects.ID), *apiID(acme.ID), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folderShares) != 3 {
+		t.Errorf("got %d folder shares, want bob's, carol's and dave's", len(folderShares))
+	}
+
+	for _, member := range []*models.User{bob, carol, dave} {
+		var fileCount, folderCount int64
+		db.Model(&models.FileSharing{}).Where("file_id = ? AND shared_with_user_id = ?", report.ID, member.ID).Count(&fileCount)
+		db.Model(&models.FolderSharing{}).Where("folder_id = ? AND shared_with_user_id = ?", projects.ID, member.ID).Count(&folderCount)
+		if fileCount != 1 || folderCount != 1 {
+			t.Errorf("%s has %d file shares and %d folder shares, want one of each", member.Username, fileCount, folderCount)
+		}
+		if !canReadFile(db, report, member) || !canReadFolder(db, projects, member) {
+			t.Errorf("%s can't read what was shared with the organization", member.Username)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/mailer"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShareExpiryService periodically removes shares and public visibility that have
+// expired, tells the owners what was revoked and tells the recipients they lost access.
+// Access checks already ignore expired grants, so the sweeper only has to clean up and notify.
+type ShareExpiryService struct {
+	DB            *gorm.DB
+	Mailer        mailer.Mailer
+	Notifications *NotificationService
+	Interval      time.Duration
+}
+
+// NewShareExpiryService creates a new instance of ShareExpiryService, reading the
+// sweep interval from shares.expiry_sweep_interval_seconds.
+func NewShareExpiryService(db *gorm.DB, m mailer.Mailer, notifications *NotificationService) *ShareExpiryService {
+	viper.SetDefault("shares.expiry_sweep_interval_seconds", 60)
+	return &ShareExpiryService{
+		DB:            db,
+		Mailer:        m,
+		Notifications: notifications,
+		Interval:      time.Duration(viper.GetInt("shares.expiry_sweep_interval_seconds")) * time.Second,
+	}
+}
+
+// Run sweeps expired grants every Interval until the context is cancelled.
+// It is meant to be started in its own goroutine.
+func (s *ShareExpiryService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx); err != nil {
+			log.Printf("Share expiry sweep failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep deletes expired file and folder shares with users and groups, makes files and folders whose public
+// visibility has expired private again, emails each affected owner a summary and notifies
+// each user who lost access. It returns the number of grants removed.
+func (s *ShareExpiryService) Sweep(ctx context.Context) (int, error) {
+	now := time.Now()
+	expired := "expires_at IS NOT NULL AND expires_at <= ?"
+	publicExpired := "is_public = ? AND public_expires_at IS NOT NULL AND public_expires_at <= ?"
+
+	var fileShares []models.FileSharing
+	if err := s.DB.Preload("File").Preload("SharedWithUser").Where(expired, now).Find(&fileShares).Error; err != nil {
+		return 0, err
+	}
+	var folderShares []models.FolderSharing
+	if err := s.DB.Preload("Folder").Preload("SharedWithUser").Where(expired, now).Find(&folderShares).Error; err != nil {
+		return 0, err
+	}
+	var fileGroupShares []models.FileGroupSharing
+	if err := s.DB.Preload("File").Preload("Group").Where(expired, now).Find(&fileGroupShares).Error; err != nil {
+		return 0, err
+	}
+	var folderGroupShares []models.FolderGroupSharing
+	if err := s.DB.Preload("Folder").Preload("Group").Where(expired, now).Find(&folderGroupShares).Error; err != nil {
+		return 0, err
+	}
+	var files []models.File
+	if err := s.DB.Where(publicExpired, true, now).Find(&files).Error; err != nil {
+		return 0, err
+	}
+	var folders []models.Folder
+	if err := s.DB.Where(publicExpired, true, now).Find(&folders).Error; err != nil {
+		return 0, err
+	}
+
+	var fileShareIDs, folderShareIDs, fileGroupShareIDs, folderGroupShareIDs, fileIDs, folderIDs []uint
+	for _, share := range fileShares {
+		fileShareIDs = append(fileShareIDs, share.ID)
+	}
+	for _, share := range folderShares {
+		folderShareIDs = append(folderShareIDs, share.ID)
+	}
+	for _, share := range fileGroupShares {
+		fileGroupShareIDs = append(fileGroupShareIDs, share.ID)
+	}
+	for _, share := range folderGroupShares {
+		folderGroupShareIDs = append(folderGroupShareIDs, share.ID)
+	}
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	for _, folder := range folders {
+		folderIDs = append(folderIDs, folder.ID)
+	}
+	if len(fileShareIDs)+len(folderShareIDs)+len(fileGroupShareIDs)+len(folderGroupShareIDs)+len(fileIDs)+len(folderIDs) == 0 {
+		return 0, nil
+	}
+
+	// Grants are only removed if they are still expired, as an owner may have renewed one
+	// since it was loaded; only those that were removed are reported.
+	var sweptFileShares, sweptFolderShares, sweptFileGroupShares, sweptFolderGroupShares, sweptFiles, sweptFolders map[uint]bool
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if sweptFileShares, err = sweepStillExpired(tx.Where("id IN ? AND "+expired, fileShareIDs, now), nil,
+			func(share *models.FileSharing) uint { return share.ID }); err != nil {
+			return err
+		}
+		if sweptFolderShares, err = sweepStillExpired(tx.Where("id IN ? AND "+expired, folderShareIDs, now), nil,
+			func(share *models.FolderSharing) uint { return share.ID }); err != nil {
+			return err
+		}
+		if sweptFileGroupShares, err = sweepStillExpired(tx.Where("id IN ? AND "+expired, fileGroupShareIDs, now), nil,
+			func(share *models.FileGroupSharing) uint { return share.ID }); err != nil {
+			return err
+		}
+		if sweptFolderGroupShares, err = sweepStillExpired(tx.Where("id IN ? AND "+expired, folderGroupShareIDs, now), nil,
+			func(share *models.FolderGroupSharing) uint { return share.ID }); err != nil {
+			return err
+		}
+		private := map[string]interface{}{"is_public": false, "public_expires_at": nil}
+		if sweptFiles, err = sweepStillExpired(tx.Where("id IN ?", fileIDs).Where(publicExpired, true, now), private,
+			func(file *models.File) uint { return file.ID }); err != nil {
+			return err
+		}
+		sweptFolders, err = sweepStillExpired(tx.Where("id IN ?", folderIDs).Where(publicExpired, true, now), private,
+			func(folder *models.Folder) uint { return folder.ID })
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := make(map[uint][]string) // owner ID -> descriptions of what was revoked
+	for i, share := range fileShares {
+		if !sweptFileShares[share.ID] {
+			continue
+		}
+		revoked[share.File.UserID] = append(revoked[share.File.UserID],
+			fmt.Sprintf("%s's access to the file %s", share.SharedWithUser.Username, share.File.FileName))
+		s.notifyExpired([]uint{share.SharedWithUserID}, &fileShares[i].File, nil, "Your access to %[2]s has expired")
+	}
+	for i, share := range folderShares {
+		if !sweptFolderShares[share.ID] {
+			continue
+		}
+		revoked[share.Folder.UserID] = append(revoked[share.Folder.UserID],
+			fmt.Sprintf("%s's access to the folder %s", share.SharedWithUser.Username, share.Folder.FolderName))
+		s.notifyExpired([]uint{share.SharedWithUserID}, nil, &folderShares[i].Folder, "Your access to %[2]s has expired")
+	}
+	for i, share := range fileGroupShares {
+		if !sweptFileGroupShares[share.ID] {
+			continue
+		}
+		revoked[share.File.UserID] = append(revoked[share.File.UserID],
+			fmt.Sprintf("the group %s's access to the file %s", share.Group.Name, share.File.FileName))
+		s.notifyExpired(s.groupMemberIDs(share.GroupID), &fileGroupShares[i].File, nil, "Your group's access to %[2]s has expired")
+	}
+	for i, share := range folderGroupShares {
+		if !sweptFolderGroupShares[share.ID] {
+			continue
+		}
+		revoked[share.Folder.UserID] = append(revoked[share.Folder.UserID],
+			fmt.Sprintf("the group %s's access to the folder %s", share.Group.Name, share.Folder.FolderName))
+		s.notifyExpired(s.groupMemberIDs(share.GroupID), nil, &folderGroupShares[i].Folder, "Your group's access to %[2]s has expired")
+	}
+	for _, file := range files {
+		if sweptFiles[file.ID] {
+			revoked[file.UserID] = append(revoked[file.UserID], fmt.Sprintf("public access to the file %s", file.FileName))
+		}
+	}
+	for _, folder := range folders {
+		if sweptFolders[folder.ID] {
+			revoked[folder.UserID] = append(revoked[folder.UserID], fmt.Sprintf("public access to the folder %s", folder.FolderName))
+		}
+	}
+
+	total := len(sweptFileShares) + len(sweptFolderShares) + len(sweptFileGroupShares) + len(sweptFolderGroupShares) + len(sweptFiles) + len(sweptFolders)
+	if total == 0 {
+		return 0, nil
+	}
+	log.Printf("Share expiry sweep removed %d expired grants", total)
+
+	for ownerID, items := range revoked {
+		s.notifyOwner(ctx, ownerID, items)
+	}
+	return total, nil
+}
+
+// sweepStillExpired deletes the rows of T that query matches, or sets private on them if it
+// isn't nil, and returns the IDs of the rows it changed.
+func sweepStillExpired[T any](query *gorm.DB, private map[string]interface{}, idOf func(*T) uint) (map[uint]bool, error) {
+	var rows []T
+	query = query.Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}})
+	var err error
+	if private == nil {
+		err = query.Delete(&rows).Error
+	} else {
+		err = query.Model(&rows).Updates(private).Error
+	}
+	swept := make(map[uint]bool, len(rows))
+	for i := range rows {
+		swept[idOf(&rows[i])] = true
+	}
+	return swept, err
+}
+
+// notifyExpired tells users in-app that their access to a file or folder has expired. format
+// is given the item's description as its second argument.
+func (s *ShareExpiryService) notifyExpired(userIDs []uint, file *models.File, folder *models.Folder, format string) {
+	s.Notifications.NotifyAll(userIDs, resourceNotification(models.NotificationTypeAccessRevoked, nil, file, folder, format))
+}
+
+// groupMemberIDs returns the IDs of the members of a group.
+func (s *ShareExpiryService) groupMemberIDs(groupID uint) []uint {
+	var memberIDs []uint
+	s.DB.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Pluck("user_id", &memberIDs)
+	return memberIDs
+}
+
+// notifyOwner emails an owner the list of grants that expired on their files and folders.
+func (s *ShareExpiryService) notifyOwner(ctx context.Context, ownerID uint, items []string) {
+	var owner models.User
+	if err := s.DB.First(&owner, ownerID).Error; err != nil {
+		return
+	}
+
+	body := "The following access to your FileVault items has expired and was removed:\n\n- " +
+		strings.Join(items, "\n- ") + "\n\nShare again if access is still needed.\n"
+	if err := s.Mailer.Send(ctx, mailer.Message{
+		To:      owner.Email,
+		Subject: "Access to your shared items has expired",
+		Body:    body,
+	}); err != nil {
+		log.Printf("Failed to notify user %d about expired shares: %v", ownerID, err)
+	}
+}
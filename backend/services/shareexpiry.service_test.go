@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/mailer"
+	"gorm.io/gorm"
+)
+
+// recordingMailer keeps the messages it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// newTestShareExpiryService returns a sweeper over db that records the emails it sends and
+// stores its notifications in db.
+func newTestShareExpiryService(t *testing.T, db *gorm.DB) (*ShareExpiryService, *recordingMailer) {
+	t.Helper()
+	if err := db.AutoMigrate(&models.Notification{}, &models.NotificationPreference{}); err != nil {
+		t.Fatal(err)
+	}
+	mail := &recordingMailer{}
+	return &ShareExpiryService{DB: db, Mailer: mail, Notifications: &NotificationService{DB: db, RDB: newTestRedis(t)}}, mail
+}
+
+// accessRevokedMessages returns the messages of the access revoked notifications a user got.
+func accessRevokedMessages(t *testing.T, db *gorm.DB, user *models.User) []string {
+	t.Helper()
+	var messages []string
+	if err := db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", user.ID, string(models.NotificationTypeAccessRevoked)).
+		Order("id").Pluck("message", &messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestShareExpirySweep(t *testing.T) {
+	db := openShareTestDB(t)
+	s, mail := newTestShareExpiryService(t, db)
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	flyer := createTestFile(t, db, alice, "flyer.pdf", nil)
+	db.Model(flyer).Updates(map[string]interface{}{"is_public": true, "public_expires_at": past})
+	poster := createTestFile(t, db, alice, "poster.pdf", nil)
+	db.Model(poster).Updates(map[string]interface{}{"is_public": true, "public_expires_at": future})
+
+	expiredShare := &models.FileSharing{FileID: report.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past}
+	activeShare := &models.FileSharing{FileID: report.ID, SharedWithUserID: carol.ID, PermissionLevel: "read", ExpiresAt: &future}
+	permanentShare := &models.FolderSharing{FolderID: projects.ID, SharedWithUserID: carol.ID, PermissionLevel: "read"}
+	expiredFolderShare := &models.FolderSharing{FolderID: projects.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past}
+	create(t, db, expiredShare, activeShare, permanentShare, expiredFolderShare)
+
+	removed, err := s.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d grants, want 3", removed)
+	}
+
+	var count int64
+	db.Model(&models.FileSharing{}).Where("id = ?", expiredShare.ID).Count(&count)
+	if count != 0 {
+		t.Error("the expired file share was kept")
+	}
+	db.Model(&models.FolderSharing{}).Where("id = ?", expiredFolderShare.ID).Count(&count)
+	if count != 0 {
+		t.Error("the expired folder share was kept")
+	}
+	db.Model(&models.FileSharing{}).Where("id = ?", activeShare.ID).Count(&count)
+	if count != 1 {
+		t.Error("the share that hasn't expired was removed")
+	}
+	db.Model(&models.FolderSharing{}).Where("id = ?", permanentShare.ID).Count(&count)
+	if count != 1 {
+		t.Error("the share without an expiry was removed")
+	}
+	if reloadFile(t, db, flyer).IsPublic {
+		t.Error("the file whose public visibility expired is still public")
+	}
+	if !reloadFile(t, db, poster).IsPublic {
+		t.Error("the file that is public for another hour was made private")
+	}
+
+	// The owner gets one email listing everything that was revoked.
+	if len(mail.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(mail.sent))
+	}
+	msg := mail.sent[0]
+	if msg.To != alice.Email {
+		t.Errorf("emailed %s, want the owner", msg.To)
+	}
+	for _, item := range []string{"bob's access to the file report.pdf", "bob's access to the folder Projects", "public access to the file flyer.pdf"} {
+		if !strings.Contains(msg.Body, item) {
+			t.Errorf("the email does not mention %q:\n%s", item, msg.Body)
+		}
+	}
+
+	// Bob is told in-app about the lost access; carol, whose shares remain, isn't.
+	want := []string{"Your access to the file report.pdf has expired", "Your access to the folder Projects has expired"}
+	if got := accessRevokedMessages(t, db, bob); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("bob was notified %q, want %q", got, want)
+	}
+	if got := accessRevokedMessages(t, db, carol); len(got) != 0 {
+		t.Errorf("carol was notified %q, want nothing", got)
+	}
+
+	// Nothing is left to sweep.
+	if removed, err := s.Sweep(context.Background()); err != nil || removed != 0 {
+		t.Errorf("second sweep removed %d grants (err %v), want 0", removed, err)
+	}
+}
+
+func TestShareExpirySweepGroupShares(t *testing.T) {
+	db := openShareTestDB(t)
+	s, mail := newTestShareExpiryService(t, db)
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	team := createTestGroup(t, db, alice, "Team", bob)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	projects := createTestFolder(t, db, alice, "Projects", nil)
+	plan := createTestFile(t, db, alice, "plan.pdf", projects)
+	notes := createTestFile(t, db, alice, "notes.txt", nil)
+	expiredFileShare := &models.FileGroupSharing{FileID: report.ID, GroupID: team.ID, PermissionLevel: "read", ExpiresAt: &past}
+	expiredFolderShare := &models.FolderGroupSharing{FolderID: projects.ID, GroupID: team.ID, PermissionLevel: "read", ExpiresAt: &past}
+	activeShare := &models.FileGroupSharing{FileID: notes.ID, GroupID: team.ID, PermissionLevel: "read", ExpiresAt: &future}
+	create(t, db, expiredFileShare, expiredFolderShare, activeShare)
+
+	// Expired group shares grant nothing, even before they are swept.
+	if canReadFile(db, report, bob) || canReadFolder(db, projects, bob) || canReadFile(db, plan, bob) {
+		t.Error("an expired group share still grants access")
+	}
+	if !canReadFile(db, notes, bob) {
+		t.Error("the group share that hasn't expired grants no access")
+	}
+
+	removed, err := s.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d grants, want 2", removed)
+	}
+	var count int64
+	db.Model(&models.FileGroupSharing{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d file group shares left, want only the active one", count)
+	}
+	db.Model(&models.FolderGroupSharing{}).Count(&count)
+	if count != 0 {
+		t.Error("the expired folder group share was kept")
+	}
+
+	if len(mail.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(mail.sent))
+	}
+	for _, item := range []string{"the group Team's access to the file report.pdf", "the group Team's access to the folder Projects"} {
+		if !strings.Contains(mail.sent[0].Body, item) {
+			t.Errorf("the email does not mention %q:\n%s", item, mail.sent[0].Body)
+		}
+	}
+
+	want := []string{"Your group's access to the file report.pdf has expired", "Your group's access to the folder Projects has expired"}
+	if got := accessRevokedMessages(t, db, bob); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("bob was notified %q, want %q", got, want)
+	}
+}
+
+func TestShareExpirySweepKeepsRenewedShares(t *testing.T) {
+	db := openShareTestDB(t)
+	s, mail := newTestShareExpiryService(t, db)
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	report := createTestFile(t, db, alice, "report.pdf", nil)
+	past := time.Now().Add(-time.Minute)
+	share := &models.FileSharing{FileID: report.ID, SharedWithUserID: bob.ID, PermissionLevel: "read", ExpiresAt: &past}
+	create(t, db, share)
+
+	// Alice renews the share right after the sweep has loaded it as expired.
+	renewed := false
+	err := db.Callback().Query().After("gorm:query").Register("test:renew_share", func(tx *gorm.DB) {
+		if renewed || tx.Statement.Table != "file_sharings" {
+			return
+		}
+		renewed = true
+		tx.Session(&gorm.Session{NewDB: true}).Model(&models.FileSharing{}).Where("id = ?", share.ID).
+			Update("expires_at", time.Now().Add(time.Hour))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := s.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed {
+		t.Fatal("the share was never renewed")
+	}
+	if removed != 0 {
+		t.Errorf("removed %d grants, want 0", removed)
+	}
+	var count int64
+	db.Model(&models.FileSharing{}).Where("id = ?", share.ID).Count(&count)
+	if count != 1 {
+		t.Error("the renewed share was deleted")
+	}
+	if len(mail.sent) != 0 || len(accessRevokedMessages(t, db, bob)) != 0 {
+		t.Error("the renewed share was reported as expired")
+	}
+}
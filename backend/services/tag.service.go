@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joel2607/FileVault/models"
+	"gorm.io/gorm"
+)
+
+// Tags are stored trimmed and lowercase, and limited in length and in number per file.
+const (
+	maxTagLength   = 50
+	maxTagsPerFile = 20
+)
+
+// normalizeTag trims and lowercases a tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes a list of tags, dropping empty ones and duplicates, and checks
+// the length of each.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// decodeTags parses the JSON tag list of a file. Malformed lists are treated as empty.
+func decodeTags(raw string) []string {
+	var tags []string
+	if err := json.Unmarshal([]byte(raw), &tags); err != nil || tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// encodeTags returns the JSON form of a tag list, as stored in files.tags.
+func encodeTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	encoded, _ := json.Marshal(tags)
+	return string(encoded)
+}
+
+// TagService manages the tags of files. Only a file's owner can change its tags.
+type TagService struct {
+	DB *gorm.DB
+}
+
+// NewTagService creates a new instance of TagService.
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{DB: db}
+}
+
+// FileTags returns the tags of a file.
+func (s *TagService) FileTags(file *models.File) []string {
+	return decodeTags(file.Tags)
+}
+
+// ownedFile loads a file the user owns.
+func (s *TagService) ownedFile(fileID string, user *models.User) (*models.File, error) {
+	id, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID")
+	}
+	var file models.File
+	if err := s.DB.First(&file, "id = ? AND user_id = ?", id, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("file not found or access denied")
+	}
+	return &file, nil
+}
+
+// saveTags replaces the tags of a file.
+func (s *TagService) saveTags(file *models.File, tags []string) (*models.File, error) {
+	if len(tags) > maxTagsPerFile {
+		return nil, fmt.Errorf("a file can have at most %d tags", maxTagsPerFile)
+	}
+	file.Tags = encodeTags(tags)
+	if err := s.DB.Model(file).Update("tags", file.Tags).Error; err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// AddTags adds tags to a file, keeping the tags it already has.
+func (s *TagService) AddTags(ctx context.Context, fileID string, tags []string, user *models.User) (*models.File, error) {
+	added, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.ownedFile(fileID, user)
+	if err != nil {
+		return nil, err
+	}
+	merged, _ := normalizeTags(append(decodeTags(file.Tags), added...))
+	return s.saveTags(file, merged)
+}
+
+// RemoveTags removes tags from a file. Tags the file doesn't have are ignored.
+func (s *TagService) RemoveTags(ctx context.Context, fileID string, tags []string, user *models.User) (*models.File, error) {
+	file, err := s.ownedFile(fileID, user)
+	if err != nil {
+		return nil, err
+	}
+	removed := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		removed[normalizeTag(tag)] = true
+	}
+	kept := []string{}
+	for _, tag := range decodeTags(file.Tags) {
+		if !removed[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	return s.saveTags(file, kept)
+}
+
+// SetTags replaces all the tags of a file.
+func (s *TagService) SetTags(ctx context.Context, fileID string, tags []string, user *models.User) (*models.File, error) {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.ownedFile(fileID, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveTags(file, normalized)
+}
+
+// ListTags returns the tags used on the user's files with the number of files using each,
+// most used first.
+func (s *TagService) ListTags(ctx context.Context, user *models.User) ([]*models.TagCount, error) {
+	var rows []struct {
+		Tag   string
+		Count int32
+	}
+	err := s.DB.Raw(`SELECT tag, COUNT(*) AS count
+		FROM files, jsonb_array_elements_text(files.tags) AS tag
+		WHERE files.user_id = ?
+		GROUP BY tag
+		ORDER BY count DESC, tag`, user.ID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]*models.TagCount, len(rows))
+	for i, row := range rows {
+		counts[i] = &models.TagCount{Tag: row.Tag, Count: row.Count}
+	}
+	return counts, nil
+}
+
+// RenameTag renames a tag on all of the user's files. If a file already has the new tag,
+// the two are merged. It returns the number of files changed.
+func (s *TagService) RenameTag(ctx context.Context, from string, to string, user *models.User) (int32, error) {
+	from = normalizeTag(from)
+	renamed, err := normalizeTags([]string{to})
+	if err != nil {
+		return 0, err
+	}
+	if from == "" || len(renamed) == 0 {
+		return 0, fmt.Errorf("both the old and the new tag are required")
+	}
+	to = renamed[0]
+	if from == to {
+		return 0, nil
+	}
+
+	var changed int32
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var files []models.File
+		if err := tx.Where("user_id = ? AND tags @> ?::jsonb", user.ID, encodeTags([]string{from})).Find(&files).Error; err != nil {
+			return err
+		}
+		for _, file := range files {
+			tags := decodeTags(file.Tags)
+			for i, tag := range tags {
+				if tag == from {
+					tags[i] = to
+				}
+			}
+			merged, _ := normalizeTags(tags)
+			if err := tx.Model(&file).Update("tags", encodeTags(merged)).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// tagsFilterScope restricts a query on the files table to files with all of the tags, or
+// with any of them if matchAll is false. Each tag is matched with jsonb containment, which
+// the GIN index on files.tags serves.
+func tagsFilterScope(tags []string, matchAll bool) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		normalized := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if tag = normalizeTag(tag); tag != "" {
+				normalized = append(normalized, tag)
+			}
+		}
+		if len(normalized) == 0 {
+			return query
+		}
+		if matchAll {
+			return query.Where("files.tags @> ?::jsonb", encodeTags(normalized))
+		}
+		conditions := make([]string, len(normalized))
+		args := make([]interface{}, len(normalized))
+		for i, tag := range normalized {
+			conditions[i] = "files.tags @> ?::jsonb"
+			args[i] = encodeTags([]string{tag})
+		}
+		return query.Where(strings.Join(conditions, " OR "), args...)
+	}
+}
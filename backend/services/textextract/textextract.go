@@ -0,0 +1,201 @@
+// Package textextract pulls the searchable text out of uploaded files: plain text,
+// Markdown, source code and other text formats, PDF and DOCX. Everything is done in
+// pure Go, so no external tools need to be installed.
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ErrUnsupported is returned for files whose format text cannot be extracted from.
+var ErrUnsupported = errors.New("text cannot be extracted from this type of file")
+
+// textExtensions are the extensions of text formats that aren't always uploaded with a
+// text/* MIME type, such as source code.
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".rst": true, ".csv": true, ".tsv": true, ".log": true,
+	".json": true, ".xml": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".conf": true,
+	".html": true, ".htm": true, ".css": true, ".sql": true, ".sh": true, ".bash": true, ".ps1": true,
+	".go": true, ".py": true, ".rb": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".java": true,
+	".kt": true, ".scala": true, ".c": true, ".h": true, ".cpp": true, ".hpp": true, ".cc": true, ".cs": true,
+	".rs": true, ".swift": true, ".php": true, ".pl": true, ".lua": true, ".r": true, ".graphql": true,
+}
+
+// textMIMETypes are MIME types outside text/* that hold text.
+var textMIMETypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-yaml":     true,
+	"application/x-sh":       true,
+	"application/sql":        true,
+	"application/graphql":    true,
+}
+
+const (
+	pdfMIMEType  = "application/pdf"
+	docxMIMEType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// Extract returns the text of the file at path, whose MIME type and original name were
+// given on upload, cut to at most maxBytes if maxBytes is positive. The text is valid UTF-8
+// without NUL characters. It returns ErrUnsupported for formats text can't be extracted from.
+func Extract(path string, mimeType string, fileName string, maxBytes int) (text string, err error) {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	var raw string
+	switch {
+	case mimeType == pdfMIMEType || ext == ".pdf":
+		raw, err = extractPDF(path, maxBytes)
+	case mimeType == docxMIMEType || ext == ".docx":
+		raw, err = extractDOCX(path, maxBytes)
+	case strings.HasPrefix(mimeType, "text/") || textMIMETypes[mimeType] || textExtensions[ext]:
+		raw, err = extractText(path, maxBytes)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return clean(raw, maxBytes), nil
+}
+
+// limitReader wraps r so that at most maxBytes are read, if maxBytes is positive.
+func limitReader(r io.Reader, maxBytes int) io.Reader {
+	if maxBytes <= 0 {
+		return r
+	}
+	return io.LimitReader(r, int64(maxBytes))
+}
+
+// extractText reads a text file. Files that turn out to be binary are unsupported.
+func extractText(path string, maxBytes int) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(limitReader(file, maxBytes))
+	if err != nil {
+		return "", err
+	}
+	sniff := data
+	if len(sniff) > 8192 {
+		sniff = sniff[:8192]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return "", ErrUnsupported
+	}
+	return string(data), nil
+}
+
+// extractPDF reads the text of every page of a PDF. The PDF reader panics on some
+// malformed files, which is reported as an error.
+func extractPDF(path string, maxBytes int) (text string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("malformed PDF: %v", recovered)
+		}
+	}()
+
+	file, reader, err := pdf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(limitReader(content, maxBytes))
+	return string(data), err
+}
+
+// extractDOCX reads the text of a Word document's body, one paragraph per line.
+func extractDOCX(path string, maxBytes int) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		if entry.Name != "word/document.xml" {
+			continue
+		}
+		document, err := entry.Open()
+		if err != nil {
+			return "", err
+		}
+		defer document.Close()
+		return docxText(document, maxBytes)
+	}
+	return "", fmt.Errorf("not a Word document: word/document.xml is missing")
+}
+
+// docxText collects the text runs (w:t) of a WordprocessingML document, ending each
+// paragraph (w:p) with a newline and turning tabs (w:tab) into tab characters.
+func docxText(document io.Reader, maxBytes int) (string, error) {
+	var out strings.Builder
+	decoder := xml.NewDecoder(document)
+	inText := false
+	for maxBytes <= 0 || out.Len() < maxBytes {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				out.WriteByte('\t')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				out.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				out.Write(t)
+			}
+		}
+	}
+	return out.String(), nil
+}
+
+// clean makes extracted text safe to store in Postgres: valid UTF-8, without NUL
+// characters, and no longer than maxBytes without cutting a character in half.
+func clean(text string, maxBytes int) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	if maxBytes > 0 && len(text) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	return strings.TrimSpace(text)
+}
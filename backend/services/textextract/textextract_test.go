@@ -0,0 +1,115 @@
+package textextract
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes data to a file in the test's temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeDOCX writes a Word document whose body is the given WordprocessingML.
+func writeDOCX(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "document.docx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	entry, err := archive.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + body + `</w:body></w:document>`))
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		mimeType string
+		data     string
+		maxBytes int
+		want     string
+	}{
+		{name: "plain text", fileName: "notes.txt", mimeType: "text/plain; charset=utf-8", data: "  quarterly numbers\n", want: "quarterly numbers"},
+		{name: "source by extension", fileName: "main.go", mimeType: "application/octet-stream", data: "package main", want: "package main"},
+		{name: "JSON by MIME type", fileName: "data", mimeType: "application/json", data: `{"a": 1}`, want: `{"a": 1}`},
+		{name: "invalid UTF-8 dropped", fileName: "notes.txt", mimeType: "text/plain", data: "caf\xc3\xa9 \xff\xfeok", want: "café ok"},
+		{name: "cut at a character boundary", fileName: "notes.txt", mimeType: "text/plain", data: "naïve", maxBytes: 3, want: "na"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.fileName, []byte(tt.data))
+			got, err := Extract(path, tt.mimeType, tt.fileName, tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		mimeType string
+		data     []byte
+	}{
+		{name: "image", fileName: "photo.png", mimeType: "image/png", data: []byte("\x89PNG\r\n")},
+		{name: "binary passed off as text", fileName: "notes.txt", mimeType: "text/plain", data: []byte("MZ\x00\x00\x03")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.fileName, tt.data)
+			if _, err := Extract(path, tt.mimeType, tt.fileName, 0); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("got %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+func TestExtractDOCX(t *testing.T) {
+	path := writeDOCX(t, `<w:p><w:r><w:t>Project</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve"> plan</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>Budget &amp; schedule</w:t></w:r></w:p>`)
+	got, err := Extract(path, docxMIMEType, "plan.docx", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Project\t plan\nBudget & schedule"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A ZIP file that isn't a Word document is an error.
+	notDOCX := filepath.Join(t.TempDir(), "archive.docx")
+	file, _ := os.Create(notDOCX)
+	archive := zip.NewWriter(file)
+	archive.Create("readme.txt")
+	archive.Close()
+	file.Close()
+	if _, err := Extract(notDOCX, docxMIMEType, "archive.docx", 0); err == nil {
+		t.Error("extracting a ZIP without word/document.xml succeeded")
+	}
+}
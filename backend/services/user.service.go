@@ -0,0 +1,472 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"github.com/joel2607/FileVault/services/validation"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// UserService provides methods for users to manage their own accounts:
+// updating their profile, exporting their data and deleting their account.
+type UserService struct {
+	DB          *gorm.DB
+	AuthService *AuthService
+	FileService *FileService
+	// DeletionRetryInterval is how often Run retries account deletions that failed part way.
+	DeletionRetryInterval time.Duration
+}
+
+// NewUserService creates a new instance of UserService, reading how often failed account
+// deletions are retried from accounts.deletion_retry_interval_seconds.
+func NewUserService(db *gorm.DB, authService *AuthService, fileService *FileService) *UserService {
+	viper.SetDefault("accounts.deletion_retry_interval_seconds", 300)
+	return &UserService{
+		DB:                    db,
+		AuthService:           authService,
+		FileService:           fileService,
+		DeletionRetryInterval: time.Duration(viper.GetInt("accounts.deletion_retry_interval_seconds")) * time.Second,
+	}
+}
+
+// UpdateProfile changes a user's username, email and/or password.
+// Changing the email or the password requires the current password.
+//
+// Inputs:
+// - ctx: The context for the request.
+// - input: The fields to change; nil fields are left untouched.
+// - user: The user whose profile is being updated.
+//
+// Outputs:
+// - A pointer to the updated models.User object.
+// - A validation.Errors listing every invalid field, or an error if the database update fails.
+func (s *UserService) UpdateProfile(ctx context.Context, input models.UpdateProfileInput, user *models.User) (*models.User, error) {
+	var errs validation.Errors
+	updates := map[string]interface{}{}
+
+	username := user.Username
+	if input.Username != nil {
+		username = strings.TrimSpace(*input.Username)
+		validation.Username(&errs, "input.username", username)
+		if len(errs) == 0 && username != user.Username {
+			s.AuthService.checkUsernameAvailable(&errs, "input.username", username, user.ID)
+		}
+		updates["username"] = username
+	}
+
+	email := user.Email
+	if input.Email != nil {
+		email = validation.NormalizeEmail(*input.Email)
+		validation.Email(&errs, "input.email", email)
+		if len(errs) == 0 && email != user.Email {
+			s.AuthService.checkEmailAvailable(&errs, "input.email", email, user.ID)
+		}
+		updates["email"] = email
+	}
+
+	if input.NewPassword != nil {
+		s.AuthService.PasswordPolicy.Password(&errs, "input.newPassword", *input.NewPassword, username, email)
+	}
+
+	if (input.Email != nil && email != user.Email) || input.NewPassword != nil {
+		if input.CurrentPassword == nil || *input.CurrentPassword == "" {
+			errs.Add("input.currentPassword", validation.CodeRequired, "current password is required to change your email or password")
+		} else if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(*input.CurrentPassword)) != nil {
+			errs.Add("input.currentPassword", validation.CodeIncorrect, "current password is incorrect")
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	if input.NewPassword != nil {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*input.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		updates["password_hash"] = string(hashedPassword)
+	}
+
+	if len(updates) == 0 {
+		return user, nil
+	}
+	if err := s.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, uniqueViolationToFieldError(err, "input")
+	}
+
+	return user, nil
+}
+
+// VerifyPassword checks a password against the user's stored hash.
+// It is used to confirm destructive actions such as deleting an account.
+func (s *UserService) VerifyPassword(user *models.User, password string) error {
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return fmt.Errorf("password is incorrect")
+	}
+	return nil
+}
+
+// AccountExport is the document produced by ExportData.
+type AccountExport struct {
+	ExportedAt     time.Time        `json:"exportedAt"`
+	Profile        exportedProfile  `json:"profile"`
+	Folders        []exportedFolder `json:"folders"`
+	Files          []exportedFile   `json:"files"`
+	SharesGiven    []exportedShare  `json:"sharesGiven"`
+	SharesReceived []exportedShare  `json:"sharesReceived"`
+}
+
+type exportedProfile struct {
+	ID             uint      `json:"id"`
+	Username       string    `json:"username"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"createdAt"`
+	StorageQuotaKB float64   `json:"storageQuotaKB"`
+	UsedStorageKB  float64   `json:"usedStorageKB"`
+	SavedStorageKB float64   `json:"savedStorageKB"`
+}
+
+type exportedFolder struct {
+	ID             uint      `json:"id"`
+	Name           string    `json:"name"`
+	ParentFolderID *uint     `json:"parentFolderId"`
+	IsPublic       bool      `json:"isPublic"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type exportedFile struct {
+	ID            uint      `json:"id"`
+	Name          string    `json:"name"`
+	MIMEType      string    `json:"mimeType"`
+	Size          int64     `json:"size"`
+	SHA256Hash    string    `json:"sha256"`
+	FolderID      *uint     `json:"folderId"`
+	IsPublic      bool      `json:"isPublic"`
+	DownloadCount int       `json:"downloadCount"`
+	Tags          string    `json:"tags"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type exportedShare struct {
+	ResourceType    string    `json:"resourceType"`
+	ResourceID      uint      `json:"resourceId"`
+	UserID          uint      `json:"userId"`
+	PermissionLevel string    `json:"permissionLevel"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// ExportData gathers everything stored about a user into a JSON document:
+// their profile, folder tree, file metadata and the shares they gave and received.
+// File contents are not included; they can be downloaded individually.
+func (s *UserService) ExportData(ctx context.Context, user *models.User) (string, error) {
+	export := AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: exportedProfile{
+			ID:             user.ID,
+			Username:       user.Username,
+			Email:          user.Email,
+			Role:           string(user.Role),
+			CreatedAt:      user.CreatedAt,
+			StorageQuotaKB: user.StorageQuotaKB,
+			UsedStorageKB:  user.UsedStorageKB,
+			SavedStorageKB: user.SavedStorageKB,
+		},
+		Folders:        []exportedFolder{},
+		Files:          []exportedFile{},
+		SharesGiven:    []exportedShare{},
+		SharesReceived: []exportedShare{},
+	}
+
+	var folders []models.Folder
+	if err := s.DB.Where("user_id = ?", user.ID).Order("id").Find(&folders).Error; err != nil {
+		return "", err
+	}
+	for _, folder := range folders {
+		export.Folders = append(export.Folders, exportedFolder{
+			ID:             folder.ID,
+			Name:           folder.FolderName,
+			ParentFolderID: folder.ParentFolderID,
+			IsPublic:       folder.IsPublic,
+			CreatedAt:      folder.CreatedAt,
+		})
+	}
+
+	var files []models.File
+	if err := s.DB.Preload("DeduplicatedContent").Where("user_id = ?", user.ID).Order("id").Find(&files).Error; err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		export.Files = append(export.Files, exportedFile{
+			ID:            file.ID,
+			Name:          file.FileName,
+			MIMEType:      file.MIMEType,
+			Size:          file.Size,
+			SHA256Hash:    file.DeduplicatedContent.SHA256Hash,
+			FolderID:      file.FolderID,
+			IsPublic:      file.IsPublic,
+			DownloadCount: file.DownloadCount,
+			Tags:          file.Tags,
+			CreatedAt:     file.CreatedAt,
+		})
+	}
+
+	var fileSharesGiven []models.FileSharing
+	if err := s.DB.Joins("JOIN files ON files.id = file_sharings.file_id").Where("files.user_id = ?", user.ID).Find(&fileSharesGiven).Error; err != nil {
+		return "", err
+	}
+	for _, share := range fileSharesGiven {
+		export.SharesGiven = append(export.SharesGiven, exportedShare{"file", share.FileID, share.SharedWithUserID, share.PermissionLevel, share.CreatedAt})
+	}
+
+	var folderSharesGiven []models.FolderSharing
+	if err := s.DB.Joins("JOIN folders ON folders.id = folder_sharings.folder_id").Where("folders.user_id = ?", user.ID).Find(&folderSharesGiven).Error; err != nil {
+		return "", err
+	}
+	for _, share := range folderSharesGiven {
+		export.SharesGiven = append(export.SharesGiven, exportedShare{"folder", share.FolderID, share.SharedWithUserID, share.PermissionLevel, share.CreatedAt})
+	}
+
+	var fileSharesReceived []models.FileSharing
+	if err := s.DB.Where("shared_with_user_id = ?", user.ID).Find(&fileSharesReceived).Error; err != nil {
+		return "", err
+	}
+	for _, share := range fileSharesReceived {
+		export.SharesReceived = append(export.SharesReceived, exportedShare{"file", share.FileID, share.SharedWithUserID, share.PermissionLevel, share.CreatedAt})
+	}
+
+	var folderSharesReceived []models.FolderSharing
+	if err := s.DB.Where("shared_with_user_id = ?", user.ID).Find(&folderSharesReceived).Error; err != nil {
+		return "", err
+	}
+	for _, share := range folderSharesReceived {
+		export.SharesReceived = append(export.SharesReceived, exportedShare{"folder", share.FolderID, share.SharedWithUserID, share.PermissionLevel, share.CreatedAt})
+	}
+
+	payload, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// DeleteAccount permanently deletes a user and everything they own. Their team folders and
+// files pass to the owners of their organizations, as when they leave an organization.
+// Folders and files are removed through FileService.DeleteFolder and FileService.DeleteFile
+// so that deduplicated content reference counts stay correct and orphaned content is
+// removed from storage. Shares of the user's files and folders, and shares with the user,
+// are removed as well.
+//
+// If requested, the user's data is exported before anything is changed. The account is then
+// marked as being deleted, which signs the user out everywhere and keeps them from signing
+// back in. Every step of the deletion can be repeated, so if one fails, Run finishes the
+// deletion later.
+//
+// Inputs:
+// - ctx: The context for the request.
+// - user: The user to delete.
+// - exportData: Whether to export the user's data first.
+//
+// Outputs:
+//   - The export as a JSON document, or an empty string if none was requested.
+//   - An error if the account cannot be deleted or the export fails, in which case nothing
+//     has been deleted.
+func (s *UserService) DeleteAccount(ctx context.Context, user *models.User, exportData bool) (string, error) {
+	if err := checkNoOwnedOrganizations(s.DB, user); err != nil {
+		return "", err
+	}
+
+	var export string
+	if exportData {
+		var err error
+		if export, err = s.ExportData(ctx, user); err != nil {
+			return "", fmt.Errorf("failed to export your data, your account was not deleted: %w", err)
+		}
+	}
+
+	now := time.Now()
+	err := s.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"deleting_at":   now,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	user.DeletingAt = &now
+
+	if err := s.purgeAccount(ctx, user); err != nil {
+		log.Printf("Deleting account %d failed, it will be retried: %v", user.ID, err)
+	}
+	return export, nil
+}
+
+// Run finishes deleting accounts whose deletion failed part way, every DeletionRetryInterval
+// until the context is cancelled. It is meant to be started in its own goroutine.
+func (s *UserService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.DeletionRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		// Deletions that have only just started are most likely still running.
+		var users []*models.User
+		if err := s.DB.Where("deleting_at <= ?", time.Now().Add(-s.DeletionRetryInterval)).Find(&users).Error; err != nil {
+			log.Printf("Loading accounts to delete failed: %v", err)
+		}
+		for _, user := range users {
+			if err := s.purgeAccount(ctx, user); err != nil {
+				log.Printf("Deleting account %d failed, it will be retried: %v", user.ID, err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkNoOwnedOrganizations returns an error if the user owns an organization, which cannot
+// be left without an owner.
+func checkNoOwnedOrganizations(db *gorm.DB, user *models.User) error {
+	var ownedOrganizations int64
+	if err := db.Model(&models.Membership{}).Where("user_id = ? AND role = ?", user.ID, models.OrgRoleOwner).Count(&ownedOrganizations).Error; err != nil {
+		return err
+	}
+	if ownedOrganizations > 0 {
+		return fmt.Errorf("transfer ownership of or delete your organizations before deleting your account")
+	}
+	return nil
+}
+
+// purgeAccount deletes everything belonging to a user marked as being deleted, and then the
+// user. Each step only deletes what is left, so it can be run again after a failure.
+func (s *UserService) purgeAccount(ctx context.Context, user *models.User) error {
+	if err := checkNoOwnedOrganizations(s.DB, user); err != nil {
+		return err
+	}
+
+	// Team folders and files stay with their organizations; everything below only deletes
+	// the user's personal items.
+	var memberships []models.Membership
+	if err := s.DB.Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+		return err
+	}
+	for i := range memberships {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := handOverTeamItems(tx, memberships[i].OrganizationID, user.ID); err != nil {
+				return err
+			}
+			return tx.Delete(&memberships[i]).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Groups the user owns, and the user's memberships of other groups.
+	var ownedGroupIDs []uint
+	if err := s.DB.Model(&models.Group{}).Where("owner_user_id = ?", user.ID).Pluck("id", &ownedGroupIDs).Error; err != nil {
+		return err
+	}
+	if err := deleteGroups(s.DB, ownedGroupIDs); err != nil {
+		return err
+	}
+	if err := s.DB.Where("user_id = ?", user.ID).Delete(&models.GroupMember{}).Error; err != nil {
+		return err
+	}
+
+	// Invites sent by this user.
+	if err := s.DB.Where("invited_by_id = ?", user.ID).Delete(&models.ShareInvite{}).Error; err != nil {
+		return err
+	}
+
+	// Access requests made by or addressed to this user.
+	if err := s.DB.Where("requester_id = ? OR owner_id = ?", user.ID, user.ID).Delete(&models.AccessRequest{}).Error; err != nil {
+		return err
+	}
+
+	// Ownership transfers offered by or to this user.
+	if err := s.DB.Where("from_user_id = ? OR to_user_id = ?", user.ID, user.ID).Delete(&models.OwnershipTransfer{}).Error; err != nil {
+		return err
+	}
+
+	// Comments written by this user, with their replies, and mentions of them.
+	if err := deleteComments(s.DB, s.DB.Model(&models.Comment{}).Select("id").Where("author_id = ?", user.ID)); err != nil {
+		return err
+	}
+	if err := s.DB.Where("user_id = ?", user.ID).Delete(&models.CommentMention{}).Error; err != nil {
+		return err
+	}
+
+	// Notifications and notification preferences.
+	if err := s.DB.Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Where("user_id = ?", user.ID).Delete(&models.NotificationPreference{}).Error; err != nil {
+		return err
+	}
+
+	// Webhooks and their delivery logs.
+	if err := deleteWebhooks(s.DB, s.DB.Model(&models.Webhook{}).Select("id").Where("user_id = ?", user.ID)); err != nil {
+		return err
+	}
+
+	// Shares with this user.
+	if err := s.DB.Where("shared_with_user_id = ?", user.ID).Delete(&models.FileSharing{}).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Where("shared_with_user_id = ?", user.ID).Delete(&models.FolderSharing{}).Error; err != nil {
+		return err
+	}
+
+	// Other users' items placed inside this user's folders are moved to their owners' root
+	// rather than being deleted along with the folder.
+	ownFolderIDs := s.DB.Model(&models.Folder{}).Select("id").Where("user_id = ? AND organization_id IS NULL", user.ID)
+	if err := s.DB.Model(&models.File{}).Where("user_id <> ? AND folder_id IN (?)", user.ID, ownFolderIDs).Update("folder_id", nil).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Model(&models.Folder{}).Where("user_id <> ? AND parent_folder_id IN (?)", user.ID, ownFolderIDs).Update("parent_folder_id", nil).Error; err != nil {
+		return err
+	}
+
+	// Delete the top of each of the user's folder trees; DeleteFolder recurses into the rest.
+	var topFolders []models.Folder
+	if err := s.DB.Where("user_id = ? AND organization_id IS NULL AND (parent_folder_id IS NULL OR parent_folder_id NOT IN (?))", user.ID, ownFolderIDs).Find(&topFolders).Error; err != nil {
+		return err
+	}
+	for _, folder := range topFolders {
+		if _, err := s.FileService.DeleteFolder(ctx, strconv.FormatUint(uint64(folder.ID), 10), user); err != nil {
+			return fmt.Errorf("failed to delete folder %d: %w", folder.ID, err)
+		}
+	}
+
+	// Any files left are outside the user's own folders.
+	var files []models.File
+	if err := s.DB.Where("user_id = ? AND organization_id IS NULL", user.ID).Find(&files).Error; err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, err := s.FileService.DeleteFile(ctx, strconv.FormatUint(uint64(file.ID), 10), user); err != nil {
+			return fmt.Errorf("failed to delete file %d: %w", file.ID, err)
+		}
+	}
+
+	// The activity feed of the user's items, including the deletions just recorded, goes
+	// with the user.
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("owner_id = ?", user.ID).Delete(&models.Activity{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
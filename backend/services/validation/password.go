@@ -0,0 +1,149 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+// defaultBreachedPasswords is a small list of the most common leaked passwords,
+// used when no breached-password list file is configured.
+//
+//go:embed breached_passwords.txt
+var defaultBreachedPasswords string
+
+// PasswordPolicy defines the requirements a new password must meet.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	CheckBreached bool
+
+	breached map[string]struct{}
+}
+
+// PasswordPolicyFromConfig builds a PasswordPolicy from the password_policy
+// section of the configuration. If password_policy.breached_list_path is set,
+// the breached-password list is read from that file (one password per line);
+// otherwise the built-in list is used.
+func PasswordPolicyFromConfig() *PasswordPolicy {
+	viper.SetDefault("password_policy.min_length", 8)
+	viper.SetDefault("password_policy.max_length", 72) // bcrypt ignores anything longer
+	viper.SetDefault("password_policy.require_upper", true)
+	viper.SetDefault("password_policy.require_lower", true)
+	viper.SetDefault("password_policy.require_digit", true)
+	viper.SetDefault("password_policy.require_symbol", false)
+	viper.SetDefault("password_policy.check_breached", true)
+
+	policy := &PasswordPolicy{
+		MinLength:     viper.GetInt("password_policy.min_length"),
+		MaxLength:     viper.GetInt("password_policy.max_length"),
+		RequireUpper:  viper.GetBool("password_policy.require_upper"),
+		RequireLower:  viper.GetBool("password_policy.require_lower"),
+		RequireDigit:  viper.GetBool("password_policy.require_digit"),
+		RequireSymbol: viper.GetBool("password_policy.require_symbol"),
+		CheckBreached: viper.GetBool("password_policy.check_breached"),
+	}
+
+	list := defaultBreachedPasswords
+	if path := viper.GetString("password_policy.breached_list_path"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Warning: could not read breached password list %s: %v. Using the built-in list.", path, err)
+		} else {
+			list = string(data)
+		}
+	}
+	policy.breached = parsePasswordList(list)
+
+	return policy
+}
+
+// parsePasswordList turns a newline-separated list into a lookup set.
+// Blank lines and lines starting with '#' are ignored; entries are compared case-insensitively.
+func parsePasswordList(list string) map[string]struct{} {
+	set := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+}
+
+// Password checks password against the policy. The username and email, when
+// given, are used to reject passwords that merely repeat them.
+func (p *PasswordPolicy) Password(errs *Errors, field, password, username, email string) {
+	if password == "" {
+		errs.Add(field, CodeRequired, "password is required")
+		return
+	}
+	if len(password) < p.MinLength {
+		errs.Add(field, CodeTooShort, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+		return
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		errs.Add(field, CodeTooLong, fmt.Sprintf("password must be at most %d characters", p.MaxLength))
+		return
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	var missing []string
+	if p.RequireUpper && !hasUpper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		errs.Add(field, CodeWeakPassword, "password must contain "+strings.Join(missing, ", "))
+		return
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		errs.Add(field, CodeWeakPassword, "password must not contain your username")
+		return
+	}
+	if localPart, _, ok := strings.Cut(strings.ToLower(email), "@"); ok && len(localPart) >= 3 && strings.Contains(lower, localPart) {
+		errs.Add(field, CodeWeakPassword, "password must not contain your email address")
+		return
+	}
+
+	if p.CheckBreached {
+		if _, found := p.breached[lower]; found {
+			errs.Add(field, CodeBreachedPassword, "this password has appeared in a data breach; please choose another")
+		}
+	}
+}
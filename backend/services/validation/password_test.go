@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := &PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     72,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		CheckBreached: true,
+		breached:      parsePasswordList(defaultBreachedPasswords),
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		code     string // empty when the password is accepted
+	}{
+		{name: "accepted", password: "Correct7Horse", code: ""},
+		{name: "empty", password: "", code: CodeRequired},
+		{name: "too short", password: "Ab1", code: CodeTooShort},
+		{name: "too long", password: "Aa1" + strings.Repeat("x", 70), code: CodeTooLong},
+		{name: "no uppercase", password: "correct7horse", code: CodeWeakPassword},
+		{name: "no lowercase", password: "CORRECT7HORSE", code: CodeWeakPassword},
+		{name: "no digit", password: "CorrectHorse", code: CodeWeakPassword},
+		{name: "contains username", password: "Xjane.doe9Y", username: "Jane.Doe", code: CodeWeakPassword},
+		{name: "contains email", password: "Janedoe2024", email: "janedoe@example.com", code: CodeWeakPassword},
+		{name: "breached", password: "Password1!", code: CodeBreachedPassword},
+		{name: "breached in other case", password: "aA123456", code: CodeBreachedPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs Errors
+			policy.Password(&errs, "input.password", tt.password, tt.username, tt.email)
+			if tt.code == "" {
+				if len(errs) != 0 {
+					t.Fatalf("expected the password to be accepted, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected one error with code %s, got %v", tt.code, errs)
+			}
+			if errs[0].Code != tt.code || errs[0].Field != "input.password" {
+				t.Errorf("got %s on %s, want %s on input.password", errs[0].Code, errs[0].Field, tt.code)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyWithoutBreachCheck(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 8, breached: parsePasswordList(defaultBreachedPasswords)}
+	var errs Errors
+	policy.Password(&errs, "password", "Password1!", "", "")
+	if len(errs) != 0 {
+		t.Errorf("expected breached passwords to be accepted when the check is off, got %v", errs)
+	}
+}
+
+func TestParsePasswordList(t *testing.T) {
+	set := parsePasswordList("# comment\n\n  Secret1  \nhunter2\n")
+	if len(set) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(set))
+	}
+	for _, password := range []string{"secret1", "hunter2"} {
+		if _, ok := set[password]; !ok {
+			t.Errorf("expected %q in the list", password)
+		}
+	}
+}
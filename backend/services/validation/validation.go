@@ -0,0 +1,104 @@
+// Package validation provides input validation helpers that report every
+// problem with an input at once as a list of field errors.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Error codes reported in FieldError.Code.
+const (
+	CodeRequired          = "REQUIRED"
+	CodeInvalidFormat     = "INVALID_FORMAT"
+	CodeInvalidCharacters = "INVALID_CHARACTERS"
+	CodeTooShort          = "TOO_SHORT"
+	CodeTooLong           = "TOO_LONG"
+	CodeAlreadyTaken      = "ALREADY_TAKEN"
+	CodeWeakPassword      = "WEAK_PASSWORD"
+	CodeBreachedPassword  = "BREACHED_PASSWORD"
+	CodeIncorrect         = "INCORRECT"
+)
+
+// FieldError describes a single invalid input field.
+type FieldError struct {
+	Field   string // Path of the offending field, e.g. "input.email".
+	Code    string // Machine-readable error code, e.g. CodeInvalidFormat.
+	Message string // Human-readable explanation.
+}
+
+// Errors is a list of field errors. It implements error so that services can
+// return every problem with an input in a single value.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fmt.Sprintf("%s: %s", fieldErr.Field, fieldErr.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Add appends a field error to the list.
+func (e *Errors) Add(field, code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns the list as an error, or nil if it is empty.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+const (
+	maxEmailLength    = 254
+	minUsernameLength = 3
+	maxUsernameLength = 32
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// NormalizeEmail trims and lower-cases an email address.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Email checks that email is a plain, well-formed address such as "jane@example.com".
+func Email(errs *Errors, field, email string) {
+	if email == "" {
+		errs.Add(field, CodeRequired, "email is required")
+		return
+	}
+	if len(email) > maxEmailLength {
+		errs.Add(field, CodeTooLong, fmt.Sprintf("email must be at most %d characters", maxEmailLength))
+		return
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		errs.Add(field, CodeInvalidFormat, "email is not a valid address")
+		return
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		errs.Add(field, CodeInvalidFormat, "email domain is not valid")
+	}
+}
+
+// Username checks the length and character set of a username.
+// Usernames may contain letters, digits, '_', '.' and '-', and must start with a letter or digit.
+func Username(errs *Errors, field, username string) {
+	switch {
+	case username == "":
+		errs.Add(field, CodeRequired, "username is required")
+	case len(username) < minUsernameLength:
+		errs.Add(field, CodeTooShort, fmt.Sprintf("username must be at least %d characters", minUsernameLength))
+	case len(username) > maxUsernameLength:
+		errs.Add(field, CodeTooLong, fmt.Sprintf("username must be at most %d characters", maxUsernameLength))
+	case !usernamePattern.MatchString(username):
+		errs.Add(field, CodeInvalidCharacters, "username may only contain letters, digits, '_', '.' and '-', and must start with a letter or digit")
+	}
+}
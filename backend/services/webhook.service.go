@@ -0,0 +1,618 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joel2607/FileVault/models"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Webhook event types.
+const (
+	EventFileUploaded   = "file.uploaded"
+	EventFileDeleted    = "file.deleted"
+	EventFileDownloaded = "file.downloaded"
+	EventFolderCreated  = "folder.created"
+	EventFolderDeleted  = "folder.deleted"
+	EventShareCreated   = "share.created"
+	EventShareRevoked   = "share.revoked"
+)
+
+// webhookEventTypes are the event types webhooks can subscribe to.
+var webhookEventTypes = map[string]bool{
+	EventFileUploaded:   true,
+	EventFileDeleted:    true,
+	EventFileDownloaded: true,
+	EventFolderCreated:  true,
+	EventFolderDeleted:  true,
+	EventShareCreated:   true,
+	EventShareRevoked:   true,
+}
+
+// defaultDeliveryLimit is how many deliveries are listed when no limit, or a larger one, is given.
+const defaultDeliveryLimit = 50
+
+// WebhookEvent describes something that happened to a file or folder. OwnerIDs are the
+// users whose webhooks should hear about it, and FolderID is the folder it happened in.
+type WebhookEvent struct {
+	Type     string
+	OwnerIDs []uint
+	FolderID *uint
+	Data     interface{}
+}
+
+// webhookPayload is the JSON body sent to webhook endpoints.
+type webhookPayload struct {
+	ID         uint        `json:"id"`
+	Event      string      `json:"event"`
+	OccurredAt string      `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookFileData is the data of file events.
+type WebhookFileData struct {
+	FileID   uint   `json:"fileId"`
+	FileName string `json:"fileName"`
+	MIMEType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	FolderID *uint  `json:"folderId"`
+	OwnerID  uint   `json:"ownerId"`
+	ActorID  uint   `json:"actorId"`
+}
+
+// WebhookFolderData is the data of folder events.
+type WebhookFolderData struct {
+	FolderID       uint   `json:"folderId"`
+	FolderName     string `json:"folderName"`
+	ParentFolderID *uint  `json:"parentFolderId"`
+	OwnerID        uint   `json:"ownerId"`
+	ActorID        uint   `json:"actorId"`
+}
+
+// WebhookShareData is the data of share events. Exactly one of FileID and FolderID, and
+// one of UserID and GroupID, is set.
+type WebhookShareData struct {
+	FileID   *uint `json:"fileId,omitempty"`
+	FolderID *uint `json:"folderId,omitempty"`
+	UserID   *uint `json:"userId,omitempty"`
+	GroupID  *uint `json:"groupId,omitempty"`
+	ActorID  uint  `json:"actorId"`
+}
+
+// FileEvent builds a webhook event about a file.
+func FileEvent(eventType string, file *models.File, actor *models.User) WebhookEvent {
+	return WebhookEvent{
+		Type:     eventType,
+		OwnerIDs: []uint{file.UserID},
+		FolderID: file.FolderID,
+		Data: WebhookFileData{
+			FileID:   file.ID,
+			FileName: file.FileName,
+			MIMEType: file.MIMEType,
+			Size:     file.Size,
+			FolderID: file.FolderID,
+			OwnerID:  file.UserID,
+			ActorID:  actor.ID,
+		},
+	}
+}
+
+// FolderEvent builds a webhook event about a folder. The event happens in the folder's parent.
+func FolderEvent(eventType string, folder *models.Folder, actor *models.User) WebhookEvent {
+	return WebhookEvent{
+		Type:     eventType,
+		OwnerIDs: []uint{folder.UserID},
+		FolderID: folder.ParentFolderID,
+		Data: WebhookFolderData{
+			FolderID:       folder.ID,
+			FolderName:     folder.FolderName,
+			ParentFolderID: folder.ParentFolderID,
+			OwnerID:        folder.UserID,
+			ActorID:        actor.ID,
+		},
+	}
+}
+
+// ShareEvent builds a webhook event about a file or folder being shared with, or unshared
+// from, a user or a group.
+func ShareEvent(eventType string, file *models.File, folder *models.Folder, userID *uint, groupID *uint, actor *models.User) WebhookEvent {
+	data := WebhookShareData{UserID: userID, GroupID: groupID, ActorID: actor.ID}
+	event := WebhookEvent{Type: eventType, Data: &data}
+	if file != nil {
+		data.FileID = &file.ID
+		event.OwnerIDs = []uint{file.UserID}
+		event.FolderID = file.FolderID
+	} else {
+		data.FolderID = &folder.ID
+		event.OwnerIDs = []uint{folder.UserID}
+		event.FolderID = folder.ParentFolderID
+	}
+	return event
+}
+
+// WebhookService delivers file events to registered HTTP endpoints. Each delivery is signed
+// with the webhook's secret and retried with exponential backoff until it succeeds or runs
+// out of attempts. Deliveries are kept as a log that can be queried and redelivered.
+type WebhookService struct {
+	DB          *gorm.DB
+	Client      *http.Client
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Interval    time.Duration
+	wake        chan struct{}
+}
+
+// NewWebhookService creates a new instance of WebhookService, reading its retry policy
+// from webhooks.max_attempts, webhooks.retry_base_seconds and webhooks.poll_interval_seconds.
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	viper.SetDefault("webhooks.max_attempts", 6)
+	viper.SetDefault("webhooks.retry_base_seconds", 30)
+	viper.SetDefault("webhooks.poll_interval_seconds", 10)
+	return &WebhookService{
+		DB:          db,
+		Client:      newWebhookClient(),
+		MaxAttempts: viper.GetInt("webhooks.max_attempts"),
+		RetryDelay:  time.Duration(viper.GetInt("webhooks.retry_base_seconds")) * time.Second,
+		Interval:    time.Duration(viper.GetInt("webhooks.poll_interval_seconds")) * time.Second,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// parseEventTypes checks a list of event types and returns them as stored on a webhook.
+func parseEventTypes(eventTypes []string) (string, error) {
+	if len(eventTypes) == 0 {
+		return "", fmt.Errorf("at least one event type is required")
+	}
+	seen := make(map[string]bool, len(eventTypes))
+	var types []string
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !webhookEventTypes[eventType] {
+			return "", fmt.Errorf("unknown event type %q", eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			types = append(types, eventType)
+		}
+	}
+	sort.Strings(types)
+	return strings.Join(types, ","), nil
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http or https URL whose host
+// resolves only to public addresses, so that webhooks can't be used to reach the server's
+// own network. The address is checked again when connecting, as DNS answers can change.
+func validateWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("webhook URL must be an absolute http or https URL")
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return fmt.Errorf("webhook URL host could not be resolved")
+	}
+	for _, address := range addresses {
+		if !isPublicAddress(address.IP) {
+			return fmt.Errorf("webhook URL must not point to a private or local address")
+		}
+	}
+	return nil
+}
+
+// errBlockedAddress is returned when a webhook endpoint resolves to an address that isn't public.
+var errBlockedAddress = errors.New("endpoint address is not allowed")
+
+// isPublicAddress reports whether an IP address can be reached on the internet, as opposed to
+// loopback, private, link-local, unspecified and multicast addresses.
+func isPublicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// newWebhookClient returns the HTTP client webhooks are delivered with. It refuses to connect
+// to addresses that aren't public, whatever the endpoint's host resolves to at the time, and
+// doesn't follow redirects, which could lead anywhere.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicAddress(ip) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// newWebhookSecret returns a random signing secret.
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CreateWebhook registers a webhook for the user. If folderID is given, the user must own the
+// folder and only events in it are sent. Only admins can register global webhooks.
+func (s *WebhookService) CreateWebhook(ctx context.Context, rawURL string, eventTypes []string, folderID *string, global bool, user *models.User) (*models.Webhook, error) {
+	if global && user.Role != models.RoleAdmin {
+		return nil, fmt.Errorf("only admins can register global webhooks")
+	}
+	rawURL = strings.TrimSpace(rawURL)
+	if err := validateWebhookURL(ctx, rawURL); err != nil {
+		return nil, err
+	}
+	types, err := parseEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{UserID: user.ID, URL: rawURL, Secret: secret, EventTypes: types, Global: global, Active: true}
+	if folderID != nil {
+		id, err := strconv.ParseUint(*folderID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folder ID")
+		}
+		// Global webhooks can watch any folder; others only the user's own.
+		query := s.DB.Where("id = ?", id)
+		if !global {
+			query = query.Where("user_id = ?", user.ID)
+		}
+		var folder models.Folder
+		if err := query.First(&folder).Error; err != nil {
+			return nil, fmt.Errorf("folder not found or access denied")
+		}
+		webhook.FolderID = &folder.ID
+	}
+
+	if err := s.DB.Create(webhook).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// findWebhook loads a webhook registered by the user.
+func (s *WebhookService) findWebhook(webhookID string, user *models.User) (*models.Webhook, error) {
+	id, err := strconv.ParseUint(webhookID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook ID")
+	}
+	var webhook models.Webhook
+	if err := s.DB.First(&webhook, "id = ? AND user_id = ?", id, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("webhook not found or access denied")
+	}
+	return &webhook, nil
+}
+
+// UpdateWebhook changes the URL, event types or active state of one of the user's webhooks.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, webhookID string, rawURL *string, eventTypes []string, active *bool, user *models.User) (*models.Webhook, error) {
+	webhook, err := s.findWebhook(webhookID, user)
+	if err != nil {
+		return nil, err
+	}
+	if rawURL != nil {
+		webhook.URL = strings.TrimSpace(*rawURL)
+		if err := validateWebhookURL(ctx, webhook.URL); err != nil {
+			return nil, err
+		}
+	}
+	if eventTypes != nil {
+		if webhook.EventTypes, err = parseEventTypes(eventTypes); err != nil {
+			return nil, err
+		}
+	}
+	if active != nil {
+		webhook.Active = *active
+	}
+	if err := s.DB.Save(webhook).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook deletes one of the user's webhooks along with its delivery log.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, webhookID string, user *models.User) (bool, error) {
+	webhook, err := s.findWebhook(webhookID, user)
+	if err != nil {
+		return false, err
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteWebhooks(tx, tx.Model(&models.Webhook{}).Select("id").Where("id = ?", webhook.ID))
+	})
+	return err == nil, err
+}
+
+// deleteWebhooks deletes the webhooks selected by the ids subquery and their deliveries.
+func deleteWebhooks(tx *gorm.DB, ids *gorm.DB) error {
+	if err := tx.Where("webhook_id IN (?)", ids).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", ids).Delete(&models.Webhook{}).Error
+}
+
+// ListWebhooks returns the user's webhooks, oldest first.
+func (s *WebhookService) ListWebhooks(ctx context.Context, user *models.User) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	err := s.DB.Where("user_id = ?", user.ID).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListDeliveries returns the deliveries of one of the user's webhooks, newest first.
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID string, limit *int32, user *models.User) ([]*models.WebhookDelivery, error) {
+	webhook, err := s.findWebhook(webhookID, user)
+	if err != nil {
+		return nil, err
+	}
+	pageSize := defaultDeliveryLimit
+	if limit != nil && *limit > 0 && *limit < defaultDeliveryLimit {
+		pageSize = int(*limit)
+	}
+	var deliveries []*models.WebhookDelivery
+	err = s.DB.Where("webhook_id = ?", webhook.ID).Order("id DESC").Limit(pageSize).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Redeliver queues a new delivery of the same payload as an earlier delivery. The payload
+// keeps the original delivery's ID, so receivers can tell it is the same event.
+func (s *WebhookService) Redeliver(ctx context.Context, deliveryID string, user *models.User) (*models.WebhookDelivery, error) {
+	id, err := strconv.ParseUint(deliveryID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery ID")
+	}
+	var original models.WebhookDelivery
+	if err := s.DB.Joins("Webhook").First(&original, "webhook_deliveries.id = ? AND \"Webhook\".user_id = ?", id, user.ID).Error; err != nil {
+		return nil, fmt.Errorf("delivery not found or access denied")
+	}
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		WebhookID:     original.WebhookID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        string(models.WebhookDeliveryStatusPending),
+		NextAttemptAt: &now,
+	}
+	if err := s.DB.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	s.poke()
+	return delivery, nil
+}
+
+// Emit queues deliveries of an event to every active webhook subscribed to it: global
+// webhooks, and webhooks of the event's owners and of the owner of the folder it happened in,
+// limited to those without a folder or registered for that folder. Failures are logged
+// rather than returned, so that emitting never fails the operation that triggered it.
+func (s *WebhookService) Emit(event WebhookEvent) {
+	ownerIDs := append([]uint{}, event.OwnerIDs...)
+	if event.FolderID != nil {
+		var folder models.Folder
+		if err := s.DB.Select("id", "user_id").First(&folder, *event.FolderID).Error; err == nil {
+			ownerIDs = append(ownerIDs, folder.UserID)
+		}
+	}
+	db := s.DB.Where("active = ? AND (',' || event_types || ',') LIKE ?", true, "%,"+event.Type+",%").
+		Where("global = ? OR user_id IN ?", true, ownerIDs)
+	if event.FolderID != nil {
+		db = db.Where("folder_id IS NULL OR folder_id = ?", *event.FolderID)
+	} else {
+		db = db.Where("folder_id IS NULL")
+	}
+	var webhooks []models.Webhook
+	if err := db.Find(&webhooks).Error; err != nil {
+		log.Printf("Failed to find webhooks for %s event: %v", event.Type, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		// The delivery is only scheduled once its payload, which includes its ID, is stored.
+		delivery := &models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventType: event.Type,
+			Status:    string(models.WebhookDeliveryStatusPending),
+		}
+		if err := s.DB.Create(delivery).Error; err != nil {
+			log.Printf("Failed to queue %s delivery for webhook %d: %v", event.Type, webhook.ID, err)
+			continue
+		}
+		payload, err := json.Marshal(webhookPayload{ID: delivery.ID, Event: event.Type, OccurredAt: now.UTC().Format(time.RFC3339), Data: event.Data})
+		if err != nil {
+			continue
+		}
+		if err := s.DB.Model(delivery).Updates(map[string]interface{}{"payload": string(payload), "next_attempt_at": now}).Error; err != nil {
+			log.Printf("Failed to queue %s delivery for webhook %d: %v", event.Type, webhook.ID, err)
+		}
+	}
+	s.poke()
+}
+
+// poke wakes the delivery worker without waiting for its next tick.
+func (s *WebhookService) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run attempts due deliveries every Interval, or as soon as new ones are queued, until
+// the context is cancelled. It is meant to be started in its own goroutine.
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverDue attempts every pending delivery whose next attempt is due. Deliveries of webhooks
+// that have been deactivated wait until they are active again.
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	var deliveries []models.WebhookDelivery
+	if err := s.DB.Preload("Webhook").
+		Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id AND webhooks.active = ?", true).
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", models.WebhookDeliveryStatusPending, time.Now()).
+		Order("webhook_deliveries.id").Limit(100).Find(&deliveries).Error; err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return
+	}
+	for i := range deliveries {
+		s.attempt(ctx, &deliveries[i])
+	}
+}
+
+// signPayload returns the signature of a payload sent at the given Unix timestamp:
+// the hex HMAC-SHA256, keyed with the webhook's secret, of "<timestamp>.<payload>".
+func signPayload(secret string, timestamp string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// attempt sends a delivery once and records the outcome. Failed deliveries are retried after
+// RetryDelay, doubling each time, until MaxAttempts is reached.
+func (s *WebhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := s.send(ctx, delivery)
+	delivery.LastStatusCode = statusCode
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = string(models.WebhookDeliveryStatusSucceeded)
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= s.MaxAttempts:
+		delivery.Status = string(models.WebhookDeliveryStatusFailed)
+		delivery.LastError = deliveryError(err)
+		delivery.NextAttemptAt = nil
+	default:
+		delivery.LastError = deliveryError(err)
+		next := now.Add(s.RetryDelay << (delivery.Attempts - 1))
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := s.DB.Model(delivery).Select("status", "attempts", "last_status_code", "last_error", "delivered_at", "next_attempt_at").
+		Updates(delivery).Error; err != nil {
+		log.Printf("Failed to record attempt of webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts a delivery's payload to its webhook and returns the response status code.
+// Any non-2xx response is an error.
+func (s *WebhookService) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "FileVault-Webhooks")
+	req.Header.Set("X-FileVault-Event", delivery.EventType)
+	req.Header.Set("X-FileVault-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-FileVault-Timestamp", timestamp)
+	req.Header.Set("X-FileVault-Signature", "sha256="+signPayload(delivery.Webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, &endpointStatusError{resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// endpointStatusError is returned by send when the endpoint responds with a non-2xx status.
+type endpointStatusError struct {
+	StatusCode int
+}
+
+func (e *endpointStatusError) Error() string {
+	return fmt.Sprintf("endpoint responded with HTTP %d", e.StatusCode)
+}
+
+// deliveryError describes why a delivery failed in general terms for its log, which its owner
+// can read. Transport errors can include details of the network the server runs in, so they
+// are only written to the server log.
+func deliveryError(err error) string {
+	var statusErr *endpointStatusError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Error()
+	case errors.Is(err, errBlockedAddress):
+		return errBlockedAddress.Error()
+	case errors.As(err, &dnsErr):
+		return "endpoint host could not be resolved"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	}
+	log.Printf("Webhook delivery failed: %v", err)
+	return "could not connect to endpoint"
+}
+
+// WebhookEventTypes returns the event types webhooks can subscribe to, sorted.
+func WebhookEventTypes() []string {
+	types := make([]string, 0, len(webhookEventTypes))
+	for eventType := range webhookEventTypes {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://203.0.113.10/hooks/filevault", true},
+		{"http://[2001:db8::10]:8080/hook", true},
+		{"ftp://203.0.113.10/hook", false},
+		{"/relative/hook", false},
+		{"https:///hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://localhost/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://172.16.0.1/hook", false},
+		{"http://192.168.1.20/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://224.0.0.1/hook", false},
+	}
+	for _, tt := range tests {
+		err := validateWebhookURL(context.Background(), tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("validateWebhookURL(%q) = %v, want it allowed", tt.url, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("validateWebhookURL(%q) allowed it, want an error", tt.url)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	// The URL could have resolved to a public address when the webhook was created; the
+	// address is checked again when the client connects.
+	_, err := newWebhookClient().Post(server.URL, "application/json", nil)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Fatalf("got %v, want %v", err, errBlockedAddress)
+	}
+	if reached {
+		t.Error("the request reached the loopback server")
+	}
+	if got := deliveryError(err); got != errBlockedAddress.Error() {
+		t.Errorf("deliveryError = %q, want %q", got, errBlockedAddress.Error())
+	}
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://203.0.113.10/moved", nil)
+	if err := newWebhookClient().CheckRedirect(req, nil); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("CheckRedirect = %v, want %v", err, http.ErrUseLastResponse)
+	}
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestDeliveryError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&endpointStatusError{StatusCode: 502}, "endpoint responded with HTTP 502"},
+		{fmt.Errorf("dial: %w", errBlockedAddress), "endpoint address is not allowed"},
+		{&net.DNSError{Err: "no such host", Name: "hooks.internal.example", IsNotFound: true}, "endpoint host could not be resolved"},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, "request timed out"},
+		{&net.OpError{Op: "dial", Net: "tcp", Addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.10"), Port: 443}, Err: errors.New("connection refused")},
+			"could not connect to endpoint"},
+	}
+	for _, tt := range tests {
+		if got := deliveryError(tt.err); got != tt.want {
+			t.Errorf("deliveryError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}